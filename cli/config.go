@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"one-api/common/logger"
+	"one-api/model"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 配置文档加密口令通过环境变量传入，避免出现在命令行历史中
+const configPassphraseEnv = "CONFIG_PASSPHRASE"
+
+func exportConfigDocument(file string) error {
+	doc, err := model.ExportConfig(model.ConfigExportOptions{
+		IncludeKeys: *includeKeys,
+		Passphrase:  os.Getenv(configPassphraseEnv),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export config: %w", err)
+	}
+
+	data, err := model.MarshalConfigDocument(doc, configFormatByFile(file))
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	logger.SysLog("Config exported to " + file)
+	return nil
+}
+
+func applyConfigDocument(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	doc, err := model.UnmarshalConfigDocument(data, configFormatByFile(file))
+	if err != nil {
+		return err
+	}
+
+	result, err := model.ApplyConfig(doc, model.ConfigApplyOptions{
+		DryRun:     *dryRun,
+		Prune:      *prune,
+		Passphrase: os.Getenv(configPassphraseEnv),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply config: %w", err)
+	}
+
+	for _, change := range result.Changes {
+		line := fmt.Sprintf("%-7s %s/%s", change.Action, change.Section, change.Key)
+		if len(change.Fields) > 0 {
+			line += " (" + strings.Join(change.Fields, ", ") + ")"
+		}
+		fmt.Println(line)
+	}
+
+	if result.DryRun {
+		logger.SysLog(fmt.Sprintf("Dry run: %d changes", len(result.Changes)))
+		return nil
+	}
+
+	logger.SysLog(fmt.Sprintf("Config applied from %s: %d changes", file, len(result.Changes)))
+	return nil
+}
+
+func configFormatByFile(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return model.ConfigFormatJSON
+	default:
+		return model.ConfigFormatYAML
+	}
+}
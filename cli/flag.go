@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/secret"
 	"one-api/common/utils"
 	"os"
 
@@ -17,6 +19,13 @@ var (
 	logDir       = flag.String("log-dir", "", "specify the log directory")
 	Config       = flag.String("config", "config.yaml", "specify the config.yaml path")
 	export       = flag.Bool("export", false, "Exports prices to a JSON file.")
+	exportConfig = flag.String("export-config", "", "export the gateway config to a YAML/JSON file and exit")
+	applyConfig  = flag.String("apply-config", "", "apply a YAML/JSON config file and exit")
+	includeKeys  = flag.Bool("include-keys", false, "include channel keys and secret options when exporting config")
+	dryRun       = flag.Bool("dry-run", false, "print the changes of --apply-config without applying them")
+	prune        = flag.Bool("prune", false, "delete items missing from the config file when applying config")
+	generateKey  = flag.String("generate-encryption-key", "", "print a new encryption master key with the given id and exit")
+	rotateKey    = flag.Bool("rotate-encryption-key", false, "re-encrypt all secrets with the active encryption key and exit")
 )
 
 func InitCli() {
@@ -45,6 +54,16 @@ func InitCli() {
 		os.Exit(0)
 	}
 
+	if *generateKey != "" {
+		key, err := secret.GenerateKey(*generateKey)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(key)
+		os.Exit(0)
+	}
+
 	if !utils.IsFileExist(*Config) {
 		return
 	}
@@ -56,10 +75,36 @@ func InitCli() {
 
 }
 
+// RunCommand 处理需要数据库的命令，需要在数据库初始化之后调用，执行完成后退出
+func RunCommand() {
+	var err error
+	switch {
+	case *exportConfig != "":
+		err = exportConfigDocument(*exportConfig)
+	case *applyConfig != "":
+		err = applyConfigDocument(*applyConfig)
+	case *rotateKey:
+		err = rotateEncryptionKey()
+	default:
+		return
+	}
+
+	if err != nil {
+		logger.SysError(err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
 func help() {
 	fmt.Println("One Hub " + config.Version + " - All in one Hub service for OpenAI API.")
 	fmt.Println("Copyright (C) 2024 MartialBE. All rights reserved.")
 	fmt.Println("Original copyright holder: JustSong")
 	fmt.Println("GitHub: https://github.com/MartialBE/one-hub")
 	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--config <config.yaml path>] [--version] [--help]")
+	fmt.Println("       one-api --export-config <file> [--include-keys]")
+	fmt.Println("       one-api --apply-config <file> [--dry-run] [--prune]")
+	fmt.Println("       one-api --generate-encryption-key <key id>")
+	fmt.Println("       one-api --rotate-encryption-key")
+	fmt.Println("Set " + configPassphraseEnv + " to encrypt or decrypt secrets in the config file.")
 }
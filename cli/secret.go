@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"fmt"
+	"one-api/common/logger"
+	"one-api/model"
+)
+
+// rotateEncryptionKey 使用当前主密钥重新加密所有敏感数据
+// 轮换步骤：将新密钥加入密钥文件并设为启用，执行本命令后再移除旧密钥
+func rotateEncryptionKey() error {
+	count, err := model.RotateEncryptedSecrets()
+	if err != nil {
+		return fmt.Errorf("failed to rotate encryption key: %w", err)
+	}
+
+	logger.SysLog(fmt.Sprintf("Re-encrypted %d secrets", count))
+	return nil
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"one-api/common/secret"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKey = "audit"
+
+// Context 控制器在请求处理过程中记录的审计信息，由审计中间件在请求结束后写入审计日志
+type Context struct {
+	Action     string
+	TargetType string
+	TargetId   string
+	Before     map[string]any
+	After      map[string]any
+}
+
+// FieldChange 字段修改前后的值
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+func get(c *gin.Context) *Context {
+	if value, ok := c.Get(contextKey); ok {
+		return value.(*Context)
+	}
+	ctx := &Context{}
+	c.Set(contextKey, ctx)
+	return ctx
+}
+
+// From 获取请求中记录的审计信息，没有记录时返回 nil
+func From(c *gin.Context) *Context {
+	if value, ok := c.Get(contextKey); ok {
+		return value.(*Context)
+	}
+	return nil
+}
+
+// SetAction 设置操作名称，GET 请求只有设置了操作名称才会记录，如查看密钥
+func SetAction(c *gin.Context, action string) {
+	get(c).Action = action
+}
+
+func SetTarget(c *gin.Context, targetType string, targetId any) {
+	ctx := get(c)
+	ctx.TargetType = targetType
+	ctx.TargetId = fmt.Sprint(targetId)
+}
+
+// SetBefore 记录修改前的数据，需要在修改之前调用
+func SetBefore(c *gin.Context, value any) {
+	get(c).Before = Snapshot(value)
+}
+
+// SetAfter 记录修改后的数据
+func SetAfter(c *gin.Context, value any) {
+	get(c).After = Snapshot(value)
+}
+
+// sensitiveFields 快照中需要隐藏的字段
+var sensitiveFields = map[string]func(string) string{
+	"key":          secret.MaskLines,
+	"access_token": secret.Mask,
+	"password":     func(string) string { return "******" },
+	"config":       secret.MaskJSONFields,
+}
+
+// Snapshot 将对象转换为字段表并隐藏敏感字段，在调用时复制数据，之后对象的修改不影响快照
+func Snapshot(value any) map[string]any {
+	if value == nil {
+		return nil
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	// 只支持对象，其他类型无法按字段隐藏敏感数据
+	fields := make(map[string]any)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	for name, mask := range sensitiveFields {
+		if str, ok := fields[name].(string); ok && str != "" {
+			fields[name] = mask(str)
+		}
+	}
+	return fields
+}
+
+// Diff 比较修改前后的快照，只返回变化的字段
+func Diff(beforeFields, afterFields map[string]any) map[string]FieldChange {
+	names := make(map[string]bool)
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	changes := make(map[string]FieldChange)
+	for name := range names {
+		beforeValue, afterValue := beforeFields[name], afterFields[name]
+		if reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		changes[name] = FieldChange{Before: beforeValue, After: afterValue}
+	}
+	return changes
+}
@@ -0,0 +1,109 @@
+package capture
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+type assembledChoice struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	finishReason string
+	toolCalls    map[int]map[string]any
+}
+
+// AssembleStream 将 OpenAI 格式的流式数据拼接为完整内容，无法识别的数据保留在原始分片中
+func AssembleStream(chunks []string) map[string]any {
+	choices := make(map[int]*assembledChoice)
+	var usage any
+
+	for _, chunk := range chunks {
+		if !gjson.Valid(chunk) {
+			continue
+		}
+		data := gjson.Parse(chunk)
+		if value := data.Get("usage"); value.IsObject() {
+			usage = value.Value()
+		}
+
+		for _, choiceData := range data.Get("choices").Array() {
+			index := int(choiceData.Get("index").Int())
+			choice, ok := choices[index]
+			if !ok {
+				choice = &assembledChoice{toolCalls: make(map[int]map[string]any)}
+				choices[index] = choice
+			}
+
+			delta := choiceData.Get("delta")
+			if !delta.Exists() {
+				// completions 接口
+				choice.content.WriteString(choiceData.Get("text").String())
+			}
+			choice.content.WriteString(delta.Get("content").String())
+			choice.reasoning.WriteString(delta.Get("reasoning_content").String())
+			if reason := choiceData.Get("finish_reason").String(); reason != "" {
+				choice.finishReason = reason
+			}
+
+			for _, toolCall := range delta.Get("tool_calls").Array() {
+				toolIndex := int(toolCall.Get("index").Int())
+				call, ok := choice.toolCalls[toolIndex]
+				if !ok {
+					call = map[string]any{"id": "", "name": "", "arguments": ""}
+					choice.toolCalls[toolIndex] = call
+				}
+				if id := toolCall.Get("id").String(); id != "" {
+					call["id"] = id
+				}
+				if name := toolCall.Get("function.name").String(); name != "" {
+					call["name"] = name
+				}
+				call["arguments"] = call["arguments"].(string) + toolCall.Get("function.arguments").String()
+			}
+		}
+	}
+
+	if len(choices) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, 0, len(choices))
+	for index := range choices {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	result := make([]map[string]any, 0, len(choices))
+	for _, index := range indexes {
+		choice := choices[index]
+		item := map[string]any{
+			"index":         index,
+			"content":       choice.content.String(),
+			"finish_reason": choice.finishReason,
+		}
+		if choice.reasoning.Len() > 0 {
+			item["reasoning_content"] = choice.reasoning.String()
+		}
+		if len(choice.toolCalls) > 0 {
+			toolIndexes := make([]int, 0, len(choice.toolCalls))
+			for toolIndex := range choice.toolCalls {
+				toolIndexes = append(toolIndexes, toolIndex)
+			}
+			sort.Ints(toolIndexes)
+			toolCalls := make([]map[string]any, 0, len(toolIndexes))
+			for _, toolIndex := range toolIndexes {
+				toolCalls = append(toolCalls, choice.toolCalls[toolIndex])
+			}
+			item["tool_calls"] = toolCalls
+		}
+		result = append(result, item)
+	}
+
+	assembled := map[string]any{"choices": result}
+	if usage != nil {
+		assembled["usage"] = usage
+	}
+	return assembled
+}
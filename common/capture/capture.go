@@ -0,0 +1,220 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/utils"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+type contextKey struct{}
+
+// Payload 一次请求采集到的内容，记录最后一次发往上游的请求
+type Payload struct {
+	Id            string         `json:"id"`
+	CreatedAt     int64          `json:"created_at"`
+	UserId        int            `json:"user_id"`
+	TokenId       int            `json:"token_id"`
+	ChannelId     int            `json:"channel_id"`
+	Model         string         `json:"model"`
+	Path          string         `json:"path"`
+	Request       *HTTPPayload   `json:"request,omitempty"`
+	Response      *HTTPPayload   `json:"response,omitempty"`
+	StreamChunks  []string       `json:"stream_chunks,omitempty"`
+	StreamContent map[string]any `json:"stream_content,omitempty"`
+}
+
+type HTTPPayload struct {
+	Method     string            `json:"method,omitempty"`
+	URL        string            `json:"url,omitempty"`
+	StatusCode int               `json:"status_code,omitempty"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	Truncated  bool              `json:"truncated,omitempty"`
+}
+
+// Recorder 在请求的 context 中传递，由 HTTPRequester 写入上游的请求与响应
+type Recorder struct {
+	sync.Mutex
+	payload     Payload
+	maxBodySize int
+	streamSize  int
+
+	responseBody      bytes.Buffer
+	responseTruncated bool
+}
+
+// Start 判断本次请求是否需要采集，需要时将 Recorder 写入请求的 context
+func Start(c *gin.Context, modelName string) *Recorder {
+	userId := c.GetInt("id")
+	tokenId := c.GetInt("token_id")
+	if !shouldCapture(userId, tokenId, modelName) {
+		return nil
+	}
+
+	recorder := &Recorder{
+		payload: Payload{
+			Id:        utils.GetUUID(),
+			CreatedAt: time.Now().Unix(),
+			UserId:    userId,
+			TokenId:   tokenId,
+			Model:     modelName,
+			Path:      c.Request.URL.Path,
+		},
+		maxBodySize: viper.GetInt("payload_capture.max_body_size"),
+	}
+
+	c.Set("payload_id", recorder.payload.Id)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, recorder))
+
+	return recorder
+}
+
+func FromContext(ctx context.Context) *Recorder {
+	if ctx == nil {
+		return nil
+	}
+	recorder, _ := ctx.Value(contextKey{}).(*Recorder)
+	return recorder
+}
+
+func shouldCapture(userId, tokenId int, modelName string) bool {
+	if !config.PayloadCaptureEnabled {
+		return false
+	}
+
+	matchAll := len(config.PayloadCaptureUserIds) == 0 && len(config.PayloadCaptureTokenIds) == 0 && len(config.PayloadCaptureModels) == 0
+	if !matchAll &&
+		!utils.Contains(userId, config.PayloadCaptureUserIds) &&
+		!utils.Contains(tokenId, config.PayloadCaptureTokenIds) &&
+		!utils.Contains(modelName, config.PayloadCaptureModels) {
+		return false
+	}
+
+	return rand.Float64() < config.PayloadCaptureSampleRate
+}
+
+// RecordRequest 记录发往上游的请求，重试时覆盖之前的记录
+func (r *Recorder) RecordRequest(req *http.Request) {
+	payload := &HTTPPayload{
+		Method:  req.Method,
+		URL:     redactURL(req.URL),
+		Headers: redactHeaders(req.Header),
+	}
+
+	switch {
+	case req.GetBody != nil:
+		if body, err := req.GetBody(); err == nil {
+			payload.Body, payload.Truncated = r.readBody(body)
+			body.Close()
+		}
+	case req.Body != nil:
+		payload.Body = "[body not replayable, not captured]"
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.payload.Request = payload
+	r.payload.Response = nil
+	r.payload.StreamChunks = nil
+	r.streamSize = 0
+	r.responseBody.Reset()
+	r.responseTruncated = false
+}
+
+// RecordResponse 记录上游响应头，响应体在读取时同步写入
+func (r *Recorder) RecordResponse(resp *http.Response) {
+	payload := &HTTPPayload{
+		StatusCode: resp.StatusCode,
+		Headers:    redactHeaders(resp.Header),
+	}
+
+	r.Lock()
+	r.payload.Response = payload
+	r.Unlock()
+
+	resp.Body = &bodyRecorder{ReadCloser: resp.Body, recorder: r}
+}
+
+// AppendStream 记录返回给客户端的流数据
+func (r *Recorder) AppendStream(data string) {
+	r.Lock()
+	defer r.Unlock()
+	if r.streamSize+len(data) > r.maxBodySize {
+		return
+	}
+	r.streamSize += len(data)
+	r.payload.StreamChunks = append(r.payload.StreamChunks, data)
+}
+
+func (r *Recorder) SetChannel(channelId int) {
+	r.Lock()
+	defer r.Unlock()
+	r.payload.ChannelId = channelId
+}
+
+func (r *Recorder) Id() string {
+	return r.payload.Id
+}
+
+// Payload 获取采集结果，流式响应会拼接为完整内容
+func (r *Recorder) Payload() Payload {
+	r.Lock()
+	defer r.Unlock()
+
+	payload := r.payload
+	if payload.Response != nil {
+		response := *payload.Response
+		response.Body = r.responseBody.String()
+		response.Truncated = r.responseTruncated
+		payload.Response = &response
+	}
+	if len(payload.StreamChunks) > 0 {
+		payload.StreamContent = AssembleStream(payload.StreamChunks)
+	}
+	return payload
+}
+
+func (r *Recorder) readBody(body io.Reader) (string, bool) {
+	data, err := io.ReadAll(io.LimitReader(body, int64(r.maxBodySize)+1))
+	if err != nil {
+		return "", false
+	}
+	if len(data) > r.maxBodySize {
+		return string(data[:r.maxBodySize]), true
+	}
+	return string(data), false
+}
+
+type bodyRecorder struct {
+	io.ReadCloser
+	recorder *Recorder
+}
+
+func (b *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.recorder.writeResponseBody(p[:n])
+	}
+	return n, err
+}
+
+func (r *Recorder) writeResponseBody(data []byte) {
+	r.Lock()
+	defer r.Unlock()
+
+	remain := r.maxBodySize - r.responseBody.Len()
+	if len(data) > remain {
+		r.responseTruncated = true
+		data = data[:max(remain, 0)]
+	}
+	r.responseBody.Write(data)
+}
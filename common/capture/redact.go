@@ -0,0 +1,62 @@
+package capture
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redacted = "***"
+
+var sensitiveNames = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"key":                 true,
+	"token":               true,
+	"sig":                 true,
+}
+
+// 名称包含以下关键字的请求头和查询参数均视为密钥
+var sensitiveKeywords = []string{"api-key", "api_key", "apikey", "access-token", "access_token", "secret", "signature", "password", "security-token", "session-token", "auth-token", "credential"}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+	if sensitiveNames[name] {
+		return true
+	}
+	for _, keyword := range sensitiveKeywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if isSensitive(name) {
+			headers[name] = redacted
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+func redactURL(u *url.URL) string {
+	redactedURL := *u
+	redactedURL.User = nil
+
+	query := redactedURL.Query()
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, redacted)
+		}
+	}
+	redactedURL.RawQuery = query.Encode()
+
+	return redactedURL.String()
+}
@@ -31,6 +31,7 @@ func setEnv() {
 
 func defaultConfig() {
 	viper.SetDefault("port", "3000")
+	viper.SetDefault("shutdown_timeout", 30)
 	viper.SetDefault("gin_mode", "release")
 	viper.SetDefault("log_dir", "./logs")
 	viper.SetDefault("sqlite_path", "one-api.db")
@@ -51,4 +52,24 @@ func defaultConfig() {
 	viper.SetDefault("uptime_kuma.enable", false)
 	viper.SetDefault("uptime_kuma.domain", "")
 	viper.SetDefault("uptime_kuma.status_page_name", "")
+	viper.SetDefault("metrics.max_model_labels", 200)
+	viper.SetDefault("metrics.channel_label", true)
+	viper.SetDefault("metrics.group_label", true)
+	viper.SetDefault("log_sink.sql_enabled", true)
+	viper.SetDefault("log_sink.buffer_size", 10000)
+	viper.SetDefault("log_sink.batch_size", 500)
+	viper.SetDefault("log_sink.flush_interval", 2)
+	viper.SetDefault("log_sink.max_retries", 3)
+	viper.SetDefault("log_archive.after_days", 90)
+	viper.SetDefault("log_archive.dir", "./data/log_archives")
+	viper.SetDefault("log_archive.batch_size", 5000)
+	viper.SetDefault("log_archive.retention_days", 730)
+	viper.SetDefault("scheduler.lock_ttl", 30)
+	viper.SetDefault("scheduler.run_retention_days", 30)
+	viper.SetDefault("channel.probe.mode", "all")
+	viper.SetDefault("channel.probe.concurrency", 5)
+	viper.SetDefault("channel.probe.retention_days", 30)
+	viper.SetDefault("payload_capture.dir", "./data/payloads")
+	viper.SetDefault("payload_capture.retention_days", 7)
+	viper.SetDefault("payload_capture.max_body_size", 1048576)
 }
@@ -29,6 +29,11 @@ var DisplayInCurrencyEnabled = true
 // 是否开启用户月账单功能
 var UserInvoiceMonth = false
 
+// 账单导出：编号前缀、结算币种、开票方信息（多行）
+var InvoicePrefix = "INV"
+var InvoiceCurrency = "USD"
+var InvoiceIssuer = ""
+
 // Any options with "Secret", "Token" in its key won't be return by GetOptions
 
 var SessionSecret = uuid.New().String()
@@ -45,6 +50,7 @@ var LarkAuthEnabled = false
 var TurnstileCheckEnabled = false
 var RegisterEnabled = true
 var OIDCAuthEnabled = false
+var LDAPAuthEnabled = false
 
 // 是否开启内容审查
 var EnableSafe = false
@@ -128,6 +134,16 @@ var SafeKeyWords = []string{
 // mj
 var MjNotifyEnabled = false
 
+// 异步任务回调签名主密钥，为空时首次投递自动生成
+var WebhookSecret = ""
+
+// 请求内容采集：按用户、令牌、模型匹配（均为空时匹配全部）后按比例采样
+var PayloadCaptureEnabled = false
+var PayloadCaptureSampleRate = 1.0
+var PayloadCaptureUserIds = []int{}
+var PayloadCaptureTokenIds = []int{}
+var PayloadCaptureModels = []string{}
+
 var EmailDomainRestrictionEnabled = false
 var EmailDomainWhitelist = []string{
 	"gmail.com",
@@ -174,6 +190,27 @@ var OIDCIssuer = ""
 var OIDCScopes = ""
 var OIDCUsernameClaims = ""
 
+var LDAPServerURL = ""   // ldap://host:389 或 ldaps://host:636
+var LDAPStartTLS = false // ldap:// 连接时是否使用 StartTLS
+var LDAPInsecureSkipVerify = false
+var LDAPBindDN = "" // 用于搜索用户的服务账号，为空时匿名搜索
+var LDAPBindPassword = ""
+var LDAPBaseDN = ""
+var LDAPUserFilter = "(uid=%s)" // %s 替换为登录用户名，AD 可使用 (&(objectClass=user)(sAMAccountName=%s))
+var LDAPUsernameAttribute = "uid"
+var LDAPEmailAttribute = "mail"
+var LDAPDisplayNameAttribute = "displayName"
+var LDAPGroupAttribute = "memberOf" // 用户条目上记录所属组的属性
+var LDAPGroupBaseDN = ""            // 不为空时通过搜索组条目获取用户所属组，适用于没有 memberOf 的目录
+var LDAPGroupFilter = "(member=%s)" // %s 替换为用户 DN
+var LDAPGroupMapping = ""           // LDAP 组到用户分组和角色的映射，JSON 数组
+var LDAPAutoProvision = true        // 首次登录时自动创建用户
+
+var TwoFactorRequiredForAdmin = false // 管理员需要通过两步验证登录才能访问管理接口
+
+var SCIMEnabled = false
+var SCIMToken = "" // 身份提供商调用 SCIM 接口使用的 Bearer Token
+
 var QuotaForNewUser = 0
 var QuotaForInviter = 0
 var QuotaForInvitee = 0
@@ -187,6 +224,9 @@ var DisableTokenEncoders = false
 var RetryTimes = 0
 var RetryTimeOut = 10
 
+var ContextSummaryModel = "" // 上下文超限时用于总结较早对话的模型，为空时只裁剪不总结
+var StructuredOutputRetries = 2 // 结构化输出校验失败时要求模型修正的次数
+
 var DefaultChannelWeight = uint(1)
 var RetryCooldownSeconds = 5
 
@@ -240,6 +280,7 @@ const (
 	RedemptionCodeStatusEnabled  = 1 // don't use 0, 0 is the default value!
 	RedemptionCodeStatusDisabled = 2 // also don't use 0
 	RedemptionCodeStatusUsed     = 3 // also don't use 0
+	RedemptionCodeStatusExpired  = 4
 )
 
 const (
@@ -306,6 +347,7 @@ const (
 	ChannelTypeAzureDatabricks = 54
 	ChannelTypeAzureV1         = 55
 	ChannelTypeXAI             = 56
+	ChannelTypeAsyncVideo      = 57
 )
 
 const (
@@ -326,6 +368,7 @@ const (
 	RelayModeChatRealtime
 	RelayModeKling
 	RelayModeResponses
+	RelayModeVideos
 )
 
 type ContextKey string
@@ -0,0 +1,59 @@
+package config
+
+// 权限，可以组合为自定义角色分配给用户，管理员及以上默认拥有全部权限
+const (
+	PermissionChannelRead     = "channel:read"
+	PermissionChannelWrite    = "channel:write"
+	PermissionChannelStatus   = "channel:status"
+	PermissionPriceWrite      = "price:write"
+	PermissionModelWrite      = "model:write"
+	PermissionUserRead        = "user:read"
+	PermissionUserWrite       = "user:write"
+	PermissionUserQuota       = "user:quota"
+	PermissionUserGroupWrite  = "user_group:write"
+	PermissionTokenManage     = "token:manage"
+	PermissionRedemption      = "redemption:manage"
+	PermissionLogRead         = "log:read"
+	PermissionLogManage       = "log:manage"
+	PermissionAnalyticsRead   = "analytics:read"
+	PermissionPaymentManage   = "payment:manage"
+	PermissionInvoiceManage   = "invoice:manage"
+	PermissionSchedulerManage = "scheduler:manage"
+	PermissionAuditRead       = "audit:read"
+)
+
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions 所有可分配的权限
+var Permissions = []PermissionInfo{
+	{PermissionChannelRead, "查看渠道（不包含密钥）、渠道测试和探测记录"},
+	{PermissionChannelWrite, "新增、修改、删除渠道"},
+	{PermissionChannelStatus, "启用、禁用渠道和渠道密钥，测试渠道，更新余额"},
+	{PermissionPriceWrite, "修改模型价格"},
+	{PermissionModelWrite, "修改模型信息和模型归属"},
+	{PermissionUserRead, "查看用户"},
+	{PermissionUserWrite, "新增、修改、禁用用户，只能管理权限等级低于自己的用户"},
+	{PermissionUserQuota, "增减用户额度"},
+	{PermissionUserGroupWrite, "管理用户分组"},
+	{PermissionTokenManage, "查看和修改所有用户的令牌"},
+	{PermissionRedemption, "管理兑换码"},
+	{PermissionLogRead, "查看调用日志、绘图和任务记录"},
+	{PermissionLogManage, "清理和归档调用日志"},
+	{PermissionAnalyticsRead, "查看统计分析"},
+	{PermissionPaymentManage, "管理支付方式和订单（不包含支付密钥）"},
+	{PermissionInvoiceManage, "生成和导出账单"},
+	{PermissionSchedulerManage, "管理定时任务"},
+	{PermissionAuditRead, "查看和导出审计日志"},
+}
+
+func IsValidPermission(permission string) bool {
+	for _, info := range Permissions {
+		if info.Name == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,17 @@
 package common
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
 
 func Password2Hash(password string) (string, error) {
 	passwordBytes := []byte(password)
@@ -12,3 +23,116 @@ func ValidatePasswordAndHash(password string, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// SecretBoxPrefix 使用口令加密后的密文前缀
+const SecretBoxPrefix = "enc:v1:"
+
+const secretBoxSaltSize = 16
+
+var ErrSecretBoxDecrypt = errors.New("解密失败，请检查口令是否正确")
+
+// SecretBox 使用口令派生密钥的 AES-GCM 加密，用于导出配置中的敏感字段
+// 同一个 SecretBox 加密时共用一个随机盐，解密时按盐缓存派生的密钥，避免每个字段都重新派生
+type SecretBox struct {
+	passphrase string
+	salt       []byte
+	keys       map[string][]byte
+	mu         sync.Mutex
+}
+
+func NewSecretBox(passphrase string) (*SecretBox, error) {
+	if passphrase == "" {
+		return nil, errors.New("口令不能为空")
+	}
+
+	salt := make([]byte, secretBoxSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return &SecretBox{
+		passphrase: passphrase,
+		salt:       salt,
+		keys:       make(map[string][]byte),
+	}, nil
+}
+
+func IsSecretBoxEncrypted(value string) bool {
+	return strings.HasPrefix(value, SecretBoxPrefix)
+}
+
+func (b *SecretBox) deriveKey(salt []byte) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if key, ok := b.keys[string(salt)]; ok {
+		return key, nil
+	}
+
+	key, err := scrypt.Key([]byte(b.passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	b.keys[string(salt)] = key
+	return key, nil
+}
+
+func (b *SecretBox) gcm(salt []byte) (cipher.AEAD, error) {
+	key, err := b.deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt 输出格式为 前缀 + base64(盐 + nonce + 密文)
+func (b *SecretBox) Encrypt(plaintext string) (string, error) {
+	aead, err := b.gcm(b.salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	data := append([]byte{}, b.salt...)
+	data = append(data, nonce...)
+	data = aead.Seal(data, nonce, []byte(plaintext), nil)
+
+	return SecretBoxPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt 非加密格式的值原样返回
+func (b *SecretBox) Decrypt(value string) (string, error) {
+	if !IsSecretBoxEncrypted(value) {
+		return value, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SecretBoxPrefix))
+	if err != nil || len(data) < secretBoxSaltSize {
+		return "", ErrSecretBoxDecrypt
+	}
+
+	salt := data[:secretBoxSaltSize]
+	aead, err := b.gcm(salt)
+	if err != nil {
+		return "", err
+	}
+
+	data = data[secretBoxSaltSize:]
+	if len(data) < aead.NonceSize() {
+		return "", ErrSecretBoxDecrypt
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrSecretBoxDecrypt
+	}
+	return string(plaintext), nil
+}
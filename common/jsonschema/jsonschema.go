@@ -0,0 +1,428 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// 最多返回的错误数，避免错误信息过长
+const maxErrors = 10
+
+// ValidationError 校验失败的位置和原因，Path 使用 JSONPath 表示
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate 校验 value 是否符合 schema，value 应为 json.Unmarshal 到 any 的结果
+// 支持结构化输出常用的关键字：type、enum、const、properties、required、additionalProperties、
+// items、anyOf、oneOf、allOf、not、$ref（仅限本文档内）以及字符串、数值、数组的长度和范围限制
+func Validate(schema any, value any) error {
+	root, err := normalize(schema)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{root: root}
+	v.validate(root, value, "$")
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+// ValidateJSON 解析 JSON 文本后校验
+func ValidateJSON(schema any, data string) error {
+	var value any
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return ValidationErrors{{Path: "$", Message: "invalid JSON: " + err.Error()}}
+	}
+	return Validate(schema, value)
+}
+
+// normalize 将任意结构的 schema 转换为 map，方便统一处理
+func normalize(schema any) (map[string]any, error) {
+	switch s := schema.(type) {
+	case map[string]any:
+		return s, nil
+	case nil:
+		return map[string]any{}, nil
+	}
+
+	var data []byte
+	switch s := schema.(type) {
+	case string:
+		data = []byte(s)
+	case []byte:
+		data = s
+	case json.RawMessage:
+		data = s
+	default:
+		var err error
+		if data, err = json.Marshal(schema); err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+	}
+
+	root := map[string]any{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return root, nil
+}
+
+type validator struct {
+	root   map[string]any
+	errors ValidationErrors
+	depth  int
+}
+
+func (v *validator) addError(path, format string, args ...any) {
+	if len(v.errors) >= maxErrors {
+		return
+	}
+	v.errors = append(v.errors, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// check 在独立的校验器中校验，用于 anyOf、oneOf 等组合关键字
+func (v *validator) check(schema any, value any, path string) ValidationErrors {
+	sub := &validator{root: v.root, depth: v.depth}
+	sub.validate(schema, value, path)
+	return sub.errors
+}
+
+func (v *validator) validate(schemaValue any, value any, path string) {
+	// true 表示任意值，false 表示不允许任何值
+	if allow, ok := schemaValue.(bool); ok {
+		if !allow {
+			v.addError(path, "value is not allowed")
+		}
+		return
+	}
+	schema, ok := schemaValue.(map[string]any)
+	if !ok {
+		return
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		// 防止循环引用
+		if v.depth > 64 {
+			v.addError(path, "schema reference is too deep")
+			return
+		}
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.addError(path, "%s", err.Error())
+			return
+		}
+		v.depth++
+		v.validate(target, value, path)
+		v.depth--
+		return
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok && !matchAnyType(types, value) {
+		v.addError(path, "expected %s, got %s", strings.Join(types, " or "), typeOf(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, item := range enum {
+			if equal(item, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.addError(path, "value must be one of %s", marshal(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !equal(constant, value) {
+		v.addError(path, "value must be %s", marshal(constant))
+	}
+
+	v.validateCombinators(schema, value, path)
+
+	switch val := value.(type) {
+	case map[string]any:
+		v.validateObject(schema, val, path)
+	case []any:
+		v.validateArray(schema, val, path)
+	case string:
+		v.validateString(schema, val, path)
+	case float64:
+		v.validateNumber(schema, val, path)
+	case json.Number:
+		if f, err := val.Float64(); err == nil {
+			v.validateNumber(schema, f, path)
+		}
+	}
+}
+
+func (v *validator) validateCombinators(schema map[string]any, value any, path string) {
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			v.validate(sub, value, path)
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var first ValidationErrors
+		matched := false
+		for i, sub := range anyOf {
+			errs := v.check(sub, value, path)
+			if len(errs) == 0 {
+				matched = true
+				break
+			}
+			if i == 0 {
+				first = errs
+			}
+		}
+		if !matched {
+			v.addError(path, "value does not match any schema in anyOf: %s", first.Error())
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		count := 0
+		for _, sub := range oneOf {
+			if len(v.check(sub, value, path)) == 0 {
+				count++
+			}
+		}
+		if count != 1 {
+			v.addError(path, "value must match exactly one schema in oneOf, matched %d", count)
+		}
+	}
+
+	if not, ok := schema["not"]; ok && len(v.check(not, value, path)) == 0 {
+		v.addError(path, "value must not match the schema in not")
+	}
+}
+
+func (v *validator) validateObject(schema map[string]any, value map[string]any, path string) {
+	properties, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, item := range required {
+			name, _ := item.(string)
+			if _, exists := value[name]; !exists {
+				v.addError(path, "missing required property %q", name)
+			}
+		}
+	}
+
+	for name, propertyValue := range value {
+		propertyPath := path + "." + name
+		if propertySchema, ok := properties[name]; ok {
+			v.validate(propertySchema, propertyValue, propertyPath)
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.addError(path, "additional property %q is not allowed", name)
+			}
+		case map[string]any:
+			v.validate(additional, propertyValue, propertyPath)
+		}
+	}
+
+	if minProperties, ok := toInt(schema["minProperties"]); ok && len(value) < minProperties {
+		v.addError(path, "object must have at least %d properties", minProperties)
+	}
+	if maxProperties, ok := toInt(schema["maxProperties"]); ok && len(value) > maxProperties {
+		v.addError(path, "object must have at most %d properties", maxProperties)
+	}
+}
+
+func (v *validator) validateArray(schema map[string]any, value []any, path string) {
+	if minItems, ok := toInt(schema["minItems"]); ok && len(value) < minItems {
+		v.addError(path, "array must have at least %d items", minItems)
+	}
+	if maxItems, ok := toInt(schema["maxItems"]); ok && len(value) > maxItems {
+		v.addError(path, "array must have at most %d items", maxItems)
+	}
+
+	prefixItems, _ := schema["prefixItems"].([]any)
+	for i, item := range value {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		if i < len(prefixItems) {
+			v.validate(prefixItems[i], item, itemPath)
+			continue
+		}
+		if items, ok := schema["items"]; ok {
+			v.validate(items, item, itemPath)
+		}
+	}
+
+	if unique, ok := schema["uniqueItems"].(bool); ok && unique {
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if equal(value[i], value[j]) {
+					v.addError(path, "array items must be unique, items %d and %d are equal", i, j)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) validateString(schema map[string]any, value string, path string) {
+	length := len([]rune(value))
+	if minLength, ok := toInt(schema["minLength"]); ok && length < minLength {
+		v.addError(path, "string must be at least %d characters", minLength)
+	}
+	if maxLength, ok := toInt(schema["maxLength"]); ok && length > maxLength {
+		v.addError(path, "string must be at most %d characters", maxLength)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(value) {
+			v.addError(path, "string does not match pattern %q", pattern)
+		}
+	}
+}
+
+func (v *validator) validateNumber(schema map[string]any, value float64, path string) {
+	if minimum, ok := toFloat(schema["minimum"]); ok && value < minimum {
+		v.addError(path, "value must be >= %v", minimum)
+	}
+	if maximum, ok := toFloat(schema["maximum"]); ok && value > maximum {
+		v.addError(path, "value must be <= %v", maximum)
+	}
+	if minimum, ok := toFloat(schema["exclusiveMinimum"]); ok && value <= minimum {
+		v.addError(path, "value must be > %v", minimum)
+	}
+	if maximum, ok := toFloat(schema["exclusiveMaximum"]); ok && value >= maximum {
+		v.addError(path, "value must be < %v", maximum)
+	}
+	if multipleOf, ok := toFloat(schema["multipleOf"]); ok && multipleOf > 0 {
+		quotient := value / multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			v.addError(path, "value must be a multiple of %v", multipleOf)
+		}
+	}
+}
+
+// resolve 解析本文档内的引用，如 #/$defs/item 或 #/definitions/item
+func (v *validator) resolve(ref string) (any, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema reference %q", ref)
+	}
+
+	var current any = v.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		node, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("schema reference %q not found", ref)
+		}
+		if current, ok = node[part]; !ok {
+			return nil, fmt.Errorf("schema reference %q not found", ref)
+		}
+	}
+	return current, nil
+}
+
+func schemaTypes(value any) ([]string, bool) {
+	switch t := value.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func matchAnyType(types []string, value any) bool {
+	actual := typeOf(value)
+	for _, name := range types {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(value any) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch val := value.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toInt(value any) (int, bool) {
+	f, ok := toFloat(value)
+	return int(f), ok
+}
+
+func equal(a, b any) bool {
+	return marshal(a) == marshal(b)
+}
+
+func marshal(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
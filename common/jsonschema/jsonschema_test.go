@@ -0,0 +1,93 @@
+package jsonschema_test
+
+import (
+	"one-api/common/jsonschema"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"email": {"type": ["string", "null"], "pattern": "@"},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"address": {"$ref": "#/$defs/address"}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"address": {
+			"type": "object",
+			"properties": {"city": {"type": "string"}},
+			"required": ["city"]
+		}
+	}
+}`
+
+func TestValidateJSON(t *testing.T) {
+	valid := []string{
+		`{"name": "Alice", "age": 30}`,
+		`{"name": "Bob", "age": 0, "email": null, "role": "user", "tags": ["a"], "address": {"city": "Paris"}}`,
+	}
+	for _, data := range valid {
+		assert.NoError(t, jsonschema.ValidateJSON(personSchema, data), data)
+	}
+
+	invalid := map[string]string{
+		`{"name": "Alice"}`:                                `$: missing required property "age"`,
+		`{"name": "Alice", "age": 1.5}`:                    `$.age: expected integer, got number`,
+		`{"name": "", "age": 1}`:                           `$.name: string must be at least 1 characters`,
+		`{"name": "A", "age": 1, "extra": true}`:           `$: additional property "extra" is not allowed`,
+		`{"name": "A", "age": 1, "role": "root"}`:          `$.role: value must be one of ["admin","user"]`,
+		`{"name": "A", "age": 1, "tags": ["a", 2]}`:        `$.tags[1]: expected string, got integer`,
+		`{"name": "A", "age": 1, "email": "a.com"}`:        `$.email: string does not match pattern "@"`,
+		`{"name": "A", "age": 1, "address": {}}`:           `$.address: missing required property "city"`,
+		`{"name": "A", "age": 1, "tags": ["a", "b", "c"]}`: `$.tags: array must have at most 2 items`,
+		`[1, 2]`:     `$: expected object, got array`,
+		`{"name": }`: `$: invalid JSON`,
+	}
+	for data, message := range invalid {
+		err := jsonschema.ValidateJSON(personSchema, data)
+		if assert.Error(t, err, data) {
+			assert.Contains(t, err.Error(), message)
+		}
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	schema := map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "integer", "exclusiveMaximum": 10},
+		},
+	}
+	assert.NoError(t, jsonschema.Validate(schema, "x"))
+	assert.NoError(t, jsonschema.Validate(schema, float64(9)))
+	assert.Error(t, jsonschema.Validate(schema, float64(10)))
+	assert.Error(t, jsonschema.Validate(schema, true))
+
+	schema = map[string]any{
+		"oneOf": []any{
+			map[string]any{"type": "number"},
+			map[string]any{"type": "integer"},
+		},
+	}
+	assert.NoError(t, jsonschema.Validate(schema, 1.5))
+	assert.Error(t, jsonschema.Validate(schema, float64(1)))
+}
+
+func TestValidateInvalidSchema(t *testing.T) {
+	err := jsonschema.Validate("{", map[string]any{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid schema")
+	}
+
+	err = jsonschema.ValidateJSON(`{"$ref": "#/$defs/missing"}`, `{}`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not found")
+	}
+}
@@ -0,0 +1,211 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"strings"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+var ErrInvalidCredentials = errors.New("用户名或密码错误")
+
+// Conn LDAP 连接，测试时可以替换为本地实现
+type Conn interface {
+	Bind(username, password string) error
+	Search(searchRequest *goldap.SearchRequest) (*goldap.SearchResult, error)
+	Close() error
+}
+
+// Dial 建立到目录服务器的连接
+var Dial = func() (Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.LDAPInsecureSkipVerify}
+	conn, err := goldap.DialURL(config.LDAPServerURL, goldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	if config.LDAPStartTLS && strings.HasPrefix(config.LDAPServerURL, "ldap://") {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Entry 通过认证的目录用户
+type Entry struct {
+	DN          string
+	Username    string
+	Email       string
+	DisplayName string
+	Groups      []string
+}
+
+// Authenticate 先用服务账号搜索用户，再使用用户 DN 和密码绑定，成功后返回用户信息和所属组
+func Authenticate(username, password string) (*Entry, error) {
+	// 空密码在多数目录中会被当作匿名绑定而成功
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+
+	filter := strings.ReplaceAll(config.LDAPUserFilter, "%s", goldap.EscapeFilter(username))
+	attributes := []string{config.LDAPUsernameAttribute, config.LDAPEmailAttribute, config.LDAPDisplayNameAttribute}
+	if config.LDAPGroupAttribute != "" {
+		attributes = append(attributes, config.LDAPGroupAttribute)
+	}
+	result, err := conn.Search(goldap.NewSearchRequest(
+		config.LDAPBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		2, 0, false, filter, attributes, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("搜索 LDAP 用户失败: %w", err)
+	}
+	// 找不到或匹配到多个用户时都按认证失败处理，避免泄露用户是否存在
+	if len(result.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	userEntry := result.Entries[0]
+
+	if err := conn.Bind(userEntry.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	entry := &Entry{
+		DN:          userEntry.DN,
+		Username:    userEntry.GetAttributeValue(config.LDAPUsernameAttribute),
+		Email:       userEntry.GetAttributeValue(config.LDAPEmailAttribute),
+		DisplayName: userEntry.GetAttributeValue(config.LDAPDisplayNameAttribute),
+	}
+	if entry.Username == "" {
+		entry.Username = username
+	}
+	if config.LDAPGroupAttribute != "" {
+		entry.Groups = append(entry.Groups, userEntry.GetAttributeValues(config.LDAPGroupAttribute)...)
+	}
+
+	if config.LDAPGroupBaseDN != "" {
+		groups, err := searchGroups(conn, entry.DN)
+		if err != nil {
+			return nil, err
+		}
+		entry.Groups = append(entry.Groups, groups...)
+	}
+
+	return entry, nil
+}
+
+func bindServiceAccount(conn Conn) error {
+	if config.LDAPBindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(config.LDAPBindDN, config.LDAPBindPassword); err != nil {
+		return fmt.Errorf("LDAP 服务账号绑定失败: %w", err)
+	}
+	return nil
+}
+
+// searchGroups 搜索成员中包含用户的组，搜索前重新绑定服务账号，用户本身可能没有读取组的权限
+func searchGroups(conn Conn, userDN string) ([]string, error) {
+	if err := bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+
+	filter := strings.ReplaceAll(config.LDAPGroupFilter, "%s", goldap.EscapeFilter(userDN))
+	result, err := conn.Search(goldap.NewSearchRequest(
+		config.LDAPGroupBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, 0, false, filter, []string{"cn"}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("搜索 LDAP 组失败: %w", err)
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// GroupMapping LDAP 组映射规则，ldap_group 可以是组的 DN 或 CN
+type GroupMapping struct {
+	LDAPGroup string `json:"ldap_group"`
+	Group     string `json:"group,omitempty"` // 用户分组标识
+	Role      int    `json:"role,omitempty"`  // 用户角色，不能映射为超级管理员
+}
+
+func ParseGroupMapping(value string) ([]GroupMapping, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var mappings []GroupMapping
+	if err := json.Unmarshal([]byte(value), &mappings); err != nil {
+		return nil, fmt.Errorf("LDAP 组映射格式错误: %w", err)
+	}
+	for _, mapping := range mappings {
+		if mapping.LDAPGroup == "" {
+			return nil, errors.New("LDAP 组映射缺少 ldap_group")
+		}
+		if mapping.Role >= config.RoleRootUser {
+			return nil, errors.New("LDAP 组不能映射为超级管理员")
+		}
+	}
+	return mappings, nil
+}
+
+// MapGroups 按映射规则计算用户分组和角色，分组取第一条匹配规则，角色取匹配规则中最高的
+func MapGroups(mappings []GroupMapping, groups []string) (group string, role int, matched bool) {
+	for _, mapping := range mappings {
+		if !containsGroup(groups, mapping.LDAPGroup) {
+			continue
+		}
+		matched = true
+		if group == "" {
+			group = mapping.Group
+		}
+		if mapping.Role > role {
+			role = mapping.Role
+		}
+	}
+	return
+}
+
+func containsGroup(groups []string, name string) bool {
+	for _, group := range groups {
+		if strings.EqualFold(group, name) || strings.EqualFold(groupCN(group), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupCN 获取组 DN 中第一个 CN 的值，无法解析时返回空字符串
+func groupCN(dn string) string {
+	parsed, err := goldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return ""
+	}
+	for _, attribute := range parsed.RDNs[0].Attributes {
+		if strings.EqualFold(attribute.Type, "cn") {
+			return attribute.Value
+		}
+	}
+	return ""
+}
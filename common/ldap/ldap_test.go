@@ -0,0 +1,124 @@
+package ldap_test
+
+import (
+	"one-api/common/config"
+	"one-api/common/ldap"
+	"strings"
+	"testing"
+
+	goldap "github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDirectory 模拟 OpenLDAP 的最小实现，只支持 (attr=value) 形式的过滤条件
+type fakeDirectory struct {
+	passwords map[string]string
+	entries   []*goldap.Entry
+	bound     string
+}
+
+func (d *fakeDirectory) Bind(username, password string) error {
+	if expected, ok := d.passwords[username]; !ok || expected != password {
+		return goldap.NewError(goldap.LDAPResultInvalidCredentials, nil)
+	}
+	d.bound = username
+	return nil
+}
+
+func (d *fakeDirectory) Search(req *goldap.SearchRequest) (*goldap.SearchResult, error) {
+	attribute, value, _ := strings.Cut(strings.Trim(req.Filter, "()"), "=")
+	result := &goldap.SearchResult{}
+	for _, entry := range d.entries {
+		if !strings.HasSuffix(entry.DN, req.BaseDN) {
+			continue
+		}
+		for _, v := range entry.GetAttributeValues(attribute) {
+			if goldap.EscapeFilter(v) == value {
+				result.Entries = append(result.Entries, entry)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *fakeDirectory) Close() error {
+	return nil
+}
+
+func newFakeDirectory() *fakeDirectory {
+	aliceDN := "uid=alice,ou=people,dc=example,dc=com"
+	return &fakeDirectory{
+		passwords: map[string]string{
+			"cn=admin,dc=example,dc=com": "admin",
+			aliceDN:                      "secret",
+		},
+		entries: []*goldap.Entry{
+			goldap.NewEntry(aliceDN, map[string][]string{
+				"uid":         {"alice"},
+				"mail":        {"alice@example.com"},
+				"displayName": {"Alice"},
+			}),
+			goldap.NewEntry("cn=ai-admins,ou=groups,dc=example,dc=com", map[string][]string{
+				"member": {aliceDN},
+			}),
+		},
+	}
+}
+
+func setupConfig(t *testing.T, directory *fakeDirectory) {
+	dial := ldap.Dial
+	ldap.Dial = func() (ldap.Conn, error) { return directory, nil }
+	t.Cleanup(func() { ldap.Dial = dial })
+
+	config.LDAPBindDN = "cn=admin,dc=example,dc=com"
+	config.LDAPBindPassword = "admin"
+	config.LDAPBaseDN = "ou=people,dc=example,dc=com"
+	config.LDAPUserFilter = "(uid=%s)"
+	config.LDAPGroupAttribute = ""
+	config.LDAPGroupBaseDN = "ou=groups,dc=example,dc=com"
+	config.LDAPGroupFilter = "(member=%s)"
+}
+
+func TestAuthenticate(t *testing.T) {
+	directory := newFakeDirectory()
+	setupConfig(t, directory)
+
+	entry, err := ldap.Authenticate("alice", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", entry.Username)
+	assert.Equal(t, "alice@example.com", entry.Email)
+	assert.Equal(t, "Alice", entry.DisplayName)
+	assert.Equal(t, []string{"cn=ai-admins,ou=groups,dc=example,dc=com"}, entry.Groups)
+
+	_, err = ldap.Authenticate("alice", "wrong")
+	assert.ErrorIs(t, err, ldap.ErrInvalidCredentials)
+
+	_, err = ldap.Authenticate("alice", "")
+	assert.ErrorIs(t, err, ldap.ErrInvalidCredentials)
+
+	_, err = ldap.Authenticate("bob", "secret")
+	assert.ErrorIs(t, err, ldap.ErrInvalidCredentials)
+
+	_, err = ldap.Authenticate("*", "secret")
+	assert.ErrorIs(t, err, ldap.ErrInvalidCredentials)
+}
+
+func TestMapGroups(t *testing.T) {
+	mappings, err := ldap.ParseGroupMapping(`[
+		{"ldap_group": "AI-Users", "group": "default", "role": 1},
+		{"ldap_group": "cn=ai-admins,ou=groups,dc=example,dc=com", "group": "vip", "role": 10}
+	]`)
+	assert.NoError(t, err)
+
+	group, role, matched := ldap.MapGroups(mappings, []string{"CN=AI-Users,OU=Groups,DC=example,DC=com", "cn=ai-admins,ou=groups,dc=example,dc=com"})
+	assert.True(t, matched)
+	assert.Equal(t, "default", group)
+	assert.Equal(t, config.RoleAdminUser, role)
+
+	_, _, matched = ldap.MapGroups(mappings, []string{"cn=other,dc=example,dc=com"})
+	assert.False(t, matched)
+
+	_, err = ldap.ParseGroupMapping(`[{"ldap_group": "root", "role": 100}]`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package logsink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClickHouseSink 通过 HTTP 接口以 JSONEachRow 格式写入 ClickHouse
+//
+// 建表示例：
+//
+//	CREATE TABLE one_hub_logs (
+//	    user_id UInt32, username String, created_at DateTime, type UInt8, content String,
+//	    token_name String, model_name LowCardinality(String), quota Int64, cost_quota Int64,
+//	    prompt_tokens UInt32, completion_tokens UInt32, channel_id UInt32, request_time UInt32,
+//	    is_stream Bool, source_ip String, metadata String
+//	) ENGINE = MergeTree ORDER BY (created_at, user_id)
+type ClickHouseSink struct {
+	name     string
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func newClickHouseSink(config SinkConfig) (Sink, error) {
+	if config.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	table := config.Table
+	if table == "" {
+		table = "one_hub_logs"
+	}
+	if config.Database != "" {
+		table = config.Database + "." + table
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(config.URL, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+	query := endpoint.Query()
+	query.Set("query", "INSERT INTO "+table+" FORMAT JSONEachRow")
+	// 忽略表中不存在的字段，方便按需建表
+	query.Set("input_format_skip_unknown_fields", "1")
+	query.Set("date_time_input_format", "best_effort")
+	endpoint.RawQuery = query.Encode()
+
+	headers := map[string]string{"Content-Type": "application/x-ndjson"}
+	if config.Username != "" {
+		headers["X-ClickHouse-User"] = config.Username
+		headers["X-ClickHouse-Key"] = config.Password
+	}
+
+	name := config.Name
+	if name == "" {
+		name = "clickhouse"
+	}
+
+	return &ClickHouseSink{
+		name:     name,
+		endpoint: endpoint.String(),
+		headers:  headers,
+		client:   newHTTPClient(config.Timeout),
+	}, nil
+}
+
+func (s *ClickHouseSink) Name() string {
+	return s.name
+}
+
+func (s *ClickHouseSink) Write(ctx context.Context, entries []*Entry) error {
+	rows := make([]*clickHouseRow, 0, len(entries))
+	for _, entry := range entries {
+		metadata, _ := json.Marshal(entry.Metadata)
+		rows = append(rows, &clickHouseRow{Entry: entry, Metadata: string(metadata)})
+	}
+
+	var body []byte
+	for _, row := range rows {
+		line, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+		body = append(body, line...)
+		body = append(body, '\n')
+	}
+
+	return postBody(ctx, s.client, s.endpoint, s.headers, body)
+}
+
+func (s *ClickHouseSink) Close() error {
+	return nil
+}
+
+// clickHouseRow metadata 以字符串写入，避免依赖 JSON 列类型
+type clickHouseRow struct {
+	*Entry
+	Metadata string `json:"metadata"`
+}
@@ -0,0 +1,111 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPSink 以 JSON 数组或 JSONL 格式批量推送到 HTTP 接口
+type HTTPSink struct {
+	name    string
+	url     string
+	headers map[string]string
+	jsonl   bool
+	client  *http.Client
+}
+
+func newHTTPSink(config SinkConfig) (Sink, error) {
+	if config.URL == "" {
+		return nil, errors.New("url is required")
+	}
+
+	name := config.Name
+	if name == "" {
+		name = "http"
+	}
+
+	return &HTTPSink{
+		name:    name,
+		url:     config.URL,
+		headers: config.Headers,
+		jsonl:   config.Format == "jsonl",
+		client:  newHTTPClient(config.Timeout),
+	}, nil
+}
+
+func (s *HTTPSink) Name() string {
+	return s.name
+}
+
+func (s *HTTPSink) Write(ctx context.Context, entries []*Entry) error {
+	var body []byte
+	var err error
+	contentType := "application/json"
+	if s.jsonl {
+		body, err = encodeJSONL(entries)
+		contentType = "application/x-ndjson"
+	} else {
+		body, err = json.Marshal(entries)
+	}
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{"Content-Type": contentType}
+	for key, value := range s.headers {
+		headers[key] = value
+	}
+
+	return postBody(ctx, s.client, s.url, headers, body)
+}
+
+func (s *HTTPSink) Close() error {
+	return nil
+}
+
+func newHTTPClient(timeout int) *http.Client {
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return &http.Client{Timeout: time.Duration(timeout) * time.Second}
+}
+
+func encodeJSONL(entries []*Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func postBody(ctx context.Context, client *http.Client, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, string(message))
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package logsink
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+// KafkaSink 写入 Kafka 及兼容 Kafka 协议的服务（如 Redpanda），以用户ID作为消息 key
+type KafkaSink struct {
+	name   string
+	writer *kafka.Writer
+}
+
+func newKafkaSink(config SinkConfig) (Sink, error) {
+	if len(config.Brokers) == 0 || config.Topic == "" {
+		return nil, errors.New("brokers and topic are required")
+	}
+
+	transport := &kafka.Transport{}
+	if config.TLS {
+		transport.TLS = &tls.Config{}
+	}
+	if config.Username != "" {
+		transport.SASL = plain.Mechanism{Username: config.Username, Password: config.Password}
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	name := config.Name
+	if name == "" {
+		name = "kafka"
+	}
+
+	return &KafkaSink{
+		name: name,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(config.Brokers...),
+			Topic:        config.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			WriteTimeout: time.Duration(timeout) * time.Second,
+			Transport:    transport,
+			// 批量由 worker 控制，这里不再等待
+			BatchTimeout: time.Millisecond,
+		},
+	}, nil
+}
+
+func (s *KafkaSink) Name() string {
+	return s.name
+}
+
+func (s *KafkaSink) Write(ctx context.Context, entries []*Entry) error {
+	messages := make([]kafka.Message, 0, len(entries))
+	for _, entry := range entries {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafka.Message{
+			Key:   []byte(strconv.Itoa(entry.UserId)),
+			Value: value,
+		})
+	}
+
+	return s.writer.WriteMessages(ctx, messages...)
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,148 @@
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"one-api/common/logger"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Entry 消费日志，字段与 logs 表保持一致
+type Entry struct {
+	UserId           int            `json:"user_id"`
+	Username         string         `json:"username"`
+	CreatedAt        int64          `json:"created_at"`
+	Type             int            `json:"type"`
+	Content          string         `json:"content"`
+	TokenName        string         `json:"token_name"`
+	ModelName        string         `json:"model_name"`
+	Quota            int            `json:"quota"`
+	CostQuota        int            `json:"cost_quota"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	ChannelId        int            `json:"channel_id"`
+	RequestTime      int            `json:"request_time"`
+	IsStream         bool           `json:"is_stream"`
+	SourceIp         string         `json:"source_ip"`
+	Metadata         map[string]any `json:"metadata"`
+}
+
+// Sink 日志输出目标，Write 返回错误时会按配置重试
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, entries []*Entry) error
+	Close() error
+}
+
+type SinkConfig struct {
+	Type    string            `mapstructure:"type"`
+	Name    string            `mapstructure:"name"`
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+	Format  string            `mapstructure:"format"`
+	Timeout int               `mapstructure:"timeout"`
+
+	// clickhouse
+	Database string `mapstructure:"database"`
+	Table    string `mapstructure:"table"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+
+	// kafka
+	Brokers []string `mapstructure:"brokers"`
+	Topic   string   `mapstructure:"topic"`
+	TLS     bool     `mapstructure:"tls"`
+}
+
+type sinkFactory func(config SinkConfig) (Sink, error)
+
+var sinkFactories = map[string]sinkFactory{
+	"clickhouse": newClickHouseSink,
+	"http":       newHTTPSink,
+	"kafka":      newKafkaSink,
+}
+
+var (
+	workers    []*worker
+	sqlEnabled = true
+)
+
+// Init 根据 log_sink 配置创建日志输出
+func Init() {
+	sqlEnabled = viper.GetBool("log_sink.sql_enabled")
+
+	// 没有可用的输出时仍写入数据库，避免消费日志丢失
+	defer func() {
+		if !sqlEnabled && len(workers) == 0 {
+			sqlEnabled = true
+			logger.SysError("log_sink.sql_enabled is false but no log sink is available, consume logs will still be written to database")
+		}
+	}()
+
+	var configs []SinkConfig
+	if err := viper.UnmarshalKey("log_sink.sinks", &configs); err != nil {
+		logger.SysError("failed to parse log sink config: " + err.Error())
+		return
+	}
+
+	options := workerOptions{
+		bufferSize:    viper.GetInt("log_sink.buffer_size"),
+		batchSize:     viper.GetInt("log_sink.batch_size"),
+		flushInterval: time.Duration(viper.GetInt("log_sink.flush_interval")) * time.Second,
+		maxRetries:    viper.GetInt("log_sink.max_retries"),
+		blockTimeout:  time.Duration(viper.GetInt("log_sink.block_timeout")) * time.Millisecond,
+	}
+
+	for _, config := range configs {
+		factory, ok := sinkFactories[config.Type]
+		if !ok {
+			logger.SysError("unknown log sink type: " + config.Type)
+			continue
+		}
+		sink, err := factory(config)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to create log sink %s: %s", config.Type, err.Error()))
+			continue
+		}
+
+		w := newWorker(sink, options)
+		workers = append(workers, w)
+		logger.SysLog("log sink enabled: " + sink.Name())
+	}
+
+	if !sqlEnabled && len(workers) > 0 {
+		logger.SysLog("consume logs will not be written to database")
+	}
+}
+
+// SQLEnabled 是否继续将消费日志写入数据库
+func SQLEnabled() bool {
+	return sqlEnabled
+}
+
+func Enabled() bool {
+	return len(workers) > 0
+}
+
+// Publish 将日志写入各输出的缓冲区
+func Publish(entry *Entry) {
+	for _, w := range workers {
+		w.push(entry)
+	}
+}
+
+// Shutdown 写入缓冲区中剩余的日志
+func Shutdown() {
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.stop()
+		}(w)
+	}
+	wg.Wait()
+}
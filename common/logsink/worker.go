@@ -0,0 +1,139 @@
+package logsink
+
+import (
+	"context"
+	"fmt"
+	"one-api/common/logger"
+	"sync/atomic"
+	"time"
+)
+
+type workerOptions struct {
+	bufferSize    int
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	blockTimeout  time.Duration
+}
+
+// worker 每个输出独立缓冲和批量写入，单个输出故障不影响其他输出
+type worker struct {
+	sink    Sink
+	options workerOptions
+	entries chan *Entry
+	done    chan struct{}
+	dropped atomic.Int64
+	closed  atomic.Bool
+}
+
+func newWorker(sink Sink, options workerOptions) *worker {
+	if options.bufferSize <= 0 {
+		options.bufferSize = 10000
+	}
+	if options.batchSize <= 0 {
+		options.batchSize = 500
+	}
+	if options.flushInterval <= 0 {
+		options.flushInterval = 2 * time.Second
+	}
+
+	w := &worker{
+		sink:    sink,
+		options: options,
+		entries: make(chan *Entry, options.bufferSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+
+	return w
+}
+
+// push 缓冲区满时最多等待 blockTimeout，仍然写不进去则丢弃
+func (w *worker) push(entry *Entry) {
+	if w.closed.Load() {
+		return
+	}
+
+	select {
+	case w.entries <- entry:
+		return
+	default:
+	}
+
+	if w.options.blockTimeout > 0 {
+		timer := time.NewTimer(w.options.blockTimeout)
+		defer timer.Stop()
+		select {
+		case w.entries <- entry:
+			return
+		case <-timer.C:
+		}
+	}
+
+	// 每丢弃 1000 条提示一次，避免刷屏
+	if dropped := w.dropped.Add(1); dropped%1000 == 1 {
+		logger.SysError(fmt.Sprintf("log sink %s buffer is full, %d logs dropped", w.sink.Name(), dropped))
+	}
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.options.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Entry, 0, w.options.batchSize)
+	for {
+		select {
+		case entry, ok := <-w.entries:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= w.options.batchSize {
+				w.flush(batch)
+				batch = make([]*Entry, 0, w.options.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = make([]*Entry, 0, w.options.batchSize)
+			}
+		}
+	}
+}
+
+// flush 写入失败时按指数退避重试
+func (w *worker) flush(batch []*Entry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	for attempt := 0; attempt <= w.options.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = w.sink.Write(ctx, batch)
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+
+	logger.SysError(fmt.Sprintf("log sink %s write %d logs failed: %s", w.sink.Name(), len(batch), err.Error()))
+}
+
+func (w *worker) stop() {
+	if !w.closed.CompareAndSwap(false, true) {
+		return
+	}
+	close(w.entries)
+	<-w.done
+	if err := w.sink.Close(); err != nil {
+		logger.SysError(fmt.Sprintf("log sink %s close error: %s", w.sink.Name(), err.Error()))
+	}
+}
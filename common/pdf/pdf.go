@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// A4 尺寸，单位 pt
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document 简易 PDF 文档生成器
+// 使用阅读器内置的 STSong-Light 字体，不需要嵌入字体文件即可显示中英文。
+// ASCII 字符按半角宽度、其余字符按全角宽度计算，便于表格对齐。
+type Document struct {
+	pages   []*bytes.Buffer
+	current *bytes.Buffer
+}
+
+func New() *Document {
+	return &Document{}
+}
+
+// AddPage 新增一页，后续绘制都在该页上
+func (d *Document) AddPage() {
+	d.current = &bytes.Buffer{}
+	d.pages = append(d.pages, d.current)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if d.current == nil {
+		d.AddPage()
+	}
+	return d.current
+}
+
+// Text 在 (x, y) 处绘制文字，y 从页面顶部开始计算
+func (d *Document) Text(x, y, size float64, text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprintf(d.page(), "BT /F1 %.2f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, PageHeight-y, encodeText(text))
+}
+
+// TextRight 以 x 为右边界绘制文字
+func (d *Document) TextRight(x, y, size float64, text string) {
+	d.Text(x-TextWidth(text, size), y, size, text)
+}
+
+// Line 绘制线段
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// TextWidth 计算文字宽度
+func TextWidth(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		if r < 0x80 {
+			width += 0.5
+		} else {
+			width += 1
+		}
+	}
+	return width * size
+}
+
+func encodeText(text string) string {
+	buf := &bytes.Buffer{}
+	for _, code := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(buf, "%04X", code)
+	}
+	return buf.String()
+}
+
+// Bytes 输出完整的 PDF 文件内容
+func (d *Document) Bytes() []byte {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	buf := &bytes.Buffer{}
+	var offsets []int
+	writeObject := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// 1: Catalog, 2: Pages, 3-5: 字体，之后每页占用 页面 + 内容 两个对象
+	pageObjectStart := 6
+	kids := &bytes.Buffer{}
+	for i := range d.pages {
+		fmt.Fprintf(kids, "%d 0 R ", pageObjectStart+i*2)
+	}
+
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids.String(), len(d.pages)))
+	writeObject("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UTF16-H /DescendantFonts [4 0 R] >>")
+	writeObject("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 4 >> /FontDescriptor 5 0 R /DW 1000 /W [1 95 500] >>")
+	writeObject("<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+
+	for i, page := range d.pages {
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, pageObjectStart+i*2+1))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	return buf.Bytes()
+}
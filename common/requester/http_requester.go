@@ -9,12 +9,17 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/capture"
+	"one-api/common/tracing"
 	"one-api/common/utils"
 	"one-api/types"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type HttpErrorHandler func(*http.Response) *types.OpenAIError
@@ -74,7 +79,7 @@ func (r *HTTPRequester) NewRequest(method, url string, setters ...requestOption)
 
 // 发送请求
 func (r *HTTPRequester) SendRequest(req *http.Request, response any, outputResp bool) (*http.Response, *types.OpenAIErrorWithStatusCode) {
-	resp, err := HTTPClient.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, common.ErrorWrapper(err, "http_request_failed", http.StatusInternalServerError)
 	}
@@ -114,7 +119,7 @@ func (r *HTTPRequester) SendRequest(req *http.Request, response any, outputResp
 // 发送请求 RAW
 func (r *HTTPRequester) SendRequestRaw(req *http.Request) (*http.Response, *types.OpenAIErrorWithStatusCode) {
 	// 发送请求
-	resp, err := HTTPClient.Do(req)
+	resp, err := doRequest(req)
 	if err != nil {
 		return nil, common.ErrorWrapper(err, "http_request_failed", http.StatusInternalServerError)
 	}
@@ -127,6 +132,38 @@ func (r *HTTPRequester) SendRequestRaw(req *http.Request) (*http.Response, *type
 	return resp, nil
 }
 
+// doRequest 发送上游请求，记录 span 并透传 traceparent，开启采集时记录请求和响应内容
+func doRequest(req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.Start(req.Context(), "upstream.request",
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+		attribute.String("url.path", req.URL.Path),
+	)
+	defer span.End()
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	recorder := capture.FromContext(ctx)
+	if recorder != nil {
+		recorder.RecordRequest(req)
+	}
+
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	if recorder != nil {
+		recorder.RecordResponse(resp)
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+
+	return resp, nil
+}
+
 // 获取流式响应
 func RequestStream[T streamable](requester *HTTPRequester, resp *http.Response, handlerPrefix HandlerPrefix[T]) (*streamReader[T], *types.OpenAIErrorWithStatusCode) {
 	// 如果返回的头是json格式 说明有错误
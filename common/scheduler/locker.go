@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+var ErrLockHeld = errors.New("lock is held by another node")
+
+// Locker 分布式锁的存储实现，token 标识锁的持有者，只有持有者可以续期和释放
+type Locker interface {
+	// TryLock 锁不存在或已过期时获取锁
+	TryLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// Refresh 延长自己持有的锁的有效期
+	Refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// Unlock 释放自己持有的锁
+	Unlock(ctx context.Context, key, token string) error
+}
+
+// jobLocker 为任务加锁，锁在任务执行期间持有并自动续期，执行结束后释放
+//
+// 定时触发时还会以本次调度所在的周期为键写入执行标记，标记在周期结束前不会删除，
+// 这样即使各节点的 DurationJob 启动时间不同，每个周期也只会在一个节点执行一次。
+// 手动执行不检查执行标记，只要没有其他节点正在执行即可。
+type jobLocker struct {
+	tm     *TaskManager
+	locker Locker
+	ttl    time.Duration
+}
+
+func (l *jobLocker) Lock(ctx context.Context, name string) (gocron.Lock, error) {
+	l.tm.mu.RLock()
+	info, ok := l.tm.jobs[name]
+	manual := ok && info.manual
+	var interval, offset time.Duration
+	if ok {
+		interval, offset = info.interval, info.offset
+	}
+	l.tm.mu.RUnlock()
+
+	token := uuid.NewString()
+	key := "scheduler_lock:" + name
+	locked, err := l.locker.TryLock(ctx, key, token, l.ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, ErrLockHeld
+	}
+
+	if !manual && interval > 0 {
+		// 周期以计划执行时间为中心划分，节点间的时钟偏差不会让同一次调度落入不同的周期
+		slot := time.Now().Add(interval/2 - offset).Truncate(interval).Unix()
+		marked, err := l.locker.TryLock(ctx, fmt.Sprintf("scheduler_run:%s:%d", name, slot), token, interval)
+		if err != nil || !marked {
+			l.locker.Unlock(ctx, key, token)
+			if err == nil {
+				err = ErrLockHeld
+			}
+			return nil, err
+		}
+	}
+
+	held := &heldLock{locker: l.locker, key: key, token: token, stop: make(chan struct{})}
+	go held.refresh(l.ttl)
+	return held, nil
+}
+
+// heldLock 任务执行期间持有的锁
+type heldLock struct {
+	locker Locker
+	key    string
+	token  string
+	stop   chan struct{}
+}
+
+// refresh 在锁过期前续期，节点异常退出时锁最多在 ttl 后过期
+func (lock *heldLock) refresh(ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			ok, err := lock.locker.Refresh(context.Background(), lock.key, lock.token, ttl)
+			if err != nil || !ok {
+				logger.SysError(fmt.Sprintf("refresh scheduler lock %s failed: %v", lock.key, err))
+			}
+		}
+	}
+}
+
+func (lock *heldLock) Unlock(ctx context.Context) error {
+	close(lock.stop)
+	return lock.locker.Unlock(ctx, lock.key, lock.token)
+}
+
+var (
+	redisRefreshScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`)
+	redisUnlockScript  = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+)
+
+// RedisLocker 基于 Redis SETNX 的分布式锁
+type RedisLocker struct{}
+
+func NewRedisLocker() *RedisLocker {
+	return &RedisLocker{}
+}
+
+func (RedisLocker) TryLock(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return redis.RDB.SetNX(ctx, key, token, ttl).Result()
+}
+
+func (RedisLocker) Refresh(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	result, err := redis.ScriptRunCtx(ctx, redisRefreshScript, []string{key}, token, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	count, _ := result.(int64)
+	return count > 0, nil
+}
+
+func (RedisLocker) Unlock(ctx context.Context, key, token string) error {
+	_, err := redis.ScriptRunCtx(ctx, redisUnlockScript, []string{key}, token)
+	return err
+}
@@ -1,17 +1,26 @@
 package scheduler
 
 import (
+	"errors"
 	"fmt"
 	"one-api/common/logger"
+	"os"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
 )
 
 type TaskManager struct {
 	scheduler gocron.Scheduler
 	jobs      map[string]*JobInfo
 	mu        sync.RWMutex
+
+	locker *jobLocker
+	store  Store
+	node   string
 }
 
 type JobInfo struct {
@@ -20,12 +29,67 @@ type JobInfo struct {
 	Definition gocron.JobDefinition
 	Task       gocron.Task
 	Options    []gocron.JobOption
+
+	running   int
+	manual    bool
+	startedAt time.Time
+	lastRun   *RunRecord
+	// 本次执行因异常结束，已在 AfterJobRunsWithPanic 中记录
+	panicked bool
+	// 手动执行的结果，任务开始执行时为 nil，因锁被其他节点持有而跳过时为错误
+	manualResult chan error
+
+	// 调度间隔和计划执行时间在间隔内的偏移，用于确定每次调度所在的周期
+	interval time.Duration
+	offset   time.Duration
+}
+
+// RunRecord 任务的一次执行记录
+type RunRecord struct {
+	Name      string
+	Node      string
+	Trigger   string
+	StartedAt time.Time
+	Duration  time.Duration
+	Error     string
+}
+
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Store 保存任务的暂停状态和执行记录，多个节点共享同一份数据
+type Store interface {
+	IsPaused(name string) bool
+	SetPaused(name string, paused bool) error
+	SaveRun(run *RunRecord)
+	LastRun(name string) *RunRecord
+}
+
+// JobStatus 管理后台展示的任务状态
+type JobStatus struct {
+	Name         string `json:"name"`
+	Paused       bool   `json:"paused"`
+	Running      bool   `json:"running"`
+	NextRun      int64  `json:"next_run"`
+	LastRun      int64  `json:"last_run"`
+	LastDuration int64  `json:"last_duration"` // in milliseconds
+	LastError    string `json:"last_error"`
+	LastNode     string `json:"last_node"`
 }
 
 var (
 	Manager *TaskManager
+
+	ErrJobNotFound = errors.New("任务不存在")
+	ErrJobPaused   = errors.New("任务已暂停")
+	ErrJobSkipped  = errors.New("任务正在其他节点执行，已跳过本次手动执行")
 )
 
+// 等待手动执行开始的最长时间
+const manualRunTimeout = 10 * time.Second
+
 func init() {
 	scheduler, err := gocron.NewScheduler()
 	if err != nil {
@@ -33,14 +97,34 @@ func init() {
 		return
 	}
 
+	node, _ := os.Hostname()
 	Manager = &TaskManager{
 		scheduler: scheduler,
 		jobs:      make(map[string]*JobInfo),
+		node:      node,
 	}
 
 	Manager.scheduler.Start()
 }
 
+// SetLocker 设置分布式锁，多个主节点时保证同一任务只在一个节点执行，需要在添加任务前调用
+// ttl 为锁的有效期，执行期间自动续期，节点异常退出后最多等待 ttl 即可由其他节点执行
+func (tm *TaskManager) SetLocker(locker Locker, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.locker = &jobLocker{tm: tm, locker: locker, ttl: ttl}
+}
+
+// SetStore 设置暂停状态和执行记录的存储，需要在添加任务前调用
+func (tm *TaskManager) SetStore(store Store) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.store = store
+}
+
 func (tm *TaskManager) AddJob(name string, definition gocron.JobDefinition, task gocron.Task, options ...gocron.JobOption) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
@@ -50,23 +134,52 @@ func (tm *TaskManager) AddJob(name string, definition gocron.JobDefinition, task
 		tm.scheduler.RemoveJob(oldJob.Job.ID())
 	}
 
+	jobOptions := append([]gocron.JobOption{
+		gocron.WithName(name),
+		gocron.WithEventListeners(
+			gocron.BeforeJobRunsSkipIfBeforeFuncErrors(tm.beforeRun),
+			gocron.AfterJobRuns(func(_ uuid.UUID, jobName string) {
+				tm.afterRun(jobName, nil)
+			}),
+			gocron.AfterJobRunsWithError(func(_ uuid.UUID, jobName string, err error) {
+				tm.afterRun(jobName, err)
+			}),
+			gocron.AfterJobRunsWithPanic(func(_ uuid.UUID, jobName string, recoverData any) {
+				tm.afterPanic(jobName, recoverData)
+			}),
+			gocron.AfterLockError(func(_ uuid.UUID, jobName string, err error) {
+				tm.lockSkipped(jobName, err)
+			}),
+		),
+	}, options...)
+	if tm.locker != nil {
+		jobOptions = append(jobOptions, gocron.WithDistributedJobLocker(tm.locker))
+	}
+
 	job, err := tm.scheduler.NewJob(
 		definition,
 		task,
-		options...,
+		jobOptions...,
 	)
 
 	if err != nil {
 		return fmt.Errorf("添加任务失败: %v", err)
 	}
 
-	tm.jobs[name] = &JobInfo{
+	info := &JobInfo{
 		Job:        job,
 		Name:       name,
 		Definition: definition,
 		Task:       task,
 		Options:    options,
 	}
+	if nextRuns, err := job.NextRuns(2); err == nil && len(nextRuns) == 2 {
+		info.interval = nextRuns[1].Sub(nextRuns[0])
+		if info.interval > 0 {
+			info.offset = nextRuns[0].Sub(nextRuns[0].Truncate(info.interval))
+		}
+	}
+	tm.jobs[name] = info
 
 	return nil
 }
@@ -78,3 +191,191 @@ func (tm *TaskManager) GetJob(name string) *JobInfo {
 
 	return tm.jobs[name]
 }
+
+// beforeRun 暂停的任务跳过执行
+func (tm *TaskManager) beforeRun(_ uuid.UUID, name string) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	info, ok := tm.jobs[name]
+	if !ok {
+		return nil
+	}
+
+	if tm.store != nil && !info.manual && tm.store.IsPaused(name) {
+		return ErrJobPaused
+	}
+	if info.manual && info.manualResult != nil {
+		info.manualResult <- nil
+		info.manualResult = nil
+	}
+
+	info.running++
+	info.startedAt = time.Now()
+	return nil
+}
+
+// afterPanic 任务异常结束时与正常结束一样记录执行结果
+func (tm *TaskManager) afterPanic(name string, recoverData any) {
+	tm.afterRun(name, fmt.Errorf("panic: %v", recoverData))
+
+	tm.mu.Lock()
+	if info, ok := tm.jobs[name]; ok {
+		info.panicked = true
+	}
+	tm.mu.Unlock()
+}
+
+func (tm *TaskManager) afterRun(name string, err error) {
+	tm.mu.Lock()
+	info, ok := tm.jobs[name]
+	if !ok {
+		tm.mu.Unlock()
+		return
+	}
+	// 异常结束后 gocron 还会以错误的形式再通知一次，已经记录过的不再重复处理
+	if info.panicked && errors.Is(err, gocron.ErrPanicRecovered) {
+		info.panicked = false
+		tm.mu.Unlock()
+		return
+	}
+
+	run := &RunRecord{
+		Name:      name,
+		Node:      tm.node,
+		Trigger:   TriggerSchedule,
+		StartedAt: info.startedAt,
+		Duration:  time.Since(info.startedAt),
+	}
+	if info.manual {
+		run.Trigger = TriggerManual
+		info.manual = false
+	}
+	if err != nil {
+		run.Error = err.Error()
+		logger.SysError(fmt.Sprintf("任务 %s 执行失败: %s", name, run.Error))
+	}
+	if info.running > 0 {
+		info.running--
+	}
+	info.lastRun = run
+	store := tm.store
+	tm.mu.Unlock()
+
+	if store != nil {
+		store.SaveRun(run)
+	}
+}
+
+// lockSkipped 其他节点已持有锁或本周期已执行时跳过本次执行
+func (tm *TaskManager) lockSkipped(name string, err error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if info, ok := tm.jobs[name]; ok && info.manual {
+		info.manual = false
+		logger.SysLog(fmt.Sprintf("任务 %s 正在其他节点执行，跳过本次手动执行: %v", name, err))
+		if info.manualResult != nil {
+			info.manualResult <- ErrJobSkipped
+			info.manualResult = nil
+		}
+	}
+}
+
+// RunJob 立即执行一次任务，不影响原有的执行计划，等待任务开始执行，被其他节点的锁跳过时返回错误
+func (tm *TaskManager) RunJob(name string) error {
+	tm.mu.Lock()
+	info, ok := tm.jobs[name]
+	if !ok {
+		tm.mu.Unlock()
+		return ErrJobNotFound
+	}
+	result := make(chan error, 1)
+	info.manual = true
+	info.manualResult = result
+	tm.mu.Unlock()
+
+	if err := info.Job.RunNow(); err != nil {
+		tm.mu.Lock()
+		info.manual = false
+		info.manualResult = nil
+		tm.mu.Unlock()
+		return err
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(manualRunTimeout):
+		return nil
+	}
+}
+
+// PauseJob 暂停任务，暂停状态保存在存储中，对所有节点生效
+func (tm *TaskManager) PauseJob(name string) error {
+	return tm.setPaused(name, true)
+}
+
+func (tm *TaskManager) ResumeJob(name string) error {
+	return tm.setPaused(name, false)
+}
+
+func (tm *TaskManager) setPaused(name string, paused bool) error {
+	tm.mu.RLock()
+	_, ok := tm.jobs[name]
+	store := tm.store
+	tm.mu.RUnlock()
+
+	if !ok {
+		return ErrJobNotFound
+	}
+	if store == nil {
+		return errors.New("未配置任务存储")
+	}
+	return store.SetPaused(name, paused)
+}
+
+// ListJobs 获取所有任务的状态，上次执行信息优先使用存储中的记录，以包含其他节点的执行结果
+func (tm *TaskManager) ListJobs() []*JobStatus {
+	tm.mu.RLock()
+	infos := make([]*JobInfo, 0, len(tm.jobs))
+	for _, info := range tm.jobs {
+		infos = append(infos, info)
+	}
+	store := tm.store
+	tm.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	statuses := make([]*JobStatus, 0, len(infos))
+	for _, info := range infos {
+		status := &JobStatus{Name: info.Name}
+		if nextRun, err := info.Job.NextRun(); err == nil && !nextRun.IsZero() {
+			status.NextRun = nextRun.Unix()
+		}
+
+		tm.mu.RLock()
+		status.Running = info.running > 0
+		lastRun := info.lastRun
+		tm.mu.RUnlock()
+
+		if store != nil {
+			status.Paused = store.IsPaused(info.Name)
+			if stored := store.LastRun(info.Name); stored != nil {
+				lastRun = stored
+			}
+		}
+
+		if lastRun != nil {
+			status.LastRun = lastRun.StartedAt.Unix()
+			status.LastDuration = lastRun.Duration.Milliseconds()
+			status.LastError = lastRun.Error
+			status.LastNode = lastRun.Node
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
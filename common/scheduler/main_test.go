@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"context"
+	"one-api/common/logger"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// memoryLocker 测试用的内存锁，多个 TaskManager 共享模拟多个节点
+type memoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLock
+}
+
+type memoryLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+func newMemoryLocker() *memoryLocker {
+	return &memoryLocker{locks: make(map[string]memoryLock)}
+}
+
+func (l *memoryLocker) TryLock(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock, ok := l.locks[key]; ok && lock.expiresAt.After(time.Now()) {
+		return false, nil
+	}
+	l.locks[key] = memoryLock{token: token, expiresAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (l *memoryLocker) Refresh(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock, ok := l.locks[key]; ok && lock.token == token {
+		l.locks[key] = memoryLock{token: token, expiresAt: time.Now().Add(ttl)}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (l *memoryLocker) Unlock(_ context.Context, key, token string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lock, ok := l.locks[key]; ok && lock.token == token {
+		delete(l.locks, key)
+	}
+	return nil
+}
+
+func newTestManager(t *testing.T, locker Locker) *TaskManager {
+	logger.Logger = zap.NewNop()
+	s, err := gocron.NewScheduler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	t.Cleanup(func() { s.Shutdown() })
+
+	tm := &TaskManager{scheduler: s, jobs: make(map[string]*JobInfo), node: t.Name()}
+	if locker != nil {
+		tm.SetLocker(locker, time.Minute)
+	}
+	return tm
+}
+
+func TestScheduledRunOncePerPeriod(t *testing.T) {
+	locker := newMemoryLocker()
+	nodes := []*TaskManager{newTestManager(t, locker), newTestManager(t, locker)}
+	for _, tm := range nodes {
+		err := tm.AddJob("job", gocron.DurationJob(time.Hour), gocron.NewTask(func() {}))
+		assert.NoError(t, err)
+		assert.Equal(t, time.Hour, tm.GetJob("job").interval)
+	}
+
+	ctx := context.Background()
+	lock, err := nodes[0].locker.Lock(ctx, "job")
+	if !assert.NoError(t, err) {
+		return
+	}
+	// 执行期间其他节点无法获取锁
+	_, err = nodes[1].locker.Lock(ctx, "job")
+	assert.ErrorIs(t, err, ErrLockHeld)
+
+	assert.NoError(t, lock.Unlock(ctx))
+	// 执行结束后锁已释放，但同一周期的调度不会再次执行
+	_, err = nodes[1].locker.Lock(ctx, "job")
+	assert.ErrorIs(t, err, ErrLockHeld)
+
+	// 手动执行只要求没有其他节点正在执行
+	nodes[1].GetJob("job").manual = true
+	lock, err = nodes[1].locker.Lock(ctx, "job")
+	if assert.NoError(t, err) {
+		assert.NoError(t, lock.Unlock(ctx))
+	}
+}
+
+func TestRunJobSkippedByLock(t *testing.T) {
+	locker := newMemoryLocker()
+	tm := newTestManager(t, locker)
+	ran := make(chan struct{}, 1)
+	err := tm.AddJob("job", gocron.DurationJob(time.Hour), gocron.NewTask(func() { ran <- struct{}{} }))
+	assert.NoError(t, err)
+
+	locker.TryLock(context.Background(), "scheduler_lock:job", "other", time.Minute)
+	assert.ErrorIs(t, tm.RunJob("job"), ErrJobSkipped)
+
+	locker.Unlock(context.Background(), "scheduler_lock:job", "other")
+	assert.NoError(t, tm.RunJob("job"))
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
+}
+
+func TestPanicJobFinishesRun(t *testing.T) {
+	tm := newTestManager(t, nil)
+	err := tm.AddJob("job", gocron.DurationJob(time.Hour), gocron.NewTask(func() { panic("boom") }))
+	assert.NoError(t, err)
+
+	assert.NoError(t, tm.RunJob("job"))
+	assert.Eventually(t, func() bool {
+		tm.mu.RLock()
+		defer tm.mu.RUnlock()
+		info := tm.jobs["job"]
+		return info.lastRun != nil && info.running == 0
+	}, time.Second, 10*time.Millisecond)
+
+	info := tm.GetJob("job")
+	assert.Equal(t, TriggerManual, info.lastRun.Trigger)
+	assert.Contains(t, info.lastRun.Error, "boom")
+}
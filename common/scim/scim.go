@@ -0,0 +1,165 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	ContentType = "application/scim+json"
+
+	// RFC 7644 3.12 定义的错误类型
+	ErrorTypeInvalidFilter = "invalidFilter"
+	ErrorTypeUniqueness    = "uniqueness"
+	ErrorTypeMutability    = "mutability"
+	ErrorTypeInvalidSyntax = "invalidSyntax"
+	ErrorTypeInvalidPath   = "invalidPath"
+	ErrorTypeInvalidValue  = "invalidValue"
+
+	DefaultCount = 100
+	MaxCount     = 1000
+)
+
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    any      `json:"Resources"`
+}
+
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Filter 只支持身份提供商常用的 attr eq "value" 形式
+type Filter struct {
+	Attribute string
+	Value     string
+}
+
+func Respond(c *gin.Context, status int, data any) {
+	c.Header("Content-Type", ContentType)
+	c.JSON(status, data)
+}
+
+func RespondError(c *gin.Context, status int, scimType, detail string) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+func NewListResponse(resources any, total int64, startIndex, count int) *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// Pagination 解析 startIndex 和 count，startIndex 从 1 开始，返回数据库使用的 offset
+func Pagination(c *gin.Context) (startIndex, count, offset int) {
+	startIndex, err := strconv.Atoi(c.Query("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err = strconv.Atoi(c.Query("count"))
+	if err != nil || count < 0 {
+		count = DefaultCount
+	}
+	if count > MaxCount {
+		count = MaxCount
+	}
+	return startIndex, count, startIndex - 1
+}
+
+func ParseFilter(filter string) (*Filter, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, nil
+	}
+
+	attribute, rest, ok := strings.Cut(filter, " ")
+	if !ok {
+		return nil, fmt.Errorf("不支持的过滤条件: %s", filter)
+	}
+	operator, value, ok := strings.Cut(strings.TrimSpace(rest), " ")
+	if !ok || !strings.EqualFold(operator, "eq") {
+		return nil, fmt.Errorf("不支持的过滤条件: %s", filter)
+	}
+	value, err := strconv.Unquote(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("过滤条件的值需要使用双引号: %s", filter)
+	}
+
+	return &Filter{Attribute: attribute, Value: value}, nil
+}
+
+// ParseBool 解析布尔值，部分身份提供商会以 "True"/"False" 字符串发送
+func ParseBool(raw json.RawMessage) (bool, error) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return false, err
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(strings.ToLower(v))
+	}
+	return false, errors.New("无效的布尔值")
+}
+
+// MemberFilterValue 解析 members[value eq "1"] 形式的路径，返回成员 ID
+func MemberFilterValue(path string) (string, bool) {
+	inner, ok := strings.CutPrefix(path, "members[")
+	if !ok {
+		return "", false
+	}
+	inner, ok = strings.CutSuffix(inner, "]")
+	if !ok {
+		return "", false
+	}
+	filter, err := ParseFilter(inner)
+	if err != nil || filter == nil || filter.Attribute != "value" {
+		return "", false
+	}
+	return filter.Value, true
+}
@@ -0,0 +1,72 @@
+package secret
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Mask 隐藏密钥中间部分，只保留前后几位用于辨认
+func Mask(value string) string {
+	if len(value) <= 12 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:6] + "..." + value[len(value)-4:]
+}
+
+// MaskLines 按行隐藏，用于一行一个密钥的字段
+func MaskLines(value string) string {
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		lines[i] = Mask(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// RestoreMaskedLines 将提交的值中未修改的隐藏行还原为原值，提交的值为空时保留原值
+// 管理接口只返回隐藏后的密钥，编辑时原样提交回来的行不能覆盖真实的密钥
+func RestoreMaskedLines(value, original string) string {
+	if value == "" {
+		return original
+	}
+
+	masked := make(map[string][]string)
+	for _, line := range strings.Split(original, "\n") {
+		if line == "" {
+			continue
+		}
+		mask := Mask(line)
+		masked[mask] = append(masked[mask], line)
+	}
+
+	lines := strings.Split(value, "\n")
+	for i, line := range lines {
+		if originals := masked[line]; len(originals) > 0 {
+			lines[i] = originals[0]
+			masked[line] = originals[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// IsSecretField 根据字段名判断是否为密钥类字段，如 private_key、secret_key、webhook_secret
+func IsSecretField(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "key") || strings.Contains(name, "secret")
+}
+
+// MaskJSONFields 隐藏 JSON 对象中的密钥类字段，无法解析时隐藏整个值
+func MaskJSONFields(value string) string {
+	fields := make(map[string]any)
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return Mask(value)
+	}
+
+	for name, field := range fields {
+		if str, ok := field.(string); ok && IsSecretField(name) {
+			fields[name] = Mask(str)
+		}
+	}
+
+	masked, _ := json.Marshal(fields)
+	return string(masked)
+}
@@ -0,0 +1,245 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"one-api/common/logger"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// 加密后的格式为 前缀 + 主密钥ID + ":" + base64(加密后的数据密钥 + nonce + 密文)
+// 每个值使用随机生成的数据密钥加密，数据密钥再由主密钥加密（信封加密），轮换主密钥时只需要重新加密数据
+const Prefix = "ev1:"
+
+const (
+	keySize     = 32
+	wrappedSize = 12 + keySize + 16 // nonce + 数据密钥 + tag
+)
+
+var (
+	ErrKeyNotFound = errors.New("找不到用于解密的主密钥")
+	ErrDecrypt     = errors.New("解密失败")
+)
+
+type keyring struct {
+	keys     map[string][]byte
+	activeId string
+}
+
+var (
+	ring *keyring
+	mu   sync.RWMutex
+)
+
+// Init 从配置加载主密钥，未配置主密钥时不加密，已加密的数据无法读取
+func Init() {
+	r, err := loadKeyring()
+	if err != nil {
+		logger.FatalLog("failed to load encryption keys: " + err.Error())
+	}
+
+	mu.Lock()
+	ring = r
+	mu.Unlock()
+
+	if r != nil {
+		logger.SysLog(fmt.Sprintf("encryption at rest enabled, active key: %s", r.activeId))
+	}
+}
+
+func loadKeyring() (*keyring, error) {
+	var lines []string
+	if masterKey := viper.GetString("encryption.master_key"); masterKey != "" {
+		lines = append(lines, masterKey)
+	}
+
+	if keyFile := viper.GetString("encryption.key_file"); keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, strings.Split(string(data), "\n")...)
+	}
+
+	r := &keyring{keys: make(map[string][]byte)}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, encoded := "default", line
+		if parts := strings.SplitN(line, ":", 2); len(parts) == 2 {
+			id, encoded = parts[0], parts[1]
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("主密钥 %s 必须是 base64 编码的 32 字节密钥", id)
+		}
+		if _, ok := r.keys[id]; ok {
+			return nil, fmt.Errorf("主密钥 %s 重复", id)
+		}
+
+		r.keys[id] = key
+		if r.activeId == "" {
+			r.activeId = id
+		}
+	}
+
+	if len(r.keys) == 0 {
+		return nil, nil
+	}
+
+	if activeId := viper.GetString("encryption.active_key_id"); activeId != "" {
+		if _, ok := r.keys[activeId]; !ok {
+			return nil, fmt.Errorf("找不到启用的主密钥 %s", activeId)
+		}
+		r.activeId = activeId
+	}
+
+	return r, nil
+}
+
+func getKeyring() *keyring {
+	mu.RLock()
+	defer mu.RUnlock()
+	return ring
+}
+
+func Enabled() bool {
+	return getKeyring() != nil
+}
+
+// ActiveKeyId 当前用于加密的主密钥ID，未开启加密时为空
+func ActiveKeyId() string {
+	if r := getKeyring(); r != nil {
+		return r.activeId
+	}
+	return ""
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Encrypt 使用当前主密钥加密，未开启加密或值为空时原样返回
+func Encrypt(plaintext string) (string, error) {
+	r := getKeyring()
+	if r == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(r.keys[r.activeId], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return Prefix + r.activeId + ":" + base64.StdEncoding.EncodeToString(append(wrapped, ciphertext...)), nil
+}
+
+// Decrypt 解密，未加密的值原样返回，以兼容开启加密前写入的数据
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, Prefix), ":", 2)
+	if len(parts) != 2 {
+		return "", ErrDecrypt
+	}
+
+	r := getKeyring()
+	if r == nil {
+		return "", ErrKeyNotFound
+	}
+	masterKey, ok := r.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrKeyNotFound, parts[0])
+	}
+
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(data) < wrappedSize {
+		return "", ErrDecrypt
+	}
+
+	dataKey, err := open(masterKey, data[:wrappedSize])
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, data[wrappedSize:])
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation 未加密或不是使用当前主密钥加密的值需要重新加密
+func NeedsRotation(value string) bool {
+	r := getKeyring()
+	if r == nil || value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, Prefix+r.activeId+":")
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateKey 生成一个新的主密钥，格式与密钥文件中的一行一致
+func GenerateKey(id string) (string, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key), nil
+}
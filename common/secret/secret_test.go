@@ -0,0 +1,190 @@
+package secret
+
+import (
+	"encoding/base64"
+	"one-api/common/logger"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func setupKeys(t *testing.T, activeId string, keys ...string) {
+	logger.Logger = zap.NewNop()
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(keyFile, []byte(strings.Join(keys, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("encryption.master_key", "")
+	viper.Set("encryption.key_file", keyFile)
+	viper.Set("encryption.active_key_id", activeId)
+	t.Cleanup(func() {
+		viper.Set("encryption.key_file", "")
+		viper.Set("encryption.active_key_id", "")
+		Init()
+	})
+	Init()
+}
+
+func newKey(t *testing.T, id string) string {
+	key, err := GenerateKey(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptDisabled(t *testing.T) {
+	setupKeys(t, "")
+
+	assert.False(t, Enabled())
+	value, err := Encrypt("sk-plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk-plain", value)
+	assert.False(t, NeedsRotation(value))
+
+	_, err = Decrypt(Prefix + "k1:AAAA")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	setupKeys(t, "", newKey(t, "k1"))
+
+	assert.True(t, Enabled())
+	assert.Equal(t, "k1", ActiveKeyId())
+
+	for _, plaintext := range []string{"sk-test", "多行\n密钥", strings.Repeat("x", 4096)} {
+		first, err := Encrypt(plaintext)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		second, _ := Encrypt(plaintext)
+		assert.True(t, strings.HasPrefix(first, Prefix+"k1:"))
+		assert.NotEqual(t, first, second, "每次加密使用新的数据密钥和 nonce")
+		assert.NotContains(t, first, plaintext)
+
+		decrypted, err := Decrypt(first)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, decrypted)
+	}
+
+	empty, err := Encrypt("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", empty)
+
+	plain, err := Decrypt("sk-written-before-encryption")
+	assert.NoError(t, err)
+	assert.Equal(t, "sk-written-before-encryption", plain)
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	setupKeys(t, "", newKey(t, "k1"))
+
+	value, err := Encrypt("sk-test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix+"k1:"))
+	tamper := func(offset int) string {
+		changed := append([]byte{}, data...)
+		changed[offset] ^= 0xff
+		return Prefix + "k1:" + base64.StdEncoding.EncodeToString(changed)
+	}
+
+	tests := map[string]struct {
+		value string
+		err   error
+	}{
+		"missing key id":    {value: Prefix + "AAAA", err: ErrDecrypt},
+		"unknown key id":    {value: strings.Replace(value, "k1:", "k2:", 1), err: ErrKeyNotFound},
+		"invalid base64":    {value: Prefix + "k1:!!!", err: ErrDecrypt},
+		"truncated":         {value: Prefix + "k1:" + base64.StdEncoding.EncodeToString(data[:wrappedSize-1]), err: ErrDecrypt},
+		"tampered data key": {value: tamper(20), err: ErrDecrypt},
+		"tampered content":  {value: tamper(len(data) - 1), err: ErrDecrypt},
+	}
+	for name, test := range tests {
+		_, err := Decrypt(test.value)
+		assert.ErrorIs(t, err, test.err, name)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, newKeyLine := newKey(t, "old"), newKey(t, "new")
+	setupKeys(t, "", oldKey, newKeyLine)
+	assert.Equal(t, "old", ActiveKeyId(), "未指定时使用第一个主密钥")
+
+	encrypted, err := Encrypt("sk-test")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, NeedsRotation(encrypted))
+	assert.True(t, NeedsRotation("sk-plain"))
+	assert.False(t, NeedsRotation(""))
+
+	setupKeys(t, "new", oldKey, newKeyLine)
+	assert.Equal(t, "new", ActiveKeyId())
+	assert.True(t, NeedsRotation(encrypted))
+
+	// 旧密钥加密的值仍然可以读取，重新加密后使用新密钥
+	decrypted, err := Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "sk-test", decrypted)
+	rotated, err := Encrypt(decrypted)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rotated, Prefix+"new:"))
+	assert.False(t, NeedsRotation(rotated))
+
+	// 移除旧密钥后只能读取重新加密的值
+	setupKeys(t, "", newKeyLine)
+	_, err = Decrypt(encrypted)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	decrypted, err = Decrypt(rotated)
+	assert.NoError(t, err)
+	assert.Equal(t, "sk-test", decrypted)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	valid := newKey(t, "k1")
+	tests := map[string]struct {
+		lines    []string
+		activeId string
+		err      bool
+		wantId   string
+	}{
+		"comments and blank lines":    {lines: []string{"# keys", "", valid}, wantId: "k1"},
+		"key without id":              {lines: []string{strings.TrimPrefix(valid, "k1:")}, wantId: "default"},
+		"active key":                  {lines: []string{valid, newKey(t, "k2")}, activeId: "k2", wantId: "k2"},
+		"unknown active key":          {lines: []string{valid}, activeId: "k2", err: true},
+		"duplicate id":                {lines: []string{valid, newKey(t, "k1")}, err: true},
+		"short key":                   {lines: []string{"k1:" + base64.StdEncoding.EncodeToString([]byte("short"))}, err: true},
+		"invalid base64":              {lines: []string{"k1:!!!"}, err: true},
+		"no keys disables encryption": {lines: []string{"# empty"}},
+	}
+	for name, test := range tests {
+		keyFile := filepath.Join(t.TempDir(), "keys")
+		os.WriteFile(keyFile, []byte(strings.Join(test.lines, "\n")), 0600)
+		viper.Set("encryption.master_key", "")
+		viper.Set("encryption.key_file", keyFile)
+		viper.Set("encryption.active_key_id", test.activeId)
+
+		r, err := loadKeyring()
+		if test.err {
+			assert.Error(t, err, name)
+			continue
+		}
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		if test.wantId == "" {
+			assert.Nil(t, r, name)
+		} else if assert.NotNil(t, r, name) {
+			assert.Equal(t, test.wantId, r.activeId, name)
+		}
+	}
+	viper.Set("encryption.key_file", "")
+	viper.Set("encryption.active_key_id", "")
+}
@@ -34,3 +34,8 @@ func (s *Storage) addDrive(drive StorageDrive) {
 		s.drives[driveName] = drive
 	}
 }
+
+// Enabled 是否配置了可用的存储
+func Enabled() bool {
+	return len(storageDrives.drives) > 0
+}
@@ -0,0 +1,53 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"one-api/common/totp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 6238 附录 B 的 SHA1 测试向量，取后 6 位
+func TestGenerate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1234567890:  "005924",
+		20000000000: "353130",
+	}
+	for timestamp, expected := range cases {
+		code, err := totp.Generate(secret, time.Unix(timestamp, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, _ := totp.Generate(secret, now.Add(-totp.Period*time.Second))
+	step, ok := totp.Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Unix()/totp.Period-1, step)
+
+	code, _ = totp.Generate(secret, now.Add(-3*totp.Period*time.Second))
+	_, ok = totp.Validate(secret, code, now)
+	assert.False(t, ok)
+
+	_, ok = totp.Validate(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := totp.GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}$`, code)
+	}
+}
@@ -0,0 +1,128 @@
+package tracing
+
+import (
+	"context"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "one-hub"
+
+var (
+	Enabled        = false
+	tracerProvider *sdktrace.TracerProvider
+)
+
+// InitTracing 初始化 OTLP 链路追踪，未开启时使用空实现，不产生任何开销
+func InitTracing() {
+	if !viper.GetBool("tracing.enable") {
+		return
+	}
+
+	var options []otlptracehttp.Option
+	if endpoint := viper.GetString("tracing.endpoint"); endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	if headers := viper.GetStringMapString("tracing.headers"); len(headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(headers))
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		logger.SysError("failed to create trace exporter: " + err.Error())
+		return
+	}
+
+	serviceName := viper.GetString("tracing.service_name")
+	if serviceName == "" {
+		serviceName = "one-hub"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", config.Version),
+	))
+	if err != nil {
+		logger.SysError("failed to create trace resource: " + err.Error())
+		return
+	}
+
+	sampleRatio := 1.0
+	if viper.IsSet("tracing.sample_ratio") {
+		sampleRatio = viper.GetFloat64("tracing.sample_ratio")
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	Enabled = true
+	logger.SysLog("tracing enabled, service name: " + serviceName)
+}
+
+// Shutdown 上报剩余的 span
+func Shutdown() {
+	if tracerProvider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		logger.SysError("failed to shutdown tracer provider: " + err.Error())
+	}
+}
+
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Inject 将当前链路信息写入请求头，传递给上游
+func Inject(ctx context.Context, header propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, header)
+}
+
+// Extract 从请求头中读取客户端传入的链路信息
+func Extract(ctx context.Context, header propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, header)
+}
+
+// RecordError 记录错误并标记 span 失败
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// AddEvent 在当前 span 上记录事件
+func AddEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(ctx).AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+// TraceID 获取当前链路ID，未开启追踪时为空
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
@@ -102,3 +102,43 @@ func ValidateToken(token string) (tokenID, userID int, err error) {
 
 	return int(numbers[0]), int(numbers[1]), nil
 }
+
+// EphemeralKeyPrefix 临时密钥前缀，临时密钥由载荷和签名组成，无需存储即可校验
+const EphemeralKeyPrefix = "ek-"
+
+func signEphemeralPayload(payload []byte) []byte {
+	h := hmac.New(sha256.New, jwtSecretBytes)
+	// 与普通令牌的签名区分，避免签名被互相复用
+	h.Write([]byte("ephemeral_key:"))
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func GenerateEphemeralKey(payload []byte) string {
+	return EphemeralKeyPrefix + base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signEphemeralPayload(payload))
+}
+
+// ParseEphemeralKey 校验临时密钥的签名并返回载荷
+func ParseEphemeralKey(key string) ([]byte, error) {
+	if len(key) <= len(EphemeralKeyPrefix) || key[:len(EphemeralKeyPrefix)] != EphemeralKeyPrefix {
+		return nil, fmt.Errorf("无效的临时密钥")
+	}
+	parts := bytes.SplitN([]byte(key[len(EphemeralKeyPrefix):]), []byte("."), 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("无效的临时密钥")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("无效的临时密钥")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("签名解码失败")
+	}
+
+	if !hmac.Equal(signature, signEphemeralPayload(payload)) {
+		return nil, fmt.Errorf("签名验证失败")
+	}
+	return payload, nil
+}
@@ -262,6 +262,36 @@ func String2Int64(str string) int64 {
 	return num
 }
 
+// SplitNonEmpty 按逗号分割，去除空白项
+func SplitNonEmpty(str string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SplitInts 按逗号分割为整数，忽略无效项
+func SplitInts(str string) []int {
+	nums := make([]int, 0)
+	for _, item := range SplitNonEmpty(str) {
+		if num, err := strconv.Atoi(item); err == nil {
+			nums = append(nums, num)
+		}
+	}
+	return nums
+}
+
+func JoinInts(nums []int) string {
+	items := make([]string, 0, len(nums))
+	for _, num := range nums {
+		items = append(items, strconv.Itoa(num))
+	}
+	return strings.Join(items, ",")
+}
+
 func IsFileExist(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil || os.IsExist(err)
@@ -0,0 +1,354 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	maxAttempts    = 8
+	requestTimeout = 10 * time.Second
+	leaseSeconds   = 60
+	retryBatchSize = 100
+	retryWorkers   = 10
+)
+
+// Payload 统一的任务回调内容，Suno/Kling/Midjourney 使用相同结构
+type Payload struct {
+	Event      string `json:"event"`
+	Source     string `json:"source"`
+	TaskId     string `json:"task_id"`
+	Action     string `json:"action"`
+	Status     string `json:"status"`
+	Progress   int    `json:"progress"`
+	FailReason string `json:"fail_reason,omitempty"`
+	SubmitTime int64  `json:"submit_time"`
+	StartTime  int64  `json:"start_time"`
+	FinishTime int64  `json:"finish_time"`
+	Data       any    `json:"data,omitempty"`
+	Timestamp  int64  `json:"timestamp"`
+}
+
+var secretLock sync.Mutex
+
+// signingKey 获取回调签名主密钥，未配置时自动生成并保存
+func signingKey() string {
+	secretLock.Lock()
+	defer secretLock.Unlock()
+
+	if config.WebhookSecret == "" {
+		err := model.UpdateOption("WebhookSecret", utils.GetRandomString(32))
+		if err != nil {
+			logger.SysError("failed to save webhook secret: " + err.Error())
+		}
+	}
+	return config.WebhookSecret
+}
+
+// UserSecret 获取用户的回调签名密钥，每个用户的密钥互不相同
+func UserSecret(userId int) string {
+	mac := hmac.New(sha256.New, []byte(signingKey()))
+	mac.Write([]byte(fmt.Sprintf("user:%d", userId)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign 计算签名：HMAC-SHA256(secret, timestamp + "." + body)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidHookURL 只允许 http/https 回调地址，且不能是内网地址
+// 域名在投递时解析，解析结果由 hookClient 再次检查
+func ValidHookURL(hook string) bool {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return false
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && isBlockedIP(ip) {
+		return false
+	}
+	return true
+}
+
+// blockedNets 标准库未覆盖的保留地址段
+var blockedNets = []*net.IPNet{
+	// 运营商级 NAT
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	// 本网络，部分系统上 0.x.x.x 会连接到本机
+	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	// NAT64，可以转换为任意 IPv4 地址（包括内网地址）
+	{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)},
+}
+
+// isBlockedIP 回环、链路本地（含云厂商元数据地址）、内网和保留地址不允许作为回调地址
+func isBlockedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range blockedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hookClient 在建立连接时检查实际连接的地址，重定向和 DNS 重绑定也无法访问内网
+var hookClient = &http.Client{
+	Timeout: requestTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: requestTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || isBlockedIP(ip) {
+					return fmt.Errorf("webhook address %s is not allowed", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   requestTimeout,
+		ResponseHeaderTimeout: requestTimeout,
+		MaxIdleConnsPerHost:   retryWorkers,
+	},
+}
+
+// NotifyTask 异步任务状态变化时投递回调
+func NotifyTask(task *model.Task, oldStatus model.TaskStatus) {
+	if task.NotifyHook == "" || task.Status == oldStatus {
+		return
+	}
+
+	var data any
+	if len(task.Data) > 0 {
+		data = json.RawMessage(task.Data)
+	}
+
+	enqueue(task.UserId, task.NotifyHook, &Payload{
+		Source:     task.Platform,
+		TaskId:     task.TaskID,
+		Action:     task.Action,
+		Status:     string(task.Status),
+		Progress:   task.Progress,
+		FailReason: task.FailReason,
+		SubmitTime: task.SubmitTime,
+		StartTime:  task.StartTime,
+		FinishTime: task.FinishTime,
+		Data:       data,
+	})
+}
+
+// NotifyMidjourney Midjourney 任务状态变化时投递回调
+func NotifyMidjourney(task *model.Midjourney, oldStatus string) {
+	if task.NotifyHook == "" || task.Status == oldStatus || !config.MjNotifyEnabled {
+		return
+	}
+
+	data := map[string]any{
+		"image_url":   task.ImageUrl,
+		"prompt":      task.Prompt,
+		"prompt_en":   task.PromptEn,
+		"description": task.Description,
+	}
+	if task.Buttons != "" {
+		data["buttons"] = json.RawMessage(task.Buttons)
+	}
+
+	progress, _ := strconv.Atoi(strings.TrimSuffix(task.Progress, "%"))
+	enqueue(task.UserId, task.NotifyHook, &Payload{
+		Source:     "midjourney",
+		TaskId:     task.MjId,
+		Action:     task.Action,
+		Status:     task.Status,
+		Progress:   progress,
+		FailReason: task.FailReason,
+		// Midjourney 的时间为毫秒
+		SubmitTime: task.SubmitTime / 1000,
+		StartTime:  task.StartTime / 1000,
+		FinishTime: task.FinishTime / 1000,
+		Data:       data,
+	})
+}
+
+func enqueue(userId int, hook string, payload *Payload) {
+	payload.Event = "task." + strings.ToLower(payload.Status)
+	payload.Timestamp = utils.GetTimestamp()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.SysError("failed to marshal webhook payload: " + err.Error())
+		return
+	}
+
+	delivery := &model.WebhookDelivery{
+		UserId:        userId,
+		Source:        payload.Source,
+		TaskId:        payload.TaskId,
+		Event:         payload.Event,
+		Url:           hook,
+		Payload:       string(body),
+		Status:        model.WebhookDeliveryStatusPending,
+		NextRetryTime: payload.Timestamp,
+	}
+	if err := delivery.Insert(); err != nil {
+		logger.SysError("failed to insert webhook delivery: " + err.Error())
+		return
+	}
+
+	go deliver(delivery)
+}
+
+// Redeliver 重新投递一条历史回调，生成新的投递记录
+func Redeliver(userId, id int) (*model.WebhookDelivery, error) {
+	old, err := model.GetUserWebhookDelivery(userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &model.WebhookDelivery{
+		UserId:        old.UserId,
+		Source:        old.Source,
+		TaskId:        old.TaskId,
+		Event:         old.Event,
+		Url:           old.Url,
+		Payload:       old.Payload,
+		Status:        model.WebhookDeliveryStatusPending,
+		NextRetryTime: utils.GetTimestamp(),
+	}
+	if err := delivery.Insert(); err != nil {
+		return nil, err
+	}
+
+	go deliver(delivery)
+	return delivery, nil
+}
+
+// RetryPendingDeliveries 重试到期的失败投递，由定时任务调用
+func RetryPendingDeliveries() {
+	deliveries, err := model.GetPendingWebhookDeliveries(retryBatchSize)
+	if err != nil {
+		logger.SysError("failed to get pending webhook deliveries: " + err.Error())
+		return
+	}
+
+	sem := make(chan struct{}, retryWorkers)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(delivery *model.WebhookDelivery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			deliver(delivery)
+		}(delivery)
+	}
+	wg.Wait()
+}
+
+func deliver(delivery *model.WebhookDelivery) {
+	ok, err := delivery.Lease(leaseSeconds)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to lease webhook delivery %d: %s", delivery.Id, err.Error()))
+		return
+	}
+	if !ok {
+		return
+	}
+
+	delivery.Attempts++
+	delivery.ResponseCode, err = send(delivery)
+	if err == nil {
+		delivery.Status = model.WebhookDeliveryStatusSuccess
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = model.WebhookDeliveryStatusFailed
+		} else {
+			delivery.NextRetryTime = utils.GetTimestamp() + backoff(delivery.Attempts)
+		}
+	}
+
+	if err := delivery.SaveResult(); err != nil {
+		logger.SysError(fmt.Sprintf("failed to save webhook delivery %d: %s", delivery.Id, err.Error()))
+	}
+}
+
+// backoff 指数退避：30s, 60s, 120s ... 最长 1 小时
+func backoff(attempts int) int64 {
+	seconds := int64(30) << (attempts - 1)
+	if seconds > 3600 || seconds <= 0 {
+		seconds = 3600
+	}
+	return seconds
+}
+
+func send(delivery *model.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := utils.GetTimestamp()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "one-hub-webhook")
+	req.Header.Set("X-Webhook-Id", strconv.Itoa(delivery.Id))
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(UserSecret(delivery.UserId), timestamp, body))
+
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// 响应内容不保存，避免回调地址被用来读取其他服务的响应
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"one-api/common/config"
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidHookURL(t *testing.T) {
+	valid := []string{
+		"https://example.com/hook",
+		"http://8.8.8.8:8080/hook",
+	}
+	for _, hook := range valid {
+		assert.True(t, ValidHookURL(hook), hook)
+	}
+
+	invalid := []string{
+		"ftp://example.com/hook",
+		"https:///hook",
+		"http://localhost/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://172.16.0.1/hook",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+		"http://0.1.2.3/hook",
+		"http://[fd00::1]/hook",
+		"http://[64:ff9b::a9fe:a9fe]/hook",
+		"http://[64:ff9b::7f00:1]/hook",
+	}
+	for _, hook := range invalid {
+		assert.False(t, ValidHookURL(hook), hook)
+	}
+}
+
+func TestSendBlocksPrivateAddress(t *testing.T) {
+	config.WebhookSecret = "secret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("internal response"))
+	}))
+	defer server.Close()
+
+	code, err := send(&model.WebhookDelivery{Id: 1, UserId: 1, Url: server.URL, Payload: "{}"})
+	assert.Equal(t, 0, code)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not allowed")
+		assert.NotContains(t, err.Error(), "internal response")
+	}
+}
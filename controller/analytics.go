@@ -2,6 +2,7 @@ package controller
 
 import (
 	"net/http"
+	"one-api/common"
 	"one-api/model"
 	"strconv"
 	"time"
@@ -91,3 +92,26 @@ func GetStatisticsDetail(c *gin.Context) {
 		"data":    statisticsDetail,
 	})
 }
+
+// GetMarginStatistics 按渠道、模型或日期查询收入与上游成本
+func GetMarginStatistics(c *gin.Context) {
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	groupType := c.Query("group_type")
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+
+	startDate := time.Unix(startTimestamp, 0).Format("2006-01-02")
+	endDate := time.Unix(endTimestamp, 0).Format("2006-01-02")
+
+	statistics, err := model.GetMarginStatisticsByPeriod(startDate, endDate, groupType, channelId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    statistics,
+	})
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAuditLogsList(c *gin.Context) {
+	var params model.AuditLogQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	logs, err := model.GetAuditLogsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+	})
+}
+
+// ExportAuditLogs 按查询条件导出审计日志为CSV
+func ExportAuditLogs(c *gin.Context) {
+	var params model.AuditLogQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	logs, err := model.ExportAuditLogs(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	filename := fmt.Sprintf("audit_logs_%s.csv", time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	writer := csv.NewWriter(c.Writer)
+	defer writer.Flush()
+
+	header := []string{
+		"ID",
+		"Time",
+		"User ID",
+		"Username",
+		"Role",
+		"IP",
+		"Method",
+		"Path",
+		"Action",
+		"Target Type",
+		"Target ID",
+		"Success",
+		"Message",
+		"Diff",
+	}
+	if err := writer.Write(header); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to write CSV header: %v", err))
+		return
+	}
+
+	for _, log := range logs {
+		row := []string{
+			strconv.Itoa(log.Id),
+			time.Unix(log.CreatedAt, 0).Format(time.RFC3339),
+			strconv.Itoa(log.UserId),
+			log.Username,
+			strconv.Itoa(log.Role),
+			log.Ip,
+			log.Method,
+			log.Path,
+			log.Action,
+			log.TargetType,
+			log.TargetId,
+			strconv.FormatBool(log.Success),
+			log.Message,
+			log.Diff,
+		}
+		if err := writer.Write(row); err != nil {
+			common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("failed to write CSV row: %v", err))
+			return
+		}
+	}
+}
+
+// DeleteAuditLogs 清理指定时间之前的审计日志，只有超级管理员可以操作
+func DeleteAuditLogs(c *gin.Context) {
+	before, _ := strconv.ParseInt(c.Query("before"), 10, 64)
+	if before <= 0 {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("before 参数无效"))
+		return
+	}
+
+	count, err := model.DeleteAuditLogsBefore(before)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
@@ -47,6 +47,41 @@ type OpenAIUsageResponse struct {
 }
 
 func updateChannelBalance(channel *model.Channel) (float64, error) {
+	if channel.IsMultiKey() {
+		return updateMultiKeyChannelBalance(channel, false)
+	}
+	return queryChannelBalance(channel)
+}
+
+// updateMultiKeyChannelBalance 逐个查询密钥余额，渠道余额为所有密钥余额之和，disableEmpty 为 true 时禁用余额不足的密钥
+func updateMultiKeyChannelBalance(channel *model.Channel, disableEmpty bool) (float64, error) {
+	var total float64
+	var lastErr error
+	succeeded := 0
+	for _, key := range channel.GetKeys() {
+		keyed := *channel
+		keyed.Key = key
+		balance, err := queryChannelBalance(&keyed)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		succeeded++
+		total += balance
+		model.UpdateChannelKeyBalance(channel.Id, model.HashChannelKey(key), balance)
+		if disableEmpty && balance <= 0 {
+			DisableChannelOrKey(&keyed, "余额不足", true)
+		}
+	}
+
+	if succeeded == 0 {
+		return 0, lastErr
+	}
+	channel.UpdateBalance(total)
+	return total, nil
+}
+
+func queryChannelBalance(channel *model.Channel) (float64, error) {
 	req, err := http.NewRequest("POST", "/balance", nil)
 	if err != nil {
 		return 0, err
@@ -116,6 +151,11 @@ func updateAllChannelsBalance() error {
 		if channel.Type != config.ChannelTypeOpenAI && channel.Type != config.ChannelTypeCustom {
 			continue
 		}
+		if channel.IsMultiKey() {
+			updateMultiKeyChannelBalance(channel, true)
+			time.Sleep(config.RequestInterval)
+			continue
+		}
 		balance, err := updateChannelBalance(channel)
 		if err != nil {
 			continue
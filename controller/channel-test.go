@@ -8,8 +8,6 @@ import (
 	"net/http/httptest"
 	"one-api/common/config"
 	"one-api/common/logger"
-	"one-api/common/notify"
-	"one-api/common/utils"
 	"one-api/model"
 	"one-api/providers"
 	providers_base "one-api/providers/base"
@@ -17,7 +15,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -191,18 +188,34 @@ func TestChannel(c *gin.Context) {
 		return
 	}
 	testModel := c.Query("model")
-	tik := time.Now()
-	openaiErr, err := testChannel(channel, testModel)
-	tok := time.Now()
-	milliseconds := tok.Sub(tik).Milliseconds()
+	if testModel == "" {
+		testModel = channel.TestModel
+	}
+	if testModel == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "请填写测速模型后再试",
+		})
+		return
+	}
+
+	result := probeChannelModel(channel, testModel, false)
+	if err := model.CreateChannelProbes([]*model.ChannelProbe{result.probe}); err != nil {
+		logger.SysError("failed to save channel probe: " + err.Error())
+	}
+	openaiErr, err := result.openaiErr, result.err
+	milliseconds := int64(result.probe.ResponseTime)
 	consumedTime := float64(milliseconds) / 1000.0
 
 	success := false
 	msg := ""
 	if openaiErr != nil {
-		if ShouldDisableChannel(channel.Type, openaiErr) {
+		if result.probe.ErrorClass == model.ProbeErrorModelNotFound && config.AutomaticDisableChannelEnabled {
+			msg = fmt.Sprintf("测速失败，模型 %s 已被禁用，原因：%s", testModel, err.Error())
+			model.DisableChannelModel(channel.Id, testModel, err.Error())
+		} else if ShouldDisableChannel(channel.Type, openaiErr) && !result.disabledKey {
 			msg = fmt.Sprintf("测速失败，已被禁用，原因：%s", err.Error())
-			DisableChannel(channel.Id, channel.Name, err.Error(), false)
+			DisableChannelOrKey(result.channel, err.Error(), false)
 		} else {
 			msg = fmt.Sprintf("测速失败，原因：%s", err.Error())
 		}
@@ -211,6 +224,13 @@ func TestChannel(c *gin.Context) {
 	} else {
 		success = true
 		msg = "测速成功"
+		if config.AutomaticEnableChannelEnabled && model.ChannelGroup.IsModelDisabled(channel.Id, testModel) {
+			model.EnableChannelModel(channel.Id, testModel)
+			msg = "测速成功，模型已恢复"
+		}
+		if enableProbedKey(result) {
+			msg += "，密钥已恢复"
+		}
 		go channel.UpdateResponseTime(milliseconds)
 	}
 
@@ -221,89 +241,6 @@ func TestChannel(c *gin.Context) {
 	})
 }
 
-var testAllChannelsLock sync.Mutex
-var testAllChannelsRunning bool = false
-
-func testAllChannels(isNotify bool) error {
-	testAllChannelsLock.Lock()
-	if testAllChannelsRunning {
-		testAllChannelsLock.Unlock()
-		return errors.New("测试已在运行中")
-	}
-	testAllChannelsRunning = true
-	testAllChannelsLock.Unlock()
-	channels, err := model.GetAllChannels()
-	if err != nil {
-		return err
-	}
-	var disableThreshold = int64(config.ChannelDisableThreshold * 1000)
-	if disableThreshold == 0 {
-		disableThreshold = 10000000 // a impossible value
-	}
-	go func() {
-		var sendMessage string
-		for _, channel := range channels {
-			time.Sleep(config.RequestInterval)
-
-			isChannelEnabled := channel.Status == config.ChannelStatusEnabled
-			sendMessage += fmt.Sprintf("**通道 %s - #%d - %s** : \n\n", utils.EscapeMarkdownText(channel.Name), channel.Id, channel.StatusToStr())
-			tik := time.Now()
-			openaiErr, err := testChannel(channel, "")
-			tok := time.Now()
-			milliseconds := tok.Sub(tik).Milliseconds()
-			// 通道为禁用状态，并且还是请求错误 或者 响应时间超过阈值 直接跳过，也不需要更新响应时间。
-			if !isChannelEnabled {
-				if err != nil {
-					sendMessage += fmt.Sprintf("- 测试报错: %s \n\n- 无需改变状态，跳过\n\n", utils.EscapeMarkdownText(err.Error()))
-					continue
-				}
-				if milliseconds > disableThreshold {
-					sendMessage += fmt.Sprintf("- 响应时间 %.2fs 超过阈值 %.2fs \n\n- 无需改变状态，跳过\n\n", float64(milliseconds)/1000.0, float64(disableThreshold)/1000.0)
-					continue
-				}
-				// 如果已被禁用，但是请求成功，需要判断是否需要恢复
-				// 手动禁用的通道，不会自动恢复
-				if shouldEnableChannel(err, openaiErr) {
-					if channel.Status == config.ChannelStatusAutoDisabled {
-						EnableChannel(channel.Id, channel.Name, false)
-						sendMessage += "- 已被启用 \n\n"
-					} else {
-						sendMessage += "- 手动禁用的通道，不会自动恢复 \n\n"
-					}
-				}
-			} else {
-				// 如果通道启用状态，但是返回了错误 或者 响应时间超过阈值，需要判断是否需要禁用
-				if milliseconds > disableThreshold {
-					errMsg := fmt.Sprintf("响应时间 %.2fs 超过阈值 %.2fs ", float64(milliseconds)/1000.0, float64(disableThreshold)/1000.0)
-					sendMessage += fmt.Sprintf("- %s \n\n- 禁用\n\n", errMsg)
-					DisableChannel(channel.Id, channel.Name, errMsg, false)
-					continue
-				}
-
-				if ShouldDisableChannel(channel.Type, openaiErr) {
-					sendMessage += fmt.Sprintf("- 已被禁用，原因：%s\n\n", utils.EscapeMarkdownText(err.Error()))
-					DisableChannel(channel.Id, channel.Name, err.Error(), false)
-					continue
-				}
-
-				if err != nil {
-					sendMessage += fmt.Sprintf("- 测试报错: %s \n\n", utils.EscapeMarkdownText(err.Error()))
-					continue
-				}
-			}
-			channel.UpdateResponseTime(milliseconds)
-			sendMessage += fmt.Sprintf("- 测试完成，耗时 %.2fs\n\n", float64(milliseconds)/1000.0)
-		}
-		testAllChannelsLock.Lock()
-		testAllChannelsRunning = false
-		testAllChannelsLock.Unlock()
-		if isNotify {
-			notify.Send("通道测试完成", sendMessage)
-		}
-	}()
-	return nil
-}
-
 func TestAllChannels(c *gin.Context) {
 	err := testAllChannels(true)
 	if err != nil {
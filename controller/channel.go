@@ -4,6 +4,9 @@ import (
 	"errors"
 	"net/http"
 	"one-api/common"
+	"one-api/common/audit"
+	"one-api/common/config"
+	"one-api/common/secret"
 	"one-api/common/utils"
 	"one-api/model"
 	"strconv"
@@ -48,6 +51,7 @@ func GetChannel(c *gin.Context) {
 		})
 		return
 	}
+	channel.Key = secret.MaskLines(channel.Key)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -55,6 +59,62 @@ func GetChannel(c *gin.Context) {
 	})
 }
 
+// CopyChannel 复制渠道，密钥不经过前端
+func CopyChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	channel, err := model.GetChannelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	copied := model.Channel{
+		Type:               channel.Type,
+		Key:                channel.Key,
+		Status:             channel.Status,
+		Name:               channel.Name + "_copy",
+		Weight:             channel.Weight,
+		CreatedTime:        utils.GetTimestamp(),
+		BaseURL:            channel.BaseURL,
+		Other:              channel.Other,
+		Models:             channel.Models,
+		Group:              channel.Group,
+		Tag:                channel.Tag,
+		ModelMapping:       channel.ModelMapping,
+		ModelHeaders:       channel.ModelHeaders,
+		CustomParameter:    channel.CustomParameter,
+		Priority:           channel.Priority,
+		Proxy:              channel.Proxy,
+		TestModel:          channel.TestModel,
+		OnlyChat:           channel.OnlyChat,
+		PreCost:            channel.PreCost,
+		CompatibleResponse: channel.CompatibleResponse,
+		AllowExtraBody:     channel.AllowExtraBody,
+		KeyRotation:        channel.KeyRotation,
+		StructuredOutput:   channel.StructuredOutput,
+		DisabledStream:     channel.DisabledStream,
+		CostRatio:          channel.CostRatio,
+		CostPrices:         channel.CostPrices,
+		Plugin:             channel.Plugin,
+	}
+	if err := copied.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "channel", copied.Id)
+	audit.SetAfter(c, &copied)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func AddChannel(c *gin.Context) {
 	channel := model.Channel{}
 	err := c.ShouldBindJSON(&channel)
@@ -67,6 +127,10 @@ func AddChannel(c *gin.Context) {
 	}
 	channel.CreatedTime = utils.GetTimestamp()
 	keys := strings.Split(channel.Key, "\n")
+	// 多密钥渠道的所有密钥保存在同一个渠道中
+	if channel.IsMultiKey() {
+		keys = []string{channel.Key}
+	}
 
 	baseUrls := []string{}
 	if channel.BaseURL != nil && *channel.BaseURL != "" {
@@ -99,6 +163,12 @@ func AddChannel(c *gin.Context) {
 		})
 		return
 	}
+	ids := make([]string, 0, len(channels))
+	for _, inserted := range channels {
+		ids = append(ids, strconv.Itoa(inserted.Id))
+	}
+	audit.SetTarget(c, "channel", strings.Join(ids, ","))
+	audit.SetAfter(c, &channel)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -107,6 +177,10 @@ func AddChannel(c *gin.Context) {
 
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	audit.SetTarget(c, "channel", id)
+	if original, err := model.GetChannelById(id); err == nil {
+		audit.SetBefore(c, original)
+	}
 	channel := model.Channel{Id: id}
 	err := channel.Delete()
 	if err != nil {
@@ -164,6 +238,16 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	// 接口返回的是隐藏后的密钥，未修改的部分还原为原密钥
+	original, err := model.GetChannelById(channel.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	channel.Key = secret.RestoreMaskedLines(channel.Key, original.Key)
+	audit.SetTarget(c, "channel", channel.Id)
+	audit.SetBefore(c, original)
+
 	if channel.Models == "" {
 		err = channel.Update(false)
 	} else {
@@ -176,6 +260,10 @@ func UpdateChannel(c *gin.Context) {
 		})
 		return
 	}
+	if updated, err := model.GetChannelById(channel.Id); err == nil {
+		audit.SetAfter(c, updated)
+	}
+	channel.Key = secret.MaskLines(channel.Key)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -183,6 +271,42 @@ func UpdateChannel(c *gin.Context) {
 	})
 }
 
+// UpdateChannelStatus 启用或禁用渠道，只修改状态，不需要渠道的修改权限
+func UpdateChannelStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var status int
+	switch c.Param("status") {
+	case "enable":
+		status = config.ChannelStatusEnabled
+	case "disable":
+		status = config.ChannelStatusManuallyDisabled
+	default:
+		common.AbortWithMessage(c, http.StatusOK, "invalid status")
+		return
+	}
+
+	channel, err := model.GetChannelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "channel", id)
+	audit.SetBefore(c, map[string]any{"status": channel.Status})
+
+	model.UpdateChannelStatusById(id, status)
+	audit.SetAfter(c, map[string]any{"status": status})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
 func BatchUpdateChannelsAzureApi(c *gin.Context) {
 	var params model.BatchChannelsParams
 	err := c.ShouldBindJSON(&params)
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelKeys 获取多密钥渠道中每个密钥的状态和用量
+func GetChannelKeys(c *gin.Context) {
+	channel, ok := getMultiKeyChannel(c)
+	if !ok {
+		return
+	}
+
+	statuses, err := model.GetChannelKeyStatuses(channel)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    statuses,
+	})
+}
+
+type channelKeyStatusRequest struct {
+	KeyHash string `json:"key_hash" binding:"required"`
+	Status  int    `json:"status" binding:"required"`
+}
+
+// UpdateChannelKeyStatus 手动启用或禁用多密钥渠道中的单个密钥
+func UpdateChannelKeyStatus(c *gin.Context) {
+	channel, ok := getMultiKeyChannel(c)
+	if !ok {
+		return
+	}
+
+	var request channelKeyStatusRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if request.Status != config.ChannelStatusEnabled && request.Status != config.ChannelStatusManuallyDisabled {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("无效的状态"))
+		return
+	}
+
+	found := false
+	for _, key := range channel.GetKeys() {
+		if model.HashChannelKey(key) == request.KeyHash {
+			found = true
+			break
+		}
+	}
+	if !found {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("密钥不存在"))
+		return
+	}
+
+	enabledCount, err := model.UpdateChannelKeyStatus(channel, request.KeyHash, request.Status, "手动禁用")
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    enabledCount,
+	})
+}
+
+func getMultiKeyChannel(c *gin.Context) (*model.Channel, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return nil, false
+	}
+
+	channel, err := model.GetChannelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return nil, false
+	}
+	if !channel.IsMultiKey() {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("该渠道不是多密钥渠道"))
+		return nil, false
+	}
+	return channel, true
+}
@@ -0,0 +1,489 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/notify"
+	"one-api/common/utils"
+	"one-api/model"
+	"one-api/types"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+var modelUnavailableKeywords = []string{
+	"model_not_found",
+	"not found",
+	"does not exist",
+	"deprecated",
+	"no longer",
+	"not supported",
+	"unsupported model",
+	"无可用渠道",
+}
+
+type probeTask struct {
+	channel   *model.Channel
+	modelName string
+	// 是否优先探测被自动禁用的密钥
+	disabledKey bool
+}
+
+type probeResult struct {
+	channel   *model.Channel
+	probe     *model.ChannelProbe
+	openaiErr *types.OpenAIErrorWithStatusCode
+	err       error
+	// 探测的是被自动禁用的密钥
+	disabledKey bool
+}
+
+// getProbeModels 获取渠道需要探测的模型，mode 为 test_model 时只测试渠道的测速模型
+func getProbeModels(channel *model.Channel) []string {
+	if viper.GetString("channel.probe.mode") == "test_model" {
+		if channel.TestModel == "" {
+			return nil
+		}
+		return []string{channel.TestModel}
+	}
+
+	excludes := viper.GetStringSlice("channel.probe.exclude_models")
+	maxModels := viper.GetInt("channel.probe.max_models")
+
+	models := make([]string, 0)
+	for _, modelName := range strings.Split(channel.Models, ",") {
+		modelName = strings.TrimSpace(modelName)
+		// 通配符模型无法直接请求
+		if modelName == "" || strings.HasSuffix(modelName, "*") || getModelType(modelName) == "noSupport" {
+			continue
+		}
+		if isProbeExcluded(modelName, excludes) || utils.Contains(modelName, models) {
+			continue
+		}
+		models = append(models, modelName)
+		if maxModels > 0 && len(models) >= maxModels {
+			break
+		}
+	}
+
+	if len(models) == 0 && channel.TestModel != "" {
+		models = append(models, channel.TestModel)
+	}
+
+	return models
+}
+
+func isProbeExcluded(modelName string, excludes []string) bool {
+	for _, exclude := range excludes {
+		exclude = strings.TrimSpace(exclude)
+		if exclude == "" {
+			continue
+		}
+		if strings.HasSuffix(exclude, "*") {
+			if strings.HasPrefix(modelName, strings.TrimSuffix(exclude, "*")) {
+				return true
+			}
+		} else if modelName == exclude {
+			return true
+		}
+	}
+	return false
+}
+
+// copyProbeChannel 并发探测时每次使用独立的渠道副本，避免修改共享的渠道
+func copyProbeChannel(channel *model.Channel) *model.Channel {
+	probed := *channel
+	if channel.Proxy != nil {
+		proxy := *channel.Proxy
+		probed.Proxy = &proxy
+	}
+	probed.SetProxy()
+	return &probed
+}
+
+// probeChannelModel 探测渠道的单个模型，并记录耗时和错误分类
+// 多密钥渠道在 preferDisabledKey 为 true 或没有可用密钥时探测被自动禁用的密钥，成功后可以恢复该密钥
+func probeChannelModel(channel *model.Channel, modelName string, preferDisabledKey bool) *probeResult {
+	tik := time.Now()
+	probed := copyProbeChannel(channel)
+	keyed, err := model.PickChannelKey(probed)
+	disabledKey := false
+	if preferDisabledKey || errors.Is(err, model.ErrNoAvailableChannelKey) {
+		if disabled := model.PickAutoDisabledChannelKey(probed); disabled != nil {
+			keyed, err, disabledKey = disabled, nil, true
+		}
+	}
+	var openaiErr *types.OpenAIErrorWithStatusCode
+	if err == nil {
+		openaiErr, err = testChannel(keyed, modelName)
+	} else {
+		keyed = probed
+	}
+	milliseconds := time.Since(tik).Milliseconds()
+
+	probe := &model.ChannelProbe{
+		ChannelId:    channel.Id,
+		ModelName:    modelName,
+		ResponseTime: int(milliseconds),
+		CreatedTime:  tik.Unix(),
+	}
+	if openaiErr != nil {
+		probe.StatusCode = openaiErr.StatusCode
+	}
+
+	probe.ErrorClass = classifyProbeError(openaiErr, err, milliseconds)
+	probe.Success = probe.ErrorClass == ""
+	if err != nil {
+		probe.ErrorMessage = err.Error()
+	} else if probe.ErrorClass == model.ProbeErrorSlow {
+		probe.ErrorMessage = fmt.Sprintf("响应时间 %.2fs 超过阈值 %.2fs", float64(milliseconds)/1000.0, config.ChannelDisableThreshold)
+	}
+
+	return &probeResult{channel: keyed, probe: probe, openaiErr: openaiErr, err: err, disabledKey: disabledKey}
+}
+
+func classifyProbeError(openaiErr *types.OpenAIErrorWithStatusCode, err error, milliseconds int64) string {
+	if err == nil && openaiErr == nil {
+		threshold := int64(config.ChannelDisableThreshold * 1000)
+		if threshold > 0 && milliseconds > threshold {
+			return model.ProbeErrorSlow
+		}
+		return ""
+	}
+
+	message := ""
+	if err != nil {
+		message = strings.ToLower(err.Error())
+	}
+
+	if openaiErr == nil {
+		switch {
+		case message == "不支持的模型类型" || message == "channel not implemented":
+			return model.ProbeErrorUnsupported
+		case strings.Contains(message, "timeout") || strings.Contains(message, "deadline exceeded"):
+			return model.ProbeErrorTimeout
+		}
+		return model.ProbeErrorOther
+	}
+
+	switch openaiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return model.ProbeErrorAuth
+	case http.StatusTooManyRequests:
+		return model.ProbeErrorRateLimit
+	case http.StatusNotFound:
+		return model.ProbeErrorModelNotFound
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return model.ProbeErrorTimeout
+	}
+
+	if openaiErr.OpenAIError.Code == "model_not_found" {
+		return model.ProbeErrorModelNotFound
+	}
+
+	if strings.Contains(message, "model") {
+		for _, keyword := range modelUnavailableKeywords {
+			if strings.Contains(message, keyword) {
+				return model.ProbeErrorModelNotFound
+			}
+		}
+	}
+
+	if strings.Contains(message, "timeout") || strings.Contains(message, "deadline exceeded") {
+		return model.ProbeErrorTimeout
+	}
+
+	if openaiErr.StatusCode >= http.StatusInternalServerError {
+		return model.ProbeErrorServer
+	}
+
+	return model.ProbeErrorOther
+}
+
+// enableProbedKey 被自动禁用的密钥探测成功后恢复该密钥
+func enableProbedKey(result *probeResult) bool {
+	if !config.AutomaticEnableChannelEnabled || !result.disabledKey {
+		return false
+	}
+	if _, err := model.UpdateChannelKeyStatus(result.channel, model.HashChannelKey(result.channel.Key), config.ChannelStatusEnabled, ""); err != nil {
+		logger.SysError("failed to enable channel key: " + err.Error())
+		return false
+	}
+	return true
+}
+
+// isModelLevelError 只影响单个模型的错误，禁用该模型而不是整个渠道
+func isModelLevelError(errorClass string) bool {
+	return errorClass == model.ProbeErrorModelNotFound || errorClass == model.ProbeErrorSlow
+}
+
+// runProbes 以有限并发执行探测任务
+func runProbes(tasks []probeTask) []*probeResult {
+	concurrency := viper.GetInt("channel.probe.concurrency")
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]*probeResult, len(tasks))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				time.Sleep(config.RequestInterval)
+				results[index] = probeChannelModel(tasks[index].channel, tasks[index].modelName, tasks[index].disabledKey)
+			}
+		}()
+	}
+
+	for index := range tasks {
+		queue <- index
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+// applyProbeResults 根据单个渠道的探测结果调整渠道和渠道模型的状态，返回通知内容
+func applyProbeResults(channel *model.Channel, results []*probeResult) string {
+	var message string
+	isChannelEnabled := channel.Status == config.ChannelStatusEnabled
+
+	var successTime, successCount int64
+	channelDisabled := false
+	for _, result := range results {
+		probe := result.probe
+		message += fmt.Sprintf("- %s : ", utils.EscapeMarkdownText(probe.ModelName))
+
+		if probe.Success {
+			successTime += int64(probe.ResponseTime)
+			successCount++
+			message += fmt.Sprintf("耗时 %.2fs", float64(probe.ResponseTime)/1000.0)
+			if config.AutomaticEnableChannelEnabled && model.ChannelGroup.IsModelDisabled(channel.Id, probe.ModelName) {
+				model.EnableChannelModel(channel.Id, probe.ModelName)
+				message += "，模型已恢复"
+			}
+			if enableProbedKey(result) {
+				message += "，密钥已恢复"
+			}
+			message += "\n\n"
+			continue
+		}
+
+		message += fmt.Sprintf("[%s] %s", probe.ErrorClass, utils.EscapeMarkdownText(probe.ErrorMessage))
+		switch {
+		case !isChannelEnabled || channelDisabled || result.disabledKey:
+		case isModelLevelError(probe.ErrorClass):
+			if config.AutomaticDisableChannelEnabled && !model.ChannelGroup.IsModelDisabled(channel.Id, probe.ModelName) {
+				model.DisableChannelModel(channel.Id, probe.ModelName, probe.ErrorMessage)
+				message += "，模型已禁用"
+			}
+		case ShouldDisableChannel(channel.Type, result.openaiErr):
+			// 密钥失效、额度耗尽等错误影响整个渠道，多密钥渠道只禁用该密钥
+			DisableChannelOrKey(result.channel, probe.ErrorMessage, false)
+			if channel.IsMultiKey() {
+				message += "，密钥已禁用"
+			} else {
+				channelDisabled = true
+				message += "，渠道已禁用"
+			}
+		}
+		message += "\n\n"
+	}
+
+	// 已被禁用的渠道，有模型探测成功时判断是否需要恢复，手动禁用的通道不会自动恢复
+	if !isChannelEnabled && successCount > 0 && shouldEnableChannel(nil, nil) {
+		if channel.Status == config.ChannelStatusAutoDisabled {
+			EnableChannel(channel.Id, channel.Name, false)
+			message += "- 渠道已被启用\n\n"
+		} else {
+			message += "- 手动禁用的通道，不会自动恢复\n\n"
+		}
+	}
+
+	if successCount > 0 {
+		channel.UpdateResponseTime(successTime / successCount)
+	}
+
+	return message
+}
+
+func getProbeUptimeSince(c *gin.Context) int64 {
+	days, _ := strconv.Atoi(c.Query("days"))
+	if days <= 0 {
+		days = 7
+	}
+	return utils.GetTimestamp() - int64(days)*86400
+}
+
+var testAllChannelsLock sync.Mutex
+var testAllChannelsRunning bool = false
+
+func testAllChannels(isNotify bool) error {
+	testAllChannelsLock.Lock()
+	if testAllChannelsRunning {
+		testAllChannelsLock.Unlock()
+		return errors.New("测试已在运行中")
+	}
+	testAllChannelsRunning = true
+	testAllChannelsLock.Unlock()
+	channels, err := model.GetAllChannels()
+	if err != nil {
+		testAllChannelsLock.Lock()
+		testAllChannelsRunning = false
+		testAllChannelsLock.Unlock()
+		return err
+	}
+
+	go func() {
+		defer func() {
+			testAllChannelsLock.Lock()
+			testAllChannelsRunning = false
+			testAllChannelsLock.Unlock()
+		}()
+
+		tasks := make([]probeTask, 0, len(channels))
+		for _, channel := range channels {
+			// 每轮每个渠道只用第一个模型探测一个被禁用的密钥，其余模型正常探测可用的密钥
+			for i, modelName := range getProbeModels(channel) {
+				tasks = append(tasks, probeTask{channel: channel, modelName: modelName, disabledKey: i == 0})
+			}
+		}
+
+		results := runProbes(tasks)
+
+		channelResults := make(map[int][]*probeResult)
+		probes := make([]*model.ChannelProbe, 0, len(results))
+		for _, result := range results {
+			channelResults[result.probe.ChannelId] = append(channelResults[result.probe.ChannelId], result)
+			probes = append(probes, result.probe)
+		}
+		if err := model.CreateChannelProbes(probes); err != nil {
+			logger.SysError("failed to save channel probes: " + err.Error())
+		}
+
+		var sendMessage string
+		for _, channel := range channels {
+			sendMessage += fmt.Sprintf("**通道 %s - #%d - %s** : \n\n", utils.EscapeMarkdownText(channel.Name), channel.Id, channel.StatusToStr())
+			if len(channelResults[channel.Id]) == 0 {
+				sendMessage += "- 没有可测试的模型，跳过\n\n"
+				continue
+			}
+			sendMessage += applyProbeResults(channel, channelResults[channel.Id])
+		}
+
+		if isNotify {
+			notify.Send("通道测试完成", sendMessage)
+		}
+	}()
+	return nil
+}
+
+// GetChannelProbesList 查询探测历史
+func GetChannelProbesList(c *gin.Context) {
+	var params model.ChannelProbeQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	probes, err := model.GetChannelProbesList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    probes,
+	})
+}
+
+// GetChannelProbeUptime 统计渠道和模型的可用率，days 默认为 7
+func GetChannelProbeUptime(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	uptimes, err := model.GetChannelModelUptimes(channelId, getProbeUptimeSince(c))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	type channelUptime struct {
+		ChannelId    int                         `json:"channel_id"`
+		Total        int64                       `json:"total"`
+		SuccessCount int64                       `json:"success_count"`
+		Uptime       float64                     `json:"uptime"`
+		Models       []*model.ChannelModelUptime `json:"models"`
+	}
+
+	channels := make([]*channelUptime, 0)
+	channelIndex := make(map[int]*channelUptime)
+	for _, uptime := range uptimes {
+		item, ok := channelIndex[uptime.ChannelId]
+		if !ok {
+			item = &channelUptime{ChannelId: uptime.ChannelId, Models: make([]*model.ChannelModelUptime, 0)}
+			channelIndex[uptime.ChannelId] = item
+			channels = append(channels, item)
+		}
+		item.Total += uptime.Total
+		item.SuccessCount += uptime.SuccessCount
+		item.Models = append(item.Models, uptime)
+	}
+	for _, item := range channels {
+		if item.Total > 0 {
+			item.Uptime = float64(item.SuccessCount) / float64(item.Total) * 100
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    channels,
+	})
+}
+
+// GetChannelModelDisables 获取被禁用的渠道模型
+func GetChannelModelDisables(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	disables, err := model.GetChannelModelDisables(channelId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    disables,
+	})
+}
+
+// EnableChannelModel 手动恢复被禁用的渠道模型
+func EnableChannelModel(c *gin.Context) {
+	channelId, err := strconv.Atoi(c.Query("channel_id"))
+	modelName := c.Query("model")
+	if err != nil || modelName == "" {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("channel_id and model are required"))
+		return
+	}
+
+	if err := model.EnableChannelModel(channelId, modelName); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"net/http"
 	"one-api/common"
+	"one-api/common/audit"
 	"one-api/common/config"
+	"one-api/common/secret"
 	"one-api/model"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +24,9 @@ func GetChannelsTagList(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	for _, channel := range channelsTag {
+		channel.Key = secret.MaskLines(channel.Key)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -53,6 +58,7 @@ func GetChannelsTag(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	channel.Key = secret.MaskLines(channel.Key)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -73,11 +79,18 @@ func UpdateChannelsTag(c *gin.Context) {
 		return
 	}
 
+	audit.SetTarget(c, "channel_tag", tag)
+	if original, err := model.GetChannelsTag(tag); err == nil {
+		audit.SetBefore(c, original)
+	}
 	err = model.UpdateChannelsTag(tag, &channel)
 	if err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	if updated, err := model.GetChannelsTag(tag); err == nil {
+		audit.SetAfter(c, updated)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -5,7 +5,9 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/common/config"
+	"one-api/common/logger"
 	"one-api/common/notify"
+	"one-api/common/secret"
 	"one-api/model"
 	"one-api/types"
 
@@ -70,6 +72,31 @@ func DisableChannel(channelId int, channelName string, reason string, sendNotify
 	notify.Send(subject, content)
 }
 
+// DisableChannelOrKey 多密钥渠道只禁用出错的密钥，所有密钥都被禁用后再禁用渠道
+func DisableChannelOrKey(channel *model.Channel, reason string, sendNotify bool) {
+	if !channel.IsMultiKey() {
+		DisableChannel(channel.Id, channel.Name, reason, sendNotify)
+		return
+	}
+
+	enabledCount, err := model.UpdateChannelKeyStatus(channel, model.HashChannelKey(channel.Key), config.ChannelStatusAutoDisabled, reason)
+	if err != nil {
+		logger.SysError("failed to disable channel key: " + err.Error())
+		return
+	}
+	if enabledCount == 0 {
+		DisableChannel(channel.Id, channel.Name, "所有密钥均已禁用，最后一次原因："+reason, sendNotify)
+		return
+	}
+	if !sendNotify {
+		return
+	}
+
+	subject := fmt.Sprintf("通道「%s」（#%d）的密钥已被禁用", channel.Name, channel.Id)
+	content := fmt.Sprintf("通道「%s」（#%d）的密钥 %s 已被禁用，剩余可用密钥 %d 个，原因：%s", channel.Name, channel.Id, secret.Mask(channel.Key), enabledCount, reason)
+	notify.Send(subject, content)
+}
+
 // enable & notify
 func EnableChannel(channelId int, channelName string, sendNotify bool) {
 	model.UpdateChannelStatusById(channelId, config.ChannelStatusEnabled)
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/audit"
+	"one-api/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 加密口令通过请求头传入，避免出现在访问日志中
+const configPassphraseHeader = "X-Config-Passphrase"
+
+// ExportConfig 导出网关配置，format 支持 yaml 和 json
+func ExportConfig(c *gin.Context) {
+	format := c.DefaultQuery("format", model.ConfigFormatYAML)
+	if format != model.ConfigFormatYAML && format != model.ConfigFormatJSON {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("不支持的格式: %s", format))
+		return
+	}
+
+	options := model.ConfigExportOptions{
+		IncludeKeys: c.Query("include_keys") == "true",
+		Passphrase:  c.GetHeader(configPassphraseHeader),
+	}
+	doc, err := model.ExportConfig(options)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if options.IncludeKeys || options.Passphrase != "" {
+		audit.SetAction(c, "config.export_secrets")
+		audit.SetTarget(c, "config", format)
+		recordSecretReveal(c, "导出了包含密钥的网关配置")
+	}
+
+	data, err := model.MarshalConfigDocument(doc, format)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	contentType := "application/x-yaml"
+	if format == model.ConfigFormatJSON {
+		contentType = "application/json"
+	}
+	filename := fmt.Sprintf("one-hub-config-%s.%s", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ApplyConfig 应用配置文档，请求体为 YAML 或 JSON 文档，dry_run=true 时只返回变更不写入
+func ApplyConfig(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	doc, err := model.UnmarshalConfigDocument(data, c.Query("format"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	result, err := model.ApplyConfig(doc, model.ConfigApplyOptions{
+		DryRun:     c.Query("dry_run") == "true",
+		Prune:      c.Query("prune") == "true",
+		Passphrase: c.GetHeader(configPassphraseHeader),
+	})
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "config", "")
+	audit.SetAfter(c, result)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    result,
+	})
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/audit"
+	"one-api/common/config"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPermissions 获取所有可分配的权限
+func GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    config.Permissions,
+	})
+}
+
+// GetSelfPermissions 获取当前用户拥有的权限，前端根据权限显示菜单
+func GetSelfPermissions(c *gin.Context) {
+	permissions, err := model.GetUserPermissions(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    permissions,
+	})
+}
+
+func GetCustomRolesList(c *gin.Context) {
+	var params model.SearchCustomRoleParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	roles, err := model.GetCustomRolesList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    roles,
+	})
+}
+
+func GetCustomRole(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	role, err := model.GetCustomRoleById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    role,
+	})
+}
+
+func AddCustomRole(c *gin.Context) {
+	role := model.CustomRole{}
+	if err := c.ShouldBindJSON(&role); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := role.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "role", role.Id)
+	audit.SetAfter(c, &role)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    role,
+	})
+}
+
+func UpdateCustomRole(c *gin.Context) {
+	role := model.CustomRole{}
+	if err := c.ShouldBindJSON(&role); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	original, err := model.GetCustomRoleById(role.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "role", role.Id)
+	audit.SetBefore(c, original)
+
+	if err := role.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetAfter(c, &role)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteCustomRole(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	role, err := model.GetCustomRoleById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "role", role.Id)
+	audit.SetBefore(c, role)
+
+	if err := role.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type SetUserCustomRoleRequest struct {
+	UserId int `json:"user_id" binding:"required"`
+	RoleId int `json:"role_id"`
+}
+
+// SetUserCustomRole 为用户分配自定义角色，role_id 为 0 时取消分配
+func SetUserCustomRole(c *gin.Context) {
+	var req SetUserCustomRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	user, err := model.GetUserById(req.UserId, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetAction(c, "user.set_role")
+	audit.SetTarget(c, "user", user.Id)
+	audit.SetBefore(c, map[string]any{"custom_role_id": user.CustomRoleId})
+
+	if err := model.SetUserCustomRole(req.UserId, req.RoleId); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetAfter(c, map[string]any{"custom_role_id": req.RoleId})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateEphemeralKey 后端服务使用自己的令牌换取短期的临时密钥，下发给浏览器或移动端直接调用接口
+func CreateEphemeralKey(c *gin.Context) {
+	if _, ok := c.Get("ephemeral_key"); ok {
+		common.APIRespondWithError(c, http.StatusForbidden, errors.New("临时密钥不能再派生临时密钥"))
+		return
+	}
+
+	var req model.EphemeralKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	token, err := model.GetTokenById(c.GetInt("token_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	key, claims, err := model.CreateEphemeralKey(token, &req)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"key":        key,
+			"expires_at": claims.ExpiresAt,
+			"models":     claims.Models,
+			"endpoints":  claims.Endpoints,
+			"max_quota":  claims.MaxQuota,
+			"user":       claims.EndUser,
+		},
+	})
+}
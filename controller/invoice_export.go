@@ -0,0 +1,450 @@
+package controller
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/common/audit"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/pdf"
+	"one-api/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceExportRequest struct {
+	Date         string `form:"date" binding:"required"`
+	Format       string `form:"format"`
+	UserId       int    `form:"user_id"`
+	Organization string `form:"organization"`
+}
+
+// GetInvoiceProfile 获取当前用户的开票信息
+func GetInvoiceProfile(c *gin.Context) {
+	profile, err := model.GetInvoiceProfile(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    profile,
+	})
+}
+
+// UpdateInvoiceProfile 更新当前用户的开票信息
+func UpdateInvoiceProfile(c *gin.Context) {
+	profile := &model.InvoiceProfile{}
+	if err := c.ShouldBindJSON(profile); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	// 所属组织由管理员分配，SaveInvoiceProfile 会保留原值
+	profile.UserId = c.GetInt("id")
+
+	if err := model.SaveInvoiceProfile(profile); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    profile,
+	})
+}
+
+// GetInvoiceOrganizations 获取所有开票组织
+func GetInvoiceOrganizations(c *gin.Context) {
+	organizations, err := model.GetInvoiceOrganizations()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organizations,
+	})
+}
+
+func AddInvoiceOrganization(c *gin.Context) {
+	organization := &model.InvoiceOrganization{}
+	if err := c.ShouldBindJSON(organization); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	organization.Id = 0
+	organization.Name = strings.TrimSpace(organization.Name)
+
+	if err := organization.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "invoice_organization", organization.Id)
+	audit.SetAfter(c, organization)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    organization,
+	})
+}
+
+func UpdateInvoiceOrganization(c *gin.Context) {
+	organization := &model.InvoiceOrganization{}
+	if err := c.ShouldBindJSON(organization); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if organization.Id == 0 {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("id 为空"))
+		return
+	}
+	audit.SetTarget(c, "invoice_organization", organization.Id)
+
+	if err := organization.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetAfter(c, organization)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteInvoiceOrganization(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	audit.SetTarget(c, "invoice_organization", id)
+
+	if err := model.DeleteInvoiceOrganization(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type InvoiceOrganizationMemberRequest struct {
+	UserId       int    `json:"user_id" binding:"required"`
+	Organization string `json:"organization"`
+}
+
+// SetInvoiceOrganizationMember 将用户分配到组织，组织为空时移出组织
+func SetInvoiceOrganizationMember(c *gin.Context) {
+	var req InvoiceOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "user", req.UserId)
+	audit.SetAfter(c, req)
+
+	if err := model.SetInvoiceProfileOrganization(req.UserId, strings.TrimSpace(req.Organization)); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// ExportUserInvoice 导出当前用户指定月份的账单
+func ExportUserInvoice(c *gin.Context) {
+	var req InvoiceExportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	date, err := model.ParseInvoiceMonth(req.Date)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	doc, err := model.GetUserInvoiceDocument(c.GetInt("id"), date)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondInvoice(c, doc, req.Format)
+}
+
+// ExportInvoice 管理员导出指定用户或组织的账单
+func ExportInvoice(c *gin.Context) {
+	var req InvoiceExportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	date, err := model.ParseInvoiceMonth(req.Date)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var doc *model.InvoiceDocument
+	if req.Organization != "" {
+		doc, err = model.GetOrganizationInvoiceDocument(req.Organization, date)
+	} else if req.UserId > 0 {
+		doc, err = model.GetUserInvoiceDocument(req.UserId, date)
+	} else {
+		err = fmt.Errorf("user_id or organization is required")
+	}
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	respondInvoice(c, doc, req.Format)
+}
+
+// BulkExportInvoice 管理员批量导出指定月份所有用户的账单，打包为 zip
+func BulkExportInvoice(c *gin.Context) {
+	date, err := model.ParseInvoiceMonth(c.Param("time"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	format := c.DefaultQuery("format", "pdf")
+
+	userIds, err := model.GetInvoiceMonthUserIds(date)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if len(userIds) == 0 {
+		common.APIRespondWithError(c, http.StatusOK, fmt.Errorf("该月份没有账单数据"))
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buf)
+
+	summary := &bytes.Buffer{}
+	summaryWriter := csv.NewWriter(summary)
+	summaryWriter.Write([]string{"Invoice Number", "User ID", "Username", "Organization", "Tax ID", "Quota", "Amount", "Currency"})
+
+	for _, userId := range userIds {
+		doc, err := model.GetUserInvoiceDocument(userId, date)
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to export invoice for user %d: %s", userId, err.Error()))
+			continue
+		}
+
+		content, ext := renderInvoice(doc, format)
+		file, err := zipWriter.Create(fmt.Sprintf("%s_%s.%s", doc.Invoice.Number, doc.Username, ext))
+		if err != nil {
+			common.APIRespondWithError(c, http.StatusOK, err)
+			return
+		}
+		file.Write(content)
+
+		summaryWriter.Write([]string{
+			doc.Invoice.Number,
+			fmt.Sprintf("%d", userId),
+			doc.Username,
+			doc.Profile.Organization,
+			doc.Profile.TaxId,
+			fmt.Sprintf("%d", doc.Invoice.Quota),
+			fmt.Sprintf("%.2f", doc.Invoice.Amount),
+			string(doc.Invoice.Currency),
+		})
+	}
+
+	summaryWriter.Flush()
+	file, err := zipWriter.Create("summary.csv")
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	file.Write(summary.Bytes())
+
+	if err := zipWriter.Close(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=invoices_%s.zip", date.Format("2006-01")))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+func respondInvoice(c *gin.Context, doc *model.InvoiceDocument, format string) {
+	content, ext := renderInvoice(doc, format)
+	contentType := "application/pdf"
+	if ext == "csv" {
+		contentType = "text/csv"
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", url.PathEscape(doc.Invoice.Number), ext))
+	c.Data(http.StatusOK, contentType, content)
+}
+
+func renderInvoice(doc *model.InvoiceDocument, format string) ([]byte, string) {
+	if format == "csv" {
+		return renderInvoiceCSV(doc), "csv"
+	}
+	return renderInvoicePDF(doc), "pdf"
+}
+
+func invoiceIssuer() []string {
+	if config.InvoiceIssuer != "" {
+		return strings.Split(strings.ReplaceAll(config.InvoiceIssuer, "\r\n", "\n"), "\n")
+	}
+	return []string{config.SystemName}
+}
+
+func invoiceCustomer(doc *model.InvoiceDocument) string {
+	if doc.Profile.Organization != "" {
+		return doc.Profile.Organization
+	}
+	return doc.Username
+}
+
+func renderInvoiceCSV(doc *model.InvoiceDocument) []byte {
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	currency := string(doc.Invoice.Currency)
+
+	writer.Write([]string{"Invoice Number", doc.Invoice.Number})
+	writer.Write([]string{"Billing Period", doc.Invoice.Month})
+	writer.Write([]string{"Issue Date", time.Unix(doc.Invoice.CreatedTime, 0).Format("2006-01-02")})
+	writer.Write([]string{"Issuer", strings.Join(invoiceIssuer(), " ")})
+	writer.Write([]string{"Customer", invoiceCustomer(doc)})
+	writer.Write([]string{"Tax ID", doc.Profile.TaxId})
+	writer.Write([]string{"Address", doc.Profile.Address})
+	writer.Write([]string{"Contact", doc.Profile.Contact})
+	writer.Write([]string{"Email", doc.Profile.Email})
+	writer.Write([]string{"Currency", currency})
+	writer.Write([]string{})
+
+	writer.Write([]string{"Model", "Request Count", "Prompt Tokens", "Completion Tokens", "Quota", "Amount"})
+	for _, item := range doc.Items {
+		writer.Write([]string{
+			item.ModelName,
+			fmt.Sprintf("%d", item.RequestCount),
+			fmt.Sprintf("%d", item.PromptTokens),
+			fmt.Sprintf("%d", item.CompletionTokens),
+			fmt.Sprintf("%d", item.Quota),
+			fmt.Sprintf("%.2f", item.Amount),
+		})
+	}
+	writer.Write([]string{"Total", "", "", "", fmt.Sprintf("%d", doc.Invoice.Quota), fmt.Sprintf("%.2f", doc.Invoice.Amount)})
+	writer.Flush()
+
+	return buf.Bytes()
+}
+
+func renderInvoicePDF(doc *model.InvoiceDocument) []byte {
+	const (
+		left   = 50.0
+		right  = pdf.PageWidth - 50
+		bottom = pdf.PageHeight - 60
+	)
+	currency := string(doc.Invoice.Currency)
+	document := pdf.New()
+	document.AddPage()
+
+	document.Text(left, 70, 22, "账单 INVOICE")
+	document.TextRight(right, 60, 10, "编号: "+doc.Invoice.Number)
+	document.TextRight(right, 76, 10, "账期: "+doc.Invoice.Month)
+	document.TextRight(right, 92, 10, "开具日期: "+time.Unix(doc.Invoice.CreatedTime, 0).Format("2006-01-02"))
+	document.TextRight(right, 108, 10, "币种: "+currency)
+
+	y := 140.0
+	document.Text(left, y, 11, "开票方")
+	document.Text(300, y, 11, "客户")
+	issuerY := y
+	for _, line := range invoiceIssuer() {
+		issuerY += 16
+		document.Text(left, issuerY, 10, truncateInvoiceText(line, 10, 230))
+	}
+
+	customerLines := []string{invoiceCustomer(doc)}
+	for _, field := range []struct{ label, value string }{
+		{"税号", doc.Profile.TaxId},
+		{"地址", doc.Profile.Address},
+		{"联系人", doc.Profile.Contact},
+		{"邮箱", doc.Profile.Email},
+	} {
+		if field.value != "" {
+			customerLines = append(customerLines, field.label+": "+field.value)
+		}
+	}
+	customerY := y
+	for _, line := range customerLines {
+		customerY += 16
+		document.Text(300, customerY, 10, truncateInvoiceText(line, 10, right-300))
+	}
+
+	y = max(issuerY, customerY) + 36
+	drawHeader := func() {
+		document.Text(left, y, 10, "模型")
+		document.TextRight(310, y, 10, "请求数")
+		document.TextRight(390, y, 10, "输入 Tokens")
+		document.TextRight(470, y, 10, "输出 Tokens")
+		document.TextRight(right, y, 10, "金额")
+		document.Line(left, y+6, right, y+6)
+		y += 22
+	}
+	drawHeader()
+
+	for _, item := range doc.Items {
+		if y > bottom {
+			document.AddPage()
+			y = 60
+			drawHeader()
+		}
+		document.Text(left, y, 9, truncateInvoiceText(item.ModelName, 9, 200))
+		document.TextRight(310, y, 9, fmt.Sprintf("%d", item.RequestCount))
+		document.TextRight(390, y, 9, fmt.Sprintf("%d", item.PromptTokens))
+		document.TextRight(470, y, 9, fmt.Sprintf("%d", item.CompletionTokens))
+		document.TextRight(right, y, 9, fmt.Sprintf("%.2f", item.Amount))
+		y += 18
+	}
+
+	document.Line(left, y-8, right, y-8)
+	y += 8
+	document.Text(left, y, 11, "合计")
+	document.TextRight(right, y, 11, fmt.Sprintf("%s %.2f", currency, doc.Invoice.Amount))
+
+	if doc.Profile.Remark != "" {
+		y += 30
+		document.Text(left, y, 9, truncateInvoiceText("备注: "+doc.Profile.Remark, 9, right-left))
+	}
+
+	return document.Bytes()
+}
+
+func truncateInvoiceText(text string, size, width float64) string {
+	if pdf.TextWidth(text, size) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.TextWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/ldap"
+	"one-api/common/logger"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LDAPLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// 关联同名的本地账号时需要提供本地账号的密码
+	LocalPassword string `json:"local_password"`
+}
+
+var errLDAPLinkRequired = errors.New("已存在同名的本地账号，请输入本地账号的密码以关联目录账号")
+
+// LDAPLogin 通过 LDAP/AD 登录
+// 首先通过 LDAP ID 查找用户，找不到时如存在同名的本地账号，需要验证本地账号密码后才能关联（管理员账号不能关联），
+// 都不存在则自动创建用户。每次登录都会按组映射规则同步用户分组和角色
+func LDAPLogin(c *gin.Context) {
+	if !config.LDAPAuthEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "管理员未开启通过 LDAP 登录",
+			"success": false,
+		})
+		return
+	}
+
+	var req LDAPLoginRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil || req.Username == "" || req.Password == "" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+
+	mappings, err := ldap.ParseGroupMapping(config.LDAPGroupMapping)
+	if err != nil {
+		logger.SysError("LDAP 组映射配置错误, err: " + err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"message": "LDAP 配置错误",
+			"success": false,
+		})
+		return
+	}
+
+	entry, err := ldap.Authenticate(req.Username, req.Password)
+	if err != nil {
+		message := err.Error()
+		if !errors.Is(err, ldap.ErrInvalidCredentials) {
+			logger.SysError("LDAP 认证失败, err: " + err.Error())
+			message = "LDAP 认证失败"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": message,
+			"success": false,
+		})
+		return
+	}
+
+	user, err := findOrCreateLDAPUser(c, entry, req.LocalPassword)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message":       err.Error(),
+			"success":       false,
+			"link_required": errors.Is(err, errLDAPLinkRequired),
+		})
+		return
+	}
+
+	if user.Status != config.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁",
+			"success": false,
+		})
+		return
+	}
+
+	// 先保存映射结果，需要两步验证时会重新从数据库读取用户
+	if applyLDAPGroupMapping(user, mappings, entry.Groups) {
+		if err := user.Update(false); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"message": err.Error(),
+				"success": false,
+			})
+			return
+		}
+	}
+	setupLogin(user, c)
+}
+
+func findOrCreateLDAPUser(c *gin.Context, entry *ldap.Entry, localPassword string) (*model.User, error) {
+	user := &model.User{LdapId: entry.Username}
+	err := user.FillUserByLdapId()
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.SysError("查询用户错误: " + err.Error())
+		return nil, err
+	}
+
+	user = &model.User{Username: entry.Username}
+	err = user.FillUserByUsername()
+	if err == nil {
+		// 管理员和已关联其他目录用户的账号不能通过用户名关联
+		if user.Role >= config.RoleAdminUser || user.LdapId != "" {
+			return nil, errors.New("用户名已存在")
+		}
+		if localPassword == "" {
+			return nil, errLDAPLinkRequired
+		}
+		if user.Password == "" || !common.ValidatePasswordAndHash(localPassword, user.Password) {
+			return nil, errors.New("本地账号密码错误")
+		}
+		user.LdapId = entry.Username
+		if err := user.Update(false); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.SysError("查询用户错误: " + err.Error())
+		return nil, err
+	}
+
+	if !config.LDAPAutoProvision {
+		return nil, errors.New("用户不存在，请联系管理员开通")
+	}
+
+	var inviterId int
+	if affCode := c.Query("aff"); affCode != "" {
+		inviterId, _ = model.GetUserIdByAffCode(affCode)
+	}
+	user = &model.User{
+		Username:    entry.Username,
+		DisplayName: entry.DisplayName,
+		Email:       entry.Email,
+		LdapId:      entry.Username,
+		InviterId:   inviterId,
+		Role:        config.RoleCommonUser,
+		Status:      config.UserStatusEnabled,
+	}
+	if err := user.Insert(inviterId); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// applyLDAPGroupMapping 配置了组映射时，角色以目录为准，不在任何映射组中的用户恢复为普通用户
+// 分组只在匹配的规则指定了分组时修改，未匹配时保留管理员手动设置的分组，返回用户是否被修改
+func applyLDAPGroupMapping(user *model.User, mappings []ldap.GroupMapping, groups []string) bool {
+	if len(mappings) == 0 || user.Role == config.RoleRootUser {
+		return false
+	}
+
+	group, role, _ := ldap.MapGroups(mappings, groups)
+	if role < config.RoleCommonUser {
+		role = config.RoleCommonUser
+	}
+	changed := user.Role != role
+	user.Role = role
+
+	if group == "" || group == user.Group {
+		return changed
+	}
+	if model.GlobalUserGroupRatio.GetBySymbol(group) == nil {
+		logger.SysError("LDAP 组映射的用户分组不存在: " + group)
+		return changed
+	}
+	user.Group = group
+	return true
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/json"
 	"net/http"
 	"one-api/common"
 	"one-api/model"
@@ -112,3 +113,33 @@ func DeleteHistoryLogs(c *gin.Context) {
 		"data":    count,
 	})
 }
+
+// GetLogPayload 查看日志关联的请求采集内容
+func GetLogPayload(c *gin.Context) {
+	record, err := model.GetPayloadCapture(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	data, err := record.GetData()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	var payload any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"capture": record,
+			"payload": payload,
+		},
+	})
+}
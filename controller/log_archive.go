@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetLogArchivesList(c *gin.Context) {
+	var params model.LogArchiveQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	archives, err := model.GetLogArchivesList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    archives,
+	})
+}
+
+// QueryLogArchive 查询归档中的日志
+func QueryLogArchive(c *gin.Context) {
+	archive, ok := getLogArchive(c)
+	if !ok {
+		return
+	}
+
+	var params model.LogArchiveLogsParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	logs, err := archive.QueryLogs(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    logs,
+	})
+}
+
+// RestoreLogArchive 将归档重新导入日志表，下一次归档任务会再次归档
+func RestoreLogArchive(c *gin.Context) {
+	archive, ok := getLogArchive(c)
+	if !ok {
+		return
+	}
+
+	count, err := archive.Restore()
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
+// RunLogArchive 手动触发一次归档，在后台执行
+func RunLogArchive(c *gin.Context) {
+	go func() {
+		if err := model.ArchiveLogs(); err != nil && !errors.Is(err, model.ErrLogArchiveRunning) {
+			logger.SysError("Archive logs error: " + err.Error())
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func getLogArchive(c *gin.Context) (*model.LogArchive, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return nil, false
+	}
+
+	archive, err := model.GetLogArchiveById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return nil, false
+	}
+	return archive, true
+}
@@ -13,6 +13,7 @@ import (
 	"one-api/common"
 	"one-api/common/logger"
 	"one-api/common/requester"
+	"one-api/common/webhook"
 	"one-api/model"
 	provider "one-api/providers/midjourney"
 	"sync"
@@ -126,9 +127,28 @@ func UpdateMidjourneyTaskBulk() {
 				continue
 			}
 
-			err := MjTaskHandler(midjourneyChannel, taskIds, taskM)
-			if err != nil {
-				logger.LogError(ctx, fmt.Sprintf("MjTaskHandler error: %v", err))
+			// 上游任务只能用提交任务时的密钥查询
+			keyTaskIds := make(map[string][]string)
+			for _, taskId := range taskIds {
+				hash := taskM[taskId].ChannelKeyHash
+				keyTaskIds[hash] = append(keyTaskIds[hash], taskId)
+			}
+			for hash, ids := range keyTaskIds {
+				keyedChannel, err := model.PickChannelKeyByHash(midjourneyChannel, hash)
+				if err != nil {
+					err := model.MjBulkUpdate(ids, map[string]any{
+						"fail_reason": fmt.Sprintf("获取渠道密钥失败，请联系管理员，渠道ID：%d", channelId),
+						"status":      "FAILURE",
+						"progress":    "100%",
+					})
+					logger.LogError(ctx, fmt.Sprintf("UpdateMidjourneyTask error: %v", err))
+					continue
+				}
+
+				err = MjTaskHandler(keyedChannel, ids, taskM)
+				if err != nil {
+					logger.LogError(ctx, fmt.Sprintf("MjTaskHandler error: %v", err))
+				}
 			}
 		}
 		time.Sleep(time.Duration(15) * time.Second)
@@ -185,6 +205,7 @@ func MjTaskHandler(midjourneyChannel *model.Channel, taskIds []string, taskM map
 		if !checkMjTaskNeedUpdate(task, responseItem) {
 			continue
 		}
+		oldStatus := task.Status
 		task.Code = 1
 		task.Progress = responseItem.Progress
 		task.PromptEn = responseItem.PromptEn
@@ -225,7 +246,9 @@ func MjTaskHandler(midjourneyChannel *model.Channel, taskIds []string, taskM map
 		err = task.Update()
 		if err != nil {
 			logger.LogError(ctx, "UpdateMidjourneyTask task error: "+err.Error())
+			continue
 		}
+		webhook.NotifyMidjourney(task, oldStatus)
 	}
 
 	return nil
@@ -3,7 +3,10 @@ package controller
 import (
 	"encoding/json"
 	"net/http"
+	"one-api/common/audit"
 	"one-api/common/config"
+	"one-api/common/ldap"
+	"one-api/common/secret"
 	"one-api/common/utils"
 	"one-api/model"
 	"one-api/safty"
@@ -15,7 +18,7 @@ import (
 func GetOptions(c *gin.Context) {
 	var options []*model.Option
 	for k, v := range config.GlobalOption.GetAll() {
-		if strings.HasSuffix(k, "Token") || strings.HasSuffix(k, "Secret") {
+		if strings.HasSuffix(k, "Token") || strings.HasSuffix(k, "Secret") || strings.HasSuffix(k, "Password") {
 			continue
 		}
 		options = append(options, &model.Option{
@@ -67,6 +70,30 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "LDAPAuthEnabled":
+		if option.Value == "true" && (config.LDAPServerURL == "" || config.LDAPBaseDN == "" || config.LDAPUserFilter == "") {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 LDAP 登录，请先填入 LDAP 服务器地址、Base DN 以及用户过滤条件！",
+			})
+			return
+		}
+	case "LDAPGroupMapping":
+		if _, err := ldap.ParseGroupMapping(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "SCIMEnabled":
+		if option.Value == "true" && len(config.SCIMToken) < 32 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法启用 SCIM，请先填入至少 32 位的 SCIM Token！",
+			})
+			return
+		}
 	case "EmailDomainRestrictionEnabled":
 		if option.Value == "true" && len(config.EmailDomainWhitelist) == 0 {
 			c.JSON(http.StatusOK, gin.H{
@@ -92,6 +119,8 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	audit.SetTarget(c, "option", option.Key)
+	audit.SetBefore(c, optionSnapshot(option.Key, config.GlobalOption.Get(option.Key)))
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -100,9 +129,18 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
+	audit.SetAfter(c, optionSnapshot(option.Key, option.Value))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
 	})
 	return
 }
+
+// optionSnapshot 审计日志中的配置项，密钥类配置只记录隐藏后的值
+func optionSnapshot(key, value string) map[string]any {
+	if model.IsSecretOptionKey(key) {
+		value = secret.Mask(value)
+	}
+	return map[string]any{"key": key, "value": value}
+}
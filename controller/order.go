@@ -176,6 +176,12 @@ func PaymentCallback(c *gin.Context) {
 
 	model.RecordQuotaLog(order.UserId, model.LogTypeTopup, order.Quota, c.ClientIP(), fmt.Sprintf("在线充值成功，充值积分: %d，支付金额：%.2f %s", order.Quota, order.OrderAmount, order.OrderCurrency))
 
+	// 兑换码赠送的充值加成
+	_, err = model.ApplyTopupBonus(order.UserId, order.Quota)
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to apply topup bonus, trade_no: %s, error: %s", payNotify.TradeNo, err.Error()))
+	}
+
 }
 
 func CheckOrderStatus(c *gin.Context) {
@@ -4,6 +4,7 @@ import (
 	"log"
 	"net/http"
 	"one-api/common"
+	"one-api/common/audit"
 	"one-api/model"
 	paymentService "one-api/payment"
 	"strconv"
@@ -23,6 +24,9 @@ func GetPaymentList(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	for _, payment := range *payments.Data {
+		payment.Config = model.MaskPaymentConfig(payment.Config)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -48,6 +52,7 @@ func GetPayment(c *gin.Context) {
 		})
 		return
 	}
+	payment.Config = model.MaskPaymentConfig(payment.Config)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -85,6 +90,9 @@ func AddPayment(c *gin.Context) {
 		return
 	}
 
+	audit.SetTarget(c, "payment", payment.ID)
+	audit.SetAfter(c, &payment)
+	payment.Config = model.MaskPaymentConfig(payment.Config)
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Payment added successfully",
@@ -106,11 +114,24 @@ func UpdatePayment(c *gin.Context) {
 		overwrite = false
 	}
 
+	original, err := model.GetPaymentByID(payment.ID)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	payment.Config = model.RestorePaymentConfig(payment.Config, original.Config)
+	audit.SetTarget(c, "payment", payment.ID)
+	audit.SetBefore(c, original)
+
 	err = payment.Update(overwrite)
 	if err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	if updated, err := model.GetPaymentByID(payment.ID); err == nil {
+		audit.SetAfter(c, updated)
+	}
+	payment.Config = model.MaskPaymentConfig(payment.Config)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -129,6 +150,10 @@ func DeletePayment(c *gin.Context) {
 		return
 	}
 
+	audit.SetTarget(c, "payment", id)
+	if original, err := model.GetPaymentByID(id); err == nil {
+		audit.SetBefore(c, original)
+	}
 	payment := model.Payment{ID: id}
 	err = payment.Delete()
 	if err != nil {
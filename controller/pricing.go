@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"one-api/common"
+	"one-api/common/audit"
 	"one-api/model"
 
 	"github.com/spf13/viper"
@@ -69,10 +70,12 @@ func AddPrice(c *gin.Context) {
 		return
 	}
 
+	audit.SetTarget(c, "price", price.Model)
 	if err := model.PricingInstance.AddPrice(&price); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	audit.SetAfter(c, &price)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -95,10 +98,15 @@ func UpdatePrice(c *gin.Context) {
 		return
 	}
 
+	audit.SetTarget(c, "price", modelName)
+	if model.PricingInstance.HasPrice(modelName) {
+		audit.SetBefore(c, model.PricingInstance.GetPrice(modelName))
+	}
 	if err := model.PricingInstance.UpdatePrice(modelName, &price); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	audit.SetAfter(c, &price)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -115,6 +123,10 @@ func DeletePrice(c *gin.Context) {
 	modelName = modelName[1:]
 	modelName, _ = url.PathUnescape(modelName)
 
+	audit.SetTarget(c, "price", modelName)
+	if model.PricingInstance.HasPrice(modelName) {
+		audit.SetBefore(c, model.PricingInstance.GetPrice(modelName))
+	}
 	if err := model.PricingInstance.DeletePrice(modelName); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"one-api/common"
 	"one-api/common/utils"
@@ -11,7 +12,7 @@ import (
 )
 
 func GetRedemptionsList(c *gin.Context) {
-	var params model.GenericParams
+	var params model.SearchRedemptionsParams
 	if err := c.ShouldBindQuery(&params); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
@@ -53,8 +54,14 @@ func GetRedemption(c *gin.Context) {
 	})
 }
 
+type AddRedemptionRequest struct {
+	model.Redemption
+	MaxUses        *int `json:"max_uses"`
+	MaxUsesPerUser *int `json:"max_uses_per_user"`
+}
+
 func AddRedemption(c *gin.Context) {
-	redemption := model.Redemption{}
+	redemption := AddRedemptionRequest{}
 	err := c.ShouldBindJSON(&redemption)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -84,15 +91,47 @@ func AddRedemption(c *gin.Context) {
 		})
 		return
 	}
+	if err := checkRedemptionCampaign(&redemption.Redemption, nil); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 未指定时保持一次性兑换码的行为
+	maxUses := 1
+	if redemption.MaxUses != nil {
+		maxUses = *redemption.MaxUses
+	}
+	maxUsesPerUser := 1
+	if redemption.MaxUsesPerUser != nil {
+		maxUsesPerUser = *redemption.MaxUsesPerUser
+	}
+	if maxUses < 0 || maxUsesPerUser < 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "兑换码使用次数不能小于0",
+		})
+		return
+	}
+
 	var keys []string
 	for i := 0; i < redemption.Count; i++ {
 		key := utils.GetUUID()
 		cleanRedemption := model.Redemption{
-			UserId:      c.GetInt("id"),
-			Name:        redemption.Name,
-			Key:         key,
-			CreatedTime: utils.GetTimestamp(),
-			Quota:       redemption.Quota,
+			UserId:         c.GetInt("id"),
+			Name:           redemption.Name,
+			Key:            key,
+			CreatedTime:    utils.GetTimestamp(),
+			Quota:          redemption.Quota,
+			Campaign:       redemption.Campaign,
+			ExpiredTime:    redemption.ExpiredTime,
+			MaxUses:        maxUses,
+			MaxUsesPerUser: maxUsesPerUser,
+			GrantGroup:     redemption.GrantGroup,
+			GrantGroupDays: redemption.GrantGroupDays,
+			TopupBonus:     redemption.TopupBonus,
 		}
 		err = cleanRedemption.Insert()
 		if err != nil {
@@ -112,6 +151,28 @@ func AddRedemption(c *gin.Context) {
 	})
 }
 
+// checkRedemptionCampaign 校验活动设置，original 为修改前的兑换码，新建时为 nil
+// 过期时间只在变更时校验，已过期的兑换码仍然可以修改其他设置或延长有效期
+func checkRedemptionCampaign(redemption *model.Redemption, original *model.Redemption) error {
+	if len(redemption.Campaign) > 64 {
+		return errors.New("活动名称长度不能超过64")
+	}
+	expiryChanged := original == nil || original.ExpiredTime != redemption.ExpiredTime
+	if expiryChanged && redemption.ExpiredTime != 0 && redemption.ExpiredTime < utils.GetTimestamp() {
+		return errors.New("过期时间不能早于当前时间")
+	}
+	if redemption.GrantGroup != "" && model.GlobalUserGroupRatio.GetBySymbol(redemption.GrantGroup) == nil {
+		return errors.New("分组不存在")
+	}
+	if redemption.GrantGroupDays < 0 {
+		return errors.New("分组有效天数不能小于0")
+	}
+	if redemption.TopupBonus < 0 || redemption.TopupBonus > 10 {
+		return errors.New("充值赠送比例必须在0-10之间")
+	}
+	return nil
+}
+
 func DeleteRedemption(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	err := model.DeleteRedemptionById(id)
@@ -130,7 +191,7 @@ func DeleteRedemption(c *gin.Context) {
 
 func UpdateRedemption(c *gin.Context) {
 	statusOnly := c.Query("status_only")
-	redemption := model.Redemption{}
+	redemption := AddRedemptionRequest{}
 	err := c.ShouldBindJSON(&redemption)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -150,9 +211,27 @@ func UpdateRedemption(c *gin.Context) {
 	if statusOnly != "" {
 		cleanRedemption.Status = redemption.Status
 	} else {
+		if err := checkRedemptionCampaign(&redemption.Redemption, cleanRedemption); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 		// If you add more fields, please also update redemption.Update()
 		cleanRedemption.Name = redemption.Name
 		cleanRedemption.Quota = redemption.Quota
+		cleanRedemption.Campaign = redemption.Campaign
+		cleanRedemption.ExpiredTime = redemption.ExpiredTime
+		if redemption.MaxUses != nil && *redemption.MaxUses >= 0 {
+			cleanRedemption.MaxUses = *redemption.MaxUses
+		}
+		if redemption.MaxUsesPerUser != nil && *redemption.MaxUsesPerUser >= 0 {
+			cleanRedemption.MaxUsesPerUser = *redemption.MaxUsesPerUser
+		}
+		cleanRedemption.GrantGroup = redemption.GrantGroup
+		cleanRedemption.GrantGroupDays = redemption.GrantGroupDays
+		cleanRedemption.TopupBonus = redemption.TopupBonus
 	}
 	err = cleanRedemption.Update()
 	if err != nil {
@@ -168,3 +247,16 @@ func UpdateRedemption(c *gin.Context) {
 		"data":    cleanRedemption,
 	})
 }
+
+func GetRedemptionCampaignStatistics(c *gin.Context) {
+	statistics, err := model.GetRedemptionCampaignStatistics(c.Query("campaign"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    statistics,
+	})
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/scheduler"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSchedulerJobs 获取定时任务列表，从节点不执行定时任务，列表为空
+func GetSchedulerJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    scheduler.Manager.ListJobs(),
+	})
+}
+
+// RunSchedulerJob 立即执行一次任务，已暂停的任务也可以手动执行
+func RunSchedulerJob(c *gin.Context) {
+	if err := scheduler.Manager.RunJob(c.Param("name")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func PauseSchedulerJob(c *gin.Context) {
+	if err := scheduler.Manager.PauseJob(c.Param("name")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func ResumeSchedulerJob(c *gin.Context) {
+	if err := scheduler.Manager.ResumeJob(c.Param("name")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetSchedulerRunsList(c *gin.Context) {
+	var params model.SchedulerRunQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	runs, err := model.GetSchedulerRunsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    runs,
+	})
+}
@@ -0,0 +1,753 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"one-api/common/audit"
+	"one-api/common/config"
+	"one-api/common/scim"
+	"one-api/model"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SCIM 用户只映射用户名、显示名称、邮箱、状态和所属分组，其他属性忽略
+// 一个用户只属于一个分组，加入新分组时会离开原分组，移出分组时回到 default 分组
+
+const scimDefaultGroup = "default"
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimUser struct {
+	Schemas     []string     `json:"schemas"`
+	Id          string       `json:"id,omitempty"`
+	UserName    string       `json:"userName"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Name        *scimName    `json:"name,omitempty"`
+	Emails      []scimEmail  `json:"emails,omitempty"`
+	Active      bool         `json:"active"`
+	Groups      []scimMember `json:"groups,omitempty"`
+	Meta        *scim.Meta   `json:"meta,omitempty"`
+}
+
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	Id          string       `json:"id,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members,omitempty"`
+	Meta        *scim.Meta   `json:"meta,omitempty"`
+}
+
+// scimUserChanges 请求中出现的用户属性，未出现的属性保持不变
+type scimUserChanges struct {
+	UserName    *string
+	DisplayName *string
+	Email       *string
+	Active      *bool
+}
+
+func GetSCIMServiceProviderConfig(c *gin.Context) {
+	scim.Respond(c, http.StatusOK, gin.H{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scim.MaxCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Authentication scheme using the OAuth Bearer Token Standard",
+		}},
+	})
+}
+
+func SCIMListUsers(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidFilter, err.Error())
+		return
+	}
+	startIndex, count, offset := scim.Pagination(c)
+
+	conditions := &model.User{}
+	if filter != nil {
+		switch filter.Attribute {
+		case "userName":
+			conditions.Username = filter.Value
+		case "emails.value", "emails":
+			conditions.Email = filter.Value
+		case "externalId":
+			// 不保存 externalId，身份提供商会改用 userName 匹配
+			scim.Respond(c, http.StatusOK, scim.NewListResponse([]*scimUser{}, 0, startIndex, 0))
+			return
+		default:
+			scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidFilter, "不支持的过滤属性: "+filter.Attribute)
+			return
+		}
+		if filter.Value == "" {
+			scim.Respond(c, http.StatusOK, scim.NewListResponse([]*scimUser{}, 0, startIndex, 0))
+			return
+		}
+	}
+
+	users, total, err := model.FindUsers(conditions, offset, count)
+	if err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	groupIds := scimGroupIds()
+	resources := make([]*scimUser, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, toSCIMUser(user, groupIds))
+	}
+	scim.Respond(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex, len(resources)))
+}
+
+func SCIMGetUser(c *gin.Context) {
+	user, ok := scimFindUser(c)
+	if !ok {
+		return
+	}
+	scim.Respond(c, http.StatusOK, toSCIMUser(user, scimGroupIds()))
+}
+
+func SCIMCreateUser(c *gin.Context) {
+	var req struct {
+		scimUser
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, "无效的参数")
+		return
+	}
+	if req.UserName == "" {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidValue, "userName 不能为空")
+		return
+	}
+	if model.RecordExists(&model.User{}, "username", req.UserName, nil) {
+		scim.RespondError(c, http.StatusConflict, scim.ErrorTypeUniqueness, "用户名已存在")
+		return
+	}
+
+	user := &model.User{
+		Username:    req.UserName,
+		DisplayName: scimDisplayName(&req.scimUser),
+		Email:       scimPrimaryEmail(req.Emails),
+		Role:        config.RoleCommonUser,
+		Status:      config.UserStatusEnabled,
+	}
+	if req.Active != nil && !*req.Active {
+		user.Status = config.UserStatusDisabled
+	}
+	if err := user.Insert(0); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	audit.SetAction(c, "scim.user.create")
+	audit.SetTarget(c, "user", user.Id)
+	audit.SetAfter(c, user)
+
+	scim.Respond(c, http.StatusCreated, toSCIMUser(user, scimGroupIds()))
+}
+
+func SCIMReplaceUser(c *gin.Context) {
+	user, ok := scimFindUser(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		scimUser
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, "无效的参数")
+		return
+	}
+
+	displayName := scimDisplayName(&req.scimUser)
+	email := scimPrimaryEmail(req.Emails)
+	changes := &scimUserChanges{
+		DisplayName: &displayName,
+		Email:       &email,
+		Active:      req.Active,
+	}
+	if req.UserName != "" {
+		changes.UserName = &req.UserName
+	}
+	scimUpdateUser(c, user, changes)
+}
+
+func SCIMPatchUser(c *gin.Context) {
+	user, ok := scimFindUser(c)
+	if !ok {
+		return
+	}
+
+	var req scim.PatchRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, "无效的参数")
+		return
+	}
+
+	changes := &scimUserChanges{}
+	for _, operation := range req.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" {
+			continue
+		}
+
+		if operation.Path != "" {
+			if err := changes.apply(operation.Path, operation.Value); err != nil {
+				scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidValue, err.Error())
+				return
+			}
+			continue
+		}
+
+		// 没有 path 时 value 为包含多个属性的对象
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidValue, "无效的参数")
+			return
+		}
+		for path, value := range values {
+			if err := changes.apply(path, value); err != nil {
+				scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidValue, err.Error())
+				return
+			}
+		}
+	}
+	scimUpdateUser(c, user, changes)
+}
+
+// SCIMDeleteUser 删除用户前先禁用用户和令牌，确保令牌缓存立即失效
+func SCIMDeleteUser(c *gin.Context) {
+	user, ok := scimFindUser(c)
+	if !ok {
+		return
+	}
+	if user.Role >= config.RoleAdminUser {
+		scim.RespondError(c, http.StatusForbidden, scim.ErrorTypeMutability, "无法删除管理员用户")
+		return
+	}
+	audit.SetAction(c, "scim.user.delete")
+	audit.SetTarget(c, "user", user.Id)
+	audit.SetBefore(c, user)
+
+	if err := model.SetUserStatus(user.Id, config.UserStatusDisabled); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if err := user.Delete(); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (changes *scimUserChanges) apply(path string, value json.RawMessage) error {
+	switch {
+	case path == "active":
+		active, err := scim.ParseBool(value)
+		if err != nil {
+			return errors.New("active 需要是布尔值")
+		}
+		changes.Active = &active
+	case path == "userName", path == "displayName", path == "name.formatted", strings.HasPrefix(path, "emails[") && strings.HasSuffix(path, ".value"):
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return errors.New(path + " 需要是字符串")
+		}
+		switch path {
+		case "userName":
+			changes.UserName = &text
+		case "displayName", "name.formatted":
+			changes.DisplayName = &text
+		default:
+			changes.Email = &text
+		}
+	case path == "emails":
+		var emails []scimEmail
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return errors.New("emails 格式错误")
+		}
+		email := scimPrimaryEmail(emails)
+		changes.Email = &email
+	case path == "name":
+		var name scimName
+		if err := json.Unmarshal(value, &name); err != nil {
+			return errors.New("name 格式错误")
+		}
+		displayName := scimDisplayName(&scimUser{Name: &name})
+		changes.DisplayName = &displayName
+	}
+	// 其他属性（如部门、职位）不保存
+	return nil
+}
+
+func scimUpdateUser(c *gin.Context, user *model.User, changes *scimUserChanges) {
+	if user.Role >= config.RoleAdminUser {
+		scim.RespondError(c, http.StatusForbidden, scim.ErrorTypeMutability, "无法修改管理员用户")
+		return
+	}
+	audit.SetAction(c, "scim.user.update")
+	audit.SetTarget(c, "user", user.Id)
+	audit.SetBefore(c, *user)
+
+	fields := make(map[string]any)
+	if changes.UserName != nil && *changes.UserName != user.Username {
+		if *changes.UserName == "" {
+			scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidValue, "userName 不能为空")
+			return
+		}
+		if model.RecordExists(&model.User{}, "username", *changes.UserName, user.Id) {
+			scim.RespondError(c, http.StatusConflict, scim.ErrorTypeUniqueness, "用户名已存在")
+			return
+		}
+		fields["username"] = *changes.UserName
+		user.Username = *changes.UserName
+	}
+	if changes.DisplayName != nil {
+		fields["display_name"] = *changes.DisplayName
+		user.DisplayName = *changes.DisplayName
+	}
+	if changes.Email != nil {
+		fields["email"] = *changes.Email
+		user.Email = *changes.Email
+	}
+	if len(fields) > 0 {
+		if err := model.UpdateUser(user.Id, fields); err != nil {
+			scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+	}
+
+	if changes.Active != nil {
+		status := config.UserStatusDisabled
+		if *changes.Active {
+			status = config.UserStatusEnabled
+		}
+		if status != user.Status {
+			if err := model.SetUserStatus(user.Id, status); err != nil {
+				scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+				return
+			}
+			user.Status = status
+		}
+	}
+	audit.SetAfter(c, user)
+
+	scim.Respond(c, http.StatusOK, toSCIMUser(user, scimGroupIds()))
+}
+
+// scimFindUser 管理员不由外部系统管理，按不存在处理
+func scimFindUser(c *gin.Context) (*model.User, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		scim.RespondError(c, http.StatusNotFound, "", "用户不存在")
+		return nil, false
+	}
+	user, err := model.GetUserById(id, false)
+	if err == nil && user.Role >= config.RoleAdminUser {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			scim.RespondError(c, http.StatusNotFound, "", "用户不存在")
+		} else {
+			scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// scimGroupIds 用户分组标识到 ID 的映射，用于返回用户所属的分组
+func scimGroupIds() map[string]int {
+	userGroups, _, err := model.FindUserGroups("", 0, scim.MaxCount)
+	groupIds := make(map[string]int, len(userGroups))
+	if err != nil {
+		return groupIds
+	}
+	for _, userGroup := range userGroups {
+		groupIds[userGroup.Symbol] = userGroup.Id
+	}
+	return groupIds
+}
+
+func toSCIMUser(user *model.User, groupIds map[string]int) *scimUser {
+	id := strconv.Itoa(user.Id)
+	result := &scimUser{
+		Schemas:     []string{scim.SchemaUser},
+		Id:          id,
+		UserName:    user.Username,
+		DisplayName: user.DisplayName,
+		Active:      user.Status == config.UserStatusEnabled,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Location:     config.ServerAddress + "/scim/v2/Users/" + id,
+		},
+	}
+	if user.DisplayName != "" {
+		result.Name = &scimName{Formatted: user.DisplayName}
+	}
+	if user.Email != "" {
+		result.Emails = []scimEmail{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	if user.CreatedTime > 0 {
+		result.Meta.Created = time.Unix(user.CreatedTime, 0).UTC().Format(time.RFC3339)
+	}
+	if groupId, ok := groupIds[user.Group]; ok {
+		result.Groups = []scimMember{{Value: strconv.Itoa(groupId), Display: user.Group}}
+	}
+	return result
+}
+
+func scimDisplayName(user *scimUser) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	if user.Name == nil {
+		return ""
+	}
+	if user.Name.Formatted != "" {
+		return user.Name.Formatted
+	}
+	return strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName)
+}
+
+func scimPrimaryEmail(emails []scimEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func SCIMListGroups(c *gin.Context) {
+	filter, err := scim.ParseFilter(c.Query("filter"))
+	if err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidFilter, err.Error())
+		return
+	}
+	startIndex, count, offset := scim.Pagination(c)
+
+	symbol := ""
+	if filter != nil {
+		if filter.Attribute != "displayName" {
+			scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidFilter, "不支持的过滤属性: "+filter.Attribute)
+			return
+		}
+		if filter.Value == "" {
+			scim.Respond(c, http.StatusOK, scim.NewListResponse([]*scimGroup{}, 0, startIndex, 0))
+			return
+		}
+		symbol = filter.Value
+	}
+
+	userGroups, total, err := model.FindUserGroups(symbol, offset, count)
+	if err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+
+	includeMembers := !strings.Contains(c.Query("excludedAttributes"), "members")
+	resources := make([]*scimGroup, 0, len(userGroups))
+	for _, userGroup := range userGroups {
+		group, err := toSCIMGroup(userGroup, includeMembers)
+		if err != nil {
+			scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		resources = append(resources, group)
+	}
+	scim.Respond(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex, len(resources)))
+}
+
+func SCIMGetGroup(c *gin.Context) {
+	userGroup, ok := scimFindGroup(c)
+	if !ok {
+		return
+	}
+	scimRespondGroup(c, http.StatusOK, userGroup)
+}
+
+// SCIMCreateGroup 新建的分组使用默认倍率，计费相关设置需要管理员在后台调整
+func SCIMCreateGroup(c *gin.Context) {
+	var req scimGroup
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, "无效的参数")
+		return
+	}
+	// 用户的分组字段最长 32 位
+	if req.DisplayName == "" || len(req.DisplayName) > 32 {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidValue, "displayName 不能为空且不能超过 32 位")
+		return
+	}
+	if _, err := model.GetUserGroupBySymbol(req.DisplayName); err == nil {
+		scim.RespondError(c, http.StatusConflict, scim.ErrorTypeUniqueness, "用户分组已存在")
+		return
+	}
+
+	userGroup := &model.UserGroup{
+		Symbol: req.DisplayName,
+		Name:   req.DisplayName,
+		Ratio:  1,
+	}
+	if err := userGroup.Create(); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	audit.SetAction(c, "scim.group.create")
+	audit.SetTarget(c, "user_group", userGroup.Id)
+	audit.SetAfter(c, userGroup)
+
+	if err := scimUpdateMembers(userGroup.Symbol, scimMemberIds(req.Members), nil); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimRespondGroup(c, http.StatusCreated, userGroup)
+}
+
+// SCIMReplaceGroup 分组标识被令牌和用户引用，不能通过 SCIM 修改，只同步成员
+func SCIMReplaceGroup(c *gin.Context) {
+	userGroup, ok := scimFindGroup(c)
+	if !ok {
+		return
+	}
+
+	var req scimGroup
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, "无效的参数")
+		return
+	}
+	audit.SetAction(c, "scim.group.update")
+	audit.SetTarget(c, "user_group", userGroup.Id)
+
+	if err := scimReplaceMembers(userGroup.Symbol, scimMemberIds(req.Members)); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scimRespondGroup(c, http.StatusOK, userGroup)
+}
+
+func SCIMPatchGroup(c *gin.Context) {
+	userGroup, ok := scimFindGroup(c)
+	if !ok {
+		return
+	}
+
+	var req scim.PatchRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidSyntax, "无效的参数")
+		return
+	}
+	audit.SetAction(c, "scim.group.update")
+	audit.SetTarget(c, "user_group", userGroup.Id)
+
+	for _, operation := range req.Operations {
+		if err := scimApplyGroupOperation(userGroup, &operation); err != nil {
+			scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeInvalidValue, err.Error())
+			return
+		}
+	}
+	scimRespondGroup(c, http.StatusOK, userGroup)
+}
+
+// SCIMDeleteGroup 删除分组前将成员移回默认分组
+func SCIMDeleteGroup(c *gin.Context) {
+	userGroup, ok := scimFindGroup(c)
+	if !ok {
+		return
+	}
+	if userGroup.Symbol == scimDefaultGroup {
+		scim.RespondError(c, http.StatusBadRequest, scim.ErrorTypeMutability, "默认用户组不能删除")
+		return
+	}
+	audit.SetAction(c, "scim.group.delete")
+	audit.SetTarget(c, "user_group", userGroup.Id)
+	audit.SetBefore(c, userGroup)
+
+	if err := scimReplaceMembers(userGroup.Symbol, nil); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	if err := userGroup.Delete(); err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func scimApplyGroupOperation(userGroup *model.UserGroup, operation *scim.PatchOperation) error {
+	op := strings.ToLower(operation.Op)
+
+	if memberId, ok := scim.MemberFilterValue(operation.Path); ok && op == "remove" {
+		return scimUpdateMembers(userGroup.Symbol, nil, scimMemberIds([]scimMember{{Value: memberId}}))
+	}
+
+	switch operation.Path {
+	case "members":
+		var members []scimMember
+		if len(operation.Value) > 0 {
+			if err := json.Unmarshal(operation.Value, &members); err != nil {
+				return errors.New("members 格式错误")
+			}
+		}
+		switch op {
+		case "add":
+			return scimUpdateMembers(userGroup.Symbol, scimMemberIds(members), nil)
+		case "remove":
+			// 没有指定成员时移除所有成员
+			if len(members) == 0 {
+				return scimReplaceMembers(userGroup.Symbol, nil)
+			}
+			return scimUpdateMembers(userGroup.Symbol, nil, scimMemberIds(members))
+		case "replace":
+			return scimReplaceMembers(userGroup.Symbol, scimMemberIds(members))
+		}
+	case "":
+		var value scimGroup
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			return errors.New("无效的参数")
+		}
+		if value.Members == nil {
+			return nil
+		}
+		if op == "replace" {
+			return scimReplaceMembers(userGroup.Symbol, scimMemberIds(value.Members))
+		}
+		return scimUpdateMembers(userGroup.Symbol, scimMemberIds(value.Members), nil)
+	}
+	// 分组标识不能修改，忽略 displayName
+	return nil
+}
+
+// scimUpdateMembers 将用户加入分组，或将仍在该分组中的用户移回默认分组
+func scimUpdateMembers(symbol string, add []int, remove []int) error {
+	if err := model.SetUsersGroup(add, symbol); err != nil {
+		return err
+	}
+	if len(remove) == 0 || symbol == scimDefaultGroup {
+		return nil
+	}
+
+	members, err := model.GetUsersByGroup(symbol)
+	if err != nil {
+		return err
+	}
+	removeIds := make([]int, 0, len(remove))
+	for _, member := range members {
+		if slices.Contains(remove, member.Id) {
+			removeIds = append(removeIds, member.Id)
+		}
+	}
+	return model.SetUsersGroup(removeIds, scimDefaultGroup)
+}
+
+func scimReplaceMembers(symbol string, memberIds []int) error {
+	members, err := model.GetUsersByGroup(symbol)
+	if err != nil {
+		return err
+	}
+	remove := make([]int, 0, len(members))
+	for _, member := range members {
+		if !slices.Contains(memberIds, member.Id) {
+			remove = append(remove, member.Id)
+		}
+	}
+	return scimUpdateMembers(symbol, memberIds, remove)
+}
+
+func scimMemberIds(members []scimMember) []int {
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		if id, err := strconv.Atoi(member.Value); err == nil && id > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func scimFindGroup(c *gin.Context) (*model.UserGroup, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		scim.RespondError(c, http.StatusNotFound, "", "用户分组不存在")
+		return nil, false
+	}
+	userGroup, err := model.GetUserGroupsById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			scim.RespondError(c, http.StatusNotFound, "", "用户分组不存在")
+		} else {
+			scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		}
+		return nil, false
+	}
+	return userGroup, true
+}
+
+func scimRespondGroup(c *gin.Context, status int, userGroup *model.UserGroup) {
+	group, err := toSCIMGroup(userGroup, true)
+	if err != nil {
+		scim.RespondError(c, http.StatusInternalServerError, "", err.Error())
+		return
+	}
+	scim.Respond(c, status, group)
+}
+
+// toSCIMGroup displayName 使用分组标识，身份提供商按 displayName 匹配已有分组
+func toSCIMGroup(userGroup *model.UserGroup, includeMembers bool) (*scimGroup, error) {
+	id := strconv.Itoa(userGroup.Id)
+	group := &scimGroup{
+		Schemas:     []string{scim.SchemaGroup},
+		Id:          id,
+		DisplayName: userGroup.Symbol,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Location:     config.ServerAddress + "/scim/v2/Groups/" + id,
+		},
+	}
+	if !includeMembers {
+		return group, nil
+	}
+
+	members, err := model.GetUsersByGroup(userGroup.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	group.Members = make([]scimMember, 0, len(members))
+	for _, member := range members {
+		group.Members = append(group.Members, scimMember{Value: strconv.Itoa(member.Id), Display: member.Username})
+	}
+	return group, nil
+}
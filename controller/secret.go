@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/audit"
+	"one-api/common/logger"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 管理接口默认只返回隐藏后的密钥，以下接口返回明文并记录操作日志
+
+// RevealChannelKey 查看渠道的完整密钥
+func RevealChannelKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	channel, err := model.GetChannelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	audit.SetAction(c, "channel.reveal_key")
+	audit.SetTarget(c, "channel", channel.Id)
+	recordSecretReveal(c, fmt.Sprintf("查看了渠道 #%d %s 的密钥", channel.Id, channel.Name))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    channel.Key,
+	})
+}
+
+// RevealPaymentConfig 查看支付方式的完整配置
+func RevealPaymentConfig(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	payment, err := model.GetPaymentByID(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	audit.SetAction(c, "payment.reveal_config")
+	audit.SetTarget(c, "payment", payment.ID)
+	recordSecretReveal(c, fmt.Sprintf("查看了支付方式 #%d %s 的配置", payment.ID, payment.Name))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    payment.Config,
+	})
+}
+
+func recordSecretReveal(c *gin.Context, content string) {
+	userId := c.GetInt("id")
+	model.RecordLog(userId, model.LogTypeManage, content)
+	logger.SysLog(fmt.Sprintf("user #%d %s, ip: %s", userId, content, c.ClientIP()))
+}
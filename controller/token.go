@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"one-api/common"
+	"one-api/common/audit"
 	"one-api/common/config"
 	"one-api/common/utils"
 	"one-api/model"
@@ -381,6 +382,8 @@ func UpdateTokenByAdmin(c *gin.Context) {
 		})
 		return
 	}
+	audit.SetTarget(c, "token", cleanToken.Id)
+	audit.SetBefore(c, cleanToken)
 
 	if token.Status == config.TokenStatusEnabled {
 		if cleanToken.Status == config.TokenStatusExpired && cleanToken.ExpiredTime <= utils.GetTimestamp() && cleanToken.ExpiredTime != -1 {
@@ -463,6 +466,7 @@ func UpdateTokenByAdmin(c *gin.Context) {
 		})
 		return
 	}
+	audit.SetAfter(c, cleanToken)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -519,5 +523,13 @@ func validateTokenSetting(setting *model.TokenSetting) error {
 		}
 	}
 
+	if err := setting.Limits.RequestPolicy.Validate(); err != nil {
+		return err
+	}
+
+	if setting.ContextManage.ToolOutputLimit < 0 {
+		return errors.New("tool output limit must not be negative")
+	}
+
 	return nil
 }
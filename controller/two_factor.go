@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/audit"
+	"one-api/common/config"
+	"one-api/common/totp"
+	"one-api/common/utils"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// 密码校验通过后，需要在有效期内提交两步验证码
+const twoFactorLoginTimeout = 5 * 60
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// beginTwoFactorLogin 记录待验证的用户，前端根据 require_2fa 显示验证码输入框
+func beginTwoFactorLogin(user *model.User, c *gin.Context) {
+	session := sessions.Default(c)
+	session.Set("2fa_user_id", user.Id)
+	session.Set("2fa_expires", utils.GetTimestamp()+twoFactorLoginTimeout)
+	if err := session.Save(); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无法保存会话信息，请重试",
+			"success": false,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "请输入两步验证码",
+		"success": false,
+		"data": gin.H{
+			"require_2fa": true,
+		},
+	})
+}
+
+func clearPendingTwoFactor(session sessions.Session) {
+	session.Delete("2fa_user_id")
+	session.Delete("2fa_expires")
+}
+
+// TwoFactorLogin 校验 TOTP 验证码或恢复码，完成登录
+func TwoFactorLogin(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无效的参数",
+			"success": false,
+		})
+		return
+	}
+
+	session := sessions.Default(c)
+	userId, _ := session.Get("2fa_user_id").(int)
+	expires, _ := session.Get("2fa_expires").(int64)
+	if userId == 0 || expires < utils.GetTimestamp() {
+		clearPendingTwoFactor(session)
+		session.Save()
+		c.JSON(http.StatusOK, gin.H{
+			"message": "两步验证已过期，请重新登录",
+			"success": false,
+		})
+		return
+	}
+
+	user, err := model.GetUserById(userId, false)
+	if err != nil || user.Status != config.UserStatusEnabled {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "用户已被封禁或不存在",
+			"success": false,
+		})
+		return
+	}
+
+	if err := model.VerifyTwoFactor(user.Id, req.Code); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": err.Error(),
+			"success": false,
+		})
+		return
+	}
+
+	completeLogin(user, c, true)
+}
+
+func GetTwoFactorStatus(c *gin.Context) {
+	enabled := false
+	remaining := 0
+	if twoFactor, err := model.GetUserTwoFactor(c.GetInt("id")); err == nil && twoFactor.Enabled {
+		enabled = true
+		remaining = len(twoFactor.RecoveryCodes)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"enabled":                  enabled,
+			"recovery_codes_remaining": remaining,
+			"required":                 config.TwoFactorRequiredForAdmin && c.GetInt("role") >= config.RoleAdminUser,
+		},
+	})
+}
+
+// SetupTwoFactor 生成密钥和扫码地址，校验验证码后才会启用
+func SetupTwoFactor(c *gin.Context) {
+	secret, err := model.SetupTwoFactor(c.GetInt("id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"secret": secret,
+			"url":    totp.URL(model.TwoFactorIssuer(), c.GetString("username"), secret),
+		},
+	})
+}
+
+// EnableTwoFactor 启用两步验证，当前会话视为已通过两步验证
+func EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	codes, err := model.EnableTwoFactor(c.GetInt("id"), req.Code)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if userSession, err := model.GetUserSession(c.GetString("session_id")); err == nil {
+		userSession.MarkTwoFactor()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	id := c.GetInt("id")
+	if err := model.VerifyTwoFactor(id, req.Code); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	if err := model.DisableTwoFactor(id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，原恢复码全部失效
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	id := c.GetInt("id")
+	if err := model.VerifyTwoFactor(id, req.Code); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	codes, err := model.RegenerateRecoveryCodes(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// ResetUserTwoFactor 管理员为丢失验证器的用户关闭两步验证
+func ResetUserTwoFactor(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, ok := getManagedUser(c, id)
+	if !ok {
+		return
+	}
+	audit.SetAction(c, "user.reset_2fa")
+	audit.SetTarget(c, "user", user.Id)
+
+	if err := model.DisableTwoFactor(user.Id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
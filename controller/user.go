@@ -7,6 +7,7 @@ import (
 	"math"
 	"net/http"
 	"one-api/common"
+	"one-api/common/audit"
 	"one-api/common/config"
 	"one-api/common/limit"
 	"one-api/common/utils"
@@ -65,13 +66,34 @@ func Login(c *gin.Context) {
 }
 
 // setup session & cookies and then return user info
+// 启用了两步验证的用户需要先通过 TwoFactorLogin 校验验证码
 func setupLogin(user *model.User, c *gin.Context) {
+	if model.IsTwoFactorEnabled(user.Id) {
+		beginTwoFactorLogin(user, c)
+		return
+	}
+	completeLogin(user, c, false)
+}
+
+// completeLogin 创建登录会话，twoFactor 表示是否已通过两步验证或通行密钥
+func completeLogin(user *model.User, c *gin.Context, twoFactor bool) {
+	userSession, err := model.CreateUserSession(user.Id, c.ClientIP(), c.Request.UserAgent(), twoFactor)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "无法保存会话信息，请重试",
+			"success": false,
+		})
+		return
+	}
+
 	session := sessions.Default(c)
+	clearPendingTwoFactor(session)
+	session.Set("sid", userSession.SessionId)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
 	session.Set("role", user.Role)
 	session.Set("status", user.Status)
-	err := session.Save()
+	err = session.Save()
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "无法保存会话信息，请重试",
@@ -101,6 +123,9 @@ func setupLogin(user *model.User, c *gin.Context) {
 
 func Logout(c *gin.Context) {
 	session := sessions.Default(c)
+	if sid, ok := session.Get("sid").(string); ok {
+		model.DeleteUserSessionBySessionId(sid)
+	}
 	session.Clear()
 	err := session.Save()
 	if err != nil {
@@ -194,7 +219,7 @@ func GetUsersList(c *gin.Context) {
 		return
 	}
 
-	users, err := model.GetUsersList(&params)
+	users, err := model.GetUsersList(&params, c.GetInt("role"))
 	if err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
@@ -223,11 +248,10 @@ func GetUser(c *gin.Context) {
 		})
 		return
 	}
-	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != config.RoleRootUser {
+	if !model.CanViewUser(c.GetInt("role"), user) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
-			"message": "无权获取同级或更高等级用户的信息",
+			"message": "无权获取该用户的信息",
 		})
 		return
 	}
@@ -331,9 +355,23 @@ func GenerateAccessToken(c *gin.Context) {
 		})
 		return
 	}
+
+	twoFactor := false
+	if userSession, err := model.GetUserSession(c.GetString("session_id")); err == nil && userSession.UserId == id {
+		twoFactor = userSession.TwoFactor
+	}
+	// 强制管理员两步验证时，管理令牌只能在通过两步验证的会话中生成
+	if config.TwoFactorRequiredForAdmin && (user.Role >= config.RoleAdminUser || user.CustomRoleId > 0) && !twoFactor {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "管理员需要启用两步验证，并通过两步验证重新登录后才能生成访问令牌",
+		})
+		return
+	}
+
 	user.AccessToken = utils.GetUUID()
 
-	if model.DB.Where("access_token = ?", user.AccessToken).First(user).RowsAffected != 0 {
+	if model.DB.Where("access_token = ?", user.AccessToken).First(&model.User{}).RowsAffected != 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "请重试，系统生成的 UUID 竟然重复了！",
@@ -341,7 +379,7 @@ func GenerateAccessToken(c *gin.Context) {
 		return
 	}
 
-	if err := user.Update(false); err != nil {
+	if err := model.UpdateAccessToken(user.Id, user.AccessToken, twoFactor); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -429,14 +467,14 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= originUser.Role && myRole != config.RoleRootUser {
+	if !model.CanManageUser(myRole, originUser) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
-	if myRole <= updatedUser.Role && myRole != config.RoleRootUser {
+	if !model.CanAssignRole(myRole, updatedUser.Role) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权将其他用户权限等级提升到大于等于自己的权限等级",
@@ -446,6 +484,8 @@ func UpdateUser(c *gin.Context) {
 	if updatedUser.Password == "$I_LOVE_U" {
 		updatedUser.Password = "" // rollback to what it should be
 	}
+	audit.SetTarget(c, "user", originUser.Id)
+	audit.SetBefore(c, originUser)
 	updatePassword := updatedUser.Password != ""
 	if err := updatedUser.Update(updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -457,6 +497,9 @@ func UpdateUser(c *gin.Context) {
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", common.LogQuota(originUser.Quota), common.LogQuota(updatedUser.Quota)))
 	}
+	if user, err := model.GetUserById(originUser.Id, false); err == nil {
+		audit.SetAfter(c, user)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -526,14 +569,15 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
-	myRole := c.GetInt("role")
-	if myRole <= originUser.Role {
+	if originUser.Role == config.RoleRootUser || !model.CanManageUser(c.GetInt("role"), originUser) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权删除同权限等级或更高权限等级的用户",
 		})
 		return
 	}
+	audit.SetTarget(c, "user", id)
+	audit.SetBefore(c, originUser)
 	err = model.DeleteUserById(id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -585,6 +629,8 @@ func CreateUser(c *gin.Context) {
 		})
 		return
 	}
+	audit.SetTarget(c, "user", cleanUser.Id)
+	audit.SetAfter(c, &cleanUser)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -622,13 +668,16 @@ func ManageUser(c *gin.Context) {
 		return
 	}
 	myRole := c.GetInt("role")
-	if myRole <= user.Role && myRole != config.RoleRootUser {
+	if !model.CanManageUser(myRole, &user) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权更新同权限等级或更高权限等级的用户信息",
 		})
 		return
 	}
+	audit.SetAction(c, "user."+req.Action)
+	audit.SetTarget(c, "user", user.Id)
+	audit.SetBefore(c, &user)
 	switch req.Action {
 	case "disable":
 		user.Status = config.UserStatusDisabled
@@ -709,6 +758,12 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	if req.Action == "disable" || req.Action == "delete" {
+		model.DeleteUserSessions(user.Id, "")
+	}
+	if req.Action != "delete" {
+		audit.SetAfter(c, &user)
+	}
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -814,6 +869,17 @@ func ChangeUserQuota(c *gin.Context) {
 		return
 	}
 
+	if userId == c.GetInt("id") {
+		common.APIRespondWithError(c, http.StatusOK, errors.New("不能修改自己的额度"))
+		return
+	}
+	user, ok := getManagedUser(c, userId)
+	if !ok {
+		return
+	}
+
+	audit.SetTarget(c, "user", userId)
+	audit.SetBefore(c, map[string]any{"quota": user.Quota})
 	err = model.ChangeUserQuota(userId, req.Quota, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -830,6 +896,9 @@ func ChangeUserQuota(c *gin.Context) {
 	}
 
 	model.RecordQuotaLog(userId, model.LogTypeManage, req.Quota, c.ClientIP(), remark)
+	if user, err := model.GetUserById(userId, false); err == nil {
+		audit.SetAfter(c, map[string]any{"quota": user.Quota})
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
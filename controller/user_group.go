@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"one-api/common"
+	"one-api/common/audit"
 	"one-api/model"
 	"strconv"
 
@@ -52,11 +53,17 @@ func AddUserGroup(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	if err := validateUserGroupPolicy(&userGroup); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
 
 	if err := userGroup.Create(); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	audit.SetTarget(c, "user_group", userGroup.Id)
+	audit.SetAfter(c, &userGroup)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -71,11 +78,22 @@ func UpdateUserGroup(c *gin.Context) {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	if err := validateUserGroupPolicy(&userGroup); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
 
+	audit.SetTarget(c, "user_group", userGroup.Id)
+	if original, err := model.GetUserGroupsById(userGroup.Id); err == nil {
+		audit.SetBefore(c, original)
+	}
 	if err := userGroup.Update(); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
 	}
+	if updated, err := model.GetUserGroupsById(userGroup.Id); err == nil {
+		audit.SetAfter(c, updated)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -97,6 +115,8 @@ func DeleteUserGroup(c *gin.Context) {
 		return
 	}
 
+	audit.SetTarget(c, "user_group", userGroup.Id)
+	audit.SetBefore(c, userGroup)
 	if err := userGroup.Delete(); err != nil {
 		common.APIRespondWithError(c, http.StatusOK, err)
 		return
@@ -150,3 +170,11 @@ func ChangeUserGroupEnable(c *gin.Context) {
 		"message": "",
 	})
 }
+
+func validateUserGroupPolicy(userGroup *model.UserGroup) error {
+	if userGroup.Policy == nil {
+		return nil
+	}
+	policy := userGroup.Policy.Data()
+	return policy.Validate()
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/audit"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getManagedUser 获取要管理的用户，管理范围见 model.CanManageUser
+func getManagedUser(c *gin.Context, id int) (*model.User, bool) {
+	user, err := model.GetUserById(id, false)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return nil, false
+	}
+	if !model.CanManageUser(c.GetInt("role"), user) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权管理该用户",
+		})
+		return nil, false
+	}
+	return user, true
+}
+
+func respondUserSessions(c *gin.Context, userId int) {
+	userSessions, err := model.GetUserSessions(userId)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	currentSessionId := c.GetString("session_id")
+	data := make([]gin.H, 0, len(userSessions))
+	for _, userSession := range userSessions {
+		data = append(data, gin.H{
+			"id":             userSession.Id,
+			"ip":             userSession.Ip,
+			"user_agent":     userSession.UserAgent,
+			"two_factor":     userSession.TwoFactor,
+			"created_time":   userSession.CreatedTime,
+			"last_seen_time": userSession.LastSeenTime,
+			"current":        currentSessionId != "" && userSession.SessionId == currentSessionId,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    data,
+	})
+}
+
+// GetSelfSessions 获取当前用户的登录会话
+func GetSelfSessions(c *gin.Context) {
+	respondUserSessions(c, c.GetInt("id"))
+}
+
+func DeleteSelfSession(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := model.DeleteUserSession(c.GetInt("id"), id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// DeleteOtherSelfSessions 退出除当前会话以外的所有会话
+func DeleteOtherSelfSessions(c *gin.Context) {
+	count, err := model.DeleteUserSessions(c.GetInt("id"), c.GetString("session_id"))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
+// RevokeSelfAccessToken 吊销当前用户的 access token
+func RevokeSelfAccessToken(c *gin.Context) {
+	if err := model.RevokeAccessToken(c.GetInt("id")); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func GetUserSessions(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, ok := getManagedUser(c, id)
+	if !ok {
+		return
+	}
+	respondUserSessions(c, user.Id)
+}
+
+// DeleteUserSessions 强制用户退出所有会话
+func DeleteUserSessions(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, ok := getManagedUser(c, id)
+	if !ok {
+		return
+	}
+	audit.SetAction(c, "user.revoke_sessions")
+	audit.SetTarget(c, "user", user.Id)
+
+	count, err := model.DeleteUserSessions(user.Id, "")
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+}
+
+func RevokeUserAccessToken(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	user, ok := getManagedUser(c, id)
+	if !ok {
+		return
+	}
+	audit.SetAction(c, "user.revoke_access_token")
+	audit.SetTarget(c, "user", user.Id)
+
+	if err := model.RevokeAccessToken(user.Id); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/audit"
+	"one-api/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetVirtualModelsList(c *gin.Context) {
+	var params model.SearchVirtualModelParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	virtualModels, err := model.GetVirtualModelsList(&params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    virtualModels,
+	})
+}
+
+func GetVirtualModel(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	virtualModel, err := model.GetVirtualModelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    virtualModel,
+	})
+}
+
+func AddVirtualModel(c *gin.Context) {
+	virtualModel := model.VirtualModel{}
+	if err := c.ShouldBindJSON(&virtualModel); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	if err := virtualModel.Insert(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "virtual_model", virtualModel.Id)
+	audit.SetAfter(c, &virtualModel)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    virtualModel,
+	})
+}
+
+func UpdateVirtualModel(c *gin.Context) {
+	virtualModel := model.VirtualModel{}
+	if err := c.ShouldBindJSON(&virtualModel); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	original, err := model.GetVirtualModelById(virtualModel.Id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "virtual_model", virtualModel.Id)
+	audit.SetBefore(c, original)
+
+	if err := virtualModel.Update(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetAfter(c, &virtualModel)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+func DeleteVirtualModel(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	virtualModel, err := model.GetVirtualModelById(id)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+	audit.SetTarget(c, "virtual_model", virtualModel.Id)
+	audit.SetBefore(c, virtualModel)
+
+	if err := virtualModel.Delete(); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -362,8 +362,8 @@ func WebauthnFinishLogin(c *gin.Context) {
 	sess.Delete("webauthn_user_id")
 	sess.Save()
 
-	// 设置用户登录状态
-	setupLogin(user, c)
+	// 设置用户登录状态，通行密钥本身满足两步验证
+	completeLogin(user, c, true)
 }
 
 // 获取用户的WebAuthn凭据列表
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/common/webhook"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWebhookSecret 获取当前用户用于校验回调签名的密钥
+func GetWebhookSecret(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"secret":    webhook.UserSecret(c.GetInt("id")),
+			"algorithm": "HMAC-SHA256(secret, X-Webhook-Timestamp + \".\" + body)",
+		},
+	})
+}
+
+func GetUserWebhookDeliveries(c *gin.Context) {
+	var params model.WebhookDeliveryQueryParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	deliveries, err := model.GetUserWebhookDeliveries(c.GetInt("id"), &params)
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    deliveries,
+	})
+}
+
+func GetUserWebhookDelivery(c *gin.Context) {
+	delivery, err := model.GetUserWebhookDelivery(c.GetInt("id"), utils.String2Int(c.Param("id")))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    delivery,
+	})
+}
+
+// RedeliverWebhook 重新投递一条回调
+func RedeliverWebhook(c *gin.Context) {
+	delivery, err := webhook.Redeliver(c.GetInt("id"), utils.String2Int(c.Param("id")))
+	if err != nil {
+		common.APIRespondWithError(c, http.StatusOK, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    delivery,
+	})
+}
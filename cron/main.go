@@ -1,10 +1,12 @@
 package cron
 
 import (
+	"fmt"
 	"github.com/spf13/viper"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/scheduler"
+	"one-api/common/webhook"
 	"one-api/model"
 	"time"
 
@@ -17,6 +19,8 @@ func InitCron() {
 		return
 	}
 
+	initSchedulerStore()
+
 	// 添加每日统计任务
 	err := scheduler.Manager.AddJob(
 		"update_daily_statistics",
@@ -25,9 +29,12 @@ func InitCron() {
 			gocron.NewAtTimes(
 				gocron.NewAtTime(0, 0, 30),
 			)),
-		gocron.NewTask(func() {
-			model.UpdateStatistics(model.StatisticsUpdateTypeYesterday)
+		gocron.NewTask(func() error {
+			if err := model.UpdateStatistics(model.StatisticsUpdateTypeYesterday); err != nil {
+				return err
+			}
 			logger.SysLog("更新昨日统计数据")
+			return nil
 		}),
 	)
 	if err != nil {
@@ -40,11 +47,8 @@ func InitCron() {
 		err = scheduler.Manager.AddJob(
 			"generate_statistics_month",
 			gocron.DailyJob(1, gocron.NewAtTimes(gocron.NewAtTime(4, 0, 0))),
-			gocron.NewTask(func() {
-				err := model.InsertStatisticsMonth()
-				if err != nil {
-					logger.SysError("Generate statistics month data error:" + err.Error())
-				}
+			gocron.NewTask(func() error {
+				return model.InsertStatisticsMonth()
 			}),
 		)
 	}
@@ -53,12 +57,93 @@ func InitCron() {
 	err = scheduler.Manager.AddJob(
 		"update_statistics",
 		gocron.DurationJob(10*time.Minute),
-		gocron.NewTask(func() {
-			model.UpdateStatistics(model.StatisticsUpdateTypeToDay)
+		gocron.NewTask(func() error {
+			if err := model.UpdateStatistics(model.StatisticsUpdateTypeToDay); err != nil {
+				return err
+			}
 			logger.SysLog("10分钟统计数据")
+			return nil
+		}),
+	)
+
+	// 每十分钟处理一次到期的活动兑换码及限时分组
+	err = scheduler.Manager.AddJob(
+		"expire_redemption_grants",
+		gocron.DurationJob(10*time.Minute),
+		gocron.NewTask(func() error {
+			if _, err := model.ExpireRedemptions(); err != nil {
+				return err
+			}
+			return model.ExpireRedemptionGroupGrants()
+		}),
+	)
+
+	// 每分钟重试一次投递失败的任务回调
+	err = scheduler.Manager.AddJob(
+		"retry_webhook_deliveries",
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(func() {
+			webhook.RetryPendingDeliveries()
+		}),
+	)
+
+	// 每小时清理一次过期的请求采集内容
+	err = scheduler.Manager.AddJob(
+		"cleanup_payload_captures",
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(func() error {
+			count, err := model.DeleteExpiredPayloadCaptures()
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("清理过期请求采集 %d 条", count))
+			}
+			return nil
 		}),
 	)
 
+	// 每天清理一次过期的渠道探测记录
+	err = scheduler.Manager.AddJob(
+		"cleanup_channel_probes",
+		gocron.DurationJob(24*time.Hour),
+		gocron.NewTask(func() error {
+			count, err := model.DeleteExpiredChannelProbes(viper.GetInt("channel.probe.retention_days"))
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("清理过期渠道探测记录 %d 条", count))
+			}
+
+			count, err = model.DeleteExpiredSchedulerRuns(viper.GetInt("scheduler.run_retention_days"))
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("清理过期任务执行记录 %d 条", count))
+			}
+
+			_, err = model.DeleteExpiredSchedulerLocks()
+			return err
+		}),
+	)
+
+	if viper.GetBool("log_archive.enable") {
+		// 每天凌晨三点半归档过期的消费日志
+		err = scheduler.Manager.AddJob(
+			"archive_logs",
+			gocron.DailyJob(1, gocron.NewAtTimes(gocron.NewAtTime(3, 30, 0))),
+			gocron.NewTask(func() error {
+				if err := model.ArchiveLogs(); err != nil {
+					return err
+				}
+				_, err := model.DeleteExpiredLogArchives()
+				return err
+			}),
+		)
+	}
+
 	// 开启自动更新 并且设置了有效自动更新时间 同时自动更新模式不是system 则会从服务器拉取最新价格表
 	autoPriceUpdatesInterval := viper.GetInt("auto_price_updates_interval")
 	autoPriceUpdates := viper.GetBool("auto_price_updates")
@@ -73,13 +158,13 @@ func InitCron() {
 		err := scheduler.Manager.AddJob(
 			"update_pricing_by_service",
 			gocron.DurationJob(time.Duration(autoPriceUpdatesInterval)*time.Minute),
-			gocron.NewTask(func() {
+			gocron.NewTask(func() error {
 				err := model.UpdatePriceByPriceService()
 				if err != nil {
-					logger.SysError("Update Price Error: " + err.Error())
-					return
+					return err
 				}
 				logger.SysLog("Update Price Done")
+				return nil
 			}),
 		)
 		if err != nil {
@@ -93,3 +178,20 @@ func InitCron() {
 		return
 	}
 }
+
+// initSchedulerStore 保存任务的暂停状态和执行记录，多个主节点时通过分布式锁保证任务只执行一次
+func initSchedulerStore() {
+	scheduler.Manager.SetStore(model.SchedulerStore{})
+
+	ttl := time.Duration(viper.GetInt("scheduler.lock_ttl")) * time.Second
+	switch viper.GetString("scheduler.lock") {
+	case "redis":
+		if !config.RedisEnabled {
+			logger.SysError("scheduler.lock is redis but Redis is not enabled, distributed lock is disabled")
+			return
+		}
+		scheduler.Manager.SetLocker(scheduler.NewRedisLocker(), ttl)
+	case "database":
+		scheduler.Manager.SetLocker(model.SchedulerDBLocker{}, ttl)
+	}
+}
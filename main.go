@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"net/http"
 	"one-api/cli"
@@ -9,21 +11,27 @@ import (
 	"one-api/common/cache"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/logsink"
 	"one-api/common/notify"
 	"one-api/common/oidc"
 	"one-api/common/redis"
 	"one-api/common/requester"
 	"one-api/common/search"
+	"one-api/common/secret"
 	"one-api/common/storage"
 	"one-api/common/telegram"
+	"one-api/common/tracing"
 	"one-api/common/webauthn"
 	"one-api/controller"
 	"one-api/cron"
+	"one-api/metrics"
 	"one-api/middleware"
 	"one-api/model"
 	"one-api/relay/task"
 	"one-api/router"
 	"one-api/safty"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -48,12 +56,20 @@ func main() {
 	logger.SetupLogger()
 	logger.SysLog("One Hub " + config.Version + " started")
 
+	tracing.InitTracing()
+	defer tracing.Shutdown()
+	metrics.InitMetrics()
+	logsink.Init()
+	defer logsink.Shutdown()
+
 	// Initialize user token
 	err := common.InitUserToken()
 	if err != nil {
 		logger.FatalLog("failed to initialize user token: " + err.Error())
 	}
 
+	// 加载加密主密钥，需要在读取数据库之前
+	secret.Init()
 	// Initialize SQL Database
 	model.SetupDB()
 	defer model.CloseDB()
@@ -68,6 +84,8 @@ func main() {
 	webauthn.InitWebAuthn()
 	model.NewPricing()
 	model.HandleOldTokenMaxId()
+	// 配置导入导出、密钥轮换等命令执行完成后退出
+	cli.RunCommand()
 
 	initMemoryCache()
 	initSync()
@@ -124,6 +142,7 @@ func initHttpServer() {
 	server := gin.New()
 	server.Use(gin.Recovery())
 	server.Use(middleware.RequestId())
+	server.Use(middleware.Tracing())
 	middleware.SetUpLogger(server)
 
 	trustedHeader := viper.GetString("trusted_header")
@@ -144,9 +163,27 @@ func initHttpServer() {
 	router.SetRouter(server, buildFS, indexPage)
 	port := viper.GetString("port")
 
-	err := server.Run(":" + port)
-	if err != nil {
-		logger.FatalLog("failed to start HTTP server: " + err.Error())
+	httpServer := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
+	}
+	go func() {
+		err := httpServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	}()
+
+	// 收到退出信号后等待处理中的请求完成，返回后由 main 中的 defer 写入缓冲的日志
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	logger.SysLog("shutting down HTTP server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(viper.GetInt("shutdown_timeout"))*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.SysError("HTTP server shutdown error: " + err.Error())
 	}
 }
 
@@ -161,6 +198,7 @@ func SyncChannelCache(frequency int) {
 		logger.SysLog("syncing channels from database")
 		model.ChannelGroup.Load()
 		model.GlobalUserGroupRatio.Load()
+		model.GlobalVirtualModels.Load()
 		model.PricingInstance.Init()
 		model.ModelOwnedBysInstance.Load()
 	}
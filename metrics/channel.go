@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ChannelState 渠道在负载均衡器中的状态
+type ChannelState struct {
+	Id             int
+	Type           int
+	Disabled       bool
+	CooldownModels int
+}
+
+var (
+	channelStateFunc func() []ChannelState
+	channelStateLock sync.RWMutex
+)
+
+// SetChannelStateFunc 设置渠道状态来源，采集时调用
+func SetChannelStateFunc(f func() []ChannelState) {
+	channelStateLock.Lock()
+	defer channelStateLock.Unlock()
+	channelStateFunc = f
+}
+
+type channelCollector struct {
+	status   *prometheus.Desc
+	cooldown *prometheus.Desc
+}
+
+func init() {
+	prometheus.MustRegister(&channelCollector{
+		status: prometheus.NewDesc(
+			"channel_enabled",
+			"Whether the channel is enabled in the balancer (1 enabled, 0 disabled).",
+			[]string{"channel_id", "channel_type"}, nil,
+		),
+		cooldown: prometheus.NewDesc(
+			"channel_cooldown_models",
+			"Number of models of the channel currently in cooldown.",
+			[]string{"channel_id", "channel_type"}, nil,
+		),
+	})
+}
+
+func (cc *channelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.status
+	ch <- cc.cooldown
+}
+
+func (cc *channelCollector) Collect(ch chan<- prometheus.Metric) {
+	channelStateLock.RLock()
+	f := channelStateFunc
+	channelStateLock.RUnlock()
+	if f == nil || !channelLabel {
+		return
+	}
+
+	for _, state := range f() {
+		channelId := strconv.Itoa(state.Id)
+		channelType := strconv.Itoa(state.Type)
+
+		enabled := 1.0
+		if state.Disabled {
+			enabled = 0
+		}
+		ch <- prometheus.MustNewConstMetric(cc.status, prometheus.GaugeValue, enabled, channelId, channelType)
+		ch <- prometheus.MustNewConstMetric(cc.cooldown, prometheus.GaugeValue, float64(state.CooldownModels), channelId, channelType)
+	}
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+const otherLabel = "other"
+
+// 标签基数控制，避免模型、渠道过多导致时序数量膨胀
+var (
+	maxModelLabels = 200
+	channelLabel   = true
+	groupLabel     = true
+
+	modelLabels sync.Map
+	modelCount  int
+	modelLock   sync.Mutex
+)
+
+func InitMetrics() {
+	maxModelLabels = viper.GetInt("metrics.max_model_labels")
+	channelLabel = viper.GetBool("metrics.channel_label")
+	groupLabel = viper.GetBool("metrics.group_label")
+}
+
+// modelValue 超出数量限制的新模型统一记为 other
+func modelValue(model string) string {
+	if model == "" || maxModelLabels <= 0 {
+		return model
+	}
+
+	if _, ok := modelLabels.Load(model); ok {
+		return model
+	}
+
+	modelLock.Lock()
+	defer modelLock.Unlock()
+	if _, ok := modelLabels.Load(model); ok {
+		return model
+	}
+	if modelCount >= maxModelLabels {
+		return otherLabel
+	}
+	modelLabels.Store(model, struct{}{})
+	modelCount++
+
+	return model
+}
+
+func channelValue(channelId int) string {
+	if !channelLabel {
+		return ""
+	}
+	return strconv.Itoa(channelId)
+}
+
+func groupValue(group string) string {
+	if !groupLabel {
+		return ""
+	}
+	return group
+}
@@ -87,8 +87,8 @@ func RecordProvider(c *gin.Context, statusCode int) {
 	go SafelyRecordMetric(func() {
 		providerCounter.WithLabelValues(
 			strconv.Itoa(channelType),
-			strconv.Itoa(channelId),
-			model,
+			channelValue(channelId),
+			modelValue(model),
 			strconv.Itoa(statusCode),
 		).Inc()
 	})
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120, 300}
+
+var (
+	firstTokenDuration *prometheus.HistogramVec
+	upstreamDuration   *prometheus.HistogramVec
+	tokensCounter      *prometheus.CounterVec
+	quotaCounter       *prometheus.CounterVec
+	retryCounter       *prometheus.CounterVec
+	activeStreams      prometheus.Gauge
+	heartbeatCounter   *prometheus.CounterVec
+)
+
+func init() {
+	// 1. 上游耗时
+	firstTokenDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "relay_first_token_seconds",
+			Help:    "Time to first token of upstream responses in seconds.",
+			Buckets: latencyBuckets,
+		},
+		[]string{"channel_id", "model"},
+	)
+	upstreamDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "relay_upstream_duration_seconds",
+			Help:    "Total duration of upstream requests in seconds.",
+			Buckets: latencyBuckets,
+		},
+		[]string{"channel_id", "model", "code"},
+	)
+
+	// 2. 用量与消费
+	tokensCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "relay_tokens_total",
+			Help: "Total number of tokens consumed.",
+		},
+		[]string{"model", "group", "type"},
+	)
+	quotaCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "relay_quota_total",
+			Help: "Total quota consumed.",
+		},
+		[]string{"model", "group"},
+	)
+
+	// 3. 重试、流与心跳
+	retryCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "relay_retries_total",
+			Help: "Total number of relay retries.",
+		},
+		[]string{"model", "reason"},
+	)
+	activeStreams = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "relay_active_streams",
+			Help: "Number of streams currently being relayed.",
+		},
+	)
+	heartbeatCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "relay_heartbeat_activations_total",
+			Help: "Total number of heartbeat activations.",
+		},
+		[]string{"type"},
+	)
+}
+
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	CachedTokens     int
+	ReasoningTokens  int
+}
+
+// 记录上游请求耗时，firstResponse 为空时不记录首字耗时
+func RecordUpstream(channelId int, model string, statusCode int, start, firstResponse time.Time) {
+	go SafelyRecordMetric(func() {
+		channel := channelValue(channelId)
+		model = modelValue(model)
+
+		upstreamDuration.WithLabelValues(channel, model, strconv.Itoa(statusCode)).Observe(time.Since(start).Seconds())
+		if !firstResponse.IsZero() {
+			firstTokenDuration.WithLabelValues(channel, model).Observe(firstResponse.Sub(start).Seconds())
+		}
+	})
+}
+
+// 记录用量和消费额度
+func RecordConsume(model, group string, usage Usage, quota int) {
+	go SafelyRecordMetric(func() {
+		model = modelValue(model)
+		group = groupValue(group)
+
+		tokens := map[string]int{
+			"prompt":     usage.PromptTokens,
+			"completion": usage.CompletionTokens,
+			"cached":     usage.CachedTokens,
+			"reasoning":  usage.ReasoningTokens,
+		}
+		for tokenType, count := range tokens {
+			if count > 0 {
+				tokensCounter.WithLabelValues(model, group, tokenType).Add(float64(count))
+			}
+		}
+		if quota > 0 {
+			quotaCounter.WithLabelValues(model, group).Add(float64(quota))
+		}
+	})
+}
+
+// 记录重试，reason 为触发重试的状态码或错误类型
+func RecordRetry(model, reason string) {
+	go SafelyRecordMetric(func() {
+		retryCounter.WithLabelValues(modelValue(model), reason).Inc()
+	})
+}
+
+// 流开始时调用，返回流结束时的回调
+func TrackStream() func() {
+	activeStreams.Inc()
+	return activeStreams.Dec
+}
+
+func RecordHeartbeat(isStream bool) {
+	heartbeatType := "json"
+	if isStream {
+		heartbeatType = "stream"
+	}
+	heartbeatCounter.WithLabelValues(heartbeatType).Inc()
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"one-api/common/audit"
+	"one-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditingKey = "auditing"
+	// 只保留响应的开头用于判断是否成功
+	auditResponseLimit = 64 * 1024
+)
+
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if remain := auditResponseLimit - w.body.Len(); remain > 0 {
+		if len(data) > remain {
+			w.body.Write(data[:remain])
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// auditNext 记录管理接口的修改操作，GET 请求只有控制器设置了操作名称才会记录
+func auditNext(c *gin.Context) {
+	if c.GetBool(auditingKey) {
+		c.Next()
+		return
+	}
+	c.Set(auditingKey, true)
+
+	writer := &auditResponseWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+	c.Writer = writer
+	c.Next()
+
+	ctx := audit.From(c)
+	mutating := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead && c.Request.Method != http.MethodOptions
+	if !mutating && (ctx == nil || ctx.Action == "") {
+		return
+	}
+
+	log := &model.AuditLog{
+		UserId:   c.GetInt("id"),
+		Username: c.GetString("username"),
+		Role:     c.GetInt("role"),
+		Ip:       c.ClientIP(),
+		Method:   c.Request.Method,
+		Path:     c.Request.URL.Path,
+		Action:   c.Request.Method + " " + c.FullPath(),
+	}
+	log.Success, log.Message = auditResult(writer)
+
+	if ctx != nil {
+		if ctx.Action != "" {
+			log.Action = ctx.Action
+		}
+		log.TargetType = ctx.TargetType
+		log.TargetId = ctx.TargetId
+		if changes := audit.Diff(ctx.Before, ctx.After); len(changes) > 0 {
+			diff, _ := json.Marshal(changes)
+			log.Diff = string(diff)
+		}
+	}
+
+	model.RecordAuditLog(log)
+}
+
+// auditResult 管理接口统一返回 success 和 message，非 JSON 响应按状态码判断
+func auditResult(writer *auditResponseWriter) (bool, string) {
+	var response struct {
+		Success *bool  `json:"success"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(writer.body.Bytes(), &response); err == nil && response.Success != nil {
+		return *response.Success, response.Message
+	}
+	return writer.Status() < http.StatusBadRequest, ""
+}
@@ -10,15 +10,39 @@ import (
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func authHelper(c *gin.Context, minRole int) {
+// authHelper 校验登录状态和权限等级，权限等级不足时拥有 permissions 中任一权限也可以访问
+func authHelper(c *gin.Context, minRole int, permissions ...string) {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
 	id := session.Get("id")
 	status := session.Get("status")
-	if username == nil {
+	var userSession *model.UserSession
+	// 当前凭证是否经过两步验证：会话以登录时是否验证为准，管理令牌以生成时所在的会话为准
+	twoFactor := false
+	if username != nil {
+		// Cookie 中的会话需要与服务端记录一致，被吊销后立即失效
+		sid, _ := session.Get("sid").(string)
+		var err error
+		userSession, err = model.CacheGetUserSession(sid)
+		if err != nil || userSession.UserId != id.(int) {
+			session.Clear()
+			session.Save()
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": model.ErrUserSessionInvalid.Error(),
+			})
+			c.Abort()
+			return
+		}
+		userSession.Touch(c.ClientIP())
+		c.Set("session_id", sid)
+		twoFactor = userSession.TwoFactor
+	} else {
 		// Check access token
 		accessToken := c.Request.Header.Get("Authorization")
 		if accessToken == "" {
@@ -40,6 +64,7 @@ func authHelper(c *gin.Context, minRole int) {
 			role = user.Role
 			id = user.Id
 			status = user.Status
+			twoFactor = user.AccessToken2FA
 		} else {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -57,7 +82,7 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
-	if role.(int) < minRole {
+	if role.(int) < minRole && (len(permissions) == 0 || !model.UserHasAnyPermission(id.(int), permissions...)) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "无权进行此操作，权限不足",
@@ -65,9 +90,26 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	// 通过自定义角色权限访问管理接口的用户与管理员一样需要两步验证
+	if config.TwoFactorRequiredForAdmin && minRole >= config.RoleAdminUser && !twoFactor {
+		message := "管理员需要启用两步验证，并通过两步验证重新登录"
+		if userSession == nil {
+			message = "管理员需要启用两步验证，并通过两步验证重新登录后重新生成访问令牌"
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": message,
+		})
+		c.Abort()
+		return
+	}
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
+	if minRole >= config.RoleAdminUser {
+		auditNext(c)
+		return
+	}
 	c.Next()
 }
 
@@ -85,6 +127,11 @@ func TrySetUserBySession() func(c *gin.Context) {
 			c.Next()
 			return
 		}
+		sid, _ := session.Get("sid").(string)
+		if userSession, err := model.CacheGetUserSession(sid); err != nil || userSession.UserId != idInt {
+			c.Next()
+			return
+		}
 
 		c.Set("id", idInt)
 		userGroup, err := model.CacheGetUserGroup(idInt)
@@ -113,8 +160,29 @@ func RootAuth() func(c *gin.Context) {
 	}
 }
 
+// PermissionAuth 管理员或拥有任一权限的用户可以访问
+func PermissionAuth(permissions ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, config.RoleAdminUser, permissions...)
+	}
+}
+
+// RootPermissionAuth 超级管理员或拥有任一权限的用户可以访问
+func RootPermissionAuth(permissions ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, config.RoleRootUser, permissions...)
+	}
+}
+
 func tokenAuth(c *gin.Context, key string) {
+	span := startMiddlewareSpan(c, "auth.token")
+	defer span.End()
+
 	key = strings.TrimPrefix(key, "Bearer ")
+	if model.IsEphemeralKey(key) {
+		ephemeralKeyAuth(c, key, span)
+		return
+	}
 	key = strings.TrimPrefix(key, "sk-")
 
 	if len(key) < 48 {
@@ -130,13 +198,7 @@ func tokenAuth(c *gin.Context, key string) {
 		return
 	}
 
-	c.Set("id", token.UserId)
-	c.Set("token_id", token.Id)
-	c.Set("token_name", token.Name)
-	c.Set("token_group", token.Group)
-	c.Set("token_backup_group", token.BackupGroup)
-	c.Set("token_unlimited_quota", token.UnlimitedQuota)
-	c.Set("token_setting", utils.GetPointer(token.Setting.Data()))
+	setTokenContext(c, token, utils.GetPointer(token.Setting.Data()))
 	if err := checkLimitIP(c); err != nil {
 		abortWithMessage(c, http.StatusForbidden, err.Error())
 		return
@@ -162,6 +224,44 @@ func tokenAuth(c *gin.Context, key string) {
 			return
 		}
 	}
+	span.SetAttributes(attribute.Int("user_id", token.UserId), attribute.Int("token_id", token.Id))
+	span.End()
+	c.Next()
+}
+
+func setTokenContext(c *gin.Context, token *model.Token, setting *model.TokenSetting) {
+	c.Set("id", token.UserId)
+	c.Set("token_id", token.Id)
+	c.Set("token_name", token.Name)
+	c.Set("token_group", token.Group)
+	c.Set("token_backup_group", token.BackupGroup)
+	c.Set("token_unlimited_quota", token.UnlimitedQuota)
+	c.Set("token_setting", setting)
+}
+
+// ephemeralKeyAuth 临时密钥按父令牌计费，并附加模型、路径和额度限制，不支持指定渠道
+func ephemeralKeyAuth(c *gin.Context, key string, span trace.Span) {
+	token, claims, err := model.ValidateEphemeralKey(key)
+	if err != nil {
+		abortWithMessage(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !claims.AllowEndpoint(c.Request.URL.Path) {
+		abortWithMessage(c, http.StatusForbidden, "临时密钥无权访问该接口")
+		return
+	}
+
+	setting := token.Setting.Data()
+	claims.ApplyToSetting(&setting)
+	setTokenContext(c, token, &setting)
+	c.Set("ephemeral_key", claims)
+	if err := checkLimitIP(c); err != nil {
+		abortWithMessage(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	span.SetAttributes(attribute.Int("user_id", token.UserId), attribute.Int("token_id", token.Id), attribute.Bool("ephemeral_key", true))
+	span.End()
 	c.Next()
 }
 
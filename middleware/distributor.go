@@ -3,9 +3,11 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"one-api/common/tracing"
 	"one-api/model"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // GroupDistributor 统一分组分发逻辑
@@ -60,10 +62,16 @@ func (gd *GroupDistributor) setGroupRatio(group string) error {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		span := startMiddlewareSpan(c, "distribute.groups")
+		defer span.End()
+
 		distributor := NewGroupDistributor(c)
 		if err := distributor.SetupGroups(); err != nil {
+			tracing.RecordError(span, err)
 			return
 		}
+		span.SetAttributes(attribute.String("group", c.GetString("token_group")))
+		span.End()
 		c.Next()
 	}
 }
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"one-api/common/logger"
+	"one-api/common/tracing"
 	"one-api/metrics"
 	"strings"
 	"time"
@@ -44,6 +45,7 @@ func GinzapWithConfig() gin.HandlerFunc {
 		fields := []zapcore.Field{
 			zap.Int("status", c.Writer.Status()),
 			zap.String("request_id", requestID),
+			zap.String("trace_id", tracing.TraceID(c.Request.Context())),
 			zap.String("method", c.Request.Method),
 			zap.String("path", path),
 			zap.String("query", query),
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"one-api/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestPolicy 按令牌、令牌分组和用户分组的请求策略检查请求，需要在 Distribute 之后使用
+// 分组策略由管理员配置，最后应用，冲突时以分组策略为准
+func RequestPolicy() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		policies := requestPolicies(c)
+		if len(policies) == 0 {
+			c.Next()
+			return
+		}
+
+		req := &model.PolicyRequest{
+			Endpoint: policyEndpoint(c.Request.URL.Path),
+			Format:   policyFormat(c.Request.URL.Path),
+			Path:     c.Request.URL.Path,
+		}
+		if req.Endpoint == "" {
+			c.Next()
+			return
+		}
+
+		if err := readPolicyBody(c, req); err != nil {
+			abortWithMessage(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		changed := false
+		for _, policy := range policies {
+			policyChanged, err := policy.Apply(req)
+			if err != nil {
+				abortWithMessage(c, http.StatusBadRequest, err.Error())
+				return
+			}
+			changed = changed || policyChanged
+		}
+
+		if changed {
+			body, err := json.Marshal(req.Body)
+			if err != nil {
+				abortWithMessage(c, http.StatusInternalServerError, err.Error())
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+			c.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+		c.Next()
+	}
+}
+
+func requestPolicies(c *gin.Context) []*model.RequestPolicy {
+	policies := make([]*model.RequestPolicy, 0, 3)
+	if setting, ok := c.Get("token_setting"); ok {
+		if tokenSetting, ok := setting.(*model.TokenSetting); ok && tokenSetting != nil && tokenSetting.Limits.RequestPolicy.Enabled {
+			policies = append(policies, &tokenSetting.Limits.RequestPolicy)
+		}
+	}
+
+	groups := []string{c.GetString("token_group")}
+	if userGroup := c.GetString("group"); userGroup != groups[0] {
+		groups = append(groups, userGroup)
+	}
+	for _, symbol := range groups {
+		userGroup := model.GlobalUserGroupRatio.GetBySymbol(symbol)
+		if userGroup == nil || userGroup.Policy == nil {
+			continue
+		}
+		policy := userGroup.Policy.Data()
+		if policy.Enabled {
+			policies = append(policies, &policy)
+		}
+	}
+	return policies
+}
+
+// readPolicyBody 读取 JSON 请求体，multipart 等其他格式只检查接口类型
+func readPolicyBody(c *gin.Context, req *model.PolicyRequest) error {
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return nil
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// 保留数字的原始精度，避免重新序列化时改变 seed 等大整数
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return errors.New("无效的请求体")
+	}
+	req.Body = fields
+	return nil
+}
+
+func policyFormat(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/claude/"):
+		return model.PolicyFormatClaude
+	case strings.HasPrefix(path, "/gemini/"):
+		return model.PolicyFormatGemini
+	}
+	return model.PolicyFormatOpenAI
+}
+
+// policyEndpoint 根据请求路径判断接口类型，无法识别的路径不做限制
+func policyEndpoint(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/gemini/"):
+		// 模型列表等不带操作的路径不做限制
+		if !strings.Contains(path, ":") {
+			return ""
+		}
+		if strings.Contains(path, "embedContent") || strings.Contains(path, "batchEmbedContents") {
+			return model.PolicyEndpointEmbeddings
+		}
+		if strings.Contains(path, "predict") {
+			return model.PolicyEndpointImages
+		}
+		return model.PolicyEndpointChat
+	case strings.HasSuffix(path, "/chat/completions"), strings.HasSuffix(path, "/responses"), strings.HasSuffix(path, "/messages"):
+		return model.PolicyEndpointChat
+	case strings.HasSuffix(path, "/completions"):
+		return model.PolicyEndpointCompletions
+	case strings.HasSuffix(path, "/embeddings"):
+		return model.PolicyEndpointEmbeddings
+	case strings.Contains(path, "/images/"):
+		return model.PolicyEndpointImages
+	case strings.Contains(path, "/audio/"), strings.HasPrefix(path, "/suno/"):
+		return model.PolicyEndpointAudio
+	case strings.HasSuffix(path, "/realtime"):
+		return model.PolicyEndpointRealtime
+	case strings.HasSuffix(path, "/moderations"):
+		return model.PolicyEndpointModerations
+	case strings.HasSuffix(path, "/rerank"):
+		return model.PolicyEndpointRerank
+	case strings.Contains(path, "/mj/"):
+		return model.PolicyEndpointMidjourney
+	case strings.Contains(path, "/videos"), strings.HasPrefix(path, "/kling/"):
+		return model.PolicyEndpointVideos
+	}
+
+	for _, prefix := range []string{"/v1/files", "/v1/fine_tuning", "/v1/assistants", "/v1/threads", "/v1/batches", "/v1/vector_stores"} {
+		if strings.HasPrefix(path, prefix) {
+			return model.PolicyEndpointFiles
+		}
+	}
+	return ""
+}
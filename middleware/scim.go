@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/scim"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMAuth 校验身份提供商的 Bearer Token，通过后以 scim 身份记录审计日志
+func SCIMAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !config.SCIMEnabled || config.SCIMToken == "" {
+			scim.RespondError(c, http.StatusNotFound, "", "SCIM 未启用")
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(config.SCIMToken)) != 1 {
+			scim.RespondError(c, http.StatusUnauthorized, "", "无效的 SCIM Token")
+			return
+		}
+
+		c.Set("username", "scim")
+		auditNext(c)
+	}
+}
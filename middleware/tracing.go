@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/common/logger"
+	"one-api/common/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing 为每个请求创建根 span，并继承客户端传入的 traceparent
+func Tracing() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !tracing.Enabled {
+			c.Next()
+			return
+		}
+
+		ctx := tracing.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		ctx, span := tracing.Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("client.address", c.ClientIP()),
+			attribute.String("request_id", c.GetString(logger.RequestIdKey)),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(
+			attribute.Int("http.response.status_code", status),
+			attribute.Int("user_id", c.GetInt("id")),
+			attribute.Int("token_id", c.GetInt("token_id")),
+			attribute.String("model", c.GetString("original_model")),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// startMiddlewareSpan 为中间件创建 span，需在 c.Next() 之前结束，避免包含后续处理的耗时
+func startMiddlewareSpan(c *gin.Context, name string) trace.Span {
+	_, span := tracing.Start(c.Request.Context(), name)
+	return span
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"one-api/common/logger"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+// AuditLog 管理操作审计日志，只能新增，不提供修改接口，过期记录只能由超级管理员清理
+type AuditLog struct {
+	Id         int    `json:"id"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	Username   string `json:"username" gorm:"type:varchar(64);default:''"`
+	Role       int    `json:"role"`
+	Ip         string `json:"ip" gorm:"type:varchar(64);default:''"`
+	Method     string `json:"method" gorm:"type:varchar(10);default:''"`
+	Path       string `json:"path" gorm:"type:varchar(255);default:''"`
+	Action     string `json:"action" gorm:"type:varchar(100);index"`
+	TargetType string `json:"target_type" gorm:"type:varchar(50);index:idx_audit_target"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(100);index:idx_audit_target"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message" gorm:"type:varchar(255);default:''"`
+	Diff       string `json:"diff" gorm:"type:text"` // JSON，字段修改前后的值
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+type AuditLogQueryParams struct {
+	UserId         int    `form:"user_id"`
+	Username       string `form:"username"`
+	Action         string `form:"action"`
+	TargetType     string `form:"target_type"`
+	TargetId       string `form:"target_id"`
+	Success        *bool  `form:"success"`
+	StartTimestamp int64  `form:"start_timestamp"`
+	EndTimestamp   int64  `form:"end_timestamp"`
+	PaginationParams
+}
+
+var allowedAuditLogOrderFields = map[string]bool{
+	"id":         true,
+	"user_id":    true,
+	"created_at": true,
+}
+
+// AuditLogExportLimit 单次导出的最大条数
+const AuditLogExportLimit = 100000
+
+func RecordAuditLog(log *AuditLog) {
+	if log.CreatedAt == 0 {
+		log.CreatedAt = utils.GetTimestamp()
+	}
+	log.Path = truncateAuditField(log.Path, 255)
+	log.Message = truncateAuditField(log.Message, 255)
+
+	if err := DB.Create(log).Error; err != nil {
+		logger.SysError("failed to record audit log: " + err.Error())
+	}
+}
+
+func (params *AuditLogQueryParams) query() *gorm.DB {
+	db := DB.Model(&AuditLog{})
+	if params.UserId > 0 {
+		db = db.Where("user_id = ?", params.UserId)
+	}
+	if params.Username != "" {
+		db = db.Where("username = ?", params.Username)
+	}
+	if params.Action != "" {
+		db = db.Where("action = ?", params.Action)
+	}
+	if params.TargetType != "" {
+		db = db.Where("target_type = ?", params.TargetType)
+	}
+	if params.TargetId != "" {
+		db = db.Where("target_id = ?", params.TargetId)
+	}
+	if params.Success != nil {
+		db = db.Where("success = ?", *params.Success)
+	}
+	if params.StartTimestamp > 0 {
+		db = db.Where("created_at >= ?", params.StartTimestamp)
+	}
+	if params.EndTimestamp > 0 {
+		db = db.Where("created_at <= ?", params.EndTimestamp)
+	}
+	return db
+}
+
+func GetAuditLogsList(params *AuditLogQueryParams) (*DataResult[AuditLog], error) {
+	var logs []*AuditLog
+	return PaginateAndOrder(params.query(), &params.PaginationParams, &logs, allowedAuditLogOrderFields)
+}
+
+// ExportAuditLogs 按条件导出审计日志，按时间倒序，最多 AuditLogExportLimit 条
+func ExportAuditLogs(params *AuditLogQueryParams) ([]*AuditLog, error) {
+	var logs []*AuditLog
+	err := params.query().Order("id desc").Limit(AuditLogExportLimit).Find(&logs).Error
+	return logs, err
+}
+
+// DeleteAuditLogsBefore 清理指定时间之前的审计日志
+func DeleteAuditLogsBefore(timestamp int64) (int64, error) {
+	result := DB.Where("created_at < ?", timestamp).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+func truncateAuditField(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return value
+}
@@ -1,16 +1,21 @@
 package model
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/tracing"
 	"one-api/common/utils"
+	"one-api/metrics"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type ChannelChoice struct {
@@ -26,6 +31,9 @@ type ChannelsChooser struct {
 	Match     []string
 	Cooldowns sync.Map
 
+	// 被探测禁用的渠道模型，key 为 channelId:modelName
+	DisabledModels map[string]bool
+
 	ModelGroup map[string]map[string]bool
 }
 
@@ -56,6 +64,8 @@ func FilterDisabledStream(modelName string) ChannelsFilterFunc {
 }
 
 func init() {
+	metrics.SetChannelStateFunc(ChannelGroup.GetChannelStates)
+
 	// 每小时清理一次过期的冷却时间
 	go func() {
 		ticker := time.NewTicker(1 * time.Hour)
@@ -103,6 +113,35 @@ func (cc *ChannelsChooser) CleanupExpiredCooldowns() {
 	})
 }
 
+// GetChannelStates 获取各渠道的启用和冷却状态，用于监控
+func (cc *ChannelsChooser) GetChannelStates() []metrics.ChannelState {
+	now := time.Now().Unix()
+	cooldowns := make(map[int]int)
+	cc.Cooldowns.Range(func(key, value interface{}) bool {
+		if now >= value.(int64) {
+			return true
+		}
+		channelId, _, _ := strings.Cut(key.(string), ":")
+		cooldowns[utils.String2Int(channelId)]++
+		return true
+	})
+
+	cc.RLock()
+	defer cc.RUnlock()
+
+	states := make([]metrics.ChannelState, 0, len(cc.Channels))
+	for channelId, choice := range cc.Channels {
+		states = append(states, metrics.ChannelState{
+			Id:             channelId,
+			Type:           choice.Channel.Type,
+			Disabled:       choice.Disable,
+			CooldownModels: cooldowns[channelId],
+		})
+	}
+
+	return states
+}
+
 func (cc *ChannelsChooser) Disable(channelId int) {
 	cc.Lock()
 	defer cc.Unlock()
@@ -123,6 +162,30 @@ func (cc *ChannelsChooser) Enable(channelId int) {
 	cc.Channels[channelId].Disable = false
 }
 
+func (cc *ChannelsChooser) DisableModel(channelId int, modelName string) {
+	cc.Lock()
+	defer cc.Unlock()
+	if cc.DisabledModels == nil {
+		cc.DisabledModels = make(map[string]bool)
+	}
+
+	cc.DisabledModels[fmt.Sprintf("%d:%s", channelId, modelName)] = true
+}
+
+func (cc *ChannelsChooser) EnableModel(channelId int, modelName string) {
+	cc.Lock()
+	defer cc.Unlock()
+
+	delete(cc.DisabledModels, fmt.Sprintf("%d:%s", channelId, modelName))
+}
+
+func (cc *ChannelsChooser) IsModelDisabled(channelId int, modelName string) bool {
+	cc.RLock()
+	defer cc.RUnlock()
+
+	return cc.DisabledModels[fmt.Sprintf("%d:%s", channelId, modelName)]
+}
+
 func (cc *ChannelsChooser) ChangeStatus(channelId int, status bool) {
 	if status {
 		cc.Enable(channelId)
@@ -141,7 +204,11 @@ func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFu
 			continue
 		}
 
-		if cc.IsInCooldown(channelId, modelName) {
+		if cc.IsInCooldown(channelId, modelName) || cc.DisabledModels[fmt.Sprintf("%d:%s", channelId, modelName)] {
+			continue
+		}
+
+		if choice.Channel.IsMultiKey() && !hasAvailableChannelKey(channelId) {
 			continue
 		}
 
@@ -181,7 +248,24 @@ func (cc *ChannelsChooser) balancer(channelIds []int, filters []ChannelsFilterFu
 	return nil
 }
 
-func (cc *ChannelsChooser) Next(group, modelName string, filters ...ChannelsFilterFunc) (*Channel, error) {
+func (cc *ChannelsChooser) Next(ctx context.Context, group, modelName string, filters ...ChannelsFilterFunc) (*Channel, error) {
+	_, span := tracing.Start(ctx, "channel.select",
+		attribute.String("group", group),
+		attribute.String("model", modelName),
+	)
+	defer span.End()
+
+	channel, err := cc.next(group, modelName, filters...)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("channel.id", channel.Id), attribute.Int("channel.type", channel.Type))
+
+	return channel, nil
+}
+
+func (cc *ChannelsChooser) next(group, modelName string, filters ...ChannelsFilterFunc) (*Channel, error) {
 	cc.RLock()
 	defer cc.RUnlock()
 	if _, ok := cc.Rule[group]; !ok {
@@ -338,6 +422,17 @@ func (cc *ChannelsChooser) Load() {
 		newGroup[key.group][key.model] = channelsList
 	}
 
+	loadChannelKeyPools(channels)
+
+	newDisabledModels := make(map[string]bool)
+	var disables []*ChannelModelDisable
+	if err := DB.Find(&disables).Error; err != nil {
+		logger.SysError("failed to load disabled channel models: " + err.Error())
+	}
+	for _, disable := range disables {
+		newDisabledModels[fmt.Sprintf("%d:%s", disable.ChannelId, disable.ModelName)] = true
+	}
+
 	// 构建newMatchList
 	newMatchList := make([]string, 0, len(newMatch))
 	for match := range newMatch {
@@ -350,6 +445,7 @@ func (cc *ChannelsChooser) Load() {
 	cc.Channels = newChannels
 	cc.Match = newMatchList
 	cc.ModelGroup = newModelGroup
+	cc.DisabledModels = newDisabledModels
 	cc.Unlock()
 	logger.SysLog("channels Load success")
 }
@@ -18,6 +18,8 @@ var (
 	UsernameCacheKey            = "user_name:%d"
 	UserQuotaCacheKey           = "user_quota:%d"
 	UserEnabledCacheKey         = "user_enabled:%d"
+	UserSessionCacheKey         = "user_session:%s"
+	ModelInfoCacheKey           = "model_info:%s"
 	UserRealtimeQuotaKey        = "user_realtime_quota:%d"
 	UserRealtimeQuotaExpiration = 24 * time.Hour
 
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/secret"
 	"one-api/common/utils"
 	"slices"
 	"strings"
@@ -16,7 +17,7 @@ import (
 type Channel struct {
 	Id                 int     `json:"id"`
 	Type               int     `json:"type" form:"type" gorm:"default:0"`
-	Key                string  `json:"key" form:"key" gorm:"type:text"`
+	Key                string  `json:"key" form:"key" gorm:"type:text;serializer:encrypted"`
 	Status             int     `json:"status" form:"status" gorm:"default:1"`
 	Name               string  `json:"name" form:"name" gorm:"index"`
 	Weight             *uint   `json:"weight" gorm:"default:1"`
@@ -41,9 +42,15 @@ type Channel struct {
 	PreCost            int     `json:"pre_cost" form:"pre_cost" gorm:"default:1"`
 	CompatibleResponse bool    `json:"compatible_response" gorm:"default:false"`
 	AllowExtraBody     bool    `json:"allow_extra_body" form:"allow_extra_body" gorm:"default:false"`
+	KeyRotation        string  `json:"key_rotation" form:"key_rotation" gorm:"type:varchar(20);default:''"`
+	StructuredOutput   string  `json:"structured_output" form:"structured_output" gorm:"type:varchar(20);default:''"`
 
 	DisabledStream *datatypes.JSONSlice[string] `json:"disabled_stream,omitempty" gorm:"type:json"`
 
+	// 渠道成本：未单独配置成本价的模型按 售价 * CostRatio 计算上游成本
+	CostRatio  *float64                                         `json:"cost_ratio" form:"cost_ratio" gorm:"default:1"`
+	CostPrices *datatypes.JSONType[map[string]ChannelCostPrice] `json:"cost_prices,omitempty" gorm:"type:json"`
+
 	Plugin    *datatypes.JSONType[PluginType] `json:"plugin" form:"plugin" gorm:"type:json"`
 	DeletedAt gorm.DeletedAt                  `json:"-" gorm:"index"`
 }
@@ -58,6 +65,37 @@ func (c *Channel) AllowStream(modelName string) bool {
 
 type PluginType map[string]map[string]interface{}
 
+// 结构化输出（response_format: json_schema）的处理方式，为空时由上游原生处理
+const (
+	StructuredOutputTools  = "tools"  // 将 schema 转换为强制调用的函数
+	StructuredOutputPrompt = "prompt" // 将 schema 写入系统提示词
+)
+
+// ChannelCostPrice 渠道采购成本价，单位与 Price 一致
+type ChannelCostPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+func (channel *Channel) GetCostRatio() float64 {
+	if channel.CostRatio == nil || *channel.CostRatio < 0 {
+		return 1
+	}
+	return *channel.CostRatio
+}
+
+// GetCostPrice 获取渠道对应模型的成本价，优先使用渠道单独配置的成本价，否则按成本倍率折算售价
+func (channel *Channel) GetCostPrice(modelName string, price *Price) (input, output float64) {
+	if channel.CostPrices != nil {
+		if costPrice, ok := channel.CostPrices.Data()[modelName]; ok {
+			return costPrice.Input, costPrice.Output
+		}
+	}
+
+	costRatio := channel.GetCostRatio()
+	return price.GetInput() * costRatio, price.GetOutput() * costRatio
+}
+
 var allowedChannelOrderFields = map[string]bool{
 	"id":            true,
 	"name":          true,
@@ -116,8 +154,15 @@ func GetChannelsList(params *SearchChannelsParams) (*DataResult[Channel], error)
 	}
 
 	if params.Key != "" {
-		db = db.Where(quotePostgresField("key")+" = ?", params.Key)
-		tagDB = tagDB.Where(quotePostgresField("key")+" = ?", params.Key)
+		if secret.Enabled() {
+			// 密钥加密存储后无法在数据库中比较，解密后在内存中查找
+			ids := getChannelIdsByKey(params.Key)
+			db = db.Where("id IN (?)", ids)
+			tagDB = tagDB.Where("id IN (?)", ids)
+		} else {
+			db = db.Where(quotePostgresField("key")+" = ?", params.Key)
+			tagDB = tagDB.Where(quotePostgresField("key")+" = ?", params.Key)
+		}
 	}
 
 	if params.TestModel != "" {
@@ -142,6 +187,21 @@ func GetChannelsList(params *SearchChannelsParams) (*DataResult[Channel], error)
 	return PaginateAndOrder(db, &params.PaginationParams, &channels, allowedChannelOrderFields)
 }
 
+func getChannelIdsByKey(key string) []int {
+	var channels []*Channel
+	if err := DB.Select("id", "key").Find(&channels).Error; err != nil {
+		logger.SysError("failed to search channel by key: " + err.Error())
+	}
+
+	ids := []int{0}
+	for _, channel := range channels {
+		if channel.Key == key {
+			ids = append(ids, channel.Id)
+		}
+	}
+	return ids
+}
+
 func GetAllChannels() ([]*Channel, error) {
 	var channels []*Channel
 	err := DB.Order("id desc").Find(&channels).Error
@@ -283,6 +343,7 @@ func (channel *Channel) Update(overwrite bool) error {
 	err := channel.UpdateRaw(overwrite)
 
 	if err == nil {
+		syncChannelKeys(channel)
 		ChannelGroup.Load()
 	}
 
@@ -0,0 +1,467 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/secret"
+	"one-api/common/utils"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 多密钥渠道的轮换方式，为空时 Key 作为单个密钥使用
+const (
+	KeyRotationRoundRobin = "round_robin"
+	KeyRotationLeastUsed  = "least_used"
+)
+
+var (
+	ErrNoAvailableChannelKey = errors.New("渠道没有可用的密钥")
+	ErrChannelKeyNotFound    = errors.New("渠道中不存在该密钥")
+)
+
+// ChannelKey 多密钥渠道中单个密钥的状态和用量，密钥本身只保存在渠道的 Key 中，这里以哈希关联
+type ChannelKey struct {
+	Id                 int     `json:"id"`
+	ChannelId          int     `json:"channel_id" gorm:"uniqueIndex:idx_channel_key_hash"`
+	KeyHash            string  `json:"key_hash" gorm:"type:varchar(64);uniqueIndex:idx_channel_key_hash"`
+	Status             int     `json:"status" gorm:"default:1"`
+	DisableReason      string  `json:"disable_reason" gorm:"type:varchar(255);default:''"`
+	RequestCount       int64   `json:"request_count" gorm:"bigint;default:0"`
+	UsedQuota          int64   `json:"used_quota" gorm:"bigint;default:0"`
+	Balance            float64 `json:"balance" gorm:"default:0"`
+	BalanceUpdatedTime int64   `json:"balance_updated_time" gorm:"bigint;default:0"`
+	LastUsedTime       int64   `json:"last_used_time" gorm:"bigint;default:0"`
+	CreatedTime        int64   `json:"created_time" gorm:"bigint"`
+}
+
+func (channel *Channel) IsMultiKey() bool {
+	return channel.KeyRotation != ""
+}
+
+// GetKeys 多密钥渠道每行一个密钥，去除空行和重复的密钥
+func (channel *Channel) GetKeys() []string {
+	if !channel.IsMultiKey() {
+		return []string{channel.Key}
+	}
+
+	keys := make([]string, 0)
+	for _, key := range strings.Split(channel.Key, "\n") {
+		key = strings.TrimSpace(key)
+		if key == "" || utils.Contains(key, keys) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func HashChannelKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+type channelKeyState struct {
+	key           string
+	hash          string
+	status        int
+	cooldownUntil atomic.Int64
+	used          atomic.Int64
+}
+
+func (state *channelKeyState) available(now int64) bool {
+	return state.status == config.ChannelStatusEnabled && state.cooldownUntil.Load() <= now
+}
+
+type channelKeyPool struct {
+	sync.RWMutex
+	rotation string
+	keys     []*channelKeyState
+	next     atomic.Uint64
+	// 轮流探测被自动禁用的密钥
+	probeNext atomic.Uint64
+}
+
+func newChannelKeyPool(channel *Channel, records []*ChannelKey) *channelKeyPool {
+	statuses := make(map[string]int, len(records))
+	for _, record := range records {
+		statuses[record.KeyHash] = record.Status
+	}
+
+	pool := &channelKeyPool{rotation: channel.KeyRotation}
+	for _, key := range channel.GetKeys() {
+		state := &channelKeyState{key: key, hash: HashChannelKey(key), status: config.ChannelStatusEnabled}
+		if status, ok := statuses[state.hash]; ok {
+			state.status = status
+		}
+		pool.keys = append(pool.keys, state)
+	}
+	return pool
+}
+
+func (pool *channelKeyPool) pick() *channelKeyState {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	count := len(pool.keys)
+	if count == 0 {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	var choice *channelKeyState
+	if pool.rotation == KeyRotationLeastUsed {
+		for _, state := range pool.keys {
+			if state.available(now) && (choice == nil || state.used.Load() < choice.used.Load()) {
+				choice = state
+			}
+		}
+	} else {
+		start := int(pool.next.Add(1) % uint64(count))
+		for i := 0; i < count; i++ {
+			state := pool.keys[(start+i)%count]
+			if state.available(now) {
+				choice = state
+				break
+			}
+		}
+	}
+
+	if choice != nil {
+		choice.used.Add(1)
+	}
+	return choice
+}
+
+// pickAutoDisabled 轮流选择一个被自动禁用的密钥，手动禁用的密钥不会被选中
+func (pool *channelKeyPool) pickAutoDisabled() *channelKeyState {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	disabled := make([]*channelKeyState, 0)
+	for _, state := range pool.keys {
+		if state.status == config.ChannelStatusAutoDisabled {
+			disabled = append(disabled, state)
+		}
+	}
+	if len(disabled) == 0 {
+		return nil
+	}
+	return disabled[int(pool.probeNext.Add(1)%uint64(len(disabled)))]
+}
+
+func (pool *channelKeyPool) hasAvailable() bool {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	now := time.Now().Unix()
+	for _, state := range pool.keys {
+		if state.available(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pool *channelKeyPool) get(hash string) *channelKeyState {
+	pool.RLock()
+	defer pool.RUnlock()
+
+	for _, state := range pool.keys {
+		if state.hash == hash {
+			return state
+		}
+	}
+	return nil
+}
+
+func (pool *channelKeyPool) setStatus(hash string, status int) (enabledCount int) {
+	pool.Lock()
+	defer pool.Unlock()
+
+	for _, state := range pool.keys {
+		if state.hash == hash {
+			state.status = status
+		}
+		if state.status == config.ChannelStatusEnabled {
+			enabledCount++
+		}
+	}
+	return
+}
+
+// channelKeyPools 渠道ID -> 密钥池，渠道重新加载时重建
+var channelKeyPools = struct {
+	sync.RWMutex
+	pools map[int]*channelKeyPool
+}{pools: make(map[int]*channelKeyPool)}
+
+// loadChannelKeyPools 为已加载的多密钥渠道重建密钥池
+func loadChannelKeyPools(channels []*Channel) {
+	pools := make(map[int]*channelKeyPool)
+	ids := make([]int, 0)
+	for _, channel := range channels {
+		if channel.IsMultiKey() {
+			ids = append(ids, channel.Id)
+		}
+	}
+
+	records := make(map[int][]*ChannelKey)
+	if len(ids) > 0 {
+		var rows []*ChannelKey
+		if err := DB.Where("channel_id IN ?", ids).Find(&rows).Error; err != nil {
+			logger.SysError("failed to load channel keys: " + err.Error())
+		}
+		for _, row := range rows {
+			records[row.ChannelId] = append(records[row.ChannelId], row)
+		}
+	}
+
+	for _, channel := range channels {
+		if channel.IsMultiKey() {
+			pools[channel.Id] = newChannelKeyPool(channel, records[channel.Id])
+		}
+	}
+
+	channelKeyPools.Lock()
+	channelKeyPools.pools = pools
+	channelKeyPools.Unlock()
+}
+
+func getChannelKeyPool(channel *Channel) *channelKeyPool {
+	channelKeyPools.RLock()
+	pool, ok := channelKeyPools.pools[channel.Id]
+	channelKeyPools.RUnlock()
+	if ok {
+		return pool
+	}
+
+	// 未启用的渠道（如测速）没有预先加载的密钥池，传入的可能是只包含单个密钥的副本，从数据库重新读取
+	if stored, err := GetChannelById(channel.Id); err == nil {
+		channel = stored
+	}
+	var records []*ChannelKey
+	DB.Where("channel_id = ?", channel.Id).Find(&records)
+	pool = newChannelKeyPool(channel, records)
+
+	channelKeyPools.Lock()
+	if existing, ok := channelKeyPools.pools[channel.Id]; ok {
+		pool = existing
+	} else {
+		channelKeyPools.pools[channel.Id] = pool
+	}
+	channelKeyPools.Unlock()
+	return pool
+}
+
+func hasAvailableChannelKey(channelId int) bool {
+	channelKeyPools.RLock()
+	pool, ok := channelKeyPools.pools[channelId]
+	channelKeyPools.RUnlock()
+
+	return !ok || pool.hasAvailable()
+}
+
+// PickChannelKey 为多密钥渠道选择一个密钥，返回只包含该密钥的渠道副本，单密钥渠道原样返回
+func PickChannelKey(channel *Channel) (*Channel, error) {
+	if !channel.IsMultiKey() {
+		return channel, nil
+	}
+
+	state := getChannelKeyPool(channel).pick()
+	if state == nil {
+		return nil, ErrNoAvailableChannelKey
+	}
+
+	keyed := *channel
+	keyed.Key = state.key
+	return &keyed, nil
+}
+
+// PickChannelKeyByHash 使用指定的密钥，返回只包含该密钥的渠道副本，用于查询由该密钥提交的异步任务
+// 密钥即使已被禁用也会返回，hash 为空时（多密钥之前提交的任务）按轮换方式选择，单密钥渠道原样返回
+func PickChannelKeyByHash(channel *Channel, hash string) (*Channel, error) {
+	if !channel.IsMultiKey() {
+		return channel, nil
+	}
+	if hash == "" {
+		return PickChannelKey(channel)
+	}
+
+	for _, key := range channel.GetKeys() {
+		if HashChannelKey(key) == hash {
+			keyed := *channel
+			keyed.Key = key
+			return &keyed, nil
+		}
+	}
+	return nil, ErrChannelKeyNotFound
+}
+
+// PickAutoDisabledChannelKey 选择一个被自动禁用的密钥用于探测，返回只包含该密钥的渠道副本，没有时返回 nil
+func PickAutoDisabledChannelKey(channel *Channel) *Channel {
+	if !channel.IsMultiKey() {
+		return nil
+	}
+
+	state := getChannelKeyPool(channel).pickAutoDisabled()
+	if state == nil {
+		return nil
+	}
+
+	keyed := *channel
+	keyed.Key = state.key
+	return &keyed
+}
+
+// CooldownChannelKey 冻结多密钥渠道中的单个密钥，返回渠道是否还有其他可用密钥
+func CooldownChannelKey(channelId int, hash string) bool {
+	channelKeyPools.RLock()
+	pool, ok := channelKeyPools.pools[channelId]
+	channelKeyPools.RUnlock()
+	if !ok {
+		return false
+	}
+
+	if state := pool.get(hash); state != nil && config.RetryCooldownSeconds > 0 {
+		state.cooldownUntil.Store(time.Now().Unix() + int64(config.RetryCooldownSeconds))
+	}
+
+	return pool.hasAvailable()
+}
+
+// UpdateChannelKeyStatus 更新密钥状态，返回渠道剩余的启用密钥数量
+func UpdateChannelKeyStatus(channel *Channel, hash string, status int, reason string) (int, error) {
+	if status == config.ChannelStatusEnabled {
+		reason = ""
+	}
+	if runes := []rune(reason); len(runes) > 255 {
+		reason = string(runes[:255])
+	}
+
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}, {Name: "key_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "disable_reason"}),
+	}).Create(&ChannelKey{
+		ChannelId:     channel.Id,
+		KeyHash:       hash,
+		Status:        status,
+		DisableReason: reason,
+		CreatedTime:   utils.GetTimestamp(),
+	}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	return getChannelKeyPool(channel).setStatus(hash, status), nil
+}
+
+// UpdateChannelKeyUsage 累加单个密钥的请求次数和消耗额度
+func UpdateChannelKeyUsage(channelId int, hash string, quota int) {
+	now := utils.GetTimestamp()
+	result := DB.Model(&ChannelKey{}).Where("channel_id = ? AND key_hash = ?", channelId, hash).Updates(map[string]any{
+		"request_count":  gorm.Expr("request_count + 1"),
+		"used_quota":     gorm.Expr("used_quota + ?", quota),
+		"last_used_time": now,
+	})
+	if result.Error != nil {
+		logger.SysError("failed to update channel key usage: " + result.Error.Error())
+		return
+	}
+	if result.RowsAffected > 0 {
+		return
+	}
+
+	err := DB.Create(&ChannelKey{
+		ChannelId:    channelId,
+		KeyHash:      hash,
+		Status:       config.ChannelStatusEnabled,
+		RequestCount: 1,
+		UsedQuota:    int64(quota),
+		LastUsedTime: now,
+		CreatedTime:  now,
+	}).Error
+	if err != nil {
+		logger.SysError("failed to create channel key usage: " + err.Error())
+	}
+}
+
+func UpdateChannelKeyBalance(channelId int, hash string, balance float64) {
+	now := utils.GetTimestamp()
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}, {Name: "key_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"balance", "balance_updated_time"}),
+	}).Create(&ChannelKey{
+		ChannelId:          channelId,
+		KeyHash:            hash,
+		Status:             config.ChannelStatusEnabled,
+		Balance:            balance,
+		BalanceUpdatedTime: now,
+		CreatedTime:        now,
+	}).Error
+	if err != nil {
+		logger.SysError("failed to update channel key balance: " + err.Error())
+	}
+}
+
+// syncChannelKeys 删除渠道中已移除密钥的记录
+func syncChannelKeys(channel *Channel) {
+	if !channel.IsMultiKey() {
+		DB.Where("channel_id = ?", channel.Id).Delete(&ChannelKey{})
+		return
+	}
+
+	hashes := make([]string, 0)
+	for _, key := range channel.GetKeys() {
+		hashes = append(hashes, HashChannelKey(key))
+	}
+	err := DB.Where("channel_id = ? AND key_hash NOT IN ?", channel.Id, hashes).Delete(&ChannelKey{}).Error
+	if err != nil {
+		logger.SysError("failed to sync channel keys: " + err.Error())
+	}
+}
+
+// ChannelKeyStatus 管理后台展示的密钥状态，密钥做脱敏处理
+type ChannelKeyStatus struct {
+	Index         int    `json:"index"`
+	Key           string `json:"key"`
+	CooldownUntil int64  `json:"cooldown_until"`
+	*ChannelKey
+}
+
+func GetChannelKeyStatuses(channel *Channel) ([]*ChannelKeyStatus, error) {
+	var records []*ChannelKey
+	if err := DB.Where("channel_id = ?", channel.Id).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	recordMap := make(map[string]*ChannelKey, len(records))
+	for _, record := range records {
+		recordMap[record.KeyHash] = record
+	}
+
+	pool := getChannelKeyPool(channel)
+	statuses := make([]*ChannelKeyStatus, 0)
+	for index, key := range channel.GetKeys() {
+		hash := HashChannelKey(key)
+		record, ok := recordMap[hash]
+		if !ok {
+			record = &ChannelKey{ChannelId: channel.Id, KeyHash: hash, Status: config.ChannelStatusEnabled}
+		}
+
+		status := &ChannelKeyStatus{Index: index, Key: secret.Mask(key), ChannelKey: record}
+		if state := pool.get(hash); state != nil {
+			status.CooldownUntil = state.cooldownUntil.Load()
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,189 @@
+package model
+
+import (
+	"fmt"
+	"one-api/common/logger"
+	"one-api/common/utils"
+
+	"gorm.io/gorm/clause"
+)
+
+// 探测错误分类
+const (
+	ProbeErrorTimeout       = "timeout"
+	ProbeErrorAuth          = "auth"
+	ProbeErrorRateLimit     = "rate_limit"
+	ProbeErrorModelNotFound = "model_not_found"
+	ProbeErrorServer        = "server"
+	ProbeErrorSlow          = "slow"
+	ProbeErrorUnsupported   = "unsupported"
+	ProbeErrorOther         = "other"
+)
+
+// ChannelProbe 渠道模型探测记录
+type ChannelProbe struct {
+	Id           int    `json:"id"`
+	ChannelId    int    `json:"channel_id" gorm:"index:idx_channel_probe_pair"`
+	ModelName    string `json:"model_name" gorm:"type:varchar(255);index:idx_channel_probe_pair"`
+	Success      bool   `json:"success"`
+	StatusCode   int    `json:"status_code" gorm:"default:0"`
+	ErrorClass   string `json:"error_class" gorm:"type:varchar(32);default:''"`
+	ErrorMessage string `json:"error_message" gorm:"type:varchar(1024);default:''"`
+	ResponseTime int    `json:"response_time" gorm:"default:0"` // in milliseconds
+	CreatedTime  int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+// ChannelModelDisable 被探测禁用的渠道模型，只影响该渠道的该模型
+type ChannelModelDisable struct {
+	ChannelId   int    `json:"channel_id" gorm:"primaryKey;autoIncrement:false"`
+	ModelName   string `json:"model_name" gorm:"type:varchar(255);primaryKey"`
+	Reason      string `json:"reason" gorm:"type:varchar(1024);default:''"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+type ChannelProbeQueryParams struct {
+	ChannelId  int    `form:"channel_id"`
+	ModelName  string `form:"model_name"`
+	Success    *bool  `form:"success"`
+	ErrorClass string `form:"error_class"`
+	PaginationParams
+}
+
+var allowedChannelProbeOrderFields = map[string]bool{
+	"id":            true,
+	"channel_id":    true,
+	"response_time": true,
+	"created_time":  true,
+}
+
+func CreateChannelProbes(probes []*ChannelProbe) error {
+	if len(probes) == 0 {
+		return nil
+	}
+	for _, probe := range probes {
+		probe.ErrorMessage = truncateProbeMessage(probe.ErrorMessage)
+	}
+	return DB.CreateInBatches(probes, 100).Error
+}
+
+func GetChannelProbesList(params *ChannelProbeQueryParams) (*DataResult[ChannelProbe], error) {
+	var probes []*ChannelProbe
+	db := DB.Model(&ChannelProbe{})
+	if params.ChannelId > 0 {
+		db = db.Where("channel_id = ?", params.ChannelId)
+	}
+	if params.ModelName != "" {
+		db = db.Where("model_name = ?", params.ModelName)
+	}
+	if params.Success != nil {
+		db = db.Where("success = ?", *params.Success)
+	}
+	if params.ErrorClass != "" {
+		db = db.Where("error_class = ?", params.ErrorClass)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &probes, allowedChannelProbeOrderFields)
+}
+
+// ChannelModelUptime 渠道模型在统计区间内的可用率
+type ChannelModelUptime struct {
+	ChannelId       int     `json:"channel_id"`
+	ModelName       string  `json:"model_name"`
+	Total           int64   `json:"total"`
+	SuccessCount    int64   `json:"success_count"`
+	Uptime          float64 `json:"uptime"`
+	AvgResponseTime float64 `json:"avg_response_time"`
+	LastProbeTime   int64   `json:"last_probe_time"`
+	Disabled        bool    `json:"disabled"`
+}
+
+// GetChannelModelUptimes 统计 since 之后的探测可用率，channelId 为 0 时统计全部渠道
+func GetChannelModelUptimes(channelId int, since int64) ([]*ChannelModelUptime, error) {
+	var uptimes []*ChannelModelUptime
+	db := DB.Model(&ChannelProbe{}).
+		Select("channel_id, model_name, count(*) as total, "+
+			"sum(case when success = ? then 1 else 0 end) as success_count, "+
+			"avg(case when success = ? then response_time else null end) as avg_response_time, "+
+			"max(created_time) as last_probe_time", true, true).
+		Where("created_time >= ?", since)
+	if channelId > 0 {
+		db = db.Where("channel_id = ?", channelId)
+	}
+
+	err := db.Group("channel_id, model_name").Order("channel_id, model_name").Scan(&uptimes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uptime := range uptimes {
+		if uptime.Total > 0 {
+			uptime.Uptime = float64(uptime.SuccessCount) / float64(uptime.Total) * 100
+		}
+		uptime.Disabled = ChannelGroup.IsModelDisabled(uptime.ChannelId, uptime.ModelName)
+	}
+
+	return uptimes, nil
+}
+
+func DeleteExpiredChannelProbes(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	expiredTime := utils.GetTimestamp() - int64(retentionDays)*86400
+	result := DB.Where("created_time < ?", expiredTime).Delete(&ChannelProbe{})
+	return result.RowsAffected, result.Error
+}
+
+func GetChannelModelDisables(channelId int) ([]*ChannelModelDisable, error) {
+	var disables []*ChannelModelDisable
+	db := DB.Model(&ChannelModelDisable{})
+	if channelId > 0 {
+		db = db.Where("channel_id = ?", channelId)
+	}
+	err := db.Order("channel_id, model_name").Find(&disables).Error
+	return disables, err
+}
+
+// DisableChannelModel 禁用渠道的单个模型
+func DisableChannelModel(channelId int, modelName, reason string) error {
+	reason = truncateProbeMessage(reason)
+
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel_id"}, {Name: "model_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason"}),
+	}).Create(&ChannelModelDisable{
+		ChannelId:   channelId,
+		ModelName:   modelName,
+		Reason:      reason,
+		CreatedTime: utils.GetTimestamp(),
+	}).Error
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to disable channel #%d model %s: %s", channelId, modelName, err.Error()))
+		return err
+	}
+
+	ChannelGroup.DisableModel(channelId, modelName)
+	return nil
+}
+
+// EnableChannelModel 恢复渠道的单个模型
+func EnableChannelModel(channelId int, modelName string) error {
+	err := DB.Where("channel_id = ? AND model_name = ?", channelId, modelName).Delete(&ChannelModelDisable{}).Error
+	if err != nil {
+		logger.SysError(fmt.Sprintf("failed to enable channel #%d model %s: %s", channelId, modelName, err.Error()))
+		return err
+	}
+
+	ChannelGroup.EnableModel(channelId, modelName)
+	return nil
+}
+
+// truncateProbeMessage 截断到 varchar(1024) 字段的长度
+func truncateProbeMessage(message string) string {
+	runes := []rune(message)
+	if len(runes) > 1024 {
+		return string(runes[:1024])
+	}
+	return message
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"one-api/common/config"
+	"one-api/common/secret"
 	"strings"
 	"time"
 )
@@ -79,6 +80,8 @@ func UpdateChannelsTag(tag string, channel *Channel) error {
 	if channel.Key == "" {
 		return errors.New("key不能为空")
 	}
+	// 接口返回的是隐藏后的密钥，未修改的行还原为原密钥
+	channel.Key = secret.RestoreMaskedLines(channel.Key, channelTag.Key)
 
 	addKeys := []string{}
 	delIds := []int{}
@@ -0,0 +1,947 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ConfigDocumentVersion 配置文档格式版本，格式不兼容时递增
+const ConfigDocumentVersion = 1
+
+const (
+	ConfigFormatYAML = "yaml"
+	ConfigFormatJSON = "json"
+)
+
+const (
+	ConfigActionCreate = "create"
+	ConfigActionUpdate = "update"
+	ConfigActionDelete = "delete"
+)
+
+// ConfigDocument 网关的声明式配置
+// 某一部分为 null 或缺失时应用配置不会修改该部分，为空列表时配合 prune 会清空该部分
+type ConfigDocument struct {
+	Version      int                `json:"version"`
+	ExportedAt   int64              `json:"exported_at,omitempty"`
+	Channels     []*ConfigChannel   `json:"channels"`
+	UserGroups   []*ConfigUserGroup `json:"user_groups"`
+	Prices       []*Price           `json:"prices"`
+	ModelInfos   []*ConfigModelInfo `json:"model_infos"`
+	ModelOwnedBy []*ModelOwnedBy    `json:"model_owned_by"`
+	Options      map[string]string  `json:"options"`
+}
+
+// ConfigChannel 渠道的声明式字段，不包含余额、响应时间等运行时数据
+// 渠道按名称匹配，同名渠道按 id 顺序依次对应
+type ConfigChannel struct {
+	Name               string                                           `json:"name"`
+	Type               int                                              `json:"type"`
+	Key                string                                           `json:"key,omitempty"`
+	Status             int                                              `json:"status"`
+	Weight             *uint                                            `json:"weight"`
+	BaseURL            *string                                          `json:"base_url"`
+	Other              string                                           `json:"other"`
+	Models             string                                           `json:"models"`
+	Group              string                                           `json:"group"`
+	Tag                string                                           `json:"tag"`
+	ModelMapping       *string                                          `json:"model_mapping"`
+	ModelHeaders       *string                                          `json:"model_headers"`
+	CustomParameter    *string                                          `json:"custom_parameter"`
+	Priority           *int64                                           `json:"priority"`
+	Proxy              *string                                          `json:"proxy"`
+	TestModel          string                                           `json:"test_model"`
+	OnlyChat           bool                                             `json:"only_chat"`
+	PreCost            int                                              `json:"pre_cost"`
+	CompatibleResponse bool                                             `json:"compatible_response"`
+	AllowExtraBody     bool                                             `json:"allow_extra_body"`
+	KeyRotation        string                                           `json:"key_rotation"`
+	StructuredOutput   string                                           `json:"structured_output"`
+	DisabledStream     *datatypes.JSONSlice[string]                     `json:"disabled_stream,omitempty"`
+	CostRatio          *float64                                         `json:"cost_ratio"`
+	CostPrices         *datatypes.JSONType[map[string]ChannelCostPrice] `json:"cost_prices,omitempty"`
+	Plugin             *datatypes.JSONType[PluginType]                  `json:"plugin"`
+}
+
+// configChannelColumns 应用配置时更新的渠道字段
+var configChannelColumns = []string{
+	"type", "key", "status", "name", "weight", "base_url", "other", "models", "group", "tag",
+	"model_mapping", "model_headers", "custom_parameter", "priority", "proxy", "test_model",
+	"only_chat", "pre_cost", "compatible_response", "allow_extra_body", "key_rotation",
+	"structured_output", "disabled_stream", "cost_ratio", "cost_prices", "plugin",
+}
+
+type ConfigUserGroup struct {
+	Symbol    string  `json:"symbol"`
+	Name      string  `json:"name"`
+	Ratio     float64 `json:"ratio"`
+	APIRate   int     `json:"api_rate"`
+	Public    bool    `json:"public"`
+	Promotion bool    `json:"promotion"`
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+	Enable    *bool   `json:"enable"`
+
+	Policy *datatypes.JSONType[RequestPolicy] `json:"policy,omitempty"`
+}
+
+type ConfigModelInfo struct {
+	Model            string `json:"model"`
+	Name             string `json:"name"`
+	Description      string `json:"description"`
+	ContextLength    int    `json:"context_length"`
+	MaxTokens        int    `json:"max_tokens"`
+	ContextManage    bool   `json:"context_manage"`
+	InputModalities  string `json:"input_modalities"`
+	OutputModalities string `json:"output_modalities"`
+	Tags             string `json:"tags"`
+	SupportUrl       string `json:"support_url"`
+}
+
+type ConfigExportOptions struct {
+	// IncludeKeys 导出渠道密钥和敏感配置项，否则留空，应用时保留目标环境原有的值
+	IncludeKeys bool
+	// Passphrase 设置后敏感字段使用口令加密导出
+	Passphrase string
+}
+
+type ConfigApplyOptions struct {
+	DryRun bool
+	// Prune 删除文档中不存在的渠道、分组、价格、模型信息和模型归属，配置项和默认分组不会被删除
+	Prune      bool
+	Passphrase string
+}
+
+// ConfigChange 应用配置时的一项变更，只列出变化的字段名，不包含字段值
+type ConfigChange struct {
+	Section string   `json:"section"`
+	Key     string   `json:"key"`
+	Action  string   `json:"action"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+type ConfigApplyResult struct {
+	DryRun  bool            `json:"dry_run"`
+	Changes []*ConfigChange `json:"changes"`
+}
+
+// configExcludedOptions 只在当前环境有意义的配置项，不参与导入导出
+var configExcludedOptions = map[string]bool{
+	"OldTokenMaxId": true,
+}
+
+// IsSecretOptionKey 敏感配置项，与配置接口中隐藏的配置项保持一致
+func IsSecretOptionKey(key string) bool {
+	return strings.HasSuffix(key, "Token") || strings.HasSuffix(key, "Secret") || strings.HasSuffix(key, "Key") || strings.HasSuffix(key, "Password")
+}
+
+func ExportConfig(options ConfigExportOptions) (*ConfigDocument, error) {
+	var box *common.SecretBox
+	if options.Passphrase != "" {
+		var err error
+		box, err = common.NewSecretBox(options.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		options.IncludeKeys = true
+	}
+
+	exportSecret := func(value string) (string, error) {
+		if !options.IncludeKeys || value == "" {
+			return "", nil
+		}
+		if box == nil {
+			return value, nil
+		}
+		return box.Encrypt(value)
+	}
+
+	doc := &ConfigDocument{
+		Version:      ConfigDocumentVersion,
+		ExportedAt:   utils.GetTimestamp(),
+		Channels:     make([]*ConfigChannel, 0),
+		UserGroups:   make([]*ConfigUserGroup, 0),
+		Prices:       make([]*Price, 0),
+		ModelInfos:   make([]*ConfigModelInfo, 0),
+		ModelOwnedBy: make([]*ModelOwnedBy, 0),
+		Options:      make(map[string]string),
+	}
+
+	var channels []*Channel
+	if err := DB.Order("id").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		item := newConfigChannel(channel)
+		key, err := exportSecret(channel.Key)
+		if err != nil {
+			return nil, err
+		}
+		item.Key = key
+		doc.Channels = append(doc.Channels, item)
+	}
+
+	var userGroups []*UserGroup
+	if err := DB.Order("id").Find(&userGroups).Error; err != nil {
+		return nil, err
+	}
+	for _, userGroup := range userGroups {
+		doc.UserGroups = append(doc.UserGroups, newConfigUserGroup(userGroup))
+	}
+
+	if err := DB.Order("model").Find(&doc.Prices).Error; err != nil {
+		return nil, err
+	}
+
+	var modelInfos []*ModelInfo
+	if err := DB.Order("model").Find(&modelInfos).Error; err != nil {
+		return nil, err
+	}
+	for _, modelInfo := range modelInfos {
+		doc.ModelInfos = append(doc.ModelInfos, newConfigModelInfo(modelInfo))
+	}
+
+	if err := DB.Order("id").Find(&doc.ModelOwnedBy).Error; err != nil {
+		return nil, err
+	}
+
+	for key, value := range config.GlobalOption.GetAll() {
+		if configExcludedOptions[key] {
+			continue
+		}
+		if IsSecretOptionKey(key) {
+			secret, err := exportSecret(value)
+			if err != nil {
+				return nil, err
+			}
+			if secret == "" {
+				continue
+			}
+			value = secret
+		}
+		doc.Options[key] = value
+	}
+
+	return doc, nil
+}
+
+// configPlan 计算出的变更和对应的数据库操作，操作在同一个事务中执行
+type configPlan struct {
+	changes []*ConfigChange
+	ops     []func(tx *gorm.DB) error
+	// afterCommit 事务提交后执行，用于刷新缓存
+	afterCommit []func()
+}
+
+func (p *configPlan) add(change *ConfigChange, op func(tx *gorm.DB) error) {
+	p.changes = append(p.changes, change)
+	p.ops = append(p.ops, op)
+}
+
+func ApplyConfig(doc *ConfigDocument, options ConfigApplyOptions) (*ConfigApplyResult, error) {
+	if doc.Version <= 0 || doc.Version > ConfigDocumentVersion {
+		return nil, fmt.Errorf("不支持的配置文档版本: %d", doc.Version)
+	}
+
+	var box *common.SecretBox
+	if options.Passphrase != "" {
+		var err error
+		box, err = common.NewSecretBox(options.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	importSecret := func(value string) (string, error) {
+		if !common.IsSecretBoxEncrypted(value) {
+			return value, nil
+		}
+		if box == nil {
+			return "", errors.New("配置文档包含加密字段，请提供口令")
+		}
+		return box.Decrypt(value)
+	}
+
+	plan := &configPlan{}
+	steps := []func() error{
+		func() error { return planConfigChannels(plan, doc.Channels, options.Prune, importSecret) },
+		func() error { return planConfigUserGroups(plan, doc.UserGroups, options.Prune) },
+		func() error { return planConfigPrices(plan, doc.Prices, options.Prune) },
+		func() error { return planConfigModelInfos(plan, doc.ModelInfos, options.Prune) },
+		func() error { return planConfigModelOwnedBy(plan, doc.ModelOwnedBy, options.Prune) },
+		func() error { return planConfigOptions(plan, doc.Options, importSecret) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &ConfigApplyResult{
+		DryRun:  options.DryRun,
+		Changes: plan.changes,
+	}
+	if result.Changes == nil {
+		result.Changes = make([]*ConfigChange, 0)
+	}
+	if options.DryRun || len(plan.ops) == 0 {
+		return result, nil
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, op := range plan.ops {
+			if err := op(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fn := range plan.afterCommit {
+		fn()
+	}
+	reloadConfigCaches()
+	logger.SysLog(fmt.Sprintf("config document applied: %d changes", len(result.Changes)))
+
+	return result, nil
+}
+
+func reloadConfigCaches() {
+	ChannelGroup.Load()
+	GlobalUserGroupRatio.Load()
+	if err := PricingInstance.Init(); err != nil {
+		logger.SysError("failed to reload prices: " + err.Error())
+	}
+	if err := ModelOwnedBysInstance.Load(); err != nil {
+		logger.SysError("failed to reload model owned by: " + err.Error())
+	}
+}
+
+func planConfigChannels(plan *configPlan, items []*ConfigChannel, prune bool, importSecret func(string) (string, error)) error {
+	if items == nil {
+		return nil
+	}
+
+	var channels []*Channel
+	if err := DB.Order("id").Find(&channels).Error; err != nil {
+		return err
+	}
+
+	existing := make(map[string]*Channel)
+	existingKeys := configChannelKeys(len(channels), func(i int) string { return channels[i].Name })
+	for i, channel := range channels {
+		existing[existingKeys[i]] = channel
+	}
+
+	seen := make(map[string]bool)
+	docKeys := configChannelKeys(len(items), func(i int) string { return items[i].Name })
+	for i, item := range items {
+		if item.Name == "" {
+			return fmt.Errorf("第 %d 个渠道缺少名称", i+1)
+		}
+
+		mapKey := docKeys[i]
+		seen[mapKey] = true
+
+		key, err := importSecret(item.Key)
+		if err != nil {
+			return fmt.Errorf("渠道 %s: %w", mapKey, err)
+		}
+		channel := item.toChannel()
+		channel.Key = key
+
+		old, ok := existing[mapKey]
+		if !ok {
+			if channel.Key == "" {
+				return fmt.Errorf("渠道 %s 缺少密钥", mapKey)
+			}
+			channel.CreatedTime = utils.GetTimestamp()
+			plan.add(&ConfigChange{Section: "channels", Key: mapKey, Action: ConfigActionCreate}, func(tx *gorm.DB) error {
+				return tx.Omit("UsedQuota").Create(channel).Error
+			})
+			plan.afterCommit = append(plan.afterCommit, func() { syncChannelKeys(channel) })
+			continue
+		}
+
+		if channel.Key == "" {
+			channel.Key = old.Key
+		}
+		fields := diffConfigFields(newConfigChannelWithKey(old), newConfigChannelWithKey(channel))
+		if len(fields) == 0 {
+			continue
+		}
+
+		channel.Id = old.Id
+		plan.add(&ConfigChange{Section: "channels", Key: mapKey, Action: ConfigActionUpdate, Fields: fields}, func(tx *gorm.DB) error {
+			return tx.Model(&Channel{Id: channel.Id}).Select(configChannelColumns).Updates(channel).Error
+		})
+		plan.afterCommit = append(plan.afterCommit, func() { syncChannelKeys(channel) })
+	}
+
+	if !prune {
+		return nil
+	}
+	for i, channel := range channels {
+		if seen[existingKeys[i]] {
+			continue
+		}
+		plan.add(&ConfigChange{Section: "channels", Key: existingKeys[i], Action: ConfigActionDelete}, func(tx *gorm.DB) error {
+			if err := tx.Where("channel_id = ?", channel.Id).Delete(&ChannelKey{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(channel).Error
+		})
+	}
+
+	return nil
+}
+
+// configChannelKeys 生成渠道的匹配键，同名渠道从第二个开始追加序号，如 name#2
+func configChannelKeys(n int, name func(i int) string) []string {
+	keys := make([]string, n)
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[name(i)]++
+		keys[i] = name(i)
+		if counts[name(i)] > 1 {
+			keys[i] = fmt.Sprintf("%s#%d", name(i), counts[name(i)])
+		}
+	}
+	return keys
+}
+
+func planConfigUserGroups(plan *configPlan, items []*ConfigUserGroup, prune bool) error {
+	if items == nil {
+		return nil
+	}
+
+	var userGroups []*UserGroup
+	if err := DB.Order("id").Find(&userGroups).Error; err != nil {
+		return err
+	}
+	existing := make(map[string]*UserGroup)
+	for _, userGroup := range userGroups {
+		existing[userGroup.Symbol] = userGroup
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if item.Symbol == "" {
+			return errors.New("用户分组缺少标识")
+		}
+		if seen[item.Symbol] {
+			return fmt.Errorf("用户分组 %s 重复", item.Symbol)
+		}
+		seen[item.Symbol] = true
+
+		userGroup := item.toUserGroup()
+		old, ok := existing[item.Symbol]
+		if !ok {
+			plan.add(&ConfigChange{Section: "user_groups", Key: item.Symbol, Action: ConfigActionCreate}, func(tx *gorm.DB) error {
+				return tx.Create(userGroup).Error
+			})
+			continue
+		}
+
+		fields := diffConfigFields(newConfigUserGroup(old), item)
+		if len(fields) == 0 {
+			continue
+		}
+		userGroup.Id = old.Id
+		plan.add(&ConfigChange{Section: "user_groups", Key: item.Symbol, Action: ConfigActionUpdate, Fields: fields}, func(tx *gorm.DB) error {
+			return tx.Model(&UserGroup{Id: userGroup.Id}).Select("*").Omit("id").Updates(userGroup).Error
+		})
+	}
+
+	if !prune {
+		return nil
+	}
+	for _, userGroup := range userGroups {
+		// 默认分组被用户和令牌引用，不会被删除
+		if seen[userGroup.Symbol] || userGroup.Symbol == "default" {
+			continue
+		}
+		plan.add(&ConfigChange{Section: "user_groups", Key: userGroup.Symbol, Action: ConfigActionDelete}, func(tx *gorm.DB) error {
+			return tx.Delete(userGroup).Error
+		})
+	}
+
+	return nil
+}
+
+func planConfigPrices(plan *configPlan, items []*Price, prune bool) error {
+	if items == nil {
+		return nil
+	}
+
+	prices, err := GetAllPrices()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*Price)
+	for _, price := range prices {
+		existing[price.Model] = price
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if item.Model == "" {
+			return errors.New("价格缺少模型名称")
+		}
+		if seen[item.Model] {
+			return fmt.Errorf("模型 %s 的价格重复", item.Model)
+		}
+		seen[item.Model] = true
+
+		price := *item
+		price.ModelInfo = nil
+		if price.Type == "" {
+			price.Type = TokensPriceType
+		}
+
+		old, ok := existing[price.Model]
+		if !ok {
+			plan.add(&ConfigChange{Section: "prices", Key: price.Model, Action: ConfigActionCreate}, func(tx *gorm.DB) error {
+				return InsertPrices(tx, []*Price{&price})
+			})
+			continue
+		}
+
+		fields := diffConfigFields(old, &price)
+		if len(fields) == 0 {
+			continue
+		}
+		plan.add(&ConfigChange{Section: "prices", Key: price.Model, Action: ConfigActionUpdate, Fields: fields}, func(tx *gorm.DB) error {
+			return UpdatePrices(tx, []string{price.Model}, &price)
+		})
+	}
+
+	if !prune {
+		return nil
+	}
+	var pruned []string
+	for _, price := range prices {
+		if seen[price.Model] {
+			continue
+		}
+		pruned = append(pruned, price.Model)
+		plan.changes = append(plan.changes, &ConfigChange{Section: "prices", Key: price.Model, Action: ConfigActionDelete})
+	}
+	if len(pruned) > 0 {
+		plan.ops = append(plan.ops, func(tx *gorm.DB) error {
+			return DeletePrices(tx, pruned)
+		})
+	}
+
+	return nil
+}
+
+func planConfigModelInfos(plan *configPlan, items []*ConfigModelInfo, prune bool) error {
+	if items == nil {
+		return nil
+	}
+
+	modelInfos, err := GetAllModelInfo()
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*ModelInfo)
+	for _, modelInfo := range modelInfos {
+		existing[modelInfo.Model] = modelInfo
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range items {
+		if item.Model == "" {
+			return errors.New("模型信息缺少模型名称")
+		}
+		if seen[item.Model] {
+			return fmt.Errorf("模型 %s 的模型信息重复", item.Model)
+		}
+		seen[item.Model] = true
+
+		modelInfo := item.toModelInfo()
+		old, ok := existing[item.Model]
+		if !ok {
+			plan.add(&ConfigChange{Section: "model_infos", Key: item.Model, Action: ConfigActionCreate}, func(tx *gorm.DB) error {
+				return tx.Create(modelInfo).Error
+			})
+			continue
+		}
+
+		fields := diffConfigFields(newConfigModelInfo(old), item)
+		if len(fields) == 0 {
+			continue
+		}
+		modelInfo.Id = old.Id
+		modelInfo.CreatedAt = old.CreatedAt
+		plan.add(&ConfigChange{Section: "model_infos", Key: item.Model, Action: ConfigActionUpdate, Fields: fields}, func(tx *gorm.DB) error {
+			return tx.Save(modelInfo).Error
+		})
+	}
+
+	if !prune {
+		return nil
+	}
+	for _, modelInfo := range modelInfos {
+		if seen[modelInfo.Model] {
+			continue
+		}
+		plan.add(&ConfigChange{Section: "model_infos", Key: modelInfo.Model, Action: ConfigActionDelete}, func(tx *gorm.DB) error {
+			return tx.Delete(modelInfo).Error
+		})
+	}
+
+	return nil
+}
+
+func planConfigModelOwnedBy(plan *configPlan, items []*ModelOwnedBy, prune bool) error {
+	if items == nil {
+		return nil
+	}
+
+	ownedBies, err := GetAllModelOwnedBy()
+	if err != nil {
+		return err
+	}
+	existing := make(map[int]*ModelOwnedBy)
+	for _, ownedBy := range ownedBies {
+		existing[ownedBy.Id] = ownedBy
+	}
+
+	seen := make(map[int]bool)
+	for _, item := range items {
+		if item.Id <= 0 {
+			return fmt.Errorf("模型归属 %s 的 id 无效", item.Name)
+		}
+		if seen[item.Id] {
+			return fmt.Errorf("模型归属 id %d 重复", item.Id)
+		}
+		seen[item.Id] = true
+
+		key := fmt.Sprintf("%d", item.Id)
+		ownedBy := *item
+		old, ok := existing[item.Id]
+		if !ok {
+			plan.add(&ConfigChange{Section: "model_owned_by", Key: key, Action: ConfigActionCreate}, func(tx *gorm.DB) error {
+				return tx.Create(&ownedBy).Error
+			})
+			continue
+		}
+
+		fields := diffConfigFields(old, &ownedBy)
+		if len(fields) == 0 {
+			continue
+		}
+		plan.add(&ConfigChange{Section: "model_owned_by", Key: key, Action: ConfigActionUpdate, Fields: fields}, func(tx *gorm.DB) error {
+			return tx.Model(&ModelOwnedBy{}).Where("id = ?", ownedBy.Id).Select("name", "icon").Updates(&ownedBy).Error
+		})
+	}
+
+	if !prune {
+		return nil
+	}
+	for _, ownedBy := range ownedBies {
+		if seen[ownedBy.Id] {
+			continue
+		}
+		id := ownedBy.Id
+		plan.add(&ConfigChange{Section: "model_owned_by", Key: fmt.Sprintf("%d", id), Action: ConfigActionDelete}, func(tx *gorm.DB) error {
+			return tx.Where("id = ?", id).Delete(&ModelOwnedBy{}).Error
+		})
+	}
+
+	return nil
+}
+
+// planConfigOptions 配置项只新增和更新，空的敏感配置项视为保留原值
+func planConfigOptions(plan *configPlan, items map[string]string, importSecret func(string) (string, error)) error {
+	if items == nil {
+		return nil
+	}
+
+	current := config.GlobalOption.GetAll()
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if configExcludedOptions[key] {
+			continue
+		}
+		old, ok := current[key]
+		if !ok {
+			return fmt.Errorf("未知的配置项: %s", key)
+		}
+
+		value, err := importSecret(items[key])
+		if err != nil {
+			return fmt.Errorf("配置项 %s: %w", key, err)
+		}
+		if value == old || (value == "" && IsSecretOptionKey(key)) {
+			continue
+		}
+
+		option := &Option{Key: key, Value: value}
+		plan.add(&ConfigChange{Section: "options", Key: key, Action: ConfigActionUpdate}, func(tx *gorm.DB) error {
+			return tx.Save(option).Error
+		})
+		plan.afterCommit = append(plan.afterCommit, func() {
+			if err := config.GlobalOption.Set(option.Key, option.Value); err != nil {
+				logger.SysError("failed to update option map: " + err.Error())
+			}
+		})
+	}
+
+	return nil
+}
+
+// diffConfigFields 比较两个对象序列化后的字段，返回不同的字段名
+func diffConfigFields(old, new any) []string {
+	oldMap := configFieldMap(old)
+	newMap := configFieldMap(new)
+
+	var fields []string
+	for key, value := range newMap {
+		if !reflect.DeepEqual(oldMap[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range oldMap {
+		if _, ok := newMap[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func configFieldMap(v any) map[string]any {
+	data, _ := json.Marshal(v)
+	fields := make(map[string]any)
+	_ = json.Unmarshal(data, &fields)
+	return fields
+}
+
+func newConfigChannel(channel *Channel) *ConfigChannel {
+	return &ConfigChannel{
+		Name:               channel.Name,
+		Type:               channel.Type,
+		Status:             channel.Status,
+		Weight:             channel.Weight,
+		BaseURL:            channel.BaseURL,
+		Other:              channel.Other,
+		Models:             channel.Models,
+		Group:              channel.Group,
+		Tag:                channel.Tag,
+		ModelMapping:       channel.ModelMapping,
+		ModelHeaders:       channel.ModelHeaders,
+		CustomParameter:    channel.CustomParameter,
+		Priority:           channel.Priority,
+		Proxy:              channel.Proxy,
+		TestModel:          channel.TestModel,
+		OnlyChat:           channel.OnlyChat,
+		PreCost:            channel.PreCost,
+		CompatibleResponse: channel.CompatibleResponse,
+		AllowExtraBody:     channel.AllowExtraBody,
+		KeyRotation:        channel.KeyRotation,
+		StructuredOutput:   channel.StructuredOutput,
+		DisabledStream:     channel.DisabledStream,
+		CostRatio:          channel.CostRatio,
+		CostPrices:         channel.CostPrices,
+		Plugin:             channel.Plugin,
+	}
+}
+
+// newConfigChannelWithKey 用于比较，密钥只参与比较不会出现在变更中
+func newConfigChannelWithKey(channel *Channel) *ConfigChannel {
+	item := newConfigChannel(channel)
+	item.Key = channel.Key
+	return item
+}
+
+func (item *ConfigChannel) toChannel() *Channel {
+	return &Channel{
+		Name:               item.Name,
+		Type:               item.Type,
+		Key:                item.Key,
+		Status:             item.Status,
+		Weight:             item.Weight,
+		BaseURL:            item.BaseURL,
+		Other:              item.Other,
+		Models:             item.Models,
+		Group:              item.Group,
+		Tag:                item.Tag,
+		ModelMapping:       item.ModelMapping,
+		ModelHeaders:       item.ModelHeaders,
+		CustomParameter:    item.CustomParameter,
+		Priority:           item.Priority,
+		Proxy:              item.Proxy,
+		TestModel:          item.TestModel,
+		OnlyChat:           item.OnlyChat,
+		PreCost:            item.PreCost,
+		CompatibleResponse: item.CompatibleResponse,
+		AllowExtraBody:     item.AllowExtraBody,
+		KeyRotation:        item.KeyRotation,
+		StructuredOutput:   item.StructuredOutput,
+		DisabledStream:     item.DisabledStream,
+		CostRatio:          item.CostRatio,
+		CostPrices:         item.CostPrices,
+		Plugin:             item.Plugin,
+	}
+}
+
+func newConfigUserGroup(userGroup *UserGroup) *ConfigUserGroup {
+	return &ConfigUserGroup{
+		Symbol:    userGroup.Symbol,
+		Name:      userGroup.Name,
+		Ratio:     userGroup.Ratio,
+		APIRate:   userGroup.APIRate,
+		Public:    userGroup.Public,
+		Promotion: userGroup.Promotion,
+		Min:       userGroup.Min,
+		Max:       userGroup.Max,
+		Enable:    userGroup.Enable,
+		Policy:    userGroup.Policy,
+	}
+}
+
+func (item *ConfigUserGroup) toUserGroup() *UserGroup {
+	return &UserGroup{
+		Symbol:    item.Symbol,
+		Name:      item.Name,
+		Ratio:     item.Ratio,
+		APIRate:   item.APIRate,
+		Public:    item.Public,
+		Promotion: item.Promotion,
+		Min:       item.Min,
+		Max:       item.Max,
+		Enable:    item.Enable,
+		Policy:    item.Policy,
+	}
+}
+
+func newConfigModelInfo(modelInfo *ModelInfo) *ConfigModelInfo {
+	return &ConfigModelInfo{
+		Model:            modelInfo.Model,
+		Name:             modelInfo.Name,
+		Description:      modelInfo.Description,
+		ContextLength:    modelInfo.ContextLength,
+		MaxTokens:        modelInfo.MaxTokens,
+		ContextManage:    modelInfo.ContextManage,
+		InputModalities:  modelInfo.InputModalities,
+		OutputModalities: modelInfo.OutputModalities,
+		Tags:             modelInfo.Tags,
+		SupportUrl:       modelInfo.SupportUrl,
+	}
+}
+
+func (item *ConfigModelInfo) toModelInfo() *ModelInfo {
+	return &ModelInfo{
+		Model:            item.Model,
+		Name:             item.Name,
+		Description:      item.Description,
+		ContextLength:    item.ContextLength,
+		MaxTokens:        item.MaxTokens,
+		ContextManage:    item.ContextManage,
+		InputModalities:  item.InputModalities,
+		OutputModalities: item.OutputModalities,
+		Tags:             item.Tags,
+		SupportUrl:       item.SupportUrl,
+	}
+}
+
+// MarshalConfigDocument YAML 通过 JSON 中转，保证两种格式的字段名一致
+func MarshalConfigDocument(doc *ConfigDocument, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if format != ConfigFormatYAML {
+		return data, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(normalizeConfigValue(value))
+}
+
+// UnmarshalConfigDocument format 为空时根据内容判断格式
+func UnmarshalConfigDocument(data []byte, format string) (*ConfigDocument, error) {
+	if format == "" {
+		format = ConfigFormatYAML
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			format = ConfigFormatJSON
+		}
+	}
+
+	if format == ConfigFormatYAML {
+		var value any
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("解析 YAML 失败: %w", err)
+		}
+		var err error
+		data, err = json.Marshal(normalizeConfigValue(value))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	doc := &ConfigDocument{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("解析配置文档失败: %w", err)
+	}
+	return doc, nil
+}
+
+// normalizeConfigValue 将 json.Number 转换为数字，将 YAML 中非字符串键的 map 转换为字符串键
+func normalizeConfigValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeConfigValue(item)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalizeConfigValue(item)
+		}
+		return m
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeConfigValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,146 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/utils"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// CustomRole 自定义角色，由若干权限组成，用于让普通用户访问部分管理接口
+type CustomRole struct {
+	Id          int                         `json:"id"`
+	Name        string                      `json:"name" gorm:"type:varchar(50);uniqueIndex"`
+	Description string                      `json:"description" gorm:"type:varchar(255);default:''"`
+	Permissions datatypes.JSONSlice[string] `json:"permissions" gorm:"type:json"`
+	CreatedTime int64                       `json:"created_time" gorm:"bigint"`
+}
+
+type SearchCustomRoleParams struct {
+	Name string `form:"name"`
+	PaginationParams
+}
+
+var allowedCustomRoleOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"created_time": true,
+}
+
+func GetCustomRolesList(params *SearchCustomRoleParams) (*DataResult[CustomRole], error) {
+	var roles []*CustomRole
+	db := DB.Model(&CustomRole{})
+	if params.Name != "" {
+		db = db.Where("name LIKE ?", params.Name+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &roles, allowedCustomRoleOrderFields)
+}
+
+func GetCustomRoleById(id int) (*CustomRole, error) {
+	var role CustomRole
+	err := DB.Where("id = ?", id).First(&role).Error
+	return &role, err
+}
+
+func (role *CustomRole) validate() error {
+	if role.Name == "" {
+		return errors.New("角色名称不能为空")
+	}
+	for _, permission := range role.Permissions {
+		if !config.IsValidPermission(permission) {
+			return fmt.Errorf("未知的权限: %s", permission)
+		}
+	}
+	return nil
+}
+
+func (role *CustomRole) Insert() error {
+	if err := role.validate(); err != nil {
+		return err
+	}
+	role.CreatedTime = utils.GetTimestamp()
+	return DB.Create(role).Error
+}
+
+func (role *CustomRole) Update() error {
+	if err := role.validate(); err != nil {
+		return err
+	}
+	return DB.Model(role).Select("name", "description", "permissions").Updates(role).Error
+}
+
+// Delete 删除角色，同时取消该角色的所有用户分配
+func (role *CustomRole) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("custom_role_id = ?", role.Id).Update("custom_role_id", 0).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
+
+// SetUserCustomRole 为用户分配自定义角色，roleId 为 0 时取消分配
+func SetUserCustomRole(userId, roleId int) error {
+	if roleId > 0 {
+		if _, err := GetCustomRoleById(roleId); err != nil {
+			return errors.New("角色不存在")
+		}
+	}
+	result := DB.Model(&User{}).Where("id = ?", userId).Update("custom_role_id", roleId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	return nil
+}
+
+// GetUserPermissions 获取用户拥有的权限，管理员及以上拥有全部权限
+func GetUserPermissions(userId int) ([]string, error) {
+	var user User
+	err := DB.Select("id", "role", "custom_role_id").Where("id = ?", userId).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role >= config.RoleAdminUser {
+		permissions := make([]string, 0, len(config.Permissions))
+		for _, info := range config.Permissions {
+			permissions = append(permissions, info.Name)
+		}
+		return permissions, nil
+	}
+	if user.CustomRoleId == 0 {
+		return []string{}, nil
+	}
+
+	role, err := GetCustomRoleById(user.CustomRoleId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	return role.Permissions, nil
+}
+
+// UserHasAnyPermission 用户是否拥有任一权限，每次从数据库读取，角色修改后在所有节点立即生效
+func UserHasAnyPermission(userId int, permissions ...string) bool {
+	granted, err := GetUserPermissions(userId)
+	if err != nil {
+		return false
+	}
+	for _, permission := range permissions {
+		for _, name := range granted {
+			if name == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
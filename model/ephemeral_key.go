@@ -0,0 +1,263 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EphemeralKeyDefaultTTL = 10      // in minutes
+	EphemeralKeyMaxTTL     = 24 * 60 // in minutes
+
+	ephemeralKeySpendKey = "ephemeral_key_spend:%s"
+)
+
+var (
+	ErrEphemeralKeyInvalid       = errors.New("无效的临时密钥")
+	ErrEphemeralKeyExpired       = errors.New("临时密钥已过期")
+	ErrEphemeralKeyQuotaExceeded = errors.New("临时密钥额度已用尽")
+)
+
+// EphemeralKeyClaims 临时密钥的载荷，签名后直接作为密钥下发，校验时不需要查询存储
+type EphemeralKeyClaims struct {
+	Id        string   `json:"jti"`
+	TokenId   int      `json:"tid"`
+	UserId    int      `json:"uid"`
+	ExpiresAt int64    `json:"exp"`
+	Models    []string `json:"models,omitempty"`
+	Endpoints []string `json:"endpoints,omitempty"` // 允许访问的路径，以 * 结尾时按前缀匹配
+	MaxQuota  int      `json:"max_quota,omitempty"` // 最多可消费的额度，0 表示只受父令牌额度限制
+	EndUser   string   `json:"user,omitempty"`      // 终端用户标识，记录在消费日志中
+}
+
+type EphemeralKeyRequest struct {
+	TTL       int      `json:"ttl"` // in minutes
+	Models    []string `json:"models"`
+	Endpoints []string `json:"endpoints"`
+	MaxQuota  int      `json:"max_quota"`
+	EndUser   string   `json:"user"`
+}
+
+// CreateEphemeralKey 由父令牌派生临时密钥，模型范围不能超出父令牌的模型限制
+func CreateEphemeralKey(token *Token, req *EphemeralKeyRequest) (string, *EphemeralKeyClaims, error) {
+	ttl := req.TTL
+	if ttl == 0 {
+		ttl = EphemeralKeyDefaultTTL
+	}
+	if ttl < 0 || ttl > EphemeralKeyMaxTTL {
+		return "", nil, fmt.Errorf("有效期需要在 1 到 %d 分钟之间", EphemeralKeyMaxTTL)
+	}
+	if req.MaxQuota < 0 {
+		return "", nil, errors.New("额度不能为负数")
+	}
+	if len(req.EndUser) > 64 {
+		return "", nil, errors.New("用户标识过长")
+	}
+
+	expiresAt := utils.GetTimestamp() + int64(ttl)*60
+	// 不能超过父令牌的过期时间
+	if token.ExpiredTime != -1 && token.ExpiredTime < expiresAt {
+		expiresAt = token.ExpiredTime
+	}
+
+	setting := token.Setting.Data()
+	if setting.Limits.LimitModelSetting.Enabled {
+		for _, modelName := range req.Models {
+			if !slices.Contains(setting.Limits.LimitModelSetting.Models, modelName) {
+				return "", nil, fmt.Errorf("令牌无权使用模型 %s", modelName)
+			}
+		}
+	}
+
+	claims := &EphemeralKeyClaims{
+		Id:        utils.GetRandomString(16),
+		TokenId:   token.Id,
+		UserId:    token.UserId,
+		ExpiresAt: expiresAt,
+		Models:    req.Models,
+		Endpoints: req.Endpoints,
+		MaxQuota:  req.MaxQuota,
+		EndUser:   req.EndUser,
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return common.GenerateEphemeralKey(payload), claims, nil
+}
+
+func IsEphemeralKey(key string) bool {
+	return strings.HasPrefix(key, common.EphemeralKeyPrefix)
+}
+
+// ValidateEphemeralKey 校验临时密钥并返回父令牌，父令牌或用户被禁用、父令牌被删除后临时密钥立即失效
+func ValidateEphemeralKey(key string) (*Token, *EphemeralKeyClaims, error) {
+	payload, err := common.ParseEphemeralKey(key)
+	if err != nil {
+		return nil, nil, ErrEphemeralKeyInvalid
+	}
+
+	claims := &EphemeralKeyClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil || claims.Id == "" || claims.TokenId == 0 {
+		return nil, nil, ErrEphemeralKeyInvalid
+	}
+	if claims.ExpiresAt < utils.GetTimestamp() {
+		return nil, nil, ErrEphemeralKeyExpired
+	}
+
+	if userEnabled, err := CacheIsUserEnabled(claims.UserId); err != nil || !userEnabled {
+		return nil, nil, ErrTokenInvalid
+	}
+
+	token, err := cacheGetEphemeralParentToken(claims)
+	if err != nil || token.Id != claims.TokenId || token.UserId != claims.UserId {
+		return nil, nil, ErrTokenNotFound
+	}
+	if err := checkTokenAvailable(token); err != nil {
+		return nil, nil, err
+	}
+
+	if claims.MaxQuota > 0 && GetEphemeralKeySpend(claims) >= int64(claims.MaxQuota) {
+		return nil, nil, ErrEphemeralKeyQuotaExceeded
+	}
+
+	return token, claims, nil
+}
+
+// cacheGetEphemeralParentToken 父令牌的密钥由令牌ID和用户ID生成，按密钥走令牌缓存，旧格式的令牌从数据库读取
+func cacheGetEphemeralParentToken(claims *EphemeralKeyClaims) (*Token, error) {
+	key, err := common.GenerateToken(claims.TokenId, claims.UserId)
+	if err == nil {
+		if token, err := CacheGetTokenByKey(key); err == nil {
+			return token, nil
+		}
+	}
+	return GetTokenById(claims.TokenId)
+}
+
+// AllowEndpoint 检查请求路径是否在临时密钥允许的范围内，未限制时允许所有路径
+func (claims *EphemeralKeyClaims) AllowEndpoint(path string) bool {
+	if len(claims.Endpoints) == 0 {
+		return true
+	}
+	for _, endpoint := range claims.Endpoints {
+		if prefix, ok := strings.CutSuffix(endpoint, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyToSetting 将临时密钥的模型限制合并到父令牌的设置中，取两者的交集
+func (claims *EphemeralKeyClaims) ApplyToSetting(setting *TokenSetting) {
+	if len(claims.Models) == 0 {
+		return
+	}
+
+	models := claims.Models
+	if setting.Limits.LimitModelSetting.Enabled {
+		models = make([]string, 0, len(claims.Models))
+		for _, modelName := range claims.Models {
+			if slices.Contains(setting.Limits.LimitModelSetting.Models, modelName) {
+				models = append(models, modelName)
+			}
+		}
+	}
+	setting.Limits.LimitModelSetting = LimitModelSetting{
+		Enabled: true,
+		Models:  models,
+	}
+}
+
+// 未开启 Redis 时，临时密钥的消费额度只在当前节点统计
+var ephemeralSpend = struct {
+	sync.Mutex
+	quota   map[string]int64
+	expires map[string]int64
+}{
+	quota:   make(map[string]int64),
+	expires: make(map[string]int64),
+}
+
+func GetEphemeralKeySpend(claims *EphemeralKeyClaims) int64 {
+	if config.RedisEnabled {
+		value, err := redis.RedisGet(fmt.Sprintf(ephemeralKeySpendKey, claims.Id))
+		if err != nil {
+			return 0
+		}
+		return int64(utils.String2Int(value))
+	}
+
+	ephemeralSpend.Lock()
+	defer ephemeralSpend.Unlock()
+	return ephemeralSpend.quota[claims.Id]
+}
+
+// ReserveEphemeralKeySpend 请求开始时预扣临时密钥的额度，预扣后超出上限则撤销并拒绝请求
+// 并发请求各自预扣，上限只会被实际消费超出预扣的部分突破，因此额度上限是近似值
+func ReserveEphemeralKeySpend(claims *EphemeralKeyClaims, quota int) error {
+	if claims.MaxQuota == 0 || quota <= 0 {
+		return nil
+	}
+
+	spend, err := AddEphemeralKeySpend(claims, quota)
+	if err != nil {
+		return err
+	}
+	if spend > int64(claims.MaxQuota) {
+		if _, err := AddEphemeralKeySpend(claims, -quota); err != nil {
+			return err
+		}
+		return ErrEphemeralKeyQuotaExceeded
+	}
+	return nil
+}
+
+// AddEphemeralKeySpend 调整临时密钥的消费额度并返回调整后的总额，quota 为负数时退还预扣，记录保留到密钥过期
+func AddEphemeralKeySpend(claims *EphemeralKeyClaims, quota int) (int64, error) {
+	if claims.MaxQuota == 0 || quota == 0 {
+		return 0, nil
+	}
+
+	if config.RedisEnabled {
+		key := fmt.Sprintf(ephemeralKeySpendKey, claims.Id)
+		client := redis.GetRedisClient()
+		ctx := context.Background()
+		spend, err := client.IncrBy(ctx, key, int64(quota)).Result()
+		if err != nil {
+			return 0, err
+		}
+		if err := client.ExpireAt(ctx, key, time.Unix(claims.ExpiresAt, 0)).Err(); err != nil {
+			return spend, err
+		}
+		return spend, nil
+	}
+
+	now := utils.GetTimestamp()
+	ephemeralSpend.Lock()
+	defer ephemeralSpend.Unlock()
+	for id, expiresAt := range ephemeralSpend.expires {
+		if expiresAt < now {
+			delete(ephemeralSpend.quota, id)
+			delete(ephemeralSpend.expires, id)
+		}
+	}
+	ephemeralSpend.quota[claims.Id] += int64(quota)
+	ephemeralSpend.expires[claims.Id] = claims.ExpiresAt
+	return ephemeralSpend.quota[claims.Id], nil
+}
@@ -0,0 +1,177 @@
+package model_test
+
+import (
+	"encoding/json"
+	"one-api/common"
+	"one-api/common/utils"
+	"one-api/model"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func initEphemeralKeySecret(t *testing.T) {
+	viper.Set("user_token_secret", "ephemeral-key-test")
+	if err := common.InitUserToken(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEphemeralKeySignature(t *testing.T) {
+	initEphemeralKeySecret(t)
+
+	token := &model.Token{Id: 1, UserId: 2, ExpiredTime: -1}
+	key, claims, err := model.CreateEphemeralKey(token, &model.EphemeralKeyRequest{MaxQuota: 100, EndUser: "end-user"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, model.IsEphemeralKey(key))
+
+	payload, err := common.ParseEphemeralKey(key)
+	if assert.NoError(t, err) {
+		parsed := &model.EphemeralKeyClaims{}
+		assert.NoError(t, json.Unmarshal(payload, parsed))
+		assert.Equal(t, claims, parsed)
+	}
+
+	body, signature, _ := strings.Cut(strings.TrimPrefix(key, common.EphemeralKeyPrefix), ".")
+	otherKey, _, _ := model.CreateEphemeralKey(&model.Token{Id: 3, UserId: 2, ExpiredTime: -1}, &model.EphemeralKeyRequest{})
+	_, otherSignature, _ := strings.Cut(strings.TrimPrefix(otherKey, common.EphemeralKeyPrefix), ".")
+
+	invalid := map[string]string{
+		"empty":            "",
+		"prefix only":      common.EphemeralKeyPrefix,
+		"missing prefix":   strings.TrimPrefix(key, common.EphemeralKeyPrefix),
+		"missing part":     common.EphemeralKeyPrefix + body,
+		"tampered payload": common.EphemeralKeyPrefix + body + "x." + signature,
+		"swapped sign":     common.EphemeralKeyPrefix + body + "." + otherSignature,
+		"bad encoding":     common.EphemeralKeyPrefix + body + ".!!!",
+	}
+	for name, value := range invalid {
+		_, err := common.ParseEphemeralKey(value)
+		assert.Error(t, err, name)
+
+		_, _, err = model.ValidateEphemeralKey(value)
+		assert.ErrorIs(t, err, model.ErrEphemeralKeyInvalid, name)
+	}
+
+	// 普通令牌的签名不能当作临时密钥使用
+	tokenKey, _ := common.GenerateToken(1, 2)
+	_, err = common.ParseEphemeralKey(common.EphemeralKeyPrefix + strings.Replace(tokenKey, "_", ".", 1))
+	assert.Error(t, err)
+}
+
+func TestEphemeralKeyExpiry(t *testing.T) {
+	initEphemeralKeySecret(t)
+
+	now := utils.GetTimestamp()
+	tests := []struct {
+		name        string
+		tokenExpiry int64
+		ttl         int
+		expiresAt   int64
+		err         bool
+	}{
+		{name: "default ttl", tokenExpiry: -1, ttl: 0, expiresAt: now + model.EphemeralKeyDefaultTTL*60},
+		{name: "custom ttl", tokenExpiry: -1, ttl: 30, expiresAt: now + 30*60},
+		{name: "capped by token", tokenExpiry: now + 60, ttl: 30, expiresAt: now + 60},
+		{name: "max ttl", tokenExpiry: -1, ttl: model.EphemeralKeyMaxTTL, expiresAt: now + model.EphemeralKeyMaxTTL*60},
+		{name: "over max ttl", tokenExpiry: -1, ttl: model.EphemeralKeyMaxTTL + 1, err: true},
+		{name: "negative ttl", tokenExpiry: -1, ttl: -1, err: true},
+	}
+	for _, test := range tests {
+		token := &model.Token{Id: 1, UserId: 2, ExpiredTime: test.tokenExpiry}
+		_, claims, err := model.CreateEphemeralKey(token, &model.EphemeralKeyRequest{TTL: test.ttl})
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+		if assert.NoError(t, err, test.name) {
+			assert.InDelta(t, test.expiresAt, claims.ExpiresAt, 1, test.name)
+		}
+	}
+
+	// 过期的密钥在查询父令牌之前即被拒绝
+	expired := &model.EphemeralKeyClaims{Id: "expired", TokenId: 1, UserId: 2, ExpiresAt: now - 1}
+	payload, _ := json.Marshal(expired)
+	_, _, err := model.ValidateEphemeralKey(common.GenerateEphemeralKey(payload))
+	assert.ErrorIs(t, err, model.ErrEphemeralKeyExpired)
+}
+
+func TestEphemeralKeyModels(t *testing.T) {
+	initEphemeralKeySecret(t)
+
+	limited := &model.Token{Id: 1, UserId: 2, ExpiredTime: -1}
+	limited.Setting.Set(model.TokenSetting{Limits: model.LimitsConfig{
+		LimitModelSetting: model.LimitModelSetting{Enabled: true, Models: []string{"gpt-4o", "gpt-4o-mini"}},
+	}})
+	_, _, err := model.CreateEphemeralKey(limited, &model.EphemeralKeyRequest{Models: []string{"gpt-4o", "o1"}})
+	assert.Error(t, err)
+
+	tests := []struct {
+		name   string
+		parent model.LimitModelSetting
+		models []string
+		want   model.LimitModelSetting
+	}{
+		{
+			name:   "no key limit",
+			parent: model.LimitModelSetting{Enabled: true, Models: []string{"gpt-4o"}},
+			want:   model.LimitModelSetting{Enabled: true, Models: []string{"gpt-4o"}},
+		},
+		{
+			name:   "no parent limit",
+			parent: model.LimitModelSetting{},
+			models: []string{"gpt-4o"},
+			want:   model.LimitModelSetting{Enabled: true, Models: []string{"gpt-4o"}},
+		},
+		{
+			name:   "intersection",
+			parent: model.LimitModelSetting{Enabled: true, Models: []string{"gpt-4o", "gpt-4o-mini"}},
+			models: []string{"gpt-4o-mini", "o1"},
+			want:   model.LimitModelSetting{Enabled: true, Models: []string{"gpt-4o-mini"}},
+		},
+		{
+			name:   "disjoint",
+			parent: model.LimitModelSetting{Enabled: true, Models: []string{"gpt-4o"}},
+			models: []string{"o1"},
+			want:   model.LimitModelSetting{Enabled: true, Models: []string{}},
+		},
+		{
+			name:   "disabled parent limit",
+			parent: model.LimitModelSetting{Enabled: false, Models: []string{"gpt-4o"}},
+			models: []string{"o1"},
+			want:   model.LimitModelSetting{Enabled: true, Models: []string{"o1"}},
+		},
+	}
+	for _, test := range tests {
+		setting := &model.TokenSetting{Limits: model.LimitsConfig{LimitModelSetting: test.parent}}
+		claims := &model.EphemeralKeyClaims{Models: test.models}
+		claims.ApplyToSetting(setting)
+		assert.Equal(t, test.want, setting.Limits.LimitModelSetting, test.name)
+	}
+}
+
+func TestEphemeralKeyAllowEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoints []string
+		path      string
+		allowed   bool
+	}{
+		{endpoints: nil, path: "/v1/chat/completions", allowed: true},
+		{endpoints: []string{"/v1/chat/completions"}, path: "/v1/chat/completions", allowed: true},
+		{endpoints: []string{"/v1/chat/completions"}, path: "/v1/chat/completions/extra", allowed: false},
+		{endpoints: []string{"/v1/chat/completions"}, path: "/v1/embeddings", allowed: false},
+		{endpoints: []string{"/v1/audio/*"}, path: "/v1/audio/speech", allowed: true},
+		{endpoints: []string{"/v1/audio/*"}, path: "/v1/audio", allowed: false},
+		{endpoints: []string{"/v1/audio*"}, path: "/v1/audio_other", allowed: true},
+		{endpoints: []string{"/v1/embeddings", "/v1/audio/*"}, path: "/v1/audio/transcriptions", allowed: true},
+		{endpoints: []string{"*"}, path: "/v1/images/generations", allowed: true},
+	}
+	for _, test := range tests {
+		claims := &model.EphemeralKeyClaims{Endpoints: test.endpoints}
+		assert.Equal(t, test.allowed, claims.AllowEndpoint(test.path), "%v %s", test.endpoints, test.path)
+	}
+}
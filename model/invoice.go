@@ -0,0 +1,408 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/config"
+	"one-api/common/utils"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InvoiceProfile 用户的开票信息，所属组织只能由管理员分配
+type InvoiceProfile struct {
+	UserId       int    `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Organization string `json:"organization" gorm:"type:varchar(128);index;default:''"`
+	TaxId        string `json:"tax_id" gorm:"type:varchar(64);default:''"`
+	Address      string `json:"address" gorm:"type:varchar(255);default:''"`
+	Contact      string `json:"contact" gorm:"type:varchar(64);default:''"`
+	Email        string `json:"email" gorm:"type:varchar(128);default:''"`
+	Remark       string `json:"remark" gorm:"type:varchar(255);default:''"`
+	UpdatedTime  int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// InvoiceOrganization 管理员维护的开票组织，组织账单使用这里的开票信息
+type InvoiceOrganization struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(128);uniqueIndex"`
+	TaxId       string `json:"tax_id" gorm:"type:varchar(64);default:''"`
+	Address     string `json:"address" gorm:"type:varchar(255);default:''"`
+	Contact     string `json:"contact" gorm:"type:varchar(64);default:''"`
+	Email       string `json:"email" gorm:"type:varchar(128);default:''"`
+	Remark      string `json:"remark" gorm:"type:varchar(255);default:''"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// InvoiceSequence 每个账期的账单编号序列
+type InvoiceSequence struct {
+	Month      string `json:"month" gorm:"type:varchar(7);primaryKey"`
+	LastNumber int    `json:"last_number" gorm:"default:0"`
+}
+
+// Invoice 已开具的账单，同一账期同一开票对象只开具一次，开具后内容不再改变
+type Invoice struct {
+	Id           int          `json:"id"`
+	Number       string       `json:"number" gorm:"type:varchar(64);uniqueIndex"`
+	Month        string       `json:"month" gorm:"type:varchar(7);uniqueIndex:idx_invoice_owner"`
+	UserId       int          `json:"user_id" gorm:"uniqueIndex:idx_invoice_owner"`
+	Organization string       `json:"organization" gorm:"type:varchar(128);uniqueIndex:idx_invoice_owner;default:''"`
+	Quota        int          `json:"quota" gorm:"default:0"`
+	Amount       float64      `json:"amount" gorm:"default:0"`
+	Currency     CurrencyType `json:"currency" gorm:"type:varchar(16)"`
+	CreatedTime  int64        `json:"created_time" gorm:"bigint"`
+	UpdatedTime  int64        `json:"updated_time" gorm:"bigint"`
+
+	// 开具时的明细和开票信息
+	Items   *datatypes.JSONType[[]*InvoiceItem]  `json:"-" gorm:"type:json"`
+	Profile *datatypes.JSONType[*InvoiceProfile] `json:"-" gorm:"type:json"`
+}
+
+type InvoiceItem struct {
+	ModelName        string  `json:"model_name" gorm:"column:model_name"`
+	RequestCount     int     `json:"request_count" gorm:"column:request_count"`
+	PromptTokens     int     `json:"prompt_tokens" gorm:"column:prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens" gorm:"column:completion_tokens"`
+	Quota            int     `json:"quota" gorm:"column:quota"`
+	Amount           float64 `json:"amount" gorm:"-"`
+}
+
+// InvoiceDocument 导出账单所需的全部数据
+type InvoiceDocument struct {
+	Invoice  *Invoice        `json:"invoice"`
+	Profile  *InvoiceProfile `json:"profile"`
+	Username string          `json:"username"`
+	Items    []*InvoiceItem  `json:"items"`
+}
+
+func GetInvoiceProfile(userId int) (*InvoiceProfile, error) {
+	profile := &InvoiceProfile{}
+	err := DB.Where("user_id = ?", userId).First(profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &InvoiceProfile{UserId: userId}, nil
+	}
+	return profile, err
+}
+
+// SaveInvoiceProfile 保存用户自己填写的开票信息，不修改所属组织
+func SaveInvoiceProfile(profile *InvoiceProfile) error {
+	current, err := GetInvoiceProfile(profile.UserId)
+	if err != nil {
+		return err
+	}
+	profile.Organization = current.Organization
+	profile.UpdatedTime = utils.GetTimestamp()
+	return DB.Save(profile).Error
+}
+
+// SetInvoiceProfileOrganization 管理员将用户分配到组织，organization 为空时移出组织
+func SetInvoiceProfileOrganization(userId int, organization string) error {
+	if _, err := GetUserById(userId, false); err != nil {
+		return err
+	}
+	if organization != "" {
+		if _, err := GetInvoiceOrganizationByName(organization); err != nil {
+			return err
+		}
+	}
+
+	profile, err := GetInvoiceProfile(userId)
+	if err != nil {
+		return err
+	}
+	profile.Organization = organization
+	profile.UpdatedTime = utils.GetTimestamp()
+	return DB.Save(profile).Error
+}
+
+func GetInvoiceOrganizations() ([]*InvoiceOrganization, error) {
+	var organizations []*InvoiceOrganization
+	err := DB.Order("id desc").Find(&organizations).Error
+	return organizations, err
+}
+
+func GetInvoiceOrganizationByName(name string) (*InvoiceOrganization, error) {
+	organization := &InvoiceOrganization{}
+	err := DB.Where("name = ?", name).First(organization).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("组织不存在")
+	}
+	return organization, err
+}
+
+func (organization *InvoiceOrganization) Insert() error {
+	if organization.Name == "" {
+		return errors.New("组织名称不能为空")
+	}
+	organization.CreatedTime = utils.GetTimestamp()
+	organization.UpdatedTime = organization.CreatedTime
+	return DB.Create(organization).Error
+}
+
+// Update 组织名称创建后不能修改，避免成员关系失效
+func (organization *InvoiceOrganization) Update() error {
+	organization.UpdatedTime = utils.GetTimestamp()
+	return DB.Model(organization).Select("tax_id", "address", "contact", "email", "remark", "updated_time").Updates(organization).Error
+}
+
+func DeleteInvoiceOrganization(id int) error {
+	organization := &InvoiceOrganization{}
+	if err := DB.First(organization, id).Error; err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&InvoiceProfile{}).Where("organization = ?", organization.Name).Update("organization", "").Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(organization).Error
+	})
+}
+
+func (organization *InvoiceOrganization) toProfile() *InvoiceProfile {
+	return &InvoiceProfile{
+		Organization: organization.Name,
+		TaxId:        organization.TaxId,
+		Address:      organization.Address,
+		Contact:      organization.Contact,
+		Email:        organization.Email,
+		Remark:       organization.Remark,
+		UpdatedTime:  organization.UpdatedTime,
+	}
+}
+
+// GetInvoiceCurrency 获取账单结算币种，目前支持 USD 和 CNY
+func GetInvoiceCurrency() CurrencyType {
+	if strings.EqualFold(config.InvoiceCurrency, string(CurrencyTypeCNY)) {
+		return CurrencyTypeCNY
+	}
+	return CurrencyTypeUSD
+}
+
+// QuotaToInvoiceAmount 将额度换算为账单币种金额
+func QuotaToInvoiceAmount(quota int) float64 {
+	amount := float64(quota) / config.QuotaPerUnit
+	if GetInvoiceCurrency() == CurrencyTypeCNY {
+		amount *= config.PaymentUSDRate
+	}
+	return utils.Decimal(amount, 2)
+}
+
+// ParseInvoiceMonth 解析账期，支持 YYYY-MM 和 YYYY-MM-DD，返回该月第一天
+func ParseInvoiceMonth(date string) (time.Time, error) {
+	invoiceTime, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		invoiceTime, err = time.Parse("2006-01", date)
+		if err != nil {
+			return time.Time{}, errors.New("无效的日期格式")
+		}
+	}
+	return time.Date(invoiceTime.Year(), invoiceTime.Month(), 1, 0, 0, 0, 0, time.Local), nil
+}
+
+func getInvoiceItems(userIds []int, date time.Time) ([]*InvoiceItem, error) {
+	var items []*InvoiceItem
+	err := DB.Table("statistics_months").
+		Select("model_name, sum(request_count) as request_count, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(quota) as quota").
+		Where("user_id IN ? AND date = ?", userIds, date.Format("2006-01-02")).
+		Group("model_name").
+		Order("model_name").
+		Scan(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		item.Amount = QuotaToInvoiceAmount(item.Quota)
+	}
+	return items, nil
+}
+
+// GetInvoiceMonthUserIds 获取指定月份有账单数据的用户
+func GetInvoiceMonthUserIds(date time.Time) ([]int, error) {
+	var userIds []int
+	err := DB.Table("statistics_months").
+		Where("date = ?", date.Format("2006-01-02")).
+		Distinct("user_id").
+		Order("user_id").
+		Pluck("user_id", &userIds).Error
+	return userIds, err
+}
+
+// GetUserInvoiceDocument 生成用户指定月份的账单
+func GetUserInvoiceDocument(userId int, date time.Time) (*InvoiceDocument, error) {
+	user, err := GetUserById(userId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := GetInvoiceProfile(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildInvoiceDocument([]int{userId}, userId, "", date, profile, user.Username)
+}
+
+// GetOrganizationInvoiceDocument 汇总管理员分配到同一组织的所有用户，使用组织的开票信息生成组织账单
+func GetOrganizationInvoiceDocument(name string, date time.Time) (*InvoiceDocument, error) {
+	if name == "" {
+		return nil, errors.New("组织名称不能为空")
+	}
+
+	organization, err := GetInvoiceOrganizationByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIds []int
+	err = DB.Model(&InvoiceProfile{}).Where("organization = ?", name).Order("user_id").Pluck("user_id", &userIds).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(userIds) == 0 {
+		return nil, errors.New("该组织没有成员")
+	}
+
+	return buildInvoiceDocument(userIds, 0, name, date, organization.toProfile(), "")
+}
+
+func buildInvoiceDocument(userIds []int, userId int, organization string, date time.Time, profile *InvoiceProfile, username string) (*InvoiceDocument, error) {
+	month := date.Format("2006-01")
+	invoice, err := getIssuedInvoice(month, userId, organization)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// 已开具的账单直接使用开具时的内容
+	if invoice != nil && invoice.Items != nil && invoice.Profile != nil {
+		return &InvoiceDocument{
+			Invoice:  invoice,
+			Profile:  invoice.Profile.Data(),
+			Username: username,
+			Items:    invoice.Items.Data(),
+		}, nil
+	}
+
+	// 只有账期结束且月度统计已生成后才能开具
+	nowMonth := time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.Local)
+	if !date.Before(nowMonth) || !IsStatisticsMonthGenerated(date) {
+		return nil, errors.New("该月份账期尚未结算，暂不能开具账单")
+	}
+
+	items, err := getInvoiceItems(userIds, date)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("该月份没有账单数据")
+	}
+
+	quota := 0
+	amount := 0.0
+	for _, item := range items {
+		quota += item.Quota
+		amount += item.Amount
+	}
+
+	if invoice != nil {
+		// 旧版本开具的账单没有保存明细，补充一次后不再改变
+		invoice, err = fillInvoiceSnapshot(invoice, items, profile)
+	} else {
+		invoice, err = issueInvoice(&Invoice{
+			Month:        month,
+			UserId:       userId,
+			Organization: organization,
+			Quota:        quota,
+			Amount:       utils.Decimal(amount, 2),
+			Currency:     GetInvoiceCurrency(),
+		}, items, profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &InvoiceDocument{
+		Invoice:  invoice,
+		Profile:  invoice.Profile.Data(),
+		Username: username,
+		Items:    invoice.Items.Data(),
+	}, nil
+}
+
+func getIssuedInvoice(month string, userId int, organization string) (*Invoice, error) {
+	invoice := &Invoice{}
+	err := DB.Where("month = ? AND user_id = ? AND organization = ?", month, userId, organization).First(invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+func fillInvoiceSnapshot(invoice *Invoice, items []*InvoiceItem, profile *InvoiceProfile) (*Invoice, error) {
+	itemsData := datatypes.NewJSONType(items)
+	profileData := datatypes.NewJSONType(profile)
+	invoice.Items = &itemsData
+	invoice.Profile = &profileData
+	invoice.UpdatedTime = utils.GetTimestamp()
+
+	err := DB.Model(&Invoice{}).
+		Where("id = ? AND items IS NULL", invoice.Id).
+		Select("items", "profile", "updated_time").
+		Updates(invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	// 并发补充时以先写入的为准
+	return getIssuedInvoice(invoice.Month, invoice.UserId, invoice.Organization)
+}
+
+// issueInvoice 开具账单，编号从账期序列中分配，序列行在事务中加锁保证编号连续且不重复
+func issueInvoice(invoice *Invoice, items []*InvoiceItem, profile *InvoiceProfile) (*Invoice, error) {
+	itemsData := datatypes.NewJSONType(items)
+	profileData := datatypes.NewJSONType(profile)
+	invoice.Items = &itemsData
+	invoice.Profile = &profileData
+	invoice.CreatedTime = utils.GetTimestamp()
+	invoice.UpdatedTime = invoice.CreatedTime
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		// 序列从该账期已有的账单数开始，兼容旧版本按数量分配的编号
+		var count int64
+		err := tx.Model(&Invoice{}).Where("month = ?", invoice.Month).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&InvoiceSequence{Month: invoice.Month, LastNumber: int(count)}).Error
+		if err != nil {
+			return err
+		}
+
+		sequence := &InvoiceSequence{}
+		err = tx.Model(sequence).Where("month = ?", invoice.Month).Update("last_number", gorm.Expr("last_number + 1")).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("month = ?", invoice.Month).First(sequence).Error
+		if err != nil {
+			return err
+		}
+
+		invoice.Number = fmt.Sprintf("%s-%s-%04d", config.InvoicePrefix, strings.ReplaceAll(invoice.Month, "-", ""), sequence.LastNumber)
+		return tx.Create(invoice).Error
+	})
+	if err != nil {
+		// 同一开票对象并发开具时，另一个请求已经开具成功
+		if existing, findErr := getIssuedInvoice(invoice.Month, invoice.UserId, invoice.Organization); findErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+
+	return invoice, nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/logsink"
 	"one-api/common/utils"
 
 	"gorm.io/datatypes"
@@ -21,6 +22,7 @@ type Log struct {
 	TokenName        string                             `json:"token_name" gorm:"index;default:''"`
 	ModelName        string                             `json:"model_name" gorm:"index;index:index_username_model_name,priority:1;default:''"`
 	Quota            int                                `json:"quota" gorm:"default:0"`
+	CostQuota        int                                `json:"cost_quota,omitempty" gorm:"default:0"`
 	PromptTokens     int                                `json:"prompt_tokens" gorm:"default:0"`
 	CompletionTokens int                                `json:"completion_tokens" gorm:"default:0"`
 	ChannelId        int                                `json:"channel_id" gorm:"index"`
@@ -88,13 +90,15 @@ func RecordConsumeLog(
 	modelName string,
 	tokenName string,
 	quota int,
+	costQuota int,
 	content string,
 	requestTime int,
 	isStream bool,
 	metadata map[string]any,
 	sourceIp string) {
-	logger.LogInfo(ctx, fmt.Sprintf("record consume log: userId=%d, channelId=%d, promptTokens=%d, completionTokens=%d, modelName=%s, tokenName=%s, quota=%d, content=%s ,sourceIp=%s", userId, channelId, promptTokens, completionTokens, modelName, tokenName, quota, content, sourceIp))
-	if !config.LogConsumeEnabled {
+	logger.LogInfo(ctx, fmt.Sprintf("record consume log: userId=%d, channelId=%d, promptTokens=%d, completionTokens=%d, modelName=%s, tokenName=%s, quota=%d, costQuota=%d, content=%s ,sourceIp=%s", userId, channelId, promptTokens, completionTokens, modelName, tokenName, quota, costQuota, content, sourceIp))
+	logSinkEnabled := logsink.Enabled()
+	if !logSinkEnabled && !config.LogConsumeEnabled {
 		return
 	}
 
@@ -111,6 +115,7 @@ func RecordConsumeLog(
 		TokenName:        tokenName,
 		ModelName:        modelName,
 		Quota:            quota,
+		CostQuota:        costQuota,
 		ChannelId:        channelId,
 		RequestTime:      requestTime,
 		IsStream:         isStream,
@@ -121,6 +126,31 @@ func RecordConsumeLog(
 		log.Metadata = datatypes.NewJSONType(metadata)
 	}
 
+	// 日志输出不受 LogConsumeEnabled 影响，关闭后仅不再写入数据库
+	if logSinkEnabled {
+		logsink.Publish(&logsink.Entry{
+			UserId:           log.UserId,
+			Username:         log.Username,
+			CreatedAt:        log.CreatedAt,
+			Type:             log.Type,
+			Content:          log.Content,
+			TokenName:        log.TokenName,
+			ModelName:        log.ModelName,
+			Quota:            log.Quota,
+			CostQuota:        log.CostQuota,
+			PromptTokens:     log.PromptTokens,
+			CompletionTokens: log.CompletionTokens,
+			ChannelId:        log.ChannelId,
+			RequestTime:      log.RequestTime,
+			IsStream:         log.IsStream,
+			SourceIp:         log.SourceIp,
+			Metadata:         metadata,
+		})
+	}
+	if !config.LogConsumeEnabled || !logsink.SQLEnabled() {
+		return
+	}
+
 	if config.BatchUpdateEnabled {
 		AddLogToBatch(log)
 	} else {
@@ -192,7 +222,8 @@ func GetLogsList(params *LogsListParams) (*DataResult[Log], error) {
 func GetUserLogsList(userId int, params *LogsListParams) (*DataResult[Log], error) {
 	var logs []*Log
 
-	tx := DB.Where("user_id = ?", userId).Omit("id")
+	// 渠道成本仅对管理员可见
+	tx := DB.Where("user_id = ?", userId).Omit("id", "cost_quota")
 
 	if params.LogType != LogTypeUnknown {
 		tx = tx.Where("type = ?", params.LogType)
@@ -219,7 +250,7 @@ func SearchAllLogs(keyword string) (logs []*Log, err error) {
 }
 
 func SearchUserLogs(userId int, keyword string) (logs []*Log, err error) {
-	err = DB.Where("user_id = ? and type = ?", userId, keyword).Order("id desc").Limit(config.MaxRecentItems).Omit("id").Find(&logs).Error
+	err = DB.Where("user_id = ? and type = ?", userId, keyword).Order("id desc").Limit(config.MaxRecentItems).Omit("id", "cost_quota").Find(&logs).Error
 	return logs, err
 }
 
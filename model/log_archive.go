@@ -0,0 +1,433 @@
+package model
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	logArchiveDateLayout = "2006-01-02"
+
+	LogArchiveStorageLocal  = "local"
+	LogArchiveStorageRemote = "storage"
+)
+
+var logArchiveRunning atomic.Bool
+
+var ErrLogArchiveRunning = errors.New("日志归档正在进行中")
+
+// LogArchive 消费日志归档记录，每天一个分区，内容为 gzip 压缩的 JSONL
+type LogArchive struct {
+	Id           int    `json:"id"`
+	Date         string `json:"date" gorm:"type:varchar(10);index"`
+	Storage      string `json:"storage" gorm:"type:varchar(20)"`
+	Location     string `json:"location" gorm:"type:varchar(1024)"`
+	Count        int64  `json:"count" gorm:"default:0"`
+	Size         int64  `json:"size" gorm:"default:0"`
+	Checksum     string `json:"checksum" gorm:"type:varchar(64)"`
+	MinLogId     int    `json:"min_log_id" gorm:"default:0"`
+	MaxLogId     int    `json:"max_log_id" gorm:"default:0"`
+	RestoredTime int64  `json:"restored_time" gorm:"bigint;default:0"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+type LogArchiveQueryParams struct {
+	Date string `form:"date"`
+	PaginationParams
+}
+
+var allowedLogArchiveOrderFields = map[string]bool{
+	"id":           true,
+	"date":         true,
+	"count":        true,
+	"created_time": true,
+}
+
+func GetLogArchivesList(params *LogArchiveQueryParams) (*DataResult[LogArchive], error) {
+	var archives []*LogArchive
+	db := DB.Model(&LogArchive{})
+	if params.Date != "" {
+		db = db.Where("date = ?", params.Date)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &archives, allowedLogArchiveOrderFields)
+}
+
+func GetLogArchiveById(id int) (*LogArchive, error) {
+	archive := &LogArchive{}
+	err := DB.Where("id = ?", id).First(archive).Error
+	return archive, err
+}
+
+// ArchiveLogs 将超过 log_archive.after_days 天的消费日志按天归档，并从数据库中删除
+func ArchiveLogs() error {
+	afterDays := viper.GetInt("log_archive.after_days")
+	if afterDays <= 0 {
+		return errors.New("log_archive.after_days must be greater than 0")
+	}
+
+	if !logArchiveRunning.CompareAndSwap(false, true) {
+		return ErrLogArchiveRunning
+	}
+	defer logArchiveRunning.Store(false)
+
+	now := time.Now()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -afterDays)
+
+	for {
+		var first Log
+		err := DB.Where("type = ? AND created_at < ?", LogTypeConsume, cutoff.Unix()).Order("created_at").Limit(1).Find(&first).Error
+		if err != nil {
+			return err
+		}
+		if first.Id == 0 {
+			return nil
+		}
+
+		day := time.Unix(first.CreatedAt, 0)
+		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+		if err := archiveLogsOfDay(dayStart); err != nil {
+			return fmt.Errorf("archive %s: %w", dayStart.Format(logArchiveDateLayout), err)
+		}
+	}
+}
+
+func archiveLogsOfDay(dayStart time.Time) error {
+	date := dayStart.Format(logArchiveDateLayout)
+	startTime := dayStart.Unix()
+	endTime := dayStart.AddDate(0, 0, 1).Unix()
+	batchSize := logArchiveBatchSize()
+
+	file, err := os.CreateTemp("", "one-hub-logs-"+date+"-*.jsonl.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	hash := sha256.New()
+	gzipWriter := gzip.NewWriter(io.MultiWriter(file, hash))
+	encoder := json.NewEncoder(gzipWriter)
+
+	archive := &LogArchive{Date: date}
+	lastId := 0
+	for {
+		var logs []*Log
+		err := DB.Where("type = ? AND created_at >= ? AND created_at < ? AND id > ?", LogTypeConsume, startTime, endTime, lastId).
+			Order("id").Limit(batchSize).Find(&logs).Error
+		if err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			break
+		}
+
+		for _, log := range logs {
+			if err := encoder.Encode(log); err != nil {
+				return err
+			}
+		}
+		if archive.MinLogId == 0 {
+			archive.MinLogId = logs[0].Id
+		}
+		lastId = logs[len(logs)-1].Id
+		archive.MaxLogId = lastId
+		archive.Count += int64(len(logs))
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+	if archive.Count == 0 {
+		return nil
+	}
+	archive.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if err := saveLogArchiveFile(archive, file); err != nil {
+		return err
+	}
+
+	// 之前恢复过的归档已包含在本次归档中，删除旧记录避免重复
+	var restored []*LogArchive
+	DB.Where("date = ? AND restored_time > 0", date).Find(&restored)
+
+	archive.CreatedTime = utils.GetTimestamp()
+	if err := DB.Create(archive).Error; err != nil {
+		return err
+	}
+
+	for _, old := range restored {
+		old.delete()
+	}
+
+	// 归档文件保存成功后再分批删除
+	for {
+		var ids []int
+		err := DB.Model(&Log{}).Where("type = ? AND created_at >= ? AND created_at < ? AND id <= ?", LogTypeConsume, startTime, endTime, archive.MaxLogId).
+			Limit(batchSize).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if err := DB.Where("id IN ?", ids).Delete(&Log{}).Error; err != nil {
+			return err
+		}
+	}
+
+	logger.SysLog(fmt.Sprintf("archived %d logs of %s", archive.Count, date))
+	return nil
+}
+
+// saveLogArchiveFile 将归档文件保存到本地目录，归档包含用户请求记录，不上传到公开的图床存储
+func saveLogArchiveFile(archive *LogArchive, file *os.File) error {
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	archive.Size = stat.Size()
+	fileName := fmt.Sprintf("logs-%s-%d.jsonl.gz", archive.Date, archive.MaxLogId)
+
+	dir := viper.GetString("log_archive.dir")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	target, err := os.OpenFile(filepath.Join(dir, fileName), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	if _, err := io.Copy(target, file); err != nil {
+		return err
+	}
+
+	archive.Storage = LogArchiveStorageLocal
+	archive.Location = fileName
+	return nil
+}
+
+// open 打开归档文件，返回解压后的内容，旧版本上传到存储的归档从存储地址读取
+func (archive *LogArchive) open() (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	if archive.Storage == LogArchiveStorageRemote {
+		resp, err := requester.HTTPClient.Get(archive.Location)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+		}
+		reader = resp.Body
+	} else {
+		file, err := os.Open(filepath.Join(viper.GetString("log_archive.dir"), filepath.Base(archive.Location)))
+		if err != nil {
+			return nil, err
+		}
+		reader = file
+	}
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	return &archiveReader{Reader: gzipReader, closers: []io.Closer{gzipReader, reader}}, nil
+}
+
+type archiveReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *archiveReader) Close() error {
+	for _, closer := range r.closers {
+		closer.Close()
+	}
+	return nil
+}
+
+// each 逐条读取归档中的日志
+func (archive *LogArchive) each(handler func(log *Log) error) error {
+	reader, err := archive.open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		log := &Log{}
+		if err := json.Unmarshal(scanner.Bytes(), log); err != nil {
+			return err
+		}
+		if err := handler(log); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+type LogArchiveLogsParams struct {
+	Username  string `form:"username"`
+	TokenName string `form:"token_name"`
+	ModelName string `form:"model_name"`
+	ChannelId int    `form:"channel_id"`
+	Page      int    `form:"page"`
+	Size      int    `form:"size"`
+}
+
+// QueryLogs 查询归档中的日志，归档文件按顺序扫描
+func (archive *LogArchive) QueryLogs(params *LogArchiveLogsParams) (*DataResult[Log], error) {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.Size < 1 {
+		params.Size = config.ItemsPerPage
+	}
+	if params.Size > config.MaxRecentItems {
+		return nil, fmt.Errorf("size 参数不能超过 %d", config.MaxRecentItems)
+	}
+
+	offset := (params.Page - 1) * params.Size
+	logs := make([]*Log, 0, params.Size)
+	var total int64
+
+	err := archive.each(func(log *Log) error {
+		if params.Username != "" && log.Username != params.Username {
+			return nil
+		}
+		if params.TokenName != "" && log.TokenName != params.TokenName {
+			return nil
+		}
+		if params.ModelName != "" && log.ModelName != params.ModelName {
+			return nil
+		}
+		if params.ChannelId != 0 && log.ChannelId != params.ChannelId {
+			return nil
+		}
+
+		if total >= int64(offset) && len(logs) < params.Size {
+			logs = append(logs, log)
+		}
+		total++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataResult[Log]{
+		Data:       &logs,
+		Page:       params.Page,
+		Size:       params.Size,
+		TotalCount: total,
+	}, nil
+}
+
+// Restore 将归档重新导入数据库，已存在的日志会被跳过
+func (archive *LogArchive) Restore() (int64, error) {
+	batchSize := logArchiveBatchSize()
+	batch := make([]*Log, 0, batchSize)
+	var count int64
+
+	insert := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch)
+		if result.Error != nil {
+			return result.Error
+		}
+		count += result.RowsAffected
+		batch = make([]*Log, 0, batchSize)
+		return nil
+	}
+
+	err := archive.each(func(log *Log) error {
+		batch = append(batch, log)
+		if len(batch) >= batchSize {
+			return insert()
+		}
+		return nil
+	})
+	if err == nil {
+		err = insert()
+	}
+	if err != nil {
+		return count, err
+	}
+
+	archive.RestoredTime = utils.GetTimestamp()
+	err = DB.Model(archive).Update("restored_time", archive.RestoredTime).Error
+	return count, err
+}
+
+func (archive *LogArchive) delete() {
+	switch archive.Storage {
+	case LogArchiveStorageLocal:
+		err := os.Remove(filepath.Join(viper.GetString("log_archive.dir"), filepath.Base(archive.Location)))
+		if err != nil && !os.IsNotExist(err) {
+			logger.SysError("remove log archive error: " + err.Error())
+			return
+		}
+	case LogArchiveStorageRemote:
+		// 存储驱动不支持删除，记录地址以便手动清理
+		logger.SysError(fmt.Sprintf("log archive %d of %s is stored remotely, please delete it from the storage manually: %s", archive.Id, archive.Date, archive.Location))
+	}
+	DB.Delete(archive)
+}
+
+// DeleteExpiredLogArchives 删除超过 log_archive.retention_days 天的归档
+func DeleteExpiredLogArchives() (int, error) {
+	retentionDays := viper.GetInt("log_archive.retention_days")
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	expiredDate := time.Now().AddDate(0, 0, -retentionDays).Format(logArchiveDateLayout)
+	var archives []*LogArchive
+	if err := DB.Where("date < ?", expiredDate).Find(&archives).Error; err != nil {
+		return 0, err
+	}
+
+	for _, archive := range archives {
+		archive.delete()
+	}
+
+	return len(archives), nil
+}
+
+func logArchiveBatchSize() int {
+	batchSize := viper.GetInt("log_archive.batch_size")
+	if batchSize <= 0 {
+		batchSize = 5000
+	}
+	return batchSize
+}
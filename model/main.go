@@ -27,6 +27,7 @@ func SetupDB() {
 	}
 	ChannelGroup.Load()
 	GlobalUserGroupRatio.Load()
+	GlobalVirtualModels.Load()
 	config.RootUserEmail = GetRootUserEmail()
 	NewModelOwnedBys()
 
@@ -140,6 +141,10 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&RedemptionRecord{}, &RedemptionGrant{})
+		if err != nil {
+			return err
+		}
 		err = db.AutoMigrate(&Log{})
 		if err != nil {
 			return err
@@ -169,6 +174,46 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&WebhookDelivery{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&PayloadCapture{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&LogArchive{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ChannelProbe{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ChannelModelDisable{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ChannelKey{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&SchedulerJob{}, &SchedulerRun{}, &SchedulerLock{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&AuditLog{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&CustomRole{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&UserTwoFactor{}, &UserSession{}, &VirtualModel{})
+		if err != nil {
+			return err
+		}
 		err = db.AutoMigrate(&Statistics{})
 		if err != nil {
 			return err
@@ -204,6 +249,11 @@ func InitDB() (err error) {
 			if err != nil {
 				return err
 			}
+
+			err = db.AutoMigrate(&InvoiceProfile{}, &InvoiceOrganization{}, &InvoiceSequence{}, &Invoice{})
+			if err != nil {
+				return err
+			}
 		}
 
 		migrationAfter(DB)
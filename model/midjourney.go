@@ -46,6 +46,9 @@ type Midjourney struct {
 	Properties  string `json:"properties"`
 	Mode        string `json:"mode,omitempty"`
 	TokenID     int    `json:"token_id" gorm:"default:0"`
+	NotifyHook  string `json:"notify_hook,omitempty" gorm:"type:varchar(1024);default:''"`
+	// 多密钥渠道提交任务时使用的密钥，上游任务只能用同一个密钥查询
+	ChannelKeyHash string `json:"-" gorm:"type:varchar(64);default:''"`
 }
 
 // TaskQueryParams 用于包含所有搜索条件的结构体，可以根据需求添加更多字段
@@ -443,6 +443,22 @@ func migrateTokenLimitsStructure() *gormigrate.Migration {
 		},
 	}
 }
+func initRedemptionMaxUses() *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: "202610190001",
+		Migrate: func(tx *gorm.DB) error {
+			// 旧兑换码均为一次性兑换码
+			return tx.Model(&Redemption{}).Where("max_uses = ?", 0).Updates(map[string]interface{}{
+				"max_uses":          1,
+				"max_uses_per_user": 1,
+			}).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return nil
+		},
+	}
+}
+
 func migrationAfter(db *gorm.DB) error {
 	// 从库不执行
 	if !config.IsMasterNode {
@@ -456,6 +472,7 @@ func migrationAfter(db *gorm.DB) error {
 		addOldTokenMaxId(),
 		addExtraRatios(),
 		migrateTokenLimitsStructure(),
+		initRedemptionMaxUses(),
 	})
 	return m.Migrate()
 }
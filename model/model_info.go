@@ -1,8 +1,12 @@
 package model
 
 import (
+	"fmt"
+	"one-api/common/cache"
+	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
+	"time"
 )
 
 type ModelInfo struct {
@@ -12,6 +16,7 @@ type ModelInfo struct {
 	Description      string `json:"description" gorm:"type:text"`
 	ContextLength    int    `json:"context_length"`
 	MaxTokens        int    `json:"max_tokens"`
+	ContextManage    bool   `json:"context_manage" gorm:"default:false"` // 超出上下文长度时自动裁剪请求
 	InputModalities  string `json:"input_modalities" gorm:"type:text"`
 	OutputModalities string `json:"output_modalities" gorm:"type:text"`
 	Tags             string `json:"tags" gorm:"type:text"`
@@ -26,6 +31,7 @@ type ModelInfoResponse struct {
 	Description      string   `json:"description"`
 	ContextLength    int      `json:"context_length"`
 	MaxTokens        int      `json:"max_tokens"`
+	ContextManage    bool     `json:"context_manage"`
 	InputModalities  []string `json:"input_modalities"`
 	OutputModalities []string `json:"output_modalities"`
 	Tags             []string `json:"tags"`
@@ -41,6 +47,7 @@ func (m *ModelInfo) ToResponse() *ModelInfoResponse {
 		Description:   m.Description,
 		ContextLength: m.ContextLength,
 		MaxTokens:     m.MaxTokens,
+		ContextManage: m.ContextManage,
 		CreatedAt:     m.CreatedAt,
 		UpdatedAt:     m.UpdatedAt,
 	}
@@ -100,6 +107,20 @@ func GetModelInfoByModel(model string) (*ModelInfo, error) {
 	return modelInfo, nil
 }
 
+func CacheGetModelInfoByModel(model string) (*ModelInfo, error) {
+	if !config.RedisEnabled {
+		return GetModelInfoByModel(model)
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(ModelInfoCacheKey, model),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (*ModelInfo, error) {
+			return GetModelInfoByModel(model)
+		},
+		cache.CacheTimeout)
+}
+
 func GetAllModelInfo() ([]*ModelInfo, error) {
 	var modelInfos []*ModelInfo
 	err := DB.Order("id desc").Find(&modelInfos).Error
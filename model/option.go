@@ -4,13 +4,14 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/utils"
 	"strings"
 	"time"
 )
 
 type Option struct {
 	Key   string `json:"key" gorm:"primaryKey"`
-	Value string `json:"value"`
+	Value string `json:"value" gorm:"serializer:encrypted_option"`
 }
 
 func AllOption() ([]*Option, error) {
@@ -33,6 +34,7 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterBool("WeChatAuthEnabled", &config.WeChatAuthEnabled)
 	config.GlobalOption.RegisterBool("LarkAuthEnabled", &config.LarkAuthEnabled)
 	config.GlobalOption.RegisterBool("OIDCAuthEnabled", &config.OIDCAuthEnabled)
+	config.GlobalOption.RegisterBool("LDAPAuthEnabled", &config.LDAPAuthEnabled)
 	config.GlobalOption.RegisterBool("TurnstileCheckEnabled", &config.TurnstileCheckEnabled)
 	config.GlobalOption.RegisterBool("RegisterEnabled", &config.RegisterEnabled)
 	config.GlobalOption.RegisterBool("AutomaticDisableChannelEnabled", &config.AutomaticDisableChannelEnabled)
@@ -72,6 +74,27 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterString("OIDCScopes", &config.OIDCScopes)
 	config.GlobalOption.RegisterString("OIDCUsernameClaims", &config.OIDCUsernameClaims)
 
+	config.GlobalOption.RegisterString("LDAPServerURL", &config.LDAPServerURL)
+	config.GlobalOption.RegisterBool("LDAPStartTLS", &config.LDAPStartTLS)
+	config.GlobalOption.RegisterBool("LDAPInsecureSkipVerify", &config.LDAPInsecureSkipVerify)
+	config.GlobalOption.RegisterString("LDAPBindDN", &config.LDAPBindDN)
+	config.GlobalOption.RegisterString("LDAPBindPassword", &config.LDAPBindPassword)
+	config.GlobalOption.RegisterString("LDAPBaseDN", &config.LDAPBaseDN)
+	config.GlobalOption.RegisterString("LDAPUserFilter", &config.LDAPUserFilter)
+	config.GlobalOption.RegisterString("LDAPUsernameAttribute", &config.LDAPUsernameAttribute)
+	config.GlobalOption.RegisterString("LDAPEmailAttribute", &config.LDAPEmailAttribute)
+	config.GlobalOption.RegisterString("LDAPDisplayNameAttribute", &config.LDAPDisplayNameAttribute)
+	config.GlobalOption.RegisterString("LDAPGroupAttribute", &config.LDAPGroupAttribute)
+	config.GlobalOption.RegisterString("LDAPGroupBaseDN", &config.LDAPGroupBaseDN)
+	config.GlobalOption.RegisterString("LDAPGroupFilter", &config.LDAPGroupFilter)
+	config.GlobalOption.RegisterString("LDAPGroupMapping", &config.LDAPGroupMapping)
+	config.GlobalOption.RegisterBool("LDAPAutoProvision", &config.LDAPAutoProvision)
+
+	config.GlobalOption.RegisterBool("TwoFactorRequiredForAdmin", &config.TwoFactorRequiredForAdmin)
+
+	config.GlobalOption.RegisterBool("SCIMEnabled", &config.SCIMEnabled)
+	config.GlobalOption.RegisterString("SCIMToken", &config.SCIMToken)
+
 	config.GlobalOption.RegisterString("WeChatServerAddress", &config.WeChatServerAddress)
 	config.GlobalOption.RegisterString("WeChatServerToken", &config.WeChatServerToken)
 	config.GlobalOption.RegisterString("WeChatAccountQRCodeImageURL", &config.WeChatAccountQRCodeImageURL)
@@ -82,6 +105,8 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterInt("QuotaForInvitee", &config.QuotaForInvitee)
 	config.GlobalOption.RegisterInt("QuotaRemindThreshold", &config.QuotaRemindThreshold)
 	config.GlobalOption.RegisterInt("PreConsumedQuota", &config.PreConsumedQuota)
+	config.GlobalOption.RegisterString("ContextSummaryModel", &config.ContextSummaryModel)
+	config.GlobalOption.RegisterInt("StructuredOutputRetries", &config.StructuredOutputRetries)
 
 	config.GlobalOption.RegisterString("TopUpLink", &config.TopUpLink)
 	config.GlobalOption.RegisterString("ChatLink", &config.ChatLink)
@@ -91,10 +116,36 @@ func InitOptionMap() {
 	config.GlobalOption.RegisterInt("RetryCooldownSeconds", &config.RetryCooldownSeconds)
 
 	config.GlobalOption.RegisterBool("MjNotifyEnabled", &config.MjNotifyEnabled)
+	config.GlobalOption.RegisterString("WebhookSecret", &config.WebhookSecret)
+
+	config.GlobalOption.RegisterBool("PayloadCaptureEnabled", &config.PayloadCaptureEnabled)
+	config.GlobalOption.RegisterFloat("PayloadCaptureSampleRate", &config.PayloadCaptureSampleRate)
+	config.GlobalOption.RegisterCustom("PayloadCaptureUserIds", func() string {
+		return utils.JoinInts(config.PayloadCaptureUserIds)
+	}, func(value string) error {
+		config.PayloadCaptureUserIds = utils.SplitInts(value)
+		return nil
+	}, "")
+	config.GlobalOption.RegisterCustom("PayloadCaptureTokenIds", func() string {
+		return utils.JoinInts(config.PayloadCaptureTokenIds)
+	}, func(value string) error {
+		config.PayloadCaptureTokenIds = utils.SplitInts(value)
+		return nil
+	}, "")
+	config.GlobalOption.RegisterCustom("PayloadCaptureModels", func() string {
+		return strings.Join(config.PayloadCaptureModels, ",")
+	}, func(value string) error {
+		config.PayloadCaptureModels = utils.SplitNonEmpty(value)
+		return nil
+	}, "")
 	config.GlobalOption.RegisterString("ChatImageRequestProxy", &config.ChatImageRequestProxy)
 	config.GlobalOption.RegisterFloat("PaymentUSDRate", &config.PaymentUSDRate)
 	config.GlobalOption.RegisterInt("PaymentMinAmount", &config.PaymentMinAmount)
 
+	config.GlobalOption.RegisterString("InvoicePrefix", &config.InvoicePrefix)
+	config.GlobalOption.RegisterString("InvoiceCurrency", &config.InvoiceCurrency)
+	config.GlobalOption.RegisterString("InvoiceIssuer", &config.InvoiceIssuer)
+
 	config.GlobalOption.RegisterCustom("RechargeDiscount", func() string {
 		return common.RechargeDiscount2JSONString()
 	}, func(value string) error {
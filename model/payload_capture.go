@@ -0,0 +1,137 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common/capture"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	PayloadStorageLocal  = "local"
+	PayloadStorageRemote = "storage"
+)
+
+var payloadIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+// PayloadCapture 请求内容采集记录，内容保存在本地目录或存储中
+type PayloadCapture struct {
+	Id          string `json:"id" gorm:"type:varchar(64);primaryKey"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	TokenId     int    `json:"token_id" gorm:"index"`
+	ChannelId   int    `json:"channel_id" gorm:"index"`
+	ModelName   string `json:"model_name" gorm:"type:varchar(255);index"`
+	Storage     string `json:"storage" gorm:"type:varchar(20)"`
+	Location    string `json:"location" gorm:"type:varchar(1024)"`
+	Size        int    `json:"size" gorm:"default:0"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint;index"`
+}
+
+// SavePayloadCapture 保存采集内容到本地目录
+// 采集内容包含完整的对话，storage 配置的都是公开访问的图床或 CDN 地址，因此不上传到存储
+func SavePayloadCapture(payload capture.Payload) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.SysError("marshal payload capture error: " + err.Error())
+		return
+	}
+
+	record := &PayloadCapture{
+		Id:          payload.Id,
+		UserId:      payload.UserId,
+		TokenId:     payload.TokenId,
+		ChannelId:   payload.ChannelId,
+		ModelName:   payload.Model,
+		Size:        len(data),
+		CreatedTime: payload.CreatedAt,
+	}
+
+	record.Storage = PayloadStorageLocal
+	record.Location = payload.Id + ".json"
+	dir := viper.GetString("payload_capture.dir")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		logger.SysError("create payload capture dir error: " + err.Error())
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, record.Location), data, 0600); err != nil {
+		logger.SysError("write payload capture error: " + err.Error())
+		return
+	}
+
+	if err := DB.Create(record).Error; err != nil {
+		logger.SysError("insert payload capture error: " + err.Error())
+	}
+}
+
+func GetPayloadCapture(id string) (*PayloadCapture, error) {
+	if !payloadIdRegexp.MatchString(id) {
+		return nil, errors.New("无效的采集ID")
+	}
+
+	record := &PayloadCapture{}
+	err := DB.Where("id = ?", id).First(record).Error
+	return record, err
+}
+
+// GetData 读取采集内容，旧版本上传到存储的记录从存储地址读取
+func (record *PayloadCapture) GetData() ([]byte, error) {
+	if record.Storage == PayloadStorageRemote {
+		resp, err := requester.HTTPClient.Get(record.Location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("status code: %d", resp.StatusCode)
+		}
+		return io.ReadAll(resp.Body)
+	}
+
+	return os.ReadFile(filepath.Join(viper.GetString("payload_capture.dir"), filepath.Base(record.Location)))
+}
+
+// DeleteExpiredPayloadCaptures 删除超过保留天数的采集记录，存储中的文件需通过存储自身的生命周期规则清理
+func DeleteExpiredPayloadCaptures() (int, error) {
+	retentionDays := viper.GetInt("payload_capture.retention_days")
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	expiredTime := utils.GetTimestamp() - int64(retentionDays)*86400
+	dir := viper.GetString("payload_capture.dir")
+
+	var records []*PayloadCapture
+	err := DB.Where("created_time < ?", expiredTime).Find(&records).Error
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		if record.Storage == PayloadStorageLocal {
+			err := os.Remove(filepath.Join(dir, filepath.Base(record.Location)))
+			if err != nil && !os.IsNotExist(err) {
+				logger.SysError("remove payload capture error: " + err.Error())
+				continue
+			}
+		}
+		ids = append(ids, record.Id)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	err = DB.Where("id IN ?", ids).Delete(&PayloadCapture{}).Error
+	return len(ids), err
+}
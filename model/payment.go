@@ -1,6 +1,8 @@
 package model
 
 import (
+	"encoding/json"
+	"one-api/common/secret"
 	"one-api/common/utils"
 
 	"gorm.io/gorm"
@@ -23,7 +25,7 @@ type Payment struct {
 	FixedFee     float64        `json:"fixed_fee" form:"fixed_fee" gorm:"type:decimal(10,2); default:0.00"`
 	PercentFee   float64        `json:"percent_fee" form:"percent_fee" gorm:"type:decimal(10,2); default:0.00"`
 	Currency     CurrencyType   `json:"currency" form:"currency" gorm:"type:varchar(5)"`
-	Config       string         `json:"config" form:"config" gorm:"type:text"`
+	Config       string         `json:"config" form:"config" gorm:"type:text;serializer:encrypted"`
 	Sort         int            `json:"sort" form:"sort" gorm:"default:1"`
 	Enable       *bool          `json:"enable" form:"enable" gorm:"default:true"`
 	CreatedAt    int64          `json:"created_at" gorm:"bigint"`
@@ -108,3 +110,32 @@ func (p *Payment) Update(overwrite bool) error {
 func (p *Payment) Delete() error {
 	return DB.Delete(p).Error
 }
+
+// MaskPaymentConfig 隐藏支付配置中的密钥字段
+func MaskPaymentConfig(config string) string {
+	return secret.MaskJSONFields(config)
+}
+
+// RestorePaymentConfig 将提交的配置中未修改的隐藏字段还原为原值
+func RestorePaymentConfig(config, original string) string {
+	if config == "" || config == secret.Mask(original) {
+		return original
+	}
+
+	fields := make(map[string]any)
+	originalFields := make(map[string]any)
+	if json.Unmarshal([]byte(config), &fields) != nil || json.Unmarshal([]byte(original), &originalFields) != nil {
+		return config
+	}
+
+	for name, value := range fields {
+		str, ok := value.(string)
+		originalStr, originalOk := originalFields[name].(string)
+		if ok && originalOk && secret.IsSecretField(name) && str == secret.Mask(originalStr) {
+			fields[name] = originalStr
+		}
+	}
+
+	restored, _ := json.Marshal(fields)
+	return string(restored)
+}
@@ -141,6 +141,15 @@ func (p *Pricing) GetPrice(modelName string) *Price {
 	}
 }
 
+// HasPrice 是否单独配置了该模型的价格，不进行模糊匹配
+func (p *Pricing) HasPrice(modelName string) bool {
+	p.RLock()
+	defer p.RUnlock()
+
+	_, ok := p.Prices[modelName]
+	return ok
+}
+
 func (p *Pricing) GetAllPrices() map[string]*Price {
 	return p.Prices
 }
@@ -9,6 +9,7 @@ import (
 	"one-api/common/utils"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Redemption struct {
@@ -21,6 +22,16 @@ type Redemption struct {
 	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
 	RedeemedTime int64  `json:"redeemed_time" gorm:"bigint"`
 	Count        int    `json:"count" gorm:"-:all"` // only for api request
+
+	// 活动兑换码
+	Campaign       string  `json:"campaign" gorm:"type:varchar(64);index;default:''"`
+	ExpiredTime    int64   `json:"expired_time" gorm:"bigint;default:0"`           // 0 表示永不过期
+	MaxUses        int     `json:"max_uses" gorm:"default:0"`                      // 兑换码总可用次数，0 表示不限
+	MaxUsesPerUser int     `json:"max_uses_per_user" gorm:"default:0"`             // 每个用户可用次数，0 表示不限
+	UsedCount      int     `json:"used_count" gorm:"default:0"`                    // 已使用次数
+	GrantGroup     string  `json:"grant_group" gorm:"type:varchar(32);default:''"` // 兑换后升级的用户分组
+	GrantGroupDays int     `json:"grant_group_days" gorm:"default:0"`              // 分组有效天数，0 表示永久
+	TopupBonus     float64 `json:"topup_bonus" gorm:"default:0"`                   // 下一次充值的额外赠送比例，0.2 表示多送 20%
 }
 
 var allowedRedemptionslOrderFields = map[string]bool{
@@ -30,14 +41,24 @@ var allowedRedemptionslOrderFields = map[string]bool{
 	"quota":         true,
 	"created_time":  true,
 	"redeemed_time": true,
+	"expired_time":  true,
+	"used_count":    true,
+}
+
+type SearchRedemptionsParams struct {
+	GenericParams
+	Campaign string `form:"campaign"`
 }
 
-func GetRedemptionsList(params *GenericParams) (*DataResult[Redemption], error) {
+func GetRedemptionsList(params *SearchRedemptionsParams) (*DataResult[Redemption], error) {
 	var redemptions []*Redemption
 	db := DB
 	if params.Keyword != "" {
 		db = db.Where("id = ? or name LIKE ?", utils.String2Int(params.Keyword), params.Keyword+"%")
 	}
+	if params.Campaign != "" {
+		db = db.Where("campaign = ?", params.Campaign)
+	}
 
 	return PaginateAndOrder[Redemption](db, &params.PaginationParams, &redemptions, allowedRedemptionslOrderFields)
 }
@@ -66,37 +87,104 @@ func Redeem(key string, userId int, ip string) (quota int, err error) {
 		keyCol = `"key"`
 	}
 
+	var grants []*RedemptionGrant
 	err = DB.Transaction(func(tx *gorm.DB) error {
-		err := tx.Set("gorm:query_option", "FOR UPDATE").Where(keyCol+" = ?", key).First(redemption).Error
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(keyCol+" = ?", key).First(redemption).Error
 		if err != nil {
 			return errors.New("无效的兑换码")
 		}
 		if redemption.Status != config.RedemptionCodeStatusEnabled {
 			return errors.New("该兑换码已被使用")
 		}
-		err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+		if redemption.IsExpired() {
+			return errors.New("该兑换码已过期")
+		}
+		if redemption.MaxUses > 0 && redemption.UsedCount >= redemption.MaxUses {
+			return errors.New("该兑换码已被使用")
+		}
+
+		if redemption.MaxUsesPerUser > 0 {
+			var userUsedCount int64
+			err = tx.Model(&RedemptionRecord{}).Where("redemption_id = ? AND user_id = ?", redemption.Id, userId).Count(&userUsedCount).Error
+			if err != nil {
+				return err
+			}
+			if userUsedCount >= int64(redemption.MaxUsesPerUser) {
+				return errors.New("您已达到该兑换码的使用次数上限")
+			}
+		}
+
+		if redemption.Quota > 0 {
+			err = tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", redemption.Quota)).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		grants, err = applyRedemptionGrants(tx, redemption, userId)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Create(&RedemptionRecord{
+			RedemptionId: redemption.Id,
+			UserId:       userId,
+			Campaign:     redemption.Campaign,
+			Quota:        redemption.Quota,
+			CreatedTime:  utils.GetTimestamp(),
+		}).Error
 		if err != nil {
 			return err
 		}
+
+		// 条件更新使用次数，并发兑换时不会超过 MaxUses
 		redemption.RedeemedTime = utils.GetTimestamp()
-		redemption.Status = config.RedemptionCodeStatusUsed
-		err = tx.Save(redemption).Error
-		return err
+		result := tx.Model(&Redemption{}).
+			Where("id = ? AND status = ? AND (max_uses = 0 OR used_count < max_uses)", redemption.Id, config.RedemptionCodeStatusEnabled).
+			Updates(map[string]any{
+				"used_count":    gorm.Expr("used_count + 1"),
+				"redeemed_time": redemption.RedeemedTime,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("该兑换码已被使用")
+		}
+
+		redemption.UsedCount++
+		if redemption.MaxUses > 0 && redemption.UsedCount >= redemption.MaxUses {
+			redemption.Status = config.RedemptionCodeStatusUsed
+			return tx.Model(&Redemption{}).Where("id = ?", redemption.Id).Update("status", redemption.Status).Error
+		}
+		return nil
 	})
 	if err != nil {
 		return 0, errors.New("兑换失败，" + err.Error())
 	}
 
-	// Try to upgrade user group based on cumulative recharge amount
-	err = CheckAndUpgradeUserGroup(userId, redemption.Quota)
-	if err != nil {
-		logger.SysError("failed to check and upgrade user group: " + err.Error())
+	if redemption.Quota > 0 {
+		// Try to upgrade user group based on cumulative recharge amount
+		err = CheckAndUpgradeUserGroup(userId, redemption.Quota)
+		if err != nil {
+			logger.SysError("failed to check and upgrade user group: " + err.Error())
+		}
+
+		RecordQuotaLog(userId, LogTypeTopup, redemption.Quota, ip, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
+	}
+
+	for _, grant := range grants {
+		grant.afterApply()
+		RecordLog(userId, LogTypeTopup, grant.description())
 	}
 
-	RecordQuotaLog(userId, LogTypeTopup, redemption.Quota, ip, fmt.Sprintf("通过兑换码充值 %s", common.LogQuota(redemption.Quota)))
 	return redemption.Quota, nil
 }
 
+func (redemption *Redemption) IsExpired() bool {
+	return redemption.ExpiredTime > 0 && redemption.ExpiredTime < utils.GetTimestamp()
+}
+
 func (redemption *Redemption) Insert() error {
 	var err error
 	err = DB.Create(redemption).Error
@@ -111,7 +199,7 @@ func (redemption *Redemption) SelectUpdate() error {
 // Update Make sure your token's fields is completed, because this will update non-zero values
 func (redemption *Redemption) Update() error {
 	var err error
-	err = DB.Model(redemption).Select("name", "status", "quota", "redeemed_time").Updates(redemption).Error
+	err = DB.Model(redemption).Select("name", "status", "quota", "redeemed_time", "campaign", "expired_time", "max_uses", "max_uses_per_user", "grant_group", "grant_group_days", "topup_bonus").Updates(redemption).Error
 	return err
 }
 
@@ -0,0 +1,305 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RedemptionRecord 兑换码使用记录，用于限制单用户使用次数和活动统计
+type RedemptionRecord struct {
+	Id           int    `json:"id"`
+	RedemptionId int    `json:"redemption_id" gorm:"index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Campaign     string `json:"campaign" gorm:"type:varchar(64);index;default:''"`
+	Quota        int    `json:"quota" gorm:"default:0"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+}
+
+const (
+	RedemptionGrantTypeGroup      = "group"
+	RedemptionGrantTypeTopupBonus = "topup_bonus"
+)
+
+const (
+	RedemptionGrantStatusActive   = 1
+	RedemptionGrantStatusUsed     = 2 // 充值赠送已使用 / 分组已到期
+	RedemptionGrantStatusReplaced = 3 // 被新的分组授予覆盖
+)
+
+// RedemptionGrant 兑换码带来的非额度权益：限时分组、下次充值赠送
+type RedemptionGrant struct {
+	Id            int     `json:"id"`
+	UserId        int     `json:"user_id" gorm:"index"`
+	RedemptionId  int     `json:"redemption_id" gorm:"index"`
+	Type          string  `json:"type" gorm:"type:varchar(32);index"`
+	Group         string  `json:"group" gorm:"type:varchar(32);default:''"`
+	OriginalGroup string  `json:"original_group" gorm:"type:varchar(32);default:''"`
+	Bonus         float64 `json:"bonus" gorm:"default:0"`
+	Status        int     `json:"status" gorm:"default:1;index"`
+	ExpiredTime   int64   `json:"expired_time" gorm:"bigint;default:0"`
+	CreatedTime   int64   `json:"created_time" gorm:"bigint"`
+}
+
+func applyRedemptionGrants(tx *gorm.DB, redemption *Redemption, userId int) ([]*RedemptionGrant, error) {
+	var grants []*RedemptionGrant
+	nowTime := utils.GetTimestamp()
+
+	if redemption.GrantGroup != "" {
+		grant, err := applyGroupGrant(tx, redemption, userId, nowTime)
+		if err != nil {
+			return nil, err
+		}
+		if grant != nil {
+			grants = append(grants, grant)
+		}
+	}
+
+	if redemption.TopupBonus > 0 {
+		grants = append(grants, &RedemptionGrant{
+			UserId:       userId,
+			RedemptionId: redemption.Id,
+			Type:         RedemptionGrantTypeTopupBonus,
+			Bonus:        redemption.TopupBonus,
+			Status:       RedemptionGrantStatusActive,
+			CreatedTime:  nowTime,
+		})
+	}
+
+	if len(grants) == 0 {
+		return grants, nil
+	}
+
+	err := tx.Create(&grants).Error
+	return grants, err
+}
+
+// applyGroupGrant 升级用户分组，授予的分组倍率不低于当前分组时跳过
+func applyGroupGrant(tx *gorm.DB, redemption *Redemption, userId int, nowTime int64) (*RedemptionGrant, error) {
+	grantGroup := GlobalUserGroupRatio.GetBySymbol(redemption.GrantGroup)
+	if grantGroup == nil {
+		return nil, errors.New("兑换码授予的分组不存在")
+	}
+
+	user := &User{}
+	err := tx.Select("id", "group").Where("id = ?", userId).First(user).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 只升级不降级，倍率不低于当前分组时不改变用户分组
+	if currentGroup := GlobalUserGroupRatio.GetBySymbol(user.Group); currentGroup != nil &&
+		currentGroup.Symbol != grantGroup.Symbol && grantGroup.Ratio >= currentGroup.Ratio {
+		return nil, nil
+	}
+
+	grant := &RedemptionGrant{
+		UserId:        userId,
+		RedemptionId:  redemption.Id,
+		Type:          RedemptionGrantTypeGroup,
+		Group:         redemption.GrantGroup,
+		OriginalGroup: user.Group,
+		Status:        RedemptionGrantStatusActive,
+		CreatedTime:   nowTime,
+	}
+
+	// 已有未到期的限时分组时，到期后仍然恢复到最初的分组
+	activeGrant := &RedemptionGrant{}
+	err = tx.Where("user_id = ? AND type = ? AND status = ?", userId, RedemptionGrantTypeGroup, RedemptionGrantStatusActive).
+		Order("id desc").First(activeGrant).Error
+	hasActiveGrant := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if redemption.GrantGroupDays > 0 {
+		grant.ExpiredTime = nowTime + int64(redemption.GrantGroupDays)*86400
+		if hasActiveGrant {
+			grant.OriginalGroup = activeGrant.OriginalGroup
+			if activeGrant.Group == grant.Group && activeGrant.ExpiredTime > grant.ExpiredTime {
+				grant.ExpiredTime = activeGrant.ExpiredTime
+			}
+		}
+	} else {
+		// 永久分组不需要到期恢复
+		grant.Status = RedemptionGrantStatusUsed
+	}
+
+	if hasActiveGrant {
+		err = tx.Model(&RedemptionGrant{}).Where("id = ?", activeGrant.Id).Update("status", RedemptionGrantStatusReplaced).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Model(&User{}).Where("id = ?", userId).Update("group", grant.Group).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+func (grant *RedemptionGrant) afterApply() {
+	if grant.Type == RedemptionGrantTypeGroup && config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, grant.UserId))
+	}
+}
+
+func (grant *RedemptionGrant) description() string {
+	if grant.Type == RedemptionGrantTypeTopupBonus {
+		return fmt.Sprintf("通过兑换码获得下次充值额外赠送 %.0f%%", grant.Bonus*100)
+	}
+
+	if grant.ExpiredTime > 0 {
+		return fmt.Sprintf("通过兑换码升级到分组 %s，有效期至 %s", grant.Group, time.Unix(grant.ExpiredTime, 0).Format("2006-01-02 15:04:05"))
+	}
+	return fmt.Sprintf("通过兑换码升级到分组 %s", grant.Group)
+}
+
+// ExpireRedemptionGroupGrants 恢复已到期的限时分组
+func ExpireRedemptionGroupGrants() error {
+	var grants []*RedemptionGrant
+	err := DB.Where("type = ? AND status = ? AND expired_time > 0 AND expired_time < ?",
+		RedemptionGrantTypeGroup, RedemptionGrantStatusActive, utils.GetTimestamp()).Find(&grants).Error
+	if err != nil {
+		return err
+	}
+
+	for _, grant := range grants {
+		expired := false
+		err = DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&RedemptionGrant{}).Where("id = ? AND status = ?", grant.Id, RedemptionGrantStatusActive).Update("status", RedemptionGrantStatusUsed)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			expired = true
+
+			// 期间用户分组被管理员或充值晋级修改过的，不再恢复
+			return tx.Model(&User{}).Where("id = ? AND "+quotePostgresField("group")+" = ?", grant.UserId, grant.Group).
+				Update("group", grant.OriginalGroup).Error
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("failed to expire redemption grant %d: %s", grant.Id, err.Error()))
+			continue
+		}
+		if !expired {
+			continue
+		}
+
+		grant.afterApply()
+		RecordLog(grant.UserId, LogTypeSystem, fmt.Sprintf("兑换码授予的分组 %s 已到期，恢复为 %s", grant.Group, grant.OriginalGroup))
+	}
+
+	return nil
+}
+
+// ApplyTopupBonus 使用用户最早获得的一次充值赠送，返回额外赠送的额度
+func ApplyTopupBonus(userId int, quota int) (bonusQuota int, err error) {
+	if quota <= 0 {
+		return 0, nil
+	}
+
+	grant := &RedemptionGrant{}
+	err = DB.Where("user_id = ? AND type = ? AND status = ?", userId, RedemptionGrantTypeTopupBonus, RedemptionGrantStatusActive).
+		Order("id asc").First(grant).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	bonusQuota = int(float64(quota) * grant.Bonus)
+	if bonusQuota <= 0 {
+		return 0, nil
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RedemptionGrant{}).Where("id = ? AND status = ?", grant.Id, RedemptionGrantStatusActive).Update("status", RedemptionGrantStatusUsed)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("充值赠送已被使用")
+		}
+
+		return tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", bonusQuota)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	RecordQuotaLog(userId, LogTypeTopup, bonusQuota, "", fmt.Sprintf("兑换码充值赠送 %s", common.LogQuota(bonusQuota)))
+	return bonusQuota, nil
+}
+
+type RedemptionCampaignStatistics struct {
+	Campaign      string `json:"campaign"`
+	CodeCount     int64  `json:"code_count"`
+	ExpiredCount  int64  `json:"expired_count"`
+	RedeemedCount int64  `json:"redeemed_count"`
+	UserCount     int64  `json:"user_count"`
+	Quota         int64  `json:"quota"`
+	FirstRedeemed int64  `json:"first_redeemed"`
+	LastRedeemed  int64  `json:"last_redeemed"`
+}
+
+// GetRedemptionCampaignStatistics 按活动统计兑换码发放与使用情况
+func GetRedemptionCampaignStatistics(campaign string) ([]*RedemptionCampaignStatistics, error) {
+	var codeStatistics []*RedemptionCampaignStatistics
+	codeDB := DB.Model(&Redemption{}).
+		Select("campaign, count(*) as code_count, sum(case when expired_time > 0 and expired_time < ? then 1 else 0 end) as expired_count", utils.GetTimestamp()).
+		Where("campaign != ''")
+	if campaign != "" {
+		codeDB = codeDB.Where("campaign = ?", campaign)
+	}
+	err := codeDB.Group("campaign").Order("campaign").Scan(&codeStatistics).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var recordStatistics []*RedemptionCampaignStatistics
+	recordDB := DB.Model(&RedemptionRecord{}).
+		Select("campaign, count(*) as redeemed_count, count(distinct user_id) as user_count, " + assembleSumSelectStr("quota") + " as quota, min(created_time) as first_redeemed, max(created_time) as last_redeemed").
+		Where("campaign != ''")
+	if campaign != "" {
+		recordDB = recordDB.Where("campaign = ?", campaign)
+	}
+	err = recordDB.Group("campaign").Scan(&recordStatistics).Error
+	if err != nil {
+		return nil, err
+	}
+
+	recordMap := make(map[string]*RedemptionCampaignStatistics, len(recordStatistics))
+	for _, record := range recordStatistics {
+		recordMap[record.Campaign] = record
+	}
+
+	for _, statistics := range codeStatistics {
+		if record, ok := recordMap[statistics.Campaign]; ok {
+			statistics.RedeemedCount = record.RedeemedCount
+			statistics.UserCount = record.UserCount
+			statistics.Quota = record.Quota
+			statistics.FirstRedeemed = record.FirstRedeemed
+			statistics.LastRedeemed = record.LastRedeemed
+		}
+	}
+
+	return codeStatistics, nil
+}
+
+// ExpireRedemptions 将已过期且未用完的兑换码标记为过期
+func ExpireRedemptions() (int64, error) {
+	result := DB.Model(&Redemption{}).
+		Where("status = ? AND expired_time > 0 AND expired_time < ?", config.RedemptionCodeStatusEnabled, utils.GetTimestamp()).
+		Update("status", config.RedemptionCodeStatusExpired)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,343 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 请求策略限制的接口类型
+const (
+	PolicyEndpointChat        = "chat"
+	PolicyEndpointCompletions = "completions"
+	PolicyEndpointEmbeddings  = "embeddings"
+	PolicyEndpointImages      = "images"
+	PolicyEndpointAudio       = "audio"
+	PolicyEndpointRealtime    = "realtime"
+	PolicyEndpointFiles       = "files"
+	PolicyEndpointModerations = "moderations"
+	PolicyEndpointRerank      = "rerank"
+	PolicyEndpointVideos      = "videos"
+	PolicyEndpointMidjourney  = "midjourney"
+)
+
+var PolicyEndpoints = []string{
+	PolicyEndpointChat,
+	PolicyEndpointCompletions,
+	PolicyEndpointEmbeddings,
+	PolicyEndpointImages,
+	PolicyEndpointAudio,
+	PolicyEndpointRealtime,
+	PolicyEndpointFiles,
+	PolicyEndpointModerations,
+	PolicyEndpointRerank,
+	PolicyEndpointVideos,
+	PolicyEndpointMidjourney,
+}
+
+// 请求格式，不同格式的参数位置不同
+const (
+	PolicyFormatOpenAI = "openai"
+	PolicyFormatClaude = "claude"
+	PolicyFormatGemini = "gemini"
+)
+
+const (
+	PolicyActionClamp  = "clamp"  // 将超出限制的参数修改为允许的值
+	PolicyActionReject = "reject" // 直接拒绝超出限制的请求
+)
+
+// RequestPolicy 令牌或用户分组的请求策略，两者同时存在时都需要满足
+type RequestPolicy struct {
+	Enabled        bool     `json:"enabled"`
+	Endpoints      []string `json:"endpoints,omitempty"` // 允许调用的接口类型，为空时不限制
+	Action         string   `json:"action,omitempty"`    // clamp 或 reject，默认 clamp
+	MaxTokens      int      `json:"max_tokens,omitempty"`
+	MaxN           int      `json:"max_n,omitempty"`
+	ForbidLogprobs bool     `json:"forbid_logprobs,omitempty"`
+	Temperature    *float64 `json:"temperature,omitempty"`    // 强制使用的温度
+	MaxImageSize   string   `json:"max_image_size,omitempty"` // 如 1024x1024，宽和高都不能超过
+}
+
+// PolicyRequest 待检查的请求，Body 为解析后的 JSON 请求体，非 JSON 请求为 nil
+type PolicyRequest struct {
+	Endpoint string
+	Format   string
+	Path     string
+	Body     map[string]any
+}
+
+// PolicyViolationError 请求违反策略
+type PolicyViolationError struct {
+	Message string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return e.Message
+}
+
+func policyViolation(format string, args ...any) error {
+	return &PolicyViolationError{Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *RequestPolicy) Validate() error {
+	if p == nil || !p.Enabled {
+		return nil
+	}
+	for _, endpoint := range p.Endpoints {
+		if !slices.Contains(PolicyEndpoints, endpoint) {
+			return fmt.Errorf("未知的接口类型: %s", endpoint)
+		}
+	}
+	if p.Action != "" && p.Action != PolicyActionClamp && p.Action != PolicyActionReject {
+		return fmt.Errorf("未知的处理方式: %s", p.Action)
+	}
+	if p.MaxTokens < 0 || p.MaxN < 0 {
+		return errors.New("限制值不能为负数")
+	}
+	if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+		return errors.New("温度需要在 0 到 2 之间")
+	}
+	if p.MaxImageSize != "" {
+		if _, _, ok := parseImageSize(p.MaxImageSize); !ok {
+			return fmt.Errorf("无效的图片尺寸: %s", p.MaxImageSize)
+		}
+	}
+	return nil
+}
+
+func (p *RequestPolicy) reject() bool {
+	return p.Action == PolicyActionReject
+}
+
+// Apply 检查请求是否符合策略，clamp 模式下会修改请求体，返回请求体是否被修改
+func (p *RequestPolicy) Apply(req *PolicyRequest) (bool, error) {
+	if p == nil || !p.Enabled {
+		return false, nil
+	}
+
+	if len(p.Endpoints) > 0 && !slices.Contains(p.Endpoints, req.Endpoint) {
+		return false, policyViolation("当前令牌不允许调用 %s 接口", req.Endpoint)
+	}
+	if req.Body == nil {
+		return false, nil
+	}
+
+	changed := false
+	steps := []func(*PolicyRequest) (bool, error){
+		p.applyMaxTokens,
+		p.applyMaxN,
+		p.applyLogprobs,
+		p.applyTemperature,
+		p.applyImageSize,
+	}
+	for _, step := range steps {
+		stepChanged, err := step(req)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || stepChanged
+	}
+	return changed, nil
+}
+
+// generationParams 生成参数所在的对象，Gemini 的参数在 generationConfig 中
+func generationParams(req *PolicyRequest, create bool) map[string]any {
+	if req.Format != PolicyFormatGemini {
+		return req.Body
+	}
+	if config, ok := req.Body["generationConfig"].(map[string]any); ok {
+		return config
+	}
+	if !create {
+		return nil
+	}
+	config := make(map[string]any)
+	req.Body["generationConfig"] = config
+	return config
+}
+
+func isTextGeneration(endpoint string) bool {
+	return endpoint == PolicyEndpointChat || endpoint == PolicyEndpointCompletions
+}
+
+func (p *RequestPolicy) applyMaxTokens(req *PolicyRequest) (bool, error) {
+	if p.MaxTokens == 0 || !isTextGeneration(req.Endpoint) {
+		return false, nil
+	}
+
+	var fields []string
+	defaultField := "max_tokens"
+	switch {
+	case req.Format == PolicyFormatGemini:
+		fields = []string{"maxOutputTokens"}
+		defaultField = "maxOutputTokens"
+	case req.Format == PolicyFormatClaude:
+		fields = []string{"max_tokens"}
+	case strings.HasSuffix(req.Path, "/responses"):
+		fields = []string{"max_output_tokens"}
+		defaultField = "max_output_tokens"
+	default:
+		fields = []string{"max_tokens", "max_completion_tokens"}
+	}
+
+	params := generationParams(req, true)
+	found := false
+	changed := false
+	for _, field := range fields {
+		value, ok := policyNumber(params[field])
+		if !ok {
+			continue
+		}
+		found = true
+		if value <= float64(p.MaxTokens) {
+			continue
+		}
+		if p.reject() {
+			return false, policyViolation("%s 不能超过 %d", field, p.MaxTokens)
+		}
+		params[field] = p.MaxTokens
+		changed = true
+	}
+
+	// 未指定时由上游使用模型的最大值，需要补充限制
+	if !found {
+		params[defaultField] = p.MaxTokens
+		changed = true
+	}
+	return changed, nil
+}
+
+func (p *RequestPolicy) applyMaxN(req *PolicyRequest) (bool, error) {
+	if p.MaxN == 0 {
+		return false, nil
+	}
+
+	field := "n"
+	if req.Format == PolicyFormatGemini {
+		field = "candidateCount"
+	}
+	params := generationParams(req, false)
+	if params == nil {
+		return false, nil
+	}
+
+	value, ok := policyNumber(params[field])
+	if !ok || value <= float64(p.MaxN) {
+		return false, nil
+	}
+	if p.reject() {
+		return false, policyViolation("%s 不能超过 %d", field, p.MaxN)
+	}
+	params[field] = p.MaxN
+	return true, nil
+}
+
+func (p *RequestPolicy) applyLogprobs(req *PolicyRequest) (bool, error) {
+	if !p.ForbidLogprobs {
+		return false, nil
+	}
+
+	fields := []string{"logprobs", "top_logprobs"}
+	if req.Format == PolicyFormatGemini {
+		fields = []string{"responseLogprobs", "logprobs"}
+	}
+	params := generationParams(req, false)
+	if params == nil {
+		return false, nil
+	}
+
+	changed := false
+	for _, field := range fields {
+		if !policyTruthy(params[field]) {
+			continue
+		}
+		if p.reject() {
+			return false, policyViolation("不允许使用 %s", field)
+		}
+		delete(params, field)
+		changed = true
+	}
+	return changed, nil
+}
+
+func (p *RequestPolicy) applyTemperature(req *PolicyRequest) (bool, error) {
+	if p.Temperature == nil || !isTextGeneration(req.Endpoint) {
+		return false, nil
+	}
+
+	params := generationParams(req, true)
+	value, ok := policyNumber(params["temperature"])
+	if ok && value == *p.Temperature {
+		return false, nil
+	}
+	if ok && p.reject() {
+		return false, policyViolation("temperature 只能为 %v", *p.Temperature)
+	}
+	params["temperature"] = *p.Temperature
+	return true, nil
+}
+
+func (p *RequestPolicy) applyImageSize(req *PolicyRequest) (bool, error) {
+	if p.MaxImageSize == "" || req.Endpoint != PolicyEndpointImages {
+		return false, nil
+	}
+
+	size, ok := req.Body["size"].(string)
+	if !ok {
+		return false, nil
+	}
+	width, height, ok := parseImageSize(size)
+	if !ok {
+		return false, nil
+	}
+	maxWidth, maxHeight, _ := parseImageSize(p.MaxImageSize)
+	if width <= maxWidth && height <= maxHeight {
+		return false, nil
+	}
+	if p.reject() {
+		return false, policyViolation("图片尺寸不能超过 %s", p.MaxImageSize)
+	}
+	req.Body["size"] = p.MaxImageSize
+	return true, nil
+}
+
+func parseImageSize(size string) (int, int, bool) {
+	widthText, heightText, ok := strings.Cut(strings.ToLower(size), "x")
+	if !ok {
+		return 0, 0, false
+	}
+	width, err := strconv.Atoi(widthText)
+	if err != nil || width <= 0 {
+		return 0, 0, false
+	}
+	height, err := strconv.Atoi(heightText)
+	if err != nil || height <= 0 {
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+func policyNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func policyTruthy(value any) bool {
+	if flag, ok := value.(bool); ok {
+		return flag
+	}
+	number, ok := policyNumber(value)
+	return ok && number > 0
+}
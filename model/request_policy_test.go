@@ -0,0 +1,249 @@
+package model_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"one-api/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func policyBody(t *testing.T, body string) map[string]any {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(body)))
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestRequestPolicyApply(t *testing.T) {
+	temperature := 0.5
+	clamp := model.RequestPolicy{
+		Enabled:        true,
+		MaxTokens:      100,
+		MaxN:           1,
+		ForbidLogprobs: true,
+		Temperature:    &temperature,
+		MaxImageSize:   "1024x1024",
+	}
+	reject := clamp
+	reject.Action = model.PolicyActionReject
+
+	tests := []struct {
+		name     string
+		policy   model.RequestPolicy
+		endpoint string
+		format   string
+		path     string
+		body     string
+		want     string
+		changed  bool
+		err      string
+	}{
+		{
+			name:     "openai within limits",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{"model": "gpt-4o", "max_tokens": 50, "n": 1, "temperature": 0.5}`,
+			want:     `{"model": "gpt-4o", "max_tokens": 50, "n": 1, "temperature": 0.5}`,
+		},
+		{
+			name:     "openai clamp",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{"max_tokens": 500, "max_completion_tokens": 800, "n": 4, "logprobs": true, "top_logprobs": 5, "temperature": 1.2, "seed": 12345678901234567890}`,
+			want:     `{"max_tokens": 100, "max_completion_tokens": 100, "n": 1, "temperature": 0.5, "seed": 12345678901234567890}`,
+			changed:  true,
+		},
+		{
+			name:     "openai missing max tokens",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{"temperature": 0.5}`,
+			want:     `{"max_tokens": 100, "temperature": 0.5}`,
+			changed:  true,
+		},
+		{
+			name:     "openai responses",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointChat,
+			path:     "/v1/responses",
+			body:     `{"max_output_tokens": 500, "temperature": 0.5}`,
+			want:     `{"max_output_tokens": 100, "temperature": 0.5}`,
+			changed:  true,
+		},
+		{
+			name:     "openai reject max tokens",
+			policy:   reject,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{"max_completion_tokens": 500}`,
+			err:      "max_completion_tokens 不能超过 100",
+		},
+		{
+			name:     "openai reject n",
+			policy:   reject,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{"max_tokens": 10, "n": 2}`,
+			err:      "n 不能超过 1",
+		},
+		{
+			name:     "openai reject logprobs",
+			policy:   reject,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{"max_tokens": 10, "logprobs": true}`,
+			err:      "不允许使用 logprobs",
+		},
+		{
+			name:     "openai reject temperature",
+			policy:   reject,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{"max_tokens": 10, "temperature": 1}`,
+			err:      "temperature 只能为 0.5",
+		},
+		{
+			name:     "reject fills missing values",
+			policy:   reject,
+			endpoint: model.PolicyEndpointChat,
+			body:     `{}`,
+			want:     `{"max_tokens": 100, "temperature": 0.5}`,
+			changed:  true,
+		},
+		{
+			name:     "claude clamp",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointChat,
+			format:   model.PolicyFormatClaude,
+			path:     "/claude/v1/messages",
+			body:     `{"max_tokens": 4096, "temperature": 1}`,
+			want:     `{"max_tokens": 100, "temperature": 0.5}`,
+			changed:  true,
+		},
+		{
+			name:     "claude reject",
+			policy:   reject,
+			endpoint: model.PolicyEndpointChat,
+			format:   model.PolicyFormatClaude,
+			path:     "/claude/v1/messages",
+			body:     `{"max_tokens": 4096}`,
+			err:      "max_tokens 不能超过 100",
+		},
+		{
+			name:     "gemini clamp",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointChat,
+			format:   model.PolicyFormatGemini,
+			path:     "/gemini/v1beta/models/gemini-2.0-flash:generateContent",
+			body:     `{"contents": [], "generationConfig": {"maxOutputTokens": 8192, "candidateCount": 2, "responseLogprobs": true, "logprobs": 3}}`,
+			want:     `{"contents": [], "generationConfig": {"maxOutputTokens": 100, "candidateCount": 1, "temperature": 0.5}}`,
+			changed:  true,
+		},
+		{
+			name:     "gemini missing generation config",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointChat,
+			format:   model.PolicyFormatGemini,
+			path:     "/gemini/v1beta/models/gemini-2.0-flash:generateContent",
+			body:     `{"contents": []}`,
+			want:     `{"contents": [], "generationConfig": {"maxOutputTokens": 100, "temperature": 0.5}}`,
+			changed:  true,
+		},
+		{
+			name:     "gemini reject",
+			policy:   reject,
+			endpoint: model.PolicyEndpointChat,
+			format:   model.PolicyFormatGemini,
+			path:     "/gemini/v1beta/models/gemini-2.0-flash:generateContent",
+			body:     `{"generationConfig": {"maxOutputTokens": 100, "candidateCount": 3}}`,
+			err:      "candidateCount 不能超过 1",
+		},
+		{
+			name:     "image clamp",
+			policy:   clamp,
+			endpoint: model.PolicyEndpointImages,
+			body:     `{"size": "1792x1024", "n": 1}`,
+			want:     `{"size": "1024x1024", "n": 1}`,
+			changed:  true,
+		},
+		{
+			name:     "image reject",
+			policy:   reject,
+			endpoint: model.PolicyEndpointImages,
+			body:     `{"size": "1024x1792"}`,
+			err:      "图片尺寸不能超过 1024x1024",
+		},
+		{
+			name:     "embeddings ignore text limits",
+			policy:   reject,
+			endpoint: model.PolicyEndpointEmbeddings,
+			body:     `{"input": "hello", "temperature": 1}`,
+			want:     `{"input": "hello", "temperature": 1}`,
+		},
+		{
+			name:     "endpoint not allowed",
+			policy:   model.RequestPolicy{Enabled: true, Endpoints: []string{model.PolicyEndpointChat}},
+			endpoint: model.PolicyEndpointImages,
+			body:     `{}`,
+			err:      "当前令牌不允许调用 images 接口",
+		},
+		{
+			name:     "disabled policy",
+			policy:   model.RequestPolicy{Enabled: false, MaxTokens: 1, Endpoints: []string{model.PolicyEndpointChat}},
+			endpoint: model.PolicyEndpointImages,
+			body:     `{"max_tokens": 100}`,
+			want:     `{"max_tokens": 100}`,
+		},
+	}
+
+	for _, test := range tests {
+		format := test.format
+		if format == "" {
+			format = model.PolicyFormatOpenAI
+		}
+		path := test.path
+		if path == "" {
+			path = "/v1/chat/completions"
+		}
+		req := &model.PolicyRequest{
+			Endpoint: test.endpoint,
+			Format:   format,
+			Path:     path,
+			Body:     policyBody(t, test.body),
+		}
+
+		changed, err := test.policy.Apply(req)
+		if test.err != "" {
+			var violation *model.PolicyViolationError
+			if assert.ErrorAs(t, err, &violation, test.name) {
+				assert.Equal(t, test.err, violation.Message, test.name)
+			}
+			continue
+		}
+		if !assert.NoError(t, err, test.name) {
+			continue
+		}
+		assert.Equal(t, test.changed, changed, test.name)
+		body, _ := json.Marshal(req.Body)
+		assert.JSONEq(t, test.want, string(body), test.name)
+	}
+}
+
+func TestRequestPolicyValidate(t *testing.T) {
+	temperature := 3.0
+	invalid := map[string]model.RequestPolicy{
+		"unknown endpoint": {Enabled: true, Endpoints: []string{"chat", "unknown"}},
+		"unknown action":   {Enabled: true, Action: "drop"},
+		"negative tokens":  {Enabled: true, MaxTokens: -1},
+		"temperature":      {Enabled: true, Temperature: &temperature},
+		"image size":       {Enabled: true, MaxImageSize: "1024"},
+	}
+	for name, policy := range invalid {
+		assert.Error(t, policy.Validate(), name)
+	}
+
+	valid := model.RequestPolicy{Enabled: true, Endpoints: []string{"chat"}, Action: model.PolicyActionReject, MaxImageSize: "1024X768"}
+	assert.NoError(t, valid.Validate())
+	assert.NoError(t, (&model.RequestPolicy{Enabled: false, Action: "drop"}).Validate())
+}
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"context"
+	"one-api/common/logger"
+	"one-api/common/scheduler"
+	"one-api/common/utils"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// SchedulerJob 定时任务的暂停状态
+type SchedulerJob struct {
+	Name        string `json:"name" gorm:"type:varchar(64);primaryKey"`
+	Paused      bool   `json:"paused" gorm:"default:false"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+}
+
+// SchedulerRun 定时任务执行记录
+type SchedulerRun struct {
+	Id        int    `json:"id"`
+	Name      string `json:"name" gorm:"type:varchar(64);index"`
+	Node      string `json:"node" gorm:"type:varchar(255);default:''"`
+	Trigger   string `json:"trigger" gorm:"type:varchar(20);default:''"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error" gorm:"type:varchar(1024);default:''"`
+	StartedAt int64  `json:"started_at" gorm:"bigint;index"`
+	Duration  int64  `json:"duration" gorm:"bigint;default:0"` // in milliseconds
+}
+
+// SchedulerLock 未开启 Redis 时使用数据库实现的分布式锁
+type SchedulerLock struct {
+	Name      string `gorm:"type:varchar(128);primaryKey"`
+	Token     string `gorm:"type:varchar(64);default:''"`
+	ExpiresAt int64  `gorm:"bigint;index"`
+}
+
+type SchedulerRunQueryParams struct {
+	Name    string `form:"name"`
+	Success *bool  `form:"success"`
+	PaginationParams
+}
+
+var allowedSchedulerRunOrderFields = map[string]bool{
+	"id":         true,
+	"started_at": true,
+	"duration":   true,
+}
+
+func GetSchedulerRunsList(params *SchedulerRunQueryParams) (*DataResult[SchedulerRun], error) {
+	var runs []*SchedulerRun
+	db := DB.Model(&SchedulerRun{})
+	if params.Name != "" {
+		db = db.Where("name = ?", params.Name)
+	}
+	if params.Success != nil {
+		db = db.Where("success = ?", *params.Success)
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &runs, allowedSchedulerRunOrderFields)
+}
+
+func DeleteExpiredSchedulerRuns(retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+
+	expiredTime := utils.GetTimestamp() - int64(retentionDays)*86400
+	result := DB.Where("started_at < ?", expiredTime).Delete(&SchedulerRun{})
+	return result.RowsAffected, result.Error
+}
+
+// SchedulerStore 将定时任务的暂停状态和执行记录保存到数据库
+type SchedulerStore struct{}
+
+func (SchedulerStore) IsPaused(name string) bool {
+	var job SchedulerJob
+	err := DB.Where("name = ?", name).Limit(1).Find(&job).Error
+	if err != nil {
+		logger.SysError("failed to get scheduler job: " + err.Error())
+		return false
+	}
+	return job.Paused
+}
+
+func (SchedulerStore) SetPaused(name string, paused bool) error {
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"paused", "updated_time"}),
+	}).Create(&SchedulerJob{
+		Name:        name,
+		Paused:      paused,
+		UpdatedTime: utils.GetTimestamp(),
+	}).Error
+}
+
+func (SchedulerStore) SaveRun(run *scheduler.RunRecord) {
+	message := []rune(run.Error)
+	if len(message) > 255 {
+		message = message[:255]
+	}
+
+	err := DB.Create(&SchedulerRun{
+		Name:      run.Name,
+		Node:      run.Node,
+		Trigger:   run.Trigger,
+		Success:   run.Error == "",
+		Error:     string(message),
+		StartedAt: run.StartedAt.Unix(),
+		Duration:  run.Duration.Milliseconds(),
+	}).Error
+	if err != nil {
+		logger.SysError("failed to save scheduler run: " + err.Error())
+	}
+}
+
+func (SchedulerStore) LastRun(name string) *scheduler.RunRecord {
+	var run SchedulerRun
+	err := DB.Where("name = ?", name).Order("id desc").Limit(1).Find(&run).Error
+	if err != nil || run.Id == 0 {
+		return nil
+	}
+
+	return &scheduler.RunRecord{
+		Name:      run.Name,
+		Node:      run.Node,
+		Trigger:   run.Trigger,
+		StartedAt: time.Unix(run.StartedAt, 0),
+		Duration:  time.Duration(run.Duration) * time.Millisecond,
+		Error:     run.Error,
+	}
+}
+
+// SchedulerDBLocker 基于数据库的分布式锁
+type SchedulerDBLocker struct{}
+
+func (SchedulerDBLocker) TryLock(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl).Unix()
+
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&SchedulerLock{Name: key, Token: token, ExpiresAt: expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 已过期的锁可以被抢占
+	result = DB.Model(&SchedulerLock{}).Where("name = ? AND expires_at <= ?", key, now.Unix()).
+		Updates(map[string]any{"token": token, "expires_at": expiresAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (SchedulerDBLocker) Refresh(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	result := DB.Model(&SchedulerLock{}).Where("name = ? AND token = ?", key, token).Update("expires_at", time.Now().Add(ttl).Unix())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (SchedulerDBLocker) Unlock(_ context.Context, key, token string) error {
+	return DB.Where("name = ? AND token = ?", key, token).Delete(&SchedulerLock{}).Error
+}
+
+// DeleteExpiredSchedulerLocks 清理过期的锁和执行标记
+func DeleteExpiredSchedulerLocks() (int64, error) {
+	result := DB.Where("expires_at <= ?", utils.GetTimestamp()).Delete(&SchedulerLock{})
+	return result.RowsAffected, result.Error
+}
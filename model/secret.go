@@ -0,0 +1,127 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"one-api/common/secret"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+	schema.RegisterSerializer("encrypted_option", EncryptedOptionSerializer{})
+}
+
+// EncryptedSerializer 写入数据库时加密，读取时解密，字段在内存中始终是明文
+type EncryptedSerializer struct{}
+
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	case nil:
+	default:
+		return fmt.Errorf("unsupported value type %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	plaintext, err := secret.Decrypt(value)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt field %s: %w", field.Name, err)
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, _ := fieldValue.(string)
+	return secret.Encrypt(value)
+}
+
+// EncryptedOptionSerializer 只加密敏感配置项的值
+type EncryptedOptionSerializer struct {
+	EncryptedSerializer
+}
+
+func (s EncryptedOptionSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, _ := fieldValue.(string)
+	if key := reflect.Indirect(dst).FieldByName("Key"); !key.IsValid() || !IsSecretOptionKey(key.String()) {
+		return value, nil
+	}
+	return s.EncryptedSerializer.Value(ctx, field, dst, fieldValue)
+}
+
+// RotateEncryptedSecrets 使用当前主密钥重新加密所有敏感字段，未加密的历史数据也会被加密
+func RotateEncryptedSecrets() (int, error) {
+	if !secret.Enabled() {
+		return 0, fmt.Errorf("未配置主密钥")
+	}
+
+	count := 0
+
+	var channels []*Channel
+	if err := DB.Unscoped().Select("id", "key").Find(&channels).Error; err != nil {
+		return count, err
+	}
+	for _, channel := range channels {
+		if channel.Key == "" {
+			continue
+		}
+		err := DB.Unscoped().Model(&Channel{Id: channel.Id}).Select("key").Updates(&Channel{Key: channel.Key}).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	var payments []*Payment
+	if err := DB.Unscoped().Select("id", "config").Find(&payments).Error; err != nil {
+		return count, err
+	}
+	for _, payment := range payments {
+		if payment.Config == "" {
+			continue
+		}
+		err := DB.Unscoped().Model(&Payment{ID: payment.ID}).Select("config").Updates(&Payment{Config: payment.Config}).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	var twoFactors []*UserTwoFactor
+	if err := DB.Select("id", "secret").Find(&twoFactors).Error; err != nil {
+		return count, err
+	}
+	for _, twoFactor := range twoFactors {
+		if twoFactor.Secret == "" {
+			continue
+		}
+		err := DB.Model(&UserTwoFactor{Id: twoFactor.Id}).Select("secret").Updates(&UserTwoFactor{Secret: twoFactor.Secret}).Error
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	options, err := AllOption()
+	if err != nil {
+		return count, err
+	}
+	for _, option := range options {
+		if option.Value == "" || !IsSecretOptionKey(option.Key) {
+			continue
+		}
+		if err := DB.Save(option).Error; err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
@@ -14,6 +14,7 @@ type Statistics struct {
 	ModelName        string    `json:"model_name" gorm:"primary_key;type:varchar(255)"`
 	RequestCount     int       `json:"request_count"`
 	Quota            int       `json:"quota"`
+	CostQuota        int       `json:"cost_quota" gorm:"default:0"`
 	PromptTokens     int       `json:"prompt_tokens"`
 	CompletionTokens int       `json:"completion_tokens"`
 	RequestTime      int       `json:"request_time"`
@@ -230,6 +231,78 @@ func GetChannelExpensesStatisticsByPeriod(startTime, endTime, groupType string,
 	return LogStatistics, nil
 }
 
+type MarginStatistic struct {
+	Date         string `gorm:"column:date" json:"date,omitempty"`
+	ChannelId    int    `gorm:"column:channel_id" json:"channel_id,omitempty"`
+	Channel      string `gorm:"column:channel" json:"channel,omitempty"`
+	ModelName    string `gorm:"column:model_name" json:"model_name,omitempty"`
+	RequestCount int64  `gorm:"column:request_count" json:"request_count"`
+	Quota        int64  `gorm:"column:quota" json:"quota"`
+	CostQuota    int64  `gorm:"column:cost_quota" json:"cost_quota"`
+	Margin       int64  `gorm:"column:margin" json:"margin"`
+}
+
+// GetMarginStatisticsByPeriod 按渠道、模型或日期统计收入、上游成本及毛利
+func GetMarginStatisticsByPeriod(startTime, endTime, groupType string, channelId int) (statistics []*MarginStatistic, err error) {
+	var whereClause strings.Builder
+	whereClause.WriteString("WHERE statistics.date BETWEEN ? AND ?")
+	args := []interface{}{startTime, endTime}
+
+	if channelId > 0 {
+		whereClause.WriteString(" AND statistics.channel_id = ?")
+		args = append(args, channelId)
+	}
+
+	dateStr := "statistics.date as date"
+	if common.UsingPostgreSQL {
+		dateStr = "TO_CHAR(statistics.date, 'YYYY-MM-DD') as date"
+	} else if common.UsingSQLite {
+		dateStr = "strftime('%%Y-%%m-%%d', statistics.date) as date"
+	}
+
+	var groupSelect, joinClause, groupBy, orderBy string
+	switch groupType {
+	case "model":
+		groupSelect = "statistics.model_name,"
+		groupBy = "statistics.model_name"
+		orderBy = "margin DESC"
+	case "day":
+		groupSelect = dateStr + ","
+		groupBy = "statistics.date"
+		orderBy = "statistics.date"
+	case "channel_model":
+		groupSelect = "statistics.channel_id, MAX(channels.name) as channel, statistics.model_name,"
+		joinClause = "LEFT JOIN channels ON statistics.channel_id = channels.id"
+		groupBy = "statistics.channel_id, statistics.model_name"
+		orderBy = "statistics.channel_id, margin DESC"
+	default:
+		groupSelect = "statistics.channel_id, MAX(channels.name) as channel,"
+		joinClause = "LEFT JOIN channels ON statistics.channel_id = channels.id"
+		groupBy = "statistics.channel_id"
+		orderBy = "margin DESC"
+	}
+
+	sql := `
+		SELECT ` + groupSelect + `
+		sum(statistics.request_count) as request_count,
+		sum(statistics.quota) as quota,
+		sum(statistics.cost_quota) as cost_quota,
+		sum(statistics.quota) - sum(statistics.cost_quota) as margin
+		FROM statistics
+		` + joinClause + `
+		%s
+		GROUP BY ` + groupBy + `
+		ORDER BY ` + orderBy
+
+	sql = fmt.Sprintf(sql, whereClause.String())
+	err = DB.Raw(sql, args...).Scan(&statistics).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return statistics, nil
+}
+
 type StatisticsUpdateType int
 
 const (
@@ -240,7 +313,7 @@ const (
 
 func UpdateStatistics(updateType StatisticsUpdateType) error {
 	sql := `
-	%s statistics (date, user_id, channel_id, model_name, request_count, quota, prompt_tokens, completion_tokens, request_time)
+	%s statistics (date, user_id, channel_id, model_name, request_count, quota, cost_quota, prompt_tokens, completion_tokens, request_time)
 	SELECT 
 		%s as date,
 		user_id,
@@ -248,6 +321,7 @@ func UpdateStatistics(updateType StatisticsUpdateType) error {
 		model_name, 
 		count(1) as request_count,
 		sum(quota) as quota,
+		sum(cost_quota) as cost_quota,
 		sum(prompt_tokens) as prompt_tokens,
 		sum(completion_tokens) as completion_tokens,
 		sum(request_time) as request_time
@@ -274,6 +348,7 @@ func UpdateStatistics(updateType StatisticsUpdateType) error {
 		sqlSuffix = `ON CONFLICT (date, user_id, channel_id, model_name) DO UPDATE SET
 		request_count = EXCLUDED.request_count,
 		quota = EXCLUDED.quota,
+		cost_quota = EXCLUDED.cost_quota,
 		prompt_tokens = EXCLUDED.prompt_tokens,
 		completion_tokens = EXCLUDED.completion_tokens,
 		request_time = EXCLUDED.request_time`
@@ -283,6 +358,7 @@ func UpdateStatistics(updateType StatisticsUpdateType) error {
 		sqlSuffix = `ON DUPLICATE KEY UPDATE
 		request_count = VALUES(request_count),
 		quota = VALUES(quota),
+		cost_quota = VALUES(cost_quota),
 		prompt_tokens = VALUES(prompt_tokens),
 		completion_tokens = VALUES(completion_tokens),
 		request_time = VALUES(request_time)`
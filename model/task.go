@@ -10,6 +10,7 @@ import (
 const (
 	TaskPlatformSuno  = "suno"
 	TaskPlatformKling = "kling"
+	TaskPlatformVideo = "video"
 )
 
 type TaskStatus string
@@ -44,6 +45,8 @@ type Task struct {
 	Data       datatypes.JSON `json:"data" gorm:"type:json"`
 	NotifyHook string         `json:"notify_hook"`
 	TokenID    int            `json:"token_id" gorm:"default:0"`
+	// 多密钥渠道提交任务时使用的密钥，上游任务只能用同一个密钥查询
+	ChannelKeyHash string `json:"-" gorm:"type:varchar(64);default:''"`
 }
 
 func GetTaskByTaskIds(platform string, userId int, taskIds []string) (task []*Task, err error) {
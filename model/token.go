@@ -64,9 +64,10 @@ func (token *Token) AfterCreate(tx *gorm.DB) (err error) {
 }
 
 type TokenSetting struct {
-	Heartbeat  HeartbeatSetting `json:"heartbeat,omitempty"`
-	Limits     LimitsConfig     `json:"limits,omitempty"`
-	BillingTag *string          `json:"billing_tag,omitempty"` // 费用标签，用于按分组统计费用，仅可信内部员工和管理员可见
+	Heartbeat     HeartbeatSetting     `json:"heartbeat,omitempty"`
+	Limits        LimitsConfig         `json:"limits,omitempty"`
+	ContextManage ContextManageSetting `json:"context_manage,omitempty"`
+	BillingTag    *string              `json:"billing_tag,omitempty"` // 费用标签，用于按分组统计费用，仅可信内部员工和管理员可见
 }
 
 type HeartbeatSetting struct {
@@ -74,9 +75,17 @@ type HeartbeatSetting struct {
 	TimeoutSeconds int  `json:"timeout_seconds"`
 }
 
+// ContextManageSetting 对话请求超出模型上下文长度时，发送前自动裁剪
+type ContextManageSetting struct {
+	Enabled         bool `json:"enabled"`
+	Summarize       bool `json:"summarize"`         // 使用摘要模型总结较早的对话，未配置摘要模型时只裁剪
+	ToolOutputLimit int  `json:"tool_output_limit"` // 单个工具输出的最大 token 数，0 使用默认值
+}
+
 type LimitsConfig struct {
 	LimitModelSetting LimitModelSetting `json:"limit_model_setting,omitempty"`
 	LimitsIPSetting   LimitsIPSetting   `json:"limits_ip_setting,omitempty"`
+	RequestPolicy     RequestPolicy     `json:"request_policy,omitempty"`
 }
 
 type LimitModelSetting struct {
@@ -229,19 +238,27 @@ func ValidateUserToken(key string) (token *Token, err error) {
 		return nil, err
 	}
 
+	if err := checkTokenAvailable(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// checkTokenAvailable 检查令牌状态、过期时间和剩余额度
+func checkTokenAvailable(token *Token) error {
 	if token.Status != config.TokenStatusEnabled {
 		switch token.Status {
 		case config.TokenStatusExhausted:
-			return nil, ErrTokenQuotaExhausted
+			return ErrTokenQuotaExhausted
 		case config.TokenStatusExpired:
-			return nil, ErrTokenExpired
+			return ErrTokenExpired
 		default:
-			return nil, ErrTokenStatusUnavailable
+			return ErrTokenStatusUnavailable
 		}
 	}
 
 	if token.ExpiredTime != -1 && token.ExpiredTime < utils.GetTimestamp() {
-		return nil, ErrTokenExpired
+		return ErrTokenExpired
 	}
 
 	if !token.UnlimitedQuota {
@@ -254,11 +271,11 @@ func ValidateUserToken(key string) (token *Token, err error) {
 					logger.SysError("failed to update token status" + err.Error())
 				}
 			}
-			return nil, ErrTokenQuotaExhausted
+			return ErrTokenQuotaExhausted
 		}
 	}
 
-	return token, nil
+	return nil
 }
 
 func GetTokenByIds(id int, userId int) (*Token, error) {
@@ -329,6 +346,26 @@ func (token *Token) UpdateByAdmin() error {
 	return err
 }
 
+// DisableUserTokens 禁用用户的所有可用令牌并清除缓存，令牌立即失效
+func DisableUserTokens(userId int) error {
+	var keys []string
+	err := DB.Model(&Token{}).Where("user_id = ? AND status = ?", userId, config.TokenStatusEnabled).Pluck("key", &keys).Error
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err = DB.Model(&Token{}).Where("user_id = ? AND status = ?", userId, config.TokenStatusEnabled).Update("status", config.TokenStatusDisabled).Error
+	if err == nil && config.RedisEnabled {
+		for _, key := range keys {
+			redis.RedisDel(fmt.Sprintf(UserTokensKey, key))
+		}
+	}
+	return err
+}
+
 func (token *Token) SelectUpdate() error {
 	// This can update zero values
 	return DB.Model(token).Select("accessed_time", "status").Updates(token).Error
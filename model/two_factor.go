@@ -0,0 +1,207 @@
+package model
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"one-api/common/config"
+	"one-api/common/totp"
+	"one-api/common/utils"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("未启用两步验证")
+	ErrTwoFactorAlreadyEnabled = errors.New("已启用两步验证")
+	ErrTwoFactorInvalidCode    = errors.New("验证码错误")
+)
+
+// UserTwoFactor 用户的 TOTP 两步验证，设置后需要校验一次验证码才会启用
+type UserTwoFactor struct {
+	Id            int                         `json:"id"`
+	UserId        int                         `json:"user_id" gorm:"uniqueIndex"`
+	Secret        string                      `json:"-" gorm:"type:text;serializer:encrypted"`
+	Enabled       bool                        `json:"enabled" gorm:"default:false"`
+	RecoveryCodes datatypes.JSONSlice[string] `json:"-" gorm:"type:json"` // 恢复码的 SHA256，使用后删除
+	LastUsedStep  int64                       `json:"-" gorm:"bigint;default:0"`
+	CreatedTime   int64                       `json:"created_time" gorm:"bigint"`
+}
+
+func GetUserTwoFactor(userId int) (*UserTwoFactor, error) {
+	var twoFactor UserTwoFactor
+	err := DB.Where("user_id = ?", userId).First(&twoFactor).Error
+	return &twoFactor, err
+}
+
+func IsTwoFactorEnabled(userId int) bool {
+	twoFactor, err := GetUserTwoFactor(userId)
+	return err == nil && twoFactor.Enabled
+}
+
+// SetupTwoFactor 生成新的密钥，启用前可以重复调用
+func SetupTwoFactor(userId int) (string, error) {
+	twoFactor, err := GetUserTwoFactor(userId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if err == nil && twoFactor.Enabled {
+		return "", ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	twoFactor.UserId = userId
+	twoFactor.Secret = secret
+	twoFactor.Enabled = false
+	twoFactor.RecoveryCodes = nil
+	twoFactor.LastUsedStep = 0
+	twoFactor.CreatedTime = utils.GetTimestamp()
+	if err := DB.Save(twoFactor).Error; err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// EnableTwoFactor 校验验证码后启用两步验证，返回只显示一次的恢复码
+func EnableTwoFactor(userId int, code string) ([]string, error) {
+	twoFactor, err := GetUserTwoFactor(userId)
+	if err != nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if twoFactor.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if err := twoFactor.useTOTP(code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = DB.Model(twoFactor).Updates(map[string]any{
+		"enabled":        true,
+		"recovery_codes": hashes,
+	}).Error
+	return codes, err
+}
+
+// VerifyTwoFactor 校验 TOTP 验证码或恢复码，恢复码只能使用一次
+func VerifyTwoFactor(userId int, code string) error {
+	twoFactor, err := GetUserTwoFactor(userId)
+	if err != nil || !twoFactor.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return twoFactor.useTOTP(code)
+	}
+	return twoFactor.useRecoveryCode(code)
+}
+
+func RegenerateRecoveryCodes(userId int) ([]string, error) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	result := DB.Model(&UserTwoFactor{}).Where("user_id = ? AND enabled = ?", userId, true).Update("recovery_codes", hashes)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor 关闭两步验证，之前在两步验证会话中生成的管理令牌不再视为已验证
+func DisableTwoFactor(userId int) error {
+	if err := DB.Where("user_id = ?", userId).Delete(&UserTwoFactor{}).Error; err != nil {
+		return err
+	}
+	return DB.Model(&User{}).Where("id = ?", userId).Update("access_token_2fa", false).Error
+}
+
+// useTOTP 校验验证码，并记录使用的时间步，同一验证码不能重复使用
+func (twoFactor *UserTwoFactor) useTOTP(code string) error {
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return ErrTwoFactorInvalidCode
+	}
+
+	// 并发请求时只有一个能更新成功
+	result := DB.Model(&UserTwoFactor{}).Where("id = ? AND last_used_step < ?", twoFactor.Id, step).Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTwoFactorInvalidCode
+	}
+	twoFactor.LastUsedStep = step
+	return nil
+}
+
+// useRecoveryCode 校验并删除恢复码，锁定记录后再读取恢复码，同一恢复码并发使用时只有一个能成功
+func (twoFactor *UserTwoFactor) useRecoveryCode(code string) error {
+	hash := hashRecoveryCode(code)
+	return DB.Transaction(func(tx *gorm.DB) error {
+		locked := &UserTwoFactor{}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "recovery_codes").Where("id = ?", twoFactor.Id).First(locked).Error
+		if err != nil {
+			return err
+		}
+
+		remaining := make([]string, 0, len(locked.RecoveryCodes))
+		found := false
+		for _, recoveryCode := range locked.RecoveryCodes {
+			if !found && subtle.ConstantTimeCompare([]byte(recoveryCode), []byte(hash)) == 1 {
+				found = true
+				continue
+			}
+			remaining = append(remaining, recoveryCode)
+		}
+		if !found {
+			return ErrTwoFactorInvalidCode
+		}
+
+		twoFactor.RecoveryCodes = remaining
+		return tx.Model(&UserTwoFactor{}).Where("id = ?", twoFactor.Id).Update("recovery_codes", twoFactor.RecoveryCodes).Error
+	})
+}
+
+func newRecoveryCodes() ([]string, datatypes.JSONSlice[string], error) {
+	codes, err := totp.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make(datatypes.JSONSlice[string], 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// TwoFactorIssuer 身份验证器应用中显示的名称
+func TwoFactorIssuer() string {
+	if config.SystemName != "" {
+		return config.SystemName
+	}
+	return "One Hub"
+}
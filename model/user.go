@@ -22,8 +22,9 @@ type User struct {
 	Username         string         `json:"username" gorm:"unique;index" validate:"max=12"`
 	Password         string         `json:"password" gorm:"not null;" validate:"min=8,max=20"`
 	DisplayName      string         `json:"display_name" gorm:"index" validate:"max=20"`
-	Role             int            `json:"role" gorm:"type:int;default:1"`   // admin, common
-	Status           int            `json:"status" gorm:"type:int;default:1"` // enabled, disabled
+	Role             int            `json:"role" gorm:"type:int;default:1"`                 // admin, common
+	CustomRoleId     int            `json:"custom_role_id" gorm:"type:int;default:0;index"` // 自定义角色，只能通过角色接口分配
+	Status           int            `json:"status" gorm:"type:int;default:1"`               // enabled, disabled
 	Email            string         `json:"email" gorm:"index" validate:"max=50"`
 	AvatarUrl        string         `json:"avatar_url" gorm:"type:varchar(500);column:avatar_url;default:''"`
 	OidcId           string         `json:"oidc_id" gorm:"column:oidc_id;index"`
@@ -32,8 +33,10 @@ type User struct {
 	WeChatId         string         `json:"wechat_id" gorm:"column:wechat_id;index"`
 	TelegramId       int64          `json:"telegram_id" gorm:"bigint,column:telegram_id;default:0;"`
 	LarkId           string         `json:"lark_id" gorm:"column:lark_id;index"`
+	LdapId           string         `json:"ldap_id" gorm:"column:ldap_id;index"`
 	VerificationCode string         `json:"verification_code" gorm:"-:all"`                                    // this field is only for Email verification, don't save it to database!
 	AccessToken      string         `json:"access_token" gorm:"type:char(32);column:access_token;uniqueIndex"` // this token is for system management
+	AccessToken2FA   bool           `json:"-" gorm:"column:access_token_2fa;default:false"`                    // 管理令牌是否在通过两步验证的会话中生成
 	Quota            int            `json:"quota" gorm:"type:int;default:0"`
 	UsedQuota        int            `json:"used_quota" gorm:"type:int;default:0;column:used_quota"` // used quota
 	RequestCount     int            `json:"request_count" gorm:"type:int;default:0;"`               // request number
@@ -67,9 +70,58 @@ var allowedUserOrderFields = map[string]bool{
 	"last_login_ip":   true,
 }
 
-func GetUsersList(params *GenericParams) (*DataResult[User], error) {
+// 用户管理的权限范围：
+// 超级管理员可以查看和管理所有用户；
+// 管理员可以查看同级及以下的用户，只能管理比自己等级低的用户；
+// 通过自定义角色获得用户权限的其他用户只能查看和管理没有自定义角色的非管理员用户。
+
+// CanViewUser 检查 role 等级的操作者能否查看用户信息
+func CanViewUser(role int, user *User) bool {
+	switch {
+	case role == config.RoleRootUser:
+		return true
+	case role >= config.RoleAdminUser:
+		return user.Role <= role
+	default:
+		return user.Role < config.RoleAdminUser && user.CustomRoleId == 0
+	}
+}
+
+// CanManageUser 检查 role 等级的操作者能否修改用户信息
+func CanManageUser(role int, user *User) bool {
+	switch {
+	case role == config.RoleRootUser:
+		return true
+	case role >= config.RoleAdminUser:
+		return user.Role < role
+	default:
+		return user.Role < config.RoleAdminUser && user.CustomRoleId == 0
+	}
+}
+
+// CanAssignRole 检查 role 等级的操作者能否将用户设置为 targetRole 等级
+func CanAssignRole(role int, targetRole int) bool {
+	switch {
+	case role == config.RoleRootUser:
+		return true
+	case role >= config.RoleAdminUser:
+		return targetRole < role
+	default:
+		return targetRole < config.RoleAdminUser
+	}
+}
+
+// GetUsersList 查询 role 等级的操作者可以查看的用户列表
+func GetUsersList(params *GenericParams, role int) (*DataResult[User], error) {
 	var users []*User
-	db := DB.Omit("password")
+	db := DB.Omit("password", "access_token")
+	switch {
+	case role == config.RoleRootUser:
+	case role >= config.RoleAdminUser:
+		db = db.Where("role <= ?", role)
+	default:
+		db = db.Where("role < ? AND custom_role_id = 0", config.RoleAdminUser)
+	}
 	if params.Keyword != "" {
 		groupCol := "`group`"
 		if common.UsingPostgreSQL {
@@ -81,6 +133,25 @@ func GetUsersList(params *GenericParams) (*DataResult[User], error) {
 	return PaginateAndOrder[User](db, &params.PaginationParams, &users, allowedUserOrderFields)
 }
 
+// FindUsers 按非零字段精确查询普通用户，返回分页后的用户和总数，管理员不由外部系统管理，不在结果中
+func FindUsers(conditions *User, offset, limit int) ([]*User, int64, error) {
+	var users []*User
+	var total int64
+	db := DB.Model(&User{}).Where(conditions).Where("role < ?", config.RoleAdminUser)
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Omit("password", "access_token").Order("id").Offset(offset).Limit(limit).Find(&users).Error
+	return users, total, err
+}
+
+// GetUsersByGroup 获取分组中的普通用户
+func GetUsersByGroup(group string) ([]*User, error) {
+	var users []*User
+	err := DB.Select("id", "username").Where(&User{Group: group}).Where("role < ?", config.RoleAdminUser).Order("id").Find(&users).Error
+	return users, err
+}
+
 func GetUserById(id int, selectAll bool) (*User, error) {
 	if id == 0 {
 		return nil, errors.New("id 为空！")
@@ -160,7 +231,7 @@ func (user *User) Insert(inviterId int) error {
 
 func (user *User) Update(updatePassword bool) error {
 	var err error
-	omitFields := []string{"quota", "used_quota", "request_count", "aff_count", "aff_quota", "aff_history"}
+	omitFields := []string{"quota", "used_quota", "request_count", "aff_count", "aff_quota", "aff_history", "custom_role_id"}
 
 	if updatePassword {
 		user.Password, err = common.Password2Hash(user.Password)
@@ -185,8 +256,15 @@ func (user *User) Update(updatePassword bool) error {
 	return err
 }
 
+// UpdateUser 修改用户的指定字段并清除用户缓存
 func UpdateUser(id int, fields map[string]interface{}) error {
-	return DB.Model(&User{}).Where("id = ?", id).Updates(fields).Error
+	err := DB.Model(&User{}).Where("id = ?", id).Updates(fields).Error
+	if err == nil && config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UsernameCacheKey, id))
+		redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, id))
+		redis.RedisDel(fmt.Sprintf(UserEnabledCacheKey, id))
+	}
+	return err
 }
 
 func (user *User) Delete() error {
@@ -303,6 +381,17 @@ func (user *User) FillUserByOidcId() error {
 	return nil
 }
 
+func (user *User) FillUserByLdapId() error {
+	if user.LdapId == "" {
+		return errors.New("LDAP ID 为空！")
+	}
+	err := DB.Where(User{LdapId: user.LdapId}).First(user)
+	if err != nil {
+		return err.Error
+	}
+	return nil
+}
+
 func (user *User) FillUserByUsername() error {
 	if user.Username == "" {
 		return errors.New("username 为空！")
@@ -397,6 +486,53 @@ func IsReliable(userId int) bool {
 	return user.Role >= config.RoleReliableUser
 }
 
+// SetUserStatus 修改用户状态并清除状态缓存，禁用时同时注销登录会话并禁用用户的所有令牌
+func SetUserStatus(userId int, status int) error {
+	err := DB.Model(&User{}).Where("id = ?", userId).Update("status", status).Error
+	if err != nil {
+		return err
+	}
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserEnabledCacheKey, userId))
+	}
+
+	if status == config.UserStatusDisabled {
+		if _, err := DeleteUserSessions(userId, ""); err != nil {
+			return err
+		}
+		return DisableUserTokens(userId)
+	}
+	return nil
+}
+
+// RevokeAccessToken 将管理令牌替换为不公开的随机值，原令牌立即失效
+func RevokeAccessToken(userId int) error {
+	return UpdateAccessToken(userId, utils.GetUUID(), false)
+}
+
+// UpdateAccessToken 设置管理令牌，twoFactor 表示令牌是否在通过两步验证的会话中生成
+// 强制管理员两步验证时，只有这样生成的令牌可以访问管理接口
+func UpdateAccessToken(userId int, token string, twoFactor bool) error {
+	return DB.Model(&User{}).Where("id = ?", userId).Updates(map[string]any{
+		"access_token":     token,
+		"access_token_2fa": twoFactor,
+	}).Error
+}
+
+// SetUsersGroup 修改普通用户的分组并清除分组缓存，管理员的分组不会被修改
+func SetUsersGroup(userIds []int, group string) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+	err := DB.Model(&User{}).Where("id IN ? AND role < ?", userIds, config.RoleAdminUser).Update("group", group).Error
+	if err == nil && config.RedisEnabled {
+		for _, userId := range userIds {
+			redis.RedisDel(fmt.Sprintf(UserGroupCacheKey, userId))
+		}
+	}
+	return err
+}
+
 func IsUserEnabled(userId int) (bool, error) {
 	if userId == 0 {
 		return false, errors.New("user id is empty")
@@ -7,6 +7,8 @@ import (
 	"one-api/common/logger"
 	"one-api/common/redis"
 	"sync"
+
+	"gorm.io/datatypes"
 )
 
 type UserGroup struct {
@@ -20,6 +22,8 @@ type UserGroup struct {
 	Min       int     `json:"min" form:"min" gorm:"default:0"`                 // 晋级条件最小值
 	Max       int     `json:"max" form:"max" gorm:"default:0"`                 // 晋级条件最大值
 	Enable    *bool   `json:"enable" form:"enable" gorm:"default:true"`        // 是否启用
+
+	Policy *datatypes.JSONType[RequestPolicy] `json:"policy,omitempty" gorm:"type:json"` // 分组内所有令牌的请求策略
 }
 
 type SearchUserGroupParams struct {
@@ -54,6 +58,27 @@ func GetUserGroupsById(id int) (*UserGroup, error) {
 	return &userGroup, err
 }
 
+func GetUserGroupBySymbol(symbol string) (*UserGroup, error) {
+	var userGroup UserGroup
+	err := DB.Where("symbol = ?", symbol).First(&userGroup).Error
+	return &userGroup, err
+}
+
+// FindUserGroups 按标识查询用户分组，标识为空时返回所有分组
+func FindUserGroups(symbol string, offset, limit int) ([]*UserGroup, int64, error) {
+	var userGroups []*UserGroup
+	var total int64
+	db := DB.Model(&UserGroup{})
+	if symbol != "" {
+		db = db.Where("symbol = ?", symbol)
+	}
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := db.Order("id").Offset(offset).Limit(limit).Find(&userGroups).Error
+	return userGroups, total, err
+}
+
 func GetUserGroupsAll(isPublic bool) ([]*UserGroup, error) {
 	var userGroups []*UserGroup
 
@@ -75,7 +100,7 @@ func (c *UserGroup) Create() error {
 }
 
 func (c *UserGroup) Update() error {
-	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max").Updates(c).Error
+	err := DB.Select("name", "ratio", "public", "api_rate", "promotion", "min", "max", "policy").Updates(c).Error
 	if err == nil {
 		GlobalUserGroupRatio.Load()
 	}
@@ -207,7 +232,7 @@ func CheckAndUpgradeUserGroup(userId int, rechargeAmount int) error {
 
 	// Calculate cumulative recharge amount
 	cumulativeAmount := user.Quota + user.UsedQuota + rechargeAmount
-	logger.SysError(fmt.Sprintf("use:%f q:%f  cumulative:%f rechargeAmount:%f", (float64)(user.UsedQuota)/config.QuotaPerUnit, (float64)(user.Quota)/config.QuotaPerUnit, cumulativeAmount, rechargeAmount))
+	logger.SysError(fmt.Sprintf("use:%f q:%f  cumulative:%d rechargeAmount:%d", (float64)(user.UsedQuota)/config.QuotaPerUnit, (float64)(user.Quota)/config.QuotaPerUnit, cumulativeAmount, rechargeAmount))
 	// Get all promotion-enabled user groups
 	var promotionGroups []*UserGroup
 	err = DB.Where("promotion = ? AND enable = ?", true, true).Find(&promotionGroups).Error
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"one-api/common/cache"
+	"one-api/common/config"
+	"one-api/common/redis"
+	"one-api/common/utils"
+	"time"
+)
+
+const (
+	UserSessionMaxAge = 30 * 24 * 60 * 60 // 与会话 Cookie 的有效期一致
+	// 最近访问时间的更新间隔，避免每个请求都写数据库
+	userSessionTouchInterval = 60
+)
+
+var ErrUserSessionInvalid = errors.New("登录已失效，请重新登录")
+
+// UserSession 登录会话，Cookie 中只保存 SessionId，删除记录即可让会话立即失效
+type UserSession struct {
+	Id           int    `json:"id"`
+	SessionId    string `json:"-" gorm:"type:varchar(64);uniqueIndex"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Ip           string `json:"ip" gorm:"type:varchar(128);default:''"`
+	UserAgent    string `json:"user_agent" gorm:"type:varchar(512);default:''"`
+	TwoFactor    bool   `json:"two_factor" gorm:"default:false"` // 是否通过两步验证或通行密钥登录
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+	LastSeenTime int64  `json:"last_seen_time" gorm:"bigint;index"`
+}
+
+func CreateUserSession(userId int, ip, userAgent string, twoFactor bool) (*UserSession, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+
+	now := utils.GetTimestamp()
+	session := &UserSession{
+		SessionId:    hex.EncodeToString(raw),
+		UserId:       userId,
+		Ip:           ip,
+		UserAgent:    userAgent,
+		TwoFactor:    twoFactor,
+		CreatedTime:  now,
+		LastSeenTime: now,
+	}
+	err := DB.Create(session).Error
+	return session, err
+}
+
+func GetUserSession(sessionId string) (*UserSession, error) {
+	if sessionId == "" {
+		return nil, ErrUserSessionInvalid
+	}
+	var session UserSession
+	err := DB.Where("session_id = ? AND last_seen_time > ?", sessionId, utils.GetTimestamp()-UserSessionMaxAge).First(&session).Error
+	if err != nil {
+		return nil, ErrUserSessionInvalid
+	}
+	return &session, nil
+}
+
+func CacheGetUserSession(sessionId string) (*UserSession, error) {
+	if !config.RedisEnabled {
+		return GetUserSession(sessionId)
+	}
+
+	return cache.GetOrSetCache(
+		fmt.Sprintf(UserSessionCacheKey, sessionId),
+		time.Duration(TokenCacheSeconds)*time.Second,
+		func() (*UserSession, error) {
+			return GetUserSession(sessionId)
+		},
+		cache.CacheTimeout)
+}
+
+// Touch 更新最近访问时间和 IP
+func (session *UserSession) Touch(ip string) {
+	now := utils.GetTimestamp()
+	if now-session.LastSeenTime < userSessionTouchInterval && session.Ip == ip {
+		return
+	}
+	session.LastSeenTime = now
+	session.Ip = ip
+	DB.Model(&UserSession{}).Where("id = ?", session.Id).Updates(map[string]any{
+		"last_seen_time": now,
+		"ip":             ip,
+	})
+	clearUserSessionCache(session.SessionId)
+}
+
+func (session *UserSession) MarkTwoFactor() error {
+	session.TwoFactor = true
+	err := DB.Model(&UserSession{}).Where("id = ?", session.Id).Update("two_factor", true).Error
+	clearUserSessionCache(session.SessionId)
+	return err
+}
+
+// GetUserSessions 获取用户的有效会话，同时清理已过期的会话
+func GetUserSessions(userId int) ([]*UserSession, error) {
+	expired := utils.GetTimestamp() - UserSessionMaxAge
+	DB.Where("user_id = ? AND last_seen_time <= ?", userId, expired).Delete(&UserSession{})
+
+	var sessions []*UserSession
+	err := DB.Where("user_id = ?", userId).Order("last_seen_time desc").Find(&sessions).Error
+	return sessions, err
+}
+
+func DeleteUserSession(userId int, id int) error {
+	var session UserSession
+	if err := DB.Where("id = ? AND user_id = ?", id, userId).First(&session).Error; err != nil {
+		return errors.New("会话不存在")
+	}
+	if err := DB.Delete(&session).Error; err != nil {
+		return err
+	}
+	clearUserSessionCache(session.SessionId)
+	return nil
+}
+
+func DeleteUserSessionBySessionId(sessionId string) error {
+	if sessionId == "" {
+		return nil
+	}
+	err := DB.Where("session_id = ?", sessionId).Delete(&UserSession{}).Error
+	clearUserSessionCache(sessionId)
+	return err
+}
+
+// DeleteUserSessions 删除用户的所有会话，exceptSessionId 不为空时保留当前会话
+func DeleteUserSessions(userId int, exceptSessionId string) (int64, error) {
+	var sessionIds []string
+	db := DB.Model(&UserSession{}).Where("user_id = ?", userId)
+	if exceptSessionId != "" {
+		db = db.Where("session_id <> ?", exceptSessionId)
+	}
+	if err := db.Pluck("session_id", &sessionIds).Error; err != nil {
+		return 0, err
+	}
+	if len(sessionIds) == 0 {
+		return 0, nil
+	}
+
+	result := DB.Where("session_id IN ?", sessionIds).Delete(&UserSession{})
+	for _, sessionId := range sessionIds {
+		clearUserSessionCache(sessionId)
+	}
+	return result.RowsAffected, result.Error
+}
+
+func clearUserSessionCache(sessionId string) {
+	if config.RedisEnabled {
+		redis.RedisDel(fmt.Sprintf(UserSessionCacheKey, sessionId))
+	}
+}
@@ -0,0 +1,218 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"one-api/common/utils"
+	"strings"
+	"sync"
+
+	"gorm.io/datatypes"
+)
+
+// 虚拟模型切换到下一个模型的条件
+const (
+	VirtualModelFallbackError         = "error"          // 上游 5xx、429、超时或无可用渠道
+	VirtualModelFallbackContextLength = "context_length" // 超出上下文长度
+	VirtualModelFallbackContentFilter = "content_filter" // 被内容审核拒绝
+)
+
+var virtualModelFallbackConditions = map[string]bool{
+	VirtualModelFallbackError:         true,
+	VirtualModelFallbackContextLength: true,
+	VirtualModelFallbackContentFilter: true,
+}
+
+// VirtualModel 虚拟模型，按顺序尝试多个实际模型，按实际使用的模型计费
+type VirtualModel struct {
+	Id          int                         `json:"id"`
+	Name        string                      `json:"name" gorm:"type:varchar(100);uniqueIndex"`
+	Description string                      `json:"description" gorm:"type:varchar(255);default:''"`
+	Models      datatypes.JSONSlice[string] `json:"models" gorm:"type:json"`
+	FallbackOn  datatypes.JSONSlice[string] `json:"fallback_on" gorm:"type:json"`
+	Enabled     bool                        `json:"enabled" gorm:"default:true"`
+	CreatedTime int64                       `json:"created_time" gorm:"bigint"`
+}
+
+type SearchVirtualModelParams struct {
+	Name string `form:"name"`
+	PaginationParams
+}
+
+var allowedVirtualModelOrderFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"created_time": true,
+}
+
+func GetVirtualModelsList(params *SearchVirtualModelParams) (*DataResult[VirtualModel], error) {
+	var virtualModels []*VirtualModel
+	db := DB.Model(&VirtualModel{})
+	if params.Name != "" {
+		db = db.Where("name LIKE ?", params.Name+"%")
+	}
+
+	return PaginateAndOrder(db, &params.PaginationParams, &virtualModels, allowedVirtualModelOrderFields)
+}
+
+func GetVirtualModelById(id int) (*VirtualModel, error) {
+	var virtualModel VirtualModel
+	err := DB.Where("id = ?", id).First(&virtualModel).Error
+	return &virtualModel, err
+}
+
+func (v *VirtualModel) validate() error {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		return errors.New("虚拟模型名称不能为空")
+	}
+	if strings.Contains(v.Name, "#") {
+		return errors.New("虚拟模型名称不能包含 #")
+	}
+	if len(v.Models) == 0 {
+		return errors.New("至少需要一个实际模型")
+	}
+	if GlobalVirtualModels.isTarget(v.Name, v.Id) {
+		return fmt.Errorf("%s 已被其他虚拟模型用作实际模型", v.Name)
+	}
+
+	seen := make(map[string]bool, len(v.Models))
+	for i, modelName := range v.Models {
+		modelName = strings.TrimSpace(modelName)
+		if modelName == "" {
+			return errors.New("实际模型名称不能为空")
+		}
+		if modelName == v.Name || seen[modelName] {
+			return fmt.Errorf("实际模型 %s 重复", modelName)
+		}
+		// 不允许嵌套，避免出现循环
+		if existing := GlobalVirtualModels.Get(modelName); existing != nil && existing.Id != v.Id {
+			return fmt.Errorf("%s 是虚拟模型，不能作为实际模型", modelName)
+		}
+		seen[modelName] = true
+		v.Models[i] = modelName
+	}
+
+	if len(v.FallbackOn) == 0 {
+		v.FallbackOn = datatypes.JSONSlice[string]{VirtualModelFallbackError}
+	}
+	for _, condition := range v.FallbackOn {
+		if !virtualModelFallbackConditions[condition] {
+			return fmt.Errorf("未知的切换条件: %s", condition)
+		}
+	}
+	return nil
+}
+
+func (v *VirtualModel) Insert() error {
+	if err := v.validate(); err != nil {
+		return err
+	}
+	v.CreatedTime = utils.GetTimestamp()
+	err := DB.Create(v).Error
+	if err == nil {
+		GlobalVirtualModels.Load()
+	}
+	return err
+}
+
+func (v *VirtualModel) Update() error {
+	if err := v.validate(); err != nil {
+		return err
+	}
+	err := DB.Model(v).Select("name", "description", "models", "fallback_on", "enabled").Updates(v).Error
+	if err == nil {
+		GlobalVirtualModels.Load()
+	}
+	return err
+}
+
+func (v *VirtualModel) Delete() error {
+	err := DB.Delete(v).Error
+	if err == nil {
+		GlobalVirtualModels.Load()
+	}
+	return err
+}
+
+// ShouldFallback 是否在出现该类型的错误时切换到下一个模型
+func (v *VirtualModel) ShouldFallback(condition string) bool {
+	for _, fallbackOn := range v.FallbackOn {
+		if fallbackOn == condition {
+			return true
+		}
+	}
+	return false
+}
+
+type VirtualModelList struct {
+	sync.RWMutex
+	VirtualModels map[string]*VirtualModel
+}
+
+var GlobalVirtualModels = VirtualModelList{}
+
+func (vml *VirtualModelList) Load() {
+	var virtualModels []*VirtualModel
+	if err := DB.Where("enabled = ?", true).Find(&virtualModels).Error; err != nil {
+		return
+	}
+
+	newVirtualModels := make(map[string]*VirtualModel, len(virtualModels))
+	for _, virtualModel := range virtualModels {
+		newVirtualModels[virtualModel.Name] = virtualModel
+	}
+
+	vml.Lock()
+	defer vml.Unlock()
+
+	vml.VirtualModels = newVirtualModels
+}
+
+// Get 获取已启用的虚拟模型，不存在时返回 nil
+func (vml *VirtualModelList) Get(name string) *VirtualModel {
+	vml.RLock()
+	defer vml.RUnlock()
+
+	return vml.VirtualModels[name]
+}
+
+// GetAvailable 获取至少有一个实际模型可用的虚拟模型名称
+func (vml *VirtualModelList) GetAvailable(models []string) []string {
+	available := make(map[string]bool, len(models))
+	for _, modelName := range models {
+		available[modelName] = true
+	}
+
+	vml.RLock()
+	defer vml.RUnlock()
+
+	names := make([]string, 0)
+	for name, virtualModel := range vml.VirtualModels {
+		for _, modelName := range virtualModel.Models {
+			if available[modelName] {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// isTarget 模型是否被其他虚拟模型用作实际模型
+func (vml *VirtualModelList) isTarget(name string, excludeId int) bool {
+	vml.RLock()
+	defer vml.RUnlock()
+
+	for _, virtualModel := range vml.VirtualModels {
+		if virtualModel.Id == excludeId {
+			continue
+		}
+		for _, modelName := range virtualModel.Models {
+			if modelName == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"errors"
+	"one-api/common/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WebhookDeliveryStatusPending = "pending"
+	WebhookDeliveryStatusSuccess = "success"
+	WebhookDeliveryStatusFailed  = "failed"
+)
+
+// WebhookDelivery 异步任务回调投递记录
+type WebhookDelivery struct {
+	Id            int    `json:"id"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Source        string `json:"source" gorm:"type:varchar(30);index"` // suno, kling, midjourney
+	TaskId        string `json:"task_id" gorm:"type:varchar(64);index"`
+	Event         string `json:"event" gorm:"type:varchar(64)"`
+	Url           string `json:"url" gorm:"type:varchar(1024)"`
+	Payload       string `json:"payload" gorm:"type:text"`
+	Status        string `json:"status" gorm:"type:varchar(20);index"`
+	Attempts      int    `json:"attempts" gorm:"default:0"`
+	NextRetryTime int64  `json:"next_retry_time" gorm:"bigint;index"`
+	ResponseCode  int    `json:"response_code" gorm:"default:0"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	CreatedTime   int64  `json:"created_time" gorm:"bigint;index"`
+	UpdatedTime   int64  `json:"updated_time" gorm:"bigint"`
+}
+
+type WebhookDeliveryQueryParams struct {
+	Source string `form:"source"`
+	TaskId string `form:"task_id"`
+	Status string `form:"status"`
+	PaginationParams
+}
+
+var allowedWebhookDeliveryOrderFields = map[string]bool{
+	"id":           true,
+	"source":       true,
+	"task_id":      true,
+	"status":       true,
+	"attempts":     true,
+	"created_time": true,
+}
+
+func (delivery *WebhookDelivery) Insert() error {
+	nowTime := utils.GetTimestamp()
+	delivery.CreatedTime = nowTime
+	delivery.UpdatedTime = nowTime
+	return DB.Create(delivery).Error
+}
+
+// Lease 抢占一次投递，防止多个协程/节点重复投递
+func (delivery *WebhookDelivery) Lease(leaseSeconds int64) (bool, error) {
+	nowTime := utils.GetTimestamp()
+	result := DB.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_retry_time = ?", delivery.Id, WebhookDeliveryStatusPending, delivery.NextRetryTime).
+		Update("next_retry_time", nowTime+leaseSeconds)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	delivery.NextRetryTime = nowTime + leaseSeconds
+	return result.RowsAffected > 0, nil
+}
+
+func (delivery *WebhookDelivery) SaveResult() error {
+	delivery.UpdatedTime = utils.GetTimestamp()
+	return DB.Model(delivery).
+		Select("status", "attempts", "next_retry_time", "response_code", "last_error", "updated_time").
+		Updates(delivery).Error
+}
+
+// GetPendingWebhookDeliveries 获取到达重试时间的投递
+func GetPendingWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := DB.Where("status = ? AND next_retry_time <= ?", WebhookDeliveryStatusPending, utils.GetTimestamp()).
+		Order("next_retry_time asc").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func GetUserWebhookDelivery(userId, id int) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	err := DB.Where("id = ? AND user_id = ?", id, userId).First(delivery).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("投递记录不存在")
+	}
+	return delivery, err
+}
+
+func GetUserWebhookDeliveries(userId int, params *WebhookDeliveryQueryParams) (*DataResult[WebhookDelivery], error) {
+	var deliveries []*WebhookDelivery
+	query := DB.Omit("payload").Where("user_id = ?", userId)
+
+	if params.Source != "" {
+		query = query.Where("source = ?", params.Source)
+	}
+	if params.TaskId != "" {
+		query = query.Where("task_id = ?", params.TaskId)
+	}
+	if params.Status != "" {
+		query = query.Where("status = ?", params.Status)
+	}
+
+	return PaginateAndOrder(query, &params.PaginationParams, &deliveries, allowedWebhookDeliveryOrderFields)
+}
@@ -0,0 +1,149 @@
+package asyncvideo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common/requester"
+	"one-api/model"
+	"one-api/providers/base"
+	"one-api/types"
+	"strings"
+)
+
+// 定义供应商工厂
+type AsyncVideoProviderFactory struct{}
+
+// 创建 AsyncVideoProvider
+func (f AsyncVideoProviderFactory) Create(channel *model.Channel) base.ProviderInterface {
+	proxyAddr := ""
+	if channel.Proxy != nil {
+		proxyAddr = *channel.Proxy
+	}
+
+	return &AsyncVideoProvider{
+		BaseProvider: base.BaseProvider{
+			Config:    base.ProviderConfig{},
+			Channel:   channel,
+			Requester: requester.NewHTTPRequester(proxyAddr, RequestErrorHandle),
+		},
+		Setting: getSetting(channel),
+	}
+}
+
+// Setting 通用异步视频接口的配置，字段路径使用 gjson 语法
+type Setting struct {
+	SubmitPath     string
+	FetchPath      string
+	IdPath         string
+	StatusPath     string
+	ProgressPath   string
+	VideoURLPath   string
+	ErrorPath      string
+	StatusMap      map[string]string
+	DefaultSeconds int
+}
+
+func getSetting(channel *model.Channel) *Setting {
+	setting := &Setting{
+		SubmitPath:     "/v1/videos",
+		FetchPath:      "/v1/videos/{id}",
+		IdPath:         "id",
+		StatusPath:     "status",
+		ProgressPath:   "progress",
+		VideoURLPath:   "video_url",
+		ErrorPath:      "error.message",
+		StatusMap:      map[string]string{},
+		DefaultSeconds: 5,
+	}
+
+	if channel.Plugin == nil {
+		return setting
+	}
+	params, ok := channel.Plugin.Data()["async_video"]
+	if !ok {
+		return setting
+	}
+
+	fields := map[string]*string{
+		"submit_path":    &setting.SubmitPath,
+		"fetch_path":     &setting.FetchPath,
+		"id_path":        &setting.IdPath,
+		"status_path":    &setting.StatusPath,
+		"progress_path":  &setting.ProgressPath,
+		"video_url_path": &setting.VideoURLPath,
+		"error_path":     &setting.ErrorPath,
+	}
+	for key, field := range fields {
+		if value, ok := params[key].(string); ok && value != "" {
+			*field = value
+		}
+	}
+
+	if statusMap, ok := params["status_map"].(string); ok {
+		for _, item := range strings.Split(statusMap, ",") {
+			upstream, status, found := strings.Cut(item, ":")
+			if !found {
+				continue
+			}
+			setting.StatusMap[strings.ToLower(strings.TrimSpace(upstream))] = strings.TrimSpace(status)
+		}
+	}
+
+	if defaultSeconds, ok := params["default_seconds"].(string); ok {
+		var seconds int
+		if _, err := fmt.Sscanf(defaultSeconds, "%d", &seconds); err == nil && seconds > 0 {
+			setting.DefaultSeconds = seconds
+		}
+	}
+
+	return setting
+}
+
+type AsyncVideoProvider struct {
+	base.BaseProvider
+	Setting *Setting
+}
+
+func (p *AsyncVideoProvider) GetRequestHeaders() (headers map[string]string) {
+	headers = make(map[string]string)
+	p.CommonRequestHeaders(headers)
+	// 提交请求统一转换为 JSON
+	headers["Content-Type"] = "application/json"
+	if p.Channel.Key != "" {
+		headers["Authorization"] = "Bearer " + p.Channel.Key
+	}
+
+	return headers
+}
+
+// 请求错误处理
+func RequestErrorHandle(resp *http.Response) *types.OpenAIError {
+	errorResponse := map[string]any{}
+	err := json.NewDecoder(resp.Body).Decode(&errorResponse)
+	if err != nil {
+		return nil
+	}
+
+	message := ""
+	for _, key := range []string{"error", "message", "detail"} {
+		switch value := errorResponse[key].(type) {
+		case string:
+			message = value
+		case map[string]any:
+			message, _ = value["message"].(string)
+		}
+		if message != "" {
+			break
+		}
+	}
+	if message == "" {
+		return nil
+	}
+
+	return &types.OpenAIError{
+		Code:    "async_video_error",
+		Message: message,
+		Type:    "async_video_error",
+	}
+}
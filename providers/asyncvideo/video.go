@@ -0,0 +1,182 @@
+package asyncvideo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/types"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func (p *AsyncVideoProvider) CreateVideo(request *types.VideoRequest) (*types.VideoTask, *types.OpenAIErrorWithStatusCode) {
+	body, err := p.getSubmitBody(request)
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "invalid_request", http.StatusBadRequest)
+	}
+
+	fullRequestURL := p.GetFullRequestURL(p.Setting.SubmitPath, request.Model)
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(p.GetRequestHeaders()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	response, errWithCode := p.send(req)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	videoId := gjson.GetBytes(response, p.Setting.IdPath).String()
+	if videoId == "" {
+		return nil, common.StringErrorWrapper("task id not found in response: "+string(response), "invalid_response", http.StatusInternalServerError)
+	}
+
+	return &types.VideoTask{
+		Id:        videoId,
+		Object:    "video",
+		Model:     request.Model,
+		Status:    types.VideoStatusQueued,
+		CreatedAt: time.Now().Unix(),
+		Seconds:   string(request.Seconds),
+		Size:      request.Size,
+	}, nil
+}
+
+func (p *AsyncVideoProvider) GetVideo(videoId string) (*types.VideoTask, *types.OpenAIErrorWithStatusCode) {
+	fetchPath := strings.ReplaceAll(p.Setting.FetchPath, "{id}", url.PathEscape(videoId))
+	req, err := p.Requester.NewRequest(http.MethodGet, p.GetFullRequestURL(fetchPath, ""), p.Requester.WithHeader(p.GetRequestHeaders()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	response, errWithCode := p.send(req)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	video := &types.VideoTask{
+		Id:       videoId,
+		Object:   "video",
+		Status:   p.Setting.mapStatus(gjson.GetBytes(response, p.Setting.StatusPath).String()),
+		Progress: parseProgress(gjson.GetBytes(response, p.Setting.ProgressPath)),
+		VideoURL: gjson.GetBytes(response, p.Setting.VideoURLPath).String(),
+	}
+
+	switch video.Status {
+	case types.VideoStatusCompleted:
+		video.Progress = 100
+		video.CompletedAt = time.Now().Unix()
+		if video.VideoURL == "" {
+			video.Status = types.VideoStatusFailed
+			video.Error = &types.VideoTaskError{Code: "video_url_not_found", Message: "video url not found in response"}
+		}
+	case types.VideoStatusFailed:
+		video.Progress = 100
+		video.CompletedAt = time.Now().Unix()
+		message := gjson.GetBytes(response, p.Setting.ErrorPath).String()
+		if message == "" {
+			message = "video generation failed"
+		}
+		video.Error = &types.VideoTaskError{Code: "video_generation_failed", Message: message}
+	}
+
+	return video, nil
+}
+
+// GetVideoContent 通用接口只返回视频地址，由调用方直接下载
+func (p *AsyncVideoProvider) GetVideoContent(videoId string) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	return nil, common.StringErrorWrapperLocal("The API interface is not supported", "unsupported_api", http.StatusNotImplemented)
+}
+
+func (p *AsyncVideoProvider) GetVideoDefaultSeconds(modelName string) int {
+	return p.Setting.DefaultSeconds
+}
+
+// 优先透传用户的原始请求体，以便使用上游特有的参数
+func (p *AsyncVideoProvider) getSubmitBody(request *types.VideoRequest) (map[string]any, error) {
+	body := make(map[string]any)
+	isJSON := strings.HasPrefix(p.Context.Request.Header.Get("Content-Type"), "application/json")
+	if rawBody, ok := p.GetRawBody(); ok && isJSON {
+		if err := json.Unmarshal(rawBody, &body); err != nil {
+			return nil, err
+		}
+	} else {
+		body["prompt"] = request.Prompt
+		if request.Seconds != "" {
+			body["seconds"] = string(request.Seconds)
+		}
+		if request.Size != "" {
+			body["size"] = request.Size
+		}
+	}
+
+	if request.InputReference != nil {
+		file, err := request.InputReference.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		body["input_reference"] = fmt.Sprintf("data:%s;base64,%s", http.DetectContentType(data), base64.StdEncoding.EncodeToString(data))
+	}
+
+	body["model"] = request.Model
+	delete(body, "notify_hook")
+
+	return body, nil
+}
+
+func (p *AsyncVideoProvider) send(req *http.Request) ([]byte, *types.OpenAIErrorWithStatusCode) {
+	resp, errWithCode := p.Requester.SendRequestRaw(req)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "read_response_body_failed", http.StatusInternalServerError)
+	}
+	if !gjson.ValidBytes(body) {
+		return nil, common.StringErrorWrapper("invalid json response: "+string(body), "invalid_response", http.StatusInternalServerError)
+	}
+
+	return body, nil
+}
+
+func (s *Setting) mapStatus(status string) string {
+	status = strings.ToLower(status)
+	if mapped, ok := s.StatusMap[status]; ok {
+		return mapped
+	}
+
+	switch status {
+	case "completed", "succeeded", "success", "done", "finished":
+		return types.VideoStatusCompleted
+	case "failed", "failure", "error", "cancelled", "canceled":
+		return types.VideoStatusFailed
+	case "queued", "pending", "submitted", "throttled", "":
+		return types.VideoStatusQueued
+	default:
+		return types.VideoStatusInProgress
+	}
+}
+
+// 进度兼容 0-1 和 0-100 两种格式
+func parseProgress(result gjson.Result) int {
+	progress := result.Float()
+	if progress > 0 && progress < 1 {
+		progress *= 100
+	}
+	return int(progress)
+}
@@ -1,6 +1,7 @@
 package base
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -31,6 +32,7 @@ type ProviderConfig struct {
 	Rerank              string
 	ChatRealtime        string
 	Responses           string
+	Videos              string
 }
 
 func (pc *ProviderConfig) SetAPIUri(customMapping map[string]interface{}) {
@@ -46,6 +48,7 @@ func (pc *ProviderConfig) SetAPIUri(customMapping map[string]interface{}) {
 		config.RelayModeImagesEdits:        &pc.ImagesEdit,
 		config.RelayModeImagesVariations:   &pc.ImagesVariations,
 		config.RelayModeResponses:          &pc.Responses,
+		config.RelayModeVideos:             &pc.Videos,
 	}
 
 	for key, value := range customMapping {
@@ -128,6 +131,10 @@ func (p *BaseProvider) SetUsage(usage *types.Usage) {
 
 func (p *BaseProvider) SetContext(c *gin.Context) {
 	p.Context = c
+	// 继承请求的链路信息，但不随客户端断开而取消上游请求
+	if c != nil && c.Request != nil && p.Requester != nil {
+		p.Requester.Context = context.WithoutCancel(c.Request.Context())
+	}
 }
 
 func (p *BaseProvider) SetOriginalModel(ModelName string) {
@@ -208,6 +215,8 @@ func (p *BaseProvider) GetAPIUri(relayMode int) string {
 		return p.Config.ChatRealtime
 	case config.RelayModeResponses:
 		return p.Config.Responses
+	case config.RelayModeVideos:
+		return p.Config.Videos
 	default:
 		return ""
 	}
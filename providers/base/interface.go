@@ -135,6 +135,16 @@ type RealtimeInterface interface {
 	CreateChatRealtime(modelName string) (*websocket.Conn, requester.MessageHandler, *types.OpenAIErrorWithStatusCode)
 }
 
+// 视频生成接口
+type VideoInterface interface {
+	ProviderInterface
+	CreateVideo(request *types.VideoRequest) (*types.VideoTask, *types.OpenAIErrorWithStatusCode)
+	GetVideo(videoId string) (*types.VideoTask, *types.OpenAIErrorWithStatusCode)
+	GetVideoContent(videoId string) (*http.Response, *types.OpenAIErrorWithStatusCode)
+	// 未指定时长时的默认秒数，用于按秒计费
+	GetVideoDefaultSeconds(modelName string) int
+}
+
 type ResponsesInterface interface {
 	ProviderInterface
 	CreateResponses(request *types.OpenAIResponsesRequest) (*types.OpenAIResponsesResponses, *types.OpenAIErrorWithStatusCode)
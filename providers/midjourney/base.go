@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/requester"
 	"one-api/model"
@@ -64,9 +63,8 @@ func (p *MidjourneyProvider) Send(timeout int, requestURL string) (*MidjourneyRe
 			"modes": {strings.ToUpper(mjModel)},
 		}
 
-		if !config.MjNotifyEnabled {
-			delete(mapResult, "notifyHook")
-		}
+		// 回调由网关统一签名投递，不透传给上游
+		delete(mapResult, "notifyHook")
 	}
 
 	if prompt, ok := mapResult["prompt"].(string); ok {
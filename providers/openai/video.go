@@ -0,0 +1,121 @@
+package openai
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/requester"
+	"one-api/types"
+)
+
+func (p *OpenAIProvider) CreateVideo(request *types.VideoRequest) (*types.VideoTask, *types.OpenAIErrorWithStatusCode) {
+	url, errWithCode := p.GetSupportedAPIUri(config.RelayModeVideos)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	fullRequestURL := p.GetFullRequestURL(url, request.Model)
+	headers := p.GetRequestHeaders()
+
+	var req *http.Request
+	var err error
+	if request.InputReference != nil {
+		var formBody bytes.Buffer
+		builder := p.Requester.CreateFormBuilder(&formBody)
+		if err := videoMultipartForm(request, builder); err != nil {
+			return nil, common.ErrorWrapper(err, "create_form_builder_failed", http.StatusInternalServerError)
+		}
+		req, err = p.Requester.NewRequest(
+			http.MethodPost,
+			fullRequestURL,
+			p.Requester.WithBody(&formBody),
+			p.Requester.WithHeader(headers),
+			p.Requester.WithContentType(builder.FormDataContentType()))
+		if err == nil {
+			req.ContentLength = int64(formBody.Len())
+		}
+	} else {
+		req, err = p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(request), p.Requester.WithHeader(headers))
+	}
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+	defer req.Body.Close()
+
+	response := &types.VideoTask{}
+	_, errWithCode = p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
+
+func (p *OpenAIProvider) GetVideo(videoId string) (*types.VideoTask, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getVideoRequest(videoId, "")
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	response := &types.VideoTask{}
+	_, errWithCode = p.Requester.SendRequest(req, response, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return response, nil
+}
+
+func (p *OpenAIProvider) GetVideoContent(videoId string) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	req, errWithCode := p.getVideoRequest(videoId, "/content")
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return p.Requester.SendRequestRaw(req)
+}
+
+func (p *OpenAIProvider) GetVideoDefaultSeconds(modelName string) int {
+	// Sora 默认生成 4 秒视频
+	return 4
+}
+
+func (p *OpenAIProvider) getVideoRequest(videoId, suffix string) (*http.Request, *types.OpenAIErrorWithStatusCode) {
+	url, errWithCode := p.GetSupportedAPIUri(config.RelayModeVideos)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+	fullRequestURL := p.GetFullRequestURL(fmt.Sprintf("%s/%s%s", url, videoId, suffix), "")
+
+	req, err := p.Requester.NewRequest(http.MethodGet, fullRequestURL, p.Requester.WithHeader(p.GetRequestHeaders()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return req, nil
+}
+
+func videoMultipartForm(request *types.VideoRequest, b requester.FormBuilder) (err error) {
+	err = b.CreateFormFile("input_reference", request.InputReference)
+	if err != nil {
+		return fmt.Errorf("creating form input_reference: %w", err)
+	}
+
+	fields := map[string]string{
+		"model":   request.Model,
+		"prompt":  request.Prompt,
+		"seconds": string(request.Seconds),
+		"size":    request.Size,
+	}
+	for key, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err = b.WriteField(key, value); err != nil {
+			return fmt.Errorf("writing %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"one-api/common/config"
 	"one-api/model"
 	"one-api/providers/ali"
+	"one-api/providers/asyncvideo"
 	"one-api/providers/azure"
 	azurespeech "one-api/providers/azureSpeech"
 	"one-api/providers/azure_v1"
@@ -93,6 +94,7 @@ func init() {
 		config.ChannelTypeAzureDatabricks: azuredatabricks.AzureDatabricksProviderFactory{},
 		config.ChannelTypeAzureV1:         azure_v1.AzureV1ProviderFactory{},
 		config.ChannelTypeXAI:             xAI.XAIProviderFactory{},
+		config.ChannelTypeAsyncVideo:      asyncvideo.AsyncVideoProviderFactory{},
 	}
 }
 
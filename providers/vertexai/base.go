@@ -62,6 +62,7 @@ func getConfig() base.ProviderConfig {
 		BaseURL:           "https://%saiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
 		ChatCompletions:   "/",
 		ImagesGenerations: "/predict",
+		Videos:            "/predictLongRunning",
 	}
 }
 
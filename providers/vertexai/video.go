@@ -0,0 +1,275 @@
+package vertexai
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"one-api/common"
+	"one-api/types"
+	"strings"
+	"time"
+)
+
+type VertexAIVideoRequest struct {
+	Instances  []VertexAIVideoInstance `json:"instances"`
+	Parameters VertexAIVideoParameters `json:"parameters"`
+}
+
+type VertexAIVideoInstance struct {
+	Prompt string              `json:"prompt"`
+	Image  *VertexAIVideoMedia `json:"image,omitempty"`
+}
+
+type VertexAIVideoParameters struct {
+	SampleCount     int    `json:"sampleCount"`
+	DurationSeconds int    `json:"durationSeconds,omitempty"`
+	AspectRatio     string `json:"aspectRatio,omitempty"`
+	Resolution      string `json:"resolution,omitempty"`
+}
+
+type VertexAIVideoMedia struct {
+	BytesBase64Encoded string `json:"bytesBase64Encoded,omitempty"`
+	GcsUri             string `json:"gcsUri,omitempty"`
+	MimeType           string `json:"mimeType,omitempty"`
+}
+
+type VertexAIOperation struct {
+	Name     string                  `json:"name"`
+	Done     bool                    `json:"done"`
+	Response *VertexAIVideoResponse  `json:"response,omitempty"`
+	Error    *VertexAIOperationError `json:"error,omitempty"`
+}
+
+type VertexAIVideoResponse struct {
+	RaiMediaFilteredCount   int                  `json:"raiMediaFilteredCount"`
+	RaiMediaFilteredReasons []string             `json:"raiMediaFilteredReasons,omitempty"`
+	Videos                  []VertexAIVideoMedia `json:"videos"`
+}
+
+type VertexAIOperationError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *VertexAIProvider) CreateVideo(request *types.VideoRequest) (*types.VideoTask, *types.OpenAIErrorWithStatusCode) {
+	instance := VertexAIVideoInstance{
+		Prompt: request.Prompt,
+	}
+	if request.InputReference != nil {
+		image, err := readVideoReference(request)
+		if err != nil {
+			return nil, common.ErrorWrapper(err, "read_input_reference_failed", http.StatusBadRequest)
+		}
+		instance.Image = image
+	}
+
+	vertexRequest := &VertexAIVideoRequest{
+		Instances: []VertexAIVideoInstance{instance},
+		Parameters: VertexAIVideoParameters{
+			SampleCount:     1,
+			DurationSeconds: request.Seconds.Int(),
+		},
+	}
+	vertexRequest.Parameters.AspectRatio, vertexRequest.Parameters.Resolution = sizeToVideoParameters(request.Size)
+
+	fullRequestURL := p.GetFullRequestURL(request.Model, "predictLongRunning")
+	if fullRequestURL == "" {
+		return nil, common.ErrorWrapper(nil, "invalid_vertex_ai_config", http.StatusInternalServerError)
+	}
+
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(vertexRequest), p.Requester.WithHeader(p.GetRequestHeaders()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	operation := &VertexAIOperation{}
+	_, errWithCode := p.Requester.SendRequest(req, operation, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	if operation.Name == "" {
+		return nil, common.StringErrorWrapper("operation name is empty", "invalid_operation", http.StatusInternalServerError)
+	}
+
+	return &types.VideoTask{
+		Id:        operation.Name,
+		Object:    "video",
+		Model:     request.Model,
+		Status:    types.VideoStatusQueued,
+		CreatedAt: time.Now().Unix(),
+		Seconds:   string(request.Seconds),
+		Size:      request.Size,
+	}, nil
+}
+
+// GetVideo 查询长时间运行操作的状态，videoId 为操作名称
+func (p *VertexAIProvider) GetVideo(videoId string) (*types.VideoTask, *types.OpenAIErrorWithStatusCode) {
+	operation, errWithCode := p.fetchVideoOperation(videoId)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	video := &types.VideoTask{
+		Id:     videoId,
+		Object: "video",
+		Model:  getOperationModel(videoId),
+		Status: types.VideoStatusInProgress,
+	}
+
+	if !operation.Done {
+		return video, nil
+	}
+
+	video.Progress = 100
+	video.CompletedAt = time.Now().Unix()
+	if operation.Error != nil {
+		video.Status = types.VideoStatusFailed
+		video.Error = &types.VideoTaskError{
+			Code:    fmt.Sprintf("%d", operation.Error.Code),
+			Message: operation.Error.Message,
+		}
+		return video, nil
+	}
+
+	if operation.Response == nil || len(operation.Response.Videos) == 0 {
+		video.Status = types.VideoStatusFailed
+		message := "no video generated"
+		if operation.Response != nil && len(operation.Response.RaiMediaFilteredReasons) > 0 {
+			message = strings.Join(operation.Response.RaiMediaFilteredReasons, "; ")
+		}
+		video.Error = &types.VideoTaskError{
+			Code:    "no_video_generated",
+			Message: message,
+		}
+		return video, nil
+	}
+
+	video.Status = types.VideoStatusCompleted
+	result := operation.Response.Videos[0]
+	if result.BytesBase64Encoded != "" {
+		data, err := base64.StdEncoding.DecodeString(result.BytesBase64Encoded)
+		if err != nil {
+			return nil, common.ErrorWrapper(err, "decode_video_failed", http.StatusInternalServerError)
+		}
+		video.VideoData = data
+		video.VideoMimeType = result.MimeType
+	}
+
+	return video, nil
+}
+
+// GetVideoContent 获取视频内容，base64 结果直接返回，GCS 结果通过存储接口下载
+func (p *VertexAIProvider) GetVideoContent(videoId string) (*http.Response, *types.OpenAIErrorWithStatusCode) {
+	operation, errWithCode := p.fetchVideoOperation(videoId)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	if !operation.Done || operation.Response == nil || len(operation.Response.Videos) == 0 {
+		return nil, common.StringErrorWrapperLocal("video is not ready", "video_not_ready", http.StatusNotFound)
+	}
+
+	result := operation.Response.Videos[0]
+	if result.BytesBase64Encoded != "" {
+		data, err := base64.StdEncoding.DecodeString(result.BytesBase64Encoded)
+		if err != nil {
+			return nil, common.ErrorWrapper(err, "decode_video_failed", http.StatusInternalServerError)
+		}
+		header := http.Header{}
+		header.Set("Content-Type", result.MimeType)
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(data)),
+			ContentLength: int64(len(data)),
+		}, nil
+	}
+
+	bucket, object, ok := strings.Cut(strings.TrimPrefix(result.GcsUri, "gs://"), "/")
+	if !ok {
+		return nil, common.StringErrorWrapper("invalid gcs uri", "invalid_gcs_uri", http.StatusInternalServerError)
+	}
+	fullRequestURL := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media", bucket, url.PathEscape(object))
+	req, err := p.Requester.NewRequest(http.MethodGet, fullRequestURL, p.Requester.WithHeader(p.GetRequestHeaders()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	return p.Requester.SendRequestRaw(req)
+}
+
+func (p *VertexAIProvider) GetVideoDefaultSeconds(modelName string) int {
+	// Veo 默认生成 8 秒视频
+	return 8
+}
+
+func (p *VertexAIProvider) fetchVideoOperation(operationName string) (*VertexAIOperation, *types.OpenAIErrorWithStatusCode) {
+	fullRequestURL := p.GetFullRequestURL(getOperationModel(operationName), "fetchPredictOperation")
+	body := map[string]string{"operationName": operationName}
+
+	req, err := p.Requester.NewRequest(http.MethodPost, fullRequestURL, p.Requester.WithBody(body), p.Requester.WithHeader(p.GetRequestHeaders()))
+	if err != nil {
+		return nil, common.ErrorWrapper(err, "new_request_failed", http.StatusInternalServerError)
+	}
+
+	operation := &VertexAIOperation{}
+	_, errWithCode := p.Requester.SendRequest(req, operation, false)
+	if errWithCode != nil {
+		return nil, errWithCode
+	}
+
+	return operation, nil
+}
+
+// 从操作名称中获取模型，格式为 projects/.../models/{model}/operations/{id}
+func getOperationModel(operationName string) string {
+	_, after, ok := strings.Cut(operationName, "/models/")
+	if !ok {
+		return ""
+	}
+	modelName, _, _ := strings.Cut(after, "/")
+	return modelName
+}
+
+func readVideoReference(request *types.VideoRequest) (*VertexAIVideoMedia, error) {
+	file, err := request.InputReference.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := request.InputReference.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	return &VertexAIVideoMedia{
+		BytesBase64Encoded: base64.StdEncoding.EncodeToString(data),
+		MimeType:           mimeType,
+	}, nil
+}
+
+// 将尺寸转换为视频比例和分辨率
+func sizeToVideoParameters(size string) (aspectRatio, resolution string) {
+	switch size {
+	case "1280x720":
+		return "16:9", "720p"
+	case "720x1280":
+		return "9:16", "720p"
+	case "1920x1080":
+		return "16:9", "1080p"
+	case "1080x1920":
+		return "9:16", "1080p"
+	default:
+		return "16:9", ""
+	}
+}
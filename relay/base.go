@@ -17,6 +17,7 @@ type relayBase struct {
 	c              *gin.Context
 	provider       providersBase.ProviderInterface
 	originalModel  string
+	requestModel   string // 虚拟模型解析后实际请求的模型，普通模型与 originalModel 相同
 	modelName      string
 	otherArg       string
 	allowHeartbeat bool
@@ -52,12 +53,13 @@ func (r *relayBase) IsStream() bool {
 	return false
 }
 
-func (r *relayBase) setProvider(modelName string) error {
-	provider, modelName, fail := GetProvider(r.c, modelName)
+func (r *relayBase) setProvider(requestModel string) error {
+	provider, modelName, fail := GetProvider(r.c, requestModel)
 	if fail != nil {
 		return fail
 	}
 	r.provider = provider
+	r.requestModel = requestModel
 	r.modelName = modelName
 
 	r.provider.SetOtherArg(r.otherArg)
@@ -95,6 +97,9 @@ func (r *relayBase) getModelName() string {
 	billingOriginalModel := r.c.GetBool("billing_original_model")
 
 	if billingOriginalModel {
+		if r.requestModel != "" {
+			return r.requestModel
+		}
 		return r.originalModel
 	}
 	return r.modelName
@@ -21,6 +21,10 @@ import (
 type relayChat struct {
 	relayBase
 	chatRequest types.ChatCompletionRequest
+
+	contextOriginal *types.ChatCompletionRequest // 上下文管理前的原始请求
+	contextSummary  *contextSummary
+	billError       bool // 返回错误时上游已产生用量，仍需计费
 }
 
 func NewRelayChat(c *gin.Context) *relayChat {
@@ -117,6 +121,10 @@ func (r *relayChat) send() (err *types.OpenAIErrorWithStatusCode, done bool) {
 		}
 	}
 
+	if structured := newStructuredOutput(r); structured != nil {
+		return structured.send(chatProvider)
+	}
+
 	if r.chatRequest.Stream {
 		var response requester.StreamReaderInterface[string]
 		response, err = chatProvider.CreateChatCompletionStream(&r.chatRequest)
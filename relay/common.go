@@ -8,9 +8,11 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/capture"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/requester"
+	"one-api/common/tracing"
 	"one-api/common/utils"
 	"one-api/controller"
 	"one-api/metrics"
@@ -59,6 +61,11 @@ func Path2Relay(c *gin.Context, path string) RelayBaseInterface {
 }
 
 func checkLimitModel(c *gin.Context, modelName string) (error error) {
+	// 虚拟模型只检查虚拟模型名称，允许使用其包含的实际模型
+	if virtualModel := c.GetString("virtual_model"); virtualModel != "" {
+		modelName = virtualModel
+	}
+
 	// 判断modelName是否在token的setting.limits.LimitModelSetting.models[]范围内
 
 	// 从context中获取token设置
@@ -107,6 +114,12 @@ func GetProvider(c *gin.Context, modelName string) (provider providersBase.Provi
 			return nil, "", err
 		}
 	}
+
+	return getProviderByModel(c, modelName)
+}
+
+// getProviderByModel 选择渠道并创建 provider，不检查令牌的模型限制
+func getProviderByModel(c *gin.Context, modelName string) (provider providersBase.ProviderInterface, newModelName string, fail error) {
 	channel, fail := fetchChannel(c, modelName)
 	if fail != nil {
 		return
@@ -114,6 +127,17 @@ func GetProvider(c *gin.Context, modelName string) (provider providersBase.Provi
 	c.Set("channel_id", channel.Id)
 	c.Set("channel_type", channel.Type)
 
+	// 查询或延续异步任务时使用提交任务的密钥
+	channel, fail = model.PickChannelKeyByHash(channel, c.GetString("specific_channel_key_hash"))
+	if fail != nil {
+		return
+	}
+	if channel.IsMultiKey() {
+		c.Set("channel_key_hash", model.HashChannelKey(channel.Key))
+	} else {
+		c.Set("channel_key_hash", "")
+	}
+
 	provider = providers.GetProvider(channel, c)
 	if provider == nil {
 		fail = errors.New("channel not found")
@@ -261,7 +285,7 @@ func fetchChannelByModel(c *gin.Context, modelName string) (*model.Channel, erro
 	// 使用统一的分组管理器
 	groupManager := NewGroupManager(c)
 	return groupManager.TryWithGroups(modelName, filters, func(group string) (*model.Channel, error) {
-		return model.ChannelGroup.Next(group, modelName, filters...)
+		return model.ChannelGroup.Next(c.Request.Context(), group, modelName, filters...)
 	})
 
 }
@@ -289,6 +313,9 @@ type StreamEndHandler func() string
 func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string], endHandler StreamEndHandler) (firstResponseTime time.Time, errWithOP *types.OpenAIErrorWithStatusCode) {
 	requester.SetEventStreamHeaders(c)
 	dataChan, errChan := stream.Recv()
+	traceCtx := c.Request.Context()
+	defer metrics.TrackStream()()
+	recorder := capture.FromContext(traceCtx)
 
 	// 创建一个done channel用于通知处理完成
 	done := make(chan struct{})
@@ -309,10 +336,14 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 					return
 				}
 				streamData := "data: " + data + "\n\n"
+				if recorder != nil {
+					recorder.AppendStream(data)
+				}
 
 				if !isFirstResponse {
 					firstResponseTime = time.Now()
 					isFirstResponse = true
+					tracing.AddEvent(traceCtx, "stream.first_byte")
 				}
 
 				// 尝试写入数据，如果客户端断开也继续处理
@@ -379,6 +410,9 @@ func responseStreamClient(c *gin.Context, stream requester.StreamReaderInterface
 func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderInterface[string], endHandler StreamEndHandler) (firstResponseTime time.Time) {
 	requester.SetEventStreamHeaders(c)
 	dataChan, errChan := stream.Recv()
+	traceCtx := c.Request.Context()
+	defer metrics.TrackStream()()
+	recorder := capture.FromContext(traceCtx)
 
 	// 创建一个done channel用于通知处理完成
 	done := make(chan struct{})
@@ -397,9 +431,13 @@ func responseGeneralStreamClient(c *gin.Context, stream requester.StreamReaderIn
 				if !ok {
 					return
 				}
+				if recorder != nil {
+					recorder.AppendStream(data)
+				}
 				if !isFirstResponse {
 					firstResponseTime = time.Now()
 					isFirstResponse = true
+					tracing.AddEvent(traceCtx, "stream.first_byte")
 				}
 				// 尝试写入数据，如果客户端断开也继续处理
 				select {
@@ -544,10 +582,10 @@ func shouldRetryBadRequest(channelType int, apiErr *types.OpenAIErrorWithStatusC
 	}
 }
 
-func processChannelRelayError(ctx context.Context, channelId int, channelName string, err *types.OpenAIErrorWithStatusCode, channelType int) {
-	logger.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channelId, channelName, err.Message))
-	if controller.ShouldDisableChannel(channelType, err) {
-		controller.DisableChannel(channelId, channelName, err.Message, true)
+func processChannelRelayError(ctx context.Context, channel *model.Channel, err *types.OpenAIErrorWithStatusCode) {
+	logger.LogError(ctx, fmt.Sprintf("relay error (channel #%d(%s)): %s", channel.Id, channel.Name, err.Message))
+	if controller.ShouldDisableChannel(channel.Type, err) {
+		controller.DisableChannelOrKey(channel, err.Message, true)
 	}
 }
 
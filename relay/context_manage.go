@@ -0,0 +1,433 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/logger"
+	"one-api/model"
+	providersBase "one-api/providers/base"
+	"one-api/relay/relay_util"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	contextManageHeader = "X-Context-Manage"
+
+	defaultToolOutputLimit = 1024
+	// 摘要的最大长度，裁剪历史时为摘要预留空间
+	contextSummaryMaxTokens = 1024
+	// 发送给摘要模型的历史对话最大 token 数
+	contextSummaryInputLimit = 16000
+
+	contextSummaryPrompt = "Summarize the following earlier part of a conversation between a user and an assistant. " +
+		"Keep the key facts, decisions, user preferences, open tasks and any names or identifiers needed to continue the conversation. " +
+		"Reply with the summary only."
+)
+
+// contextManager 发送前根据模型的上下文长度调整请求
+type contextManager interface {
+	manageContext()
+}
+
+// contextManageResult 记录对请求所做的调整，通过响应头和日志告知用户
+type contextManageResult struct {
+	TrimmedMessages      int
+	TruncatedToolOutputs int
+	SummarizedMessages   int
+	MaxTokens            int
+}
+
+func (r *contextManageResult) changed() bool {
+	return r.TrimmedMessages > 0 || r.TruncatedToolOutputs > 0 || r.SummarizedMessages > 0 || r.MaxTokens > 0
+}
+
+func (r *contextManageResult) String() string {
+	parts := make([]string, 0, 4)
+	if r.TrimmedMessages > 0 {
+		parts = append(parts, fmt.Sprintf("trimmed_messages=%d", r.TrimmedMessages))
+	}
+	if r.TruncatedToolOutputs > 0 {
+		parts = append(parts, fmt.Sprintf("truncated_tool_outputs=%d", r.TruncatedToolOutputs))
+	}
+	if r.SummarizedMessages > 0 {
+		parts = append(parts, fmt.Sprintf("summarized_messages=%d", r.SummarizedMessages))
+	}
+	if r.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("max_tokens=%d", r.MaxTokens))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// contextSummary 缓存摘要结果，重试时不必重复请求摘要模型
+type contextSummary struct {
+	count   int
+	content string
+}
+
+// chatContext 对话请求的上下文裁剪，messages 是原始消息的副本
+type chatContext struct {
+	c               *gin.Context
+	modelName       string
+	messages        []types.ChatCompletionMessage
+	tokens          []int
+	total           int
+	limit           int
+	toolOutputLimit int
+	summary         *contextSummary
+	result          contextManageResult
+}
+
+// getContextModelInfo 获取配置了上下文长度的模型信息，依次按名称查找
+func getContextModelInfo(names ...string) *model.ModelInfo {
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if modelInfo, err := model.CacheGetModelInfoByModel(name); err == nil && modelInfo.ContextLength > 0 {
+			return modelInfo
+		}
+	}
+	return nil
+}
+
+// getContextManageSetting 令牌开启或模型开启时生效，模型开启时使用默认设置
+func getContextManageSetting(c *gin.Context, modelInfo *model.ModelInfo) (model.ContextManageSetting, bool) {
+	setting := model.ContextManageSetting{}
+	if value, exists := c.Get("token_setting"); exists {
+		if tokenSetting, ok := value.(*model.TokenSetting); ok && tokenSetting != nil {
+			setting = tokenSetting.ContextManage
+		}
+	}
+	if !setting.Enabled && !modelInfo.ContextManage {
+		return setting, false
+	}
+	if setting.ToolOutputLimit <= 0 {
+		setting.ToolOutputLimit = defaultToolOutputLimit
+	}
+	return setting, true
+}
+
+func (r *relayChat) manageContext() {
+	if r.contextOriginal == nil {
+		original := r.chatRequest
+		r.contextOriginal = &original
+	}
+	// 每次重试都从原始请求开始，切换模型后上下文长度可能不同
+	r.chatRequest.Messages = r.contextOriginal.Messages
+	r.chatRequest.MaxTokens = r.contextOriginal.MaxTokens
+	r.chatRequest.MaxCompletionTokens = r.contextOriginal.MaxCompletionTokens
+	r.c.Writer.Header().Del(contextManageHeader)
+	r.c.Set("context_manage", "")
+
+	modelInfo := getContextModelInfo(r.requestModel, r.modelName)
+	if modelInfo == nil {
+		return
+	}
+	setting, enabled := getContextManageSetting(r.c, modelInfo)
+	if !enabled {
+		return
+	}
+
+	contextLength := modelInfo.ContextLength
+	requested := r.chatRequest.MaxCompletionTokens
+	if requested == 0 {
+		requested = r.chatRequest.MaxTokens
+	}
+	// 为输出预留空间，最多占上下文的四分之一
+	reserve := requested
+	if reserve == 0 {
+		reserve = modelInfo.MaxTokens
+	}
+	if reserve > contextLength/4 {
+		reserve = contextLength / 4
+	}
+	// token 数是估算值，保留一定余量
+	margin := contextLength / 50
+	overhead := 0
+	if len(r.chatRequest.Tools) > 0 {
+		if tools, err := json.Marshal(r.chatRequest.Tools); err == nil {
+			overhead = common.CountTokenText(string(tools), r.modelName)
+		}
+	}
+
+	ctx := newChatContext(r.c, r.modelName, r.chatRequest.Messages)
+	ctx.limit = contextLength - reserve - margin - overhead
+	ctx.toolOutputLimit = setting.ToolOutputLimit
+	if r.contextSummary == nil {
+		r.contextSummary = &contextSummary{}
+	}
+	ctx.summary = r.contextSummary
+
+	if ctx.total > ctx.limit {
+		ctx.truncateToolOutputs()
+	}
+	if ctx.total > ctx.limit && setting.Summarize && config.ContextSummaryModel != "" {
+		if err := ctx.summarizeHistory(); err != nil {
+			logger.LogError(r.c.Request.Context(), "context summary failed: "+err.Error())
+		}
+	}
+	if ctx.total > ctx.limit {
+		ctx.trimHistory(ctx.limit)
+	}
+
+	// 输出长度不能超过剩余的上下文
+	remaining := contextLength - ctx.total - overhead - margin
+	if remaining > 0 {
+		if r.chatRequest.MaxCompletionTokens > remaining {
+			r.chatRequest.MaxCompletionTokens = remaining
+			ctx.result.MaxTokens = remaining
+		}
+		if r.chatRequest.MaxTokens > remaining {
+			r.chatRequest.MaxTokens = remaining
+			ctx.result.MaxTokens = remaining
+		}
+	}
+
+	if !ctx.result.changed() {
+		return
+	}
+	r.chatRequest.Messages = ctx.messages
+	annotation := ctx.result.String()
+	r.c.Writer.Header().Set(contextManageHeader, annotation)
+	r.c.Set("context_manage", annotation)
+	logger.LogInfo(r.c.Request.Context(), fmt.Sprintf("context managed for model %s: %s", r.modelName, annotation))
+}
+
+func newChatContext(c *gin.Context, modelName string, messages []types.ChatCompletionMessage) *chatContext {
+	ctx := &chatContext{
+		c:         c,
+		modelName: modelName,
+		messages:  append([]types.ChatCompletionMessage(nil), messages...),
+		tokens:    make([]int, len(messages)),
+	}
+	for i, message := range ctx.messages {
+		ctx.tokens[i] = countMessageTokens(message, modelName)
+		ctx.total += ctx.tokens[i]
+	}
+	return ctx
+}
+
+func countMessageTokens(message types.ChatCompletionMessage, modelName string) int {
+	// 图片需要下载后才能计算，这里只计算文本
+	tokens := common.CountTokenMessages([]types.ChatCompletionMessage{message}, modelName, config.PreCostNotImage)
+	for _, toolCall := range message.ToolCalls {
+		if toolCall.Function != nil {
+			tokens += common.CountTokenText(toolCall.Function.Name+toolCall.Function.Arguments, modelName)
+		}
+	}
+	return tokens
+}
+
+// historyEnd 最后一条用户消息及之后的内容属于当前轮次，不会被裁剪
+func (ctx *chatContext) historyEnd() int {
+	for i := len(ctx.messages) - 1; i >= 0; i-- {
+		if ctx.messages[i].Role == types.ChatMessageRoleUser {
+			return i
+		}
+	}
+	return len(ctx.messages) - 1
+}
+
+// truncateToolOutputs 从最早的工具输出开始截断，最后一条消息除外
+func (ctx *chatContext) truncateToolOutputs() {
+	for i := 0; i < len(ctx.messages)-1 && ctx.total > ctx.limit; i++ {
+		message := ctx.messages[i]
+		if message.Role != types.ChatMessageRoleTool && message.Role != types.ChatMessageRoleFunction {
+			continue
+		}
+		if ctx.tokens[i] <= ctx.toolOutputLimit {
+			continue
+		}
+
+		content := message.StringContent()
+		truncated := truncateText(content, ctx.toolOutputLimit, ctx.modelName)
+		message.Content = fmt.Sprintf("%s\n...[truncated %d characters]", truncated, len([]rune(content))-len([]rune(truncated)))
+		ctx.replace(i, message)
+		ctx.result.TruncatedToolOutputs++
+	}
+}
+
+// cutPoint 计算需要移除的历史消息，使剩余消息不超过 limit
+func (ctx *chatContext) cutPoint(limit int) []bool {
+	end := ctx.historyEnd()
+	removed := make([]bool, len(ctx.messages))
+	total := ctx.total
+	for i := 0; i < end; i++ {
+		message := ctx.messages[i]
+		if message.IsSystemRole() {
+			continue
+		}
+		// 达到限制后，继续移除开头的助手和工具消息，保证历史以用户消息开始，工具结果不会脱离对应的调用
+		if total <= limit && message.Role == types.ChatMessageRoleUser {
+			break
+		}
+		removed[i] = true
+		total -= ctx.tokens[i]
+	}
+	return removed
+}
+
+// trimHistory 从最早的对话开始移除，直到不超过 limit
+func (ctx *chatContext) trimHistory(limit int) {
+	removed := ctx.cutPoint(limit)
+	ctx.result.TrimmedMessages += ctx.remove(removed, nil)
+}
+
+// summarizeHistory 使用摘要模型总结将被移除的历史对话，替换为一条系统消息
+func (ctx *chatContext) summarizeHistory() error {
+	removed := ctx.cutPoint(ctx.limit - contextSummaryMaxTokens)
+	count := 0
+	for _, ok := range removed {
+		if ok {
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	content := ctx.summary.content
+	if ctx.summary.count != count || content == "" {
+		history := make([]types.ChatCompletionMessage, 0, count)
+		for i, ok := range removed {
+			if ok {
+				history = append(history, ctx.messages[i])
+			}
+		}
+		var err error
+		content, err = requestContextSummary(ctx.c, history)
+		if err != nil {
+			return err
+		}
+		ctx.summary.count = count
+		ctx.summary.content = content
+	}
+
+	summary := types.ChatCompletionMessage{
+		Role:    types.ChatMessageRoleSystem,
+		Content: "Summary of the earlier conversation:\n" + content,
+	}
+	ctx.result.SummarizedMessages += ctx.remove(removed, &summary)
+	return nil
+}
+
+// remove 移除标记的消息，insert 不为空时插入到第一条被移除的消息的位置
+func (ctx *chatContext) remove(removed []bool, insert *types.ChatCompletionMessage) int {
+	messages := make([]types.ChatCompletionMessage, 0, len(ctx.messages))
+	tokens := make([]int, 0, len(ctx.messages))
+	count := 0
+	for i, message := range ctx.messages {
+		if !removed[i] {
+			messages = append(messages, message)
+			tokens = append(tokens, ctx.tokens[i])
+			continue
+		}
+		if count == 0 && insert != nil {
+			messages = append(messages, *insert)
+			tokens = append(tokens, countMessageTokens(*insert, ctx.modelName))
+		}
+		count++
+	}
+
+	ctx.messages = messages
+	ctx.tokens = tokens
+	ctx.total = 0
+	for _, token := range tokens {
+		ctx.total += token
+	}
+	return count
+}
+
+func (ctx *chatContext) replace(i int, message types.ChatCompletionMessage) {
+	tokens := countMessageTokens(message, ctx.modelName)
+	ctx.total += tokens - ctx.tokens[i]
+	ctx.tokens[i] = tokens
+	ctx.messages[i] = message
+}
+
+// truncateText 截断文本，使其不超过 maxTokens
+func truncateText(text string, maxTokens int, modelName string) string {
+	runes := []rune(text)
+	tokens := common.CountTokenText(text, modelName)
+	for tokens > maxTokens && len(runes) > 0 {
+		keep := len(runes) * maxTokens / tokens
+		if keep >= len(runes) {
+			keep = len(runes) - 1
+		}
+		runes = runes[:keep]
+		tokens = common.CountTokenText(string(runes), modelName)
+	}
+	return string(runes)
+}
+
+// requestContextSummary 请求摘要模型，按摘要模型的价格计费
+func requestContextSummary(c *gin.Context, history []types.ChatCompletionMessage) (string, error) {
+	// 选择渠道会修改上下文中的渠道信息，完成后恢复，不影响主请求
+	keys := []string{"channel_id", "channel_type", "channel_key_hash", "original_model", "new_model", "billing_original_model"}
+	saved := make(map[string]any, len(keys))
+	for _, key := range keys {
+		if value, exists := c.Get(key); exists {
+			saved[key] = value
+		}
+	}
+	defer func() {
+		for _, key := range keys {
+			c.Set(key, saved[key])
+		}
+	}()
+
+	provider, modelName, err := getProviderByModel(c, config.ContextSummaryModel)
+	if err != nil {
+		return "", err
+	}
+	chatProvider, ok := provider.(providersBase.ChatInterface)
+	if !ok {
+		return "", errors.New("summary channel does not support chat")
+	}
+
+	var transcript strings.Builder
+	for _, message := range history {
+		transcript.WriteString(message.Role + ": " + message.StringContent() + "\n")
+		for _, toolCall := range message.ToolCalls {
+			if toolCall.Function != nil {
+				transcript.WriteString(fmt.Sprintf("%s called %s(%s)\n", message.Role, toolCall.Function.Name, toolCall.Function.Arguments))
+			}
+		}
+	}
+
+	request := &types.ChatCompletionRequest{
+		Model: modelName,
+		Messages: []types.ChatCompletionMessage{
+			{Role: types.ChatMessageRoleSystem, Content: contextSummaryPrompt},
+			{Role: types.ChatMessageRoleUser, Content: truncateText(transcript.String(), contextSummaryInputLimit, modelName)},
+		},
+		MaxTokens: contextSummaryMaxTokens,
+	}
+
+	promptTokens := common.CountTokenMessages(request.Messages, modelName, config.PreCostNotImage)
+	usage := &types.Usage{PromptTokens: promptTokens}
+	provider.SetUsage(usage)
+
+	quota := relay_util.NewQuota(c, modelName, promptTokens)
+	if apiErr := quota.PreQuotaConsumption(); apiErr != nil {
+		return "", apiErr
+	}
+	response, apiErr := chatProvider.CreateChatCompletion(request)
+	if apiErr != nil {
+		quota.Undo(c)
+		return "", apiErr
+	}
+	quota.Consume(c, usage, false)
+
+	content := strings.TrimSpace(response.GetContent())
+	if content == "" {
+		return "", errors.New("empty summary")
+	}
+	return content, nil
+}
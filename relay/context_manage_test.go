@@ -0,0 +1,148 @@
+package relay
+
+import (
+	"one-api/types"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMessage struct {
+	role   string
+	tokens int
+}
+
+// newTestChatContext 使用给定的 token 数构造上下文，role 为 "call:<id>" 表示带工具调用的助手消息，"tool:<id>" 表示工具结果
+func newTestChatContext(messages []testMessage) *chatContext {
+	ctx := &chatContext{modelName: "gpt-4o"}
+	for _, m := range messages {
+		message := types.ChatCompletionMessage{Role: m.role, Content: m.role}
+		if id, ok := strings.CutPrefix(m.role, "call:"); ok {
+			message.Role = types.ChatMessageRoleAssistant
+			message.ToolCalls = []*types.ChatCompletionToolCalls{{Id: id, Type: "function", Function: &types.ChatCompletionToolCallsFunction{Name: "search"}}}
+		} else if id, ok := strings.CutPrefix(m.role, "tool:"); ok {
+			message.Role = types.ChatMessageRoleTool
+			message.ToolCallID = id
+		}
+		ctx.messages = append(ctx.messages, message)
+		ctx.tokens = append(ctx.tokens, m.tokens)
+		ctx.total += m.tokens
+	}
+	return ctx
+}
+
+func TestContextCutPoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []testMessage
+		limit    int
+		removed  []int
+	}{
+		{
+			name:     "within limit",
+			messages: []testMessage{{"system", 10}, {"user", 10}, {"assistant", 10}, {"user", 10}},
+			limit:    100,
+		},
+		{
+			name:     "oldest round",
+			messages: []testMessage{{"system", 10}, {"user", 10}, {"assistant", 10}, {"user", 10}, {"assistant", 10}, {"user", 10}},
+			limit:    40,
+			removed:  []int{1, 2},
+		},
+		{
+			name:     "tool results follow their call",
+			messages: []testMessage{{"system", 10}, {"user", 10}, {"call:a", 10}, {"tool:a", 10}, {"assistant", 10}, {"user", 10}, {"assistant", 10}, {"user", 10}},
+			limit:    65,
+			removed:  []int{1, 2, 3, 4},
+		},
+		{
+			name:     "large tool result",
+			messages: []testMessage{{"user", 10}, {"call:a", 10}, {"call:b", 10}, {"tool:b", 500}, {"assistant", 10}, {"user", 10}},
+			limit:    100,
+			removed:  []int{0, 1, 2, 3, 4},
+		},
+		{
+			name:     "system messages kept",
+			messages: []testMessage{{"system", 10}, {"user", 10}, {"system", 10}, {"assistant", 10}, {"user", 10}},
+			limit:    30,
+			removed:  []int{1, 3},
+		},
+		{
+			name:     "current round kept",
+			messages: []testMessage{{"user", 10}, {"assistant", 10}, {"user", 10}, {"call:a", 10}, {"tool:a", 500}},
+			limit:    100,
+			removed:  []int{0, 1},
+		},
+		{
+			name:     "only current round",
+			messages: []testMessage{{"system", 10}, {"user", 100}, {"call:a", 10}, {"tool:a", 100}},
+			limit:    50,
+		},
+	}
+
+	for _, test := range tests {
+		ctx := newTestChatContext(test.messages)
+		removed := ctx.cutPoint(test.limit)
+
+		want := make([]bool, len(test.messages))
+		for _, i := range test.removed {
+			want[i] = true
+		}
+		assert.Equal(t, want, removed, test.name)
+	}
+}
+
+func TestContextTrimHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []testMessage
+		limit    int
+		want     []string
+		total    int
+	}{
+		{
+			name:     "tool round removed together",
+			messages: []testMessage{{"system", 10}, {"user", 10}, {"call:a", 10}, {"tool:a", 300}, {"assistant", 10}, {"user", 10}, {"assistant", 10}, {"user", 10}},
+			limit:    100,
+			want:     []string{"system", "user", "assistant", "user"},
+			total:    40,
+		},
+		{
+			name:     "parallel tool calls",
+			messages: []testMessage{{"user", 10}, {"call:a", 10}, {"tool:a", 50}, {"call:b", 10}, {"tool:b", 50}, {"assistant", 10}, {"user", 10}, {"call:c", 10}, {"tool:c", 10}, {"user", 10}},
+			limit:    50,
+			want:     []string{"user", "call:c", "tool:c", "user"},
+			total:    40,
+		},
+		{
+			name:     "nothing to trim",
+			messages: []testMessage{{"system", 10}, {"user", 10}, {"call:a", 10}, {"tool:a", 10}},
+			limit:    10,
+			want:     []string{"system", "user", "call:a", "tool:a"},
+			total:    40,
+		},
+	}
+
+	for _, test := range tests {
+		ctx := newTestChatContext(test.messages)
+		ctx.trimHistory(test.limit)
+
+		assert.Equal(t, len(test.messages)-len(test.want), ctx.result.TrimmedMessages, test.name)
+		assert.Equal(t, test.total, ctx.total, test.name)
+		assert.Len(t, ctx.tokens, len(ctx.messages), test.name)
+
+		roles := make([]string, 0, len(ctx.messages))
+		calls := make(map[string]bool)
+		for _, message := range ctx.messages {
+			roles = append(roles, message.StringContent())
+			for _, toolCall := range message.ToolCalls {
+				calls[toolCall.Id] = true
+			}
+			if message.Role == types.ChatMessageRoleTool {
+				assert.True(t, calls[message.ToolCallID], "%s: tool result %s without call", test.name, message.ToolCallID)
+			}
+		}
+		assert.Equal(t, test.want, roles, test.name)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"one-api/common"
+	"one-api/common/capture"
 	"one-api/common/config"
 	"one-api/common/logger"
 	"one-api/common/utils"
@@ -14,6 +15,7 @@ import (
 	"one-api/model"
 	"one-api/relay/relay_util"
 	"one-api/types"
+	"strconv"
 	"strings"
 	"time"
 
@@ -37,25 +39,75 @@ func Relay(c *gin.Context) {
 	}
 
 	c.Set("is_stream", relay.IsStream())
-	if err := relay.setProvider(relay.getOriginalModel()); err != nil {
-		openaiErr := common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
-		relay.HandleJsonError(openaiErr)
-		return
+	if recorder := capture.Start(c, relay.getOriginalModel()); recorder != nil {
+		defer saveCapture(relay, recorder)
+	}
+
+	virtualModel, modelNames := resolveVirtualModel(c, relay.getOriginalModel())
+
+	var heartbeat *relay_util.Heartbeat
+	defer func() {
+		if heartbeat != nil {
+			heartbeat.Close()
+		}
+	}()
+
+	var apiErr *types.OpenAIErrorWithStatusCode
+	attempts := 0
+	for i, modelName := range modelNames {
+		if i > 0 {
+			logger.LogWarn(c.Request.Context(), fmt.Sprintf("virtual model %s falls back to %s", virtualModel.Name, modelName))
+			// 渠道对上一个模型失败不代表对新模型也不可用
+			c.Set("skip_channel_ids", []int{})
+		}
+
+		attempts++
+		attempt := startAttemptSpan(c, attempts, apiErr)
+		if err := relay.setProvider(modelName); err != nil {
+			apiErr = common.StringErrorWrapperLocal(err.Error(), "one_hub_error", http.StatusServiceUnavailable)
+			attempt.End(nil, apiErr)
+			// 虚拟模型的当前模型无可用渠道时直接尝试下一个模型
+			if c.IsAborted() {
+				break
+			}
+			continue
+		}
+
+		if heartbeat == nil {
+			heartbeat = relay.SetHeartbeat(relay.IsStream())
+		}
+
+		var done bool
+		apiErr, done = relayWithRetry(relay, modelName, attempt, &attempts)
+		if apiErr == nil {
+			return
+		}
+		if done || !shouldFallbackModel(c, virtualModel, apiErr) {
+			break
+		}
 	}
 
-	heartbeat := relay.SetHeartbeat(relay.IsStream())
-	if heartbeat != nil {
-		defer heartbeat.Close()
+	if heartbeat != nil && heartbeat.IsSafeWriteStream() {
+		relay.HandleStreamError(apiErr)
+		return
 	}
 
-	apiErr, done := RelayHandler(relay)
+	relay.HandleJsonError(apiErr)
+}
+
+// relayWithRetry 使用指定模型请求上游，失败时按配置切换渠道重试
+func relayWithRetry(relay RelayBaseInterface, modelName string, attempt *attemptSpan, attempts *int) (apiErr *types.OpenAIErrorWithStatusCode, done bool) {
+	c := relay.getContext()
+
+	apiErr, done = RelayHandler(relay)
+	attempt.End(relay.getProvider(), apiErr)
 	if apiErr == nil {
 		metrics.RecordProvider(c, 200)
 		return
 	}
 
 	channel := relay.getProvider().GetChannel()
-	go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+	go processChannelRelayError(c.Request.Context(), channel, apiErr)
 
 	retryTimes := config.RetryTimes
 	if done || !shouldRetry(c, apiErr, channel.Type) {
@@ -74,35 +126,37 @@ func Relay(c *gin.Context) {
 			apiErr = common.StringErrorWrapperLocal("重试超时，上游负载已饱和，请稍后再试", "system_error", http.StatusTooManyRequests)
 			break
 		}
+		metrics.RecordRetry(modelName, strconv.Itoa(apiErr.StatusCode))
 
-		if err := relay.setProvider(relay.getOriginalModel()); err != nil {
+		*attempts++
+		attempt = startAttemptSpan(c, *attempts, apiErr)
+		if err := relay.setProvider(modelName); err != nil {
+			attempt.End(nil, apiErr)
 			break
 		}
 
 		channel = relay.getProvider().GetChannel()
 		logger.LogError(c.Request.Context(), fmt.Sprintf("using channel #%d(%s) to retry (remain times %d)", channel.Id, channel.Name, i))
 		apiErr, done = RelayHandler(relay)
+		attempt.End(relay.getProvider(), apiErr)
 		if apiErr == nil {
 			metrics.RecordProvider(c, 200)
 			return
 		}
-		go processChannelRelayError(c.Request.Context(), channel.Id, channel.Name, apiErr, channel.Type)
+		go processChannelRelayError(c.Request.Context(), channel, apiErr)
 		if done || !shouldRetry(c, apiErr, channel.Type) {
 			break
 		}
 	}
 
-	if apiErr != nil {
-		if heartbeat != nil && heartbeat.IsSafeWriteStream() {
-			relay.HandleStreamError(apiErr)
-			return
-		}
-
-		relay.HandleJsonError(apiErr)
-	}
+	return
 }
 
 func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCode, done bool) {
+	if manager, ok := relay.(contextManager); ok {
+		manager.manageContext()
+	}
+
 	promptTokens, tonkeErr := relay.getPromptTokens()
 	if tonkeErr != nil {
 		err = common.ErrorWrapperLocal(tonkeErr, "token_error", http.StatusBadRequest)
@@ -122,13 +176,19 @@ func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCod
 		return
 	}
 
+	sendStart := time.Now()
 	err, done = relay.send()
+	recordUpstream(relay, sendStart, err)
 	// 最后处理流式中断时计算tokens
 	if usage.CompletionTokens == 0 && usage.TextBuilder.Len() > 0 {
 		usage.CompletionTokens = common.CountTokenText(usage.TextBuilder.String(), relay.getModelName())
 		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
 	}
 	if err != nil {
+		if biller, ok := relay.(errorBiller); ok && biller.billOnError() {
+			quota.Consume(relay.getContext(), usage, relay.IsStream())
+			return
+		}
 		quota.Undo(relay.getContext())
 		return
 	}
@@ -140,12 +200,37 @@ func RelayHandler(relay RelayBaseInterface) (err *types.OpenAIErrorWithStatusCod
 	return
 }
 
+// saveCapture 请求结束后保存采集内容，此时上游响应已读取完毕
+func saveCapture(relay RelayBaseInterface, recorder *capture.Recorder) {
+	if provider := relay.getProvider(); provider != nil {
+		recorder.SetChannel(provider.GetChannel().Id)
+	}
+	go model.SavePayloadCapture(recorder.Payload())
+}
+
+func recordUpstream(relay RelayBaseInterface, start time.Time, apiErr *types.OpenAIErrorWithStatusCode) {
+	statusCode := http.StatusOK
+	if apiErr != nil {
+		statusCode = apiErr.StatusCode
+	}
+
+	firstResponse := relay.GetFirstResponseTime()
+	if firstResponse.Before(start) {
+		firstResponse = time.Time{}
+	}
+	metrics.RecordUpstream(relay.getProvider().GetChannel().Id, relay.getModelName(), statusCode, start, firstResponse)
+}
+
 func shouldCooldowns(c *gin.Context, channel *model.Channel, apiErr *types.OpenAIErrorWithStatusCode) {
 	modelName := c.GetString("new_model")
 	channelId := channel.Id
 
 	// 如果是频率限制，冻结通道
 	if apiErr.StatusCode == http.StatusTooManyRequests {
+		// 多密钥渠道只冻结当前密钥，还有其他可用密钥时允许重试该渠道
+		if channel.IsMultiKey() && model.CooldownChannelKey(channelId, model.HashChannelKey(channel.Key)) {
+			return
+		}
 		model.ChannelGroup.SetCooldowns(channelId, modelName)
 	}
 
@@ -186,6 +271,11 @@ func applyPreMappingBeforeRequest(c *gin.Context) {
 		return
 	}
 
+	// 虚拟模型在重试时才确定实际使用的渠道
+	if model.GlobalVirtualModels.Get(requestBody.Model) != nil {
+		return
+	}
+
 	provider, _, err := GetProvider(c, requestBody.Model)
 	if err != nil {
 		return
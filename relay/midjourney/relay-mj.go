@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/common/logger"
+	"one-api/common/webhook"
 	"one-api/controller"
 	"one-api/model"
 	provider "one-api/providers/midjourney"
@@ -83,6 +84,7 @@ func RelayMidjourneyNotify(c *gin.Context) *provider.MidjourneyResponse {
 			Result:      "",
 		}
 	}
+	oldStatus := midjourneyTask.Status
 	midjourneyTask.Progress = midjRequest.Progress
 	midjourneyTask.PromptEn = midjRequest.PromptEn
 	midjourneyTask.State = midjRequest.State
@@ -99,6 +101,7 @@ func RelayMidjourneyNotify(c *gin.Context) *provider.MidjourneyResponse {
 			Description: "update_midjourney_task_failed",
 		}
 	}
+	webhook.NotifyMidjourney(midjourneyTask, oldStatus)
 
 	return nil
 }
@@ -227,6 +230,7 @@ func RelaySwapFace(c *gin.Context) *provider.MidjourneyResponse {
 		Quota:       quota,
 		Mode:        mjModelType,
 	}
+	midjourneyTask.ChannelKeyHash = c.GetString("channel_key_hash")
 	err = midjourneyTask.Insert()
 	if err != nil {
 		return provider.MidjourneyErrorWrapper(provider.MjRequestError, "insert_midjourney_task_failed")
@@ -254,7 +258,7 @@ func RelayMidjourneyTaskImageSeed(c *gin.Context) *provider.MidjourneyResponse {
 		return provider.MidjourneyErrorWrapper(provider.MjRequestError, "task_no_found")
 	}
 
-	mjProvider, errWithMJ := getMJProviderWithChannelId(c, originTask.ChannelId)
+	mjProvider, errWithMJ := getMJProviderWithChannelId(c, originTask)
 	if errWithMJ != nil {
 		return errWithMJ
 	}
@@ -415,7 +419,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *provider.MidjourneyRe
 		} else if originTask.Status != "SUCCESS" && relayMode != provider.RelayModeMidjourneyModal {
 			return provider.MidjourneyErrorWrapper(provider.MjRequestError, "task_status_not_success")
 		} else { //原任务的Status=SUCCESS，则可以做放大UPSCALE、变换VARIATION等动作，此时必须使用原来的请求地址才能正确处理
-			mjProvider, errWithMJ = getMJProviderWithChannelId(c, originTask.ChannelId)
+			mjProvider, errWithMJ = getMJProviderWithChannelId(c, originTask)
 			if errWithMJ != nil {
 				return errWithMJ
 			}
@@ -444,7 +448,11 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *provider.MidjourneyRe
 	//baseURL := common.ChannelBaseURLs[channelType]
 	requestURL := getMjRequestPath(c.Request.URL.String())
 
-	//midjRequest.NotifyHook = "http://127.0.0.1:3000/mj/notify"
+	// 回调地址保存在任务中，由网关在任务状态变化时签名投递
+	notifyHook := ""
+	if webhook.ValidHookURL(midjRequest.NotifyHook) {
+		notifyHook = midjRequest.NotifyHook
+	}
 
 	quotaInstance, errWithOA := getQuota(c, midjRequest.Action)
 	if errWithOA != nil {
@@ -523,7 +531,9 @@ func RelayMidjourneySubmit(c *gin.Context, relayMode int) *provider.MidjourneyRe
 		ChannelId:   c.GetInt("channel_id"),
 		Quota:       quota,
 		Mode:        mjModelType,
+		NotifyHook:  notifyHook,
 	}
+	midjourneyTask.ChannelKeyHash = c.GetString("channel_key_hash")
 
 	if midjResponse.Code != 1 && midjResponse.Code != 21 && midjResponse.Code != 22 {
 		//非1-提交成功,21-任务已存在和22-排队中，则记录错误原因
@@ -637,8 +647,10 @@ func getMJProviderWithRequest(c *gin.Context, relayMode int, request *provider.M
 	return getMJProvider(c, midjourneyModel)
 }
 
-func getMJProviderWithChannelId(c *gin.Context, channelId int) (*provider.MidjourneyProvider, *provider.MidjourneyResponse) {
-	c.Set("specific_channel_id", channelId)
+// getMJProviderWithChannelId 使用原任务的渠道和密钥
+func getMJProviderWithChannelId(c *gin.Context, originTask *model.Midjourney) (*provider.MidjourneyProvider, *provider.MidjourneyResponse) {
+	c.Set("specific_channel_id", originTask.ChannelId)
+	c.Set("specific_channel_key_hash", originTask.ChannelKeyHash)
 
 	return getMJProvider(c, "")
 }
@@ -46,6 +46,7 @@ func ListModelsByToken(c *gin.Context) {
 		})
 		return
 	}
+	models = append(models, model.GlobalVirtualModels.GetAvailable(models)...)
 	sort.Strings(models)
 
 	var groupOpenAIModels []*OpenAIModels
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"one-api/common/requester"
+	"one-api/metrics"
 	"sync/atomic"
 	"time"
 
@@ -102,6 +103,7 @@ func (h *Heartbeat) Start() {
 				if !h.writeHeader() {
 					return
 				}
+				metrics.RecordHeartbeat(h.isStream)
 
 				// 超时后开始发送心跳
 				ticker := time.NewTicker(time.Duration(h.config.IntervalSeconds) * time.Second)
@@ -8,11 +8,14 @@ import (
 	"one-api/common"
 	"one-api/common/config"
 	"one-api/common/logger"
+	"one-api/common/tracing"
+	"one-api/metrics"
 	"one-api/model"
 	"one-api/types"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Quota struct {
@@ -25,15 +28,25 @@ type Quota struct {
 	groupRatio       float64
 	inputRatio       float64
 	outputRatio      float64
+	costRatio        float64 // 渠道成本倍率
+	costInputRatio   float64
+	costOutputRatio  float64
 	preConsumedQuota int
 	cacheQuota       int
+	units            int // 按次计费时的计量单位数，如视频秒数
 	userId           int
 	channelId        int
+	channelKeyHash   string // 多密钥渠道本次使用的密钥
 	tokenId          int
 	unlimitedQuota   bool
+	ephemeralKey     *model.EphemeralKeyClaims // 使用临时密钥时额外统计临时密钥的消费
+	ephemeralQuota   int                       // 临时密钥预扣的额度
 	HandelStatus     bool
 
 	startTime         time.Time
+	payloadId         string
+	virtualModel      string
+	contextManage     string // 上下文管理对请求所做的调整
 	firstResponseTime time.Time
 	extraBillingData  map[string]ExtraBillingData
 }
@@ -46,10 +59,18 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 		promptTokens:   promptTokens,
 		userId:         c.GetInt("id"),
 		channelId:      c.GetInt("channel_id"),
+		channelKeyHash: c.GetString("channel_key_hash"),
 		tokenId:        c.GetInt("token_id"),
 		unlimitedQuota: c.GetBool("token_unlimited_quota"),
 		HandelStatus:   false,
 		isBackupGroup:  isBackupGroup, // 记录是否使用备用分组
+		payloadId:      c.GetString("payload_id"),
+		virtualModel:   c.GetString("virtual_model"),
+		contextManage:  c.GetString("context_manage"),
+	}
+
+	if claims, ok := c.Get("ephemeral_key"); ok {
+		quota.ephemeralKey = claims.(*model.EphemeralKeyClaims)
 	}
 
 	quota.price = *model.PricingInstance.GetPrice(quota.modelName)
@@ -59,13 +80,21 @@ func NewQuota(c *gin.Context, modelName string, promptTokens int) *Quota {
 	quota.inputRatio = quota.price.GetInput() * quota.groupRatio
 	quota.outputRatio = quota.price.GetOutput() * quota.groupRatio
 
+	quota.costRatio = 1
+	quota.costInputRatio = quota.price.GetInput()
+	quota.costOutputRatio = quota.price.GetOutput()
+	if channel := model.ChannelGroup.GetChannel(quota.channelId); channel != nil {
+		quota.costRatio = channel.GetCostRatio()
+		quota.costInputRatio, quota.costOutputRatio = channel.GetCostPrice(quota.modelName, &quota.price)
+	}
+
 	return quota
 
 }
 
 func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	if q.price.Type == model.TimesPriceType {
-		q.preConsumedQuota = int(1000 * q.inputRatio)
+		q.preConsumedQuota = q.getTimesQuota(q.inputRatio)
 	} else if q.price.Input != 0 || q.price.Output != 0 {
 		q.preConsumedQuota = int(float64(q.promptTokens)*q.inputRatio) + config.PreConsumedQuota
 	}
@@ -83,8 +112,20 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 		return common.ErrorWrapper(errors.New("user quota is not enough"), "insufficient_user_quota", http.StatusPaymentRequired)
 	}
 
+	if q.ephemeralKey != nil {
+		err = model.ReserveEphemeralKeySpend(q.ephemeralKey, q.preConsumedQuota)
+		if errors.Is(err, model.ErrEphemeralKeyQuotaExceeded) {
+			return common.ErrorWrapper(err, "ephemeral_key_quota_exceeded", http.StatusForbidden)
+		}
+		if err != nil {
+			return common.ErrorWrapper(err, "reserve_ephemeral_key_quota_failed", http.StatusInternalServerError)
+		}
+		q.ephemeralQuota = q.preConsumedQuota
+	}
+
 	err = model.CacheDecreaseUserQuota(q.userId, q.preConsumedQuota)
 	if err != nil {
+		q.releaseEphemeralQuota()
 		return common.ErrorWrapper(err, "decrease_user_quota_failed", http.StatusInternalServerError)
 	}
 
@@ -98,6 +139,7 @@ func (q *Quota) PreQuotaConsumption() *types.OpenAIErrorWithStatusCode {
 	if q.preConsumedQuota > 0 {
 		err := model.PreConsumeTokenQuota(q.tokenId, q.preConsumedQuota)
 		if err != nil {
+			q.releaseEphemeralQuota()
 			return common.ErrorWrapper(err, "pre_consume_token_quota_failed", http.StatusForbidden)
 		}
 		q.HandelStatus = true
@@ -136,7 +178,18 @@ func (q *Quota) UpdateUserRealtimeQuota(usage *types.UsageEvent, nowUsage *types
 	return nil
 }
 
-func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string, isStream bool, sourceIp string, ctx context.Context) error {
+func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string, isStream bool, sourceIp string, ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "quota.consume",
+		attribute.String("model", q.modelName),
+		attribute.Int("channel.id", q.channelId),
+		attribute.Int("tokens.prompt", usage.PromptTokens),
+		attribute.Int("tokens.completion", usage.CompletionTokens),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	defer func() {
 		if q.cacheQuota > 0 {
 			model.CacheDecreaseUserRealtimeQuota(q.userId, q.cacheQuota)
@@ -144,10 +197,18 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 	}()
 
 	quota := q.GetTotalQuotaByUsage(usage)
+	costQuota := q.GetCostQuotaByUsage(usage)
+	span.SetAttributes(attribute.Int("quota", quota), attribute.Int("quota.pre_consumed", q.preConsumedQuota))
+	metrics.RecordConsume(q.modelName, q.groupName, metrics.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CachedTokens:     usage.PromptTokensDetails.CachedTokens,
+		ReasoningTokens:  usage.CompletionTokensDetails.ReasoningTokens,
+	}, quota)
 
 	if quota > 0 {
 		quotaDelta := quota - q.preConsumedQuota
-		err := model.PostConsumeTokenQuotaWithInfo(q.tokenId, q.userId, q.unlimitedQuota, quotaDelta)
+		err = model.PostConsumeTokenQuotaWithInfo(q.tokenId, q.userId, q.unlimitedQuota, quotaDelta)
 		if err != nil {
 			return errors.New("error consuming token remain quota: " + err.Error())
 		}
@@ -158,6 +219,16 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 		model.UpdateChannelUsedQuota(q.channelId, quota)
 	}
 
+	if q.ephemeralKey != nil {
+		if _, err := model.AddEphemeralKeySpend(q.ephemeralKey, quota-q.ephemeralQuota); err != nil {
+			logger.LogError(ctx, "error update ephemeral key spend: "+err.Error())
+		}
+	}
+
+	if q.channelKeyHash != "" {
+		go model.UpdateChannelKeyUsage(q.channelId, q.channelKeyHash, quota)
+	}
+
 	model.RecordConsumeLog(
 		ctx,
 		q.userId,
@@ -167,6 +238,7 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 		q.modelName,
 		tokenName,
 		quota,
+		costQuota,
 		"",
 		q.getRequestTime(),
 		isStream,
@@ -178,7 +250,19 @@ func (q *Quota) completedQuotaConsumption(usage *types.Usage, tokenName string,
 	return nil
 }
 
+// releaseEphemeralQuota 退还临时密钥预扣的额度
+func (q *Quota) releaseEphemeralQuota() {
+	if q.ephemeralKey == nil || q.ephemeralQuota == 0 {
+		return
+	}
+	if _, err := model.AddEphemeralKeySpend(q.ephemeralKey, -q.ephemeralQuota); err != nil {
+		logger.SysError("error return ephemeral key quota: " + err.Error())
+	}
+	q.ephemeralQuota = 0
+}
+
 func (q *Quota) Undo(c *gin.Context) {
+	q.releaseEphemeralQuota()
 	if q.HandelStatus {
 		go func(ctx context.Context) {
 			// return pre-consumed quota
@@ -206,6 +290,20 @@ func (q *Quota) GetInputRatio() float64 {
 	return q.inputRatio
 }
 
+// SetUnits 设置按次计费的计量单位数，按次价格视为单位价格，如视频按秒计费时为秒数
+func (q *Quota) SetUnits(units int) {
+	q.units = units
+}
+
+// GetTimesQuota 获取按次计费的配额
+func (q *Quota) GetTimesQuota() int {
+	return q.getTimesQuota(q.inputRatio)
+}
+
+func (q *Quota) getTimesQuota(ratio float64) int {
+	return int(1000 * ratio * float64(max(q.units, 1)))
+}
+
 func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 	meta := map[string]any{
 		"group_name":        q.groupName,
@@ -217,6 +315,29 @@ func (q *Quota) GetLogMeta(usage *types.Usage) map[string]any {
 		"output_ratio":      q.price.GetOutput(),
 	}
 
+	if q.units > 0 {
+		meta["units"] = q.units
+	}
+
+	if q.payloadId != "" {
+		meta["payload_id"] = q.payloadId
+	}
+
+	if q.virtualModel != "" {
+		meta["virtual_model"] = q.virtualModel
+	}
+
+	if q.contextManage != "" {
+		meta["context_manage"] = q.contextManage
+	}
+
+	if q.ephemeralKey != nil {
+		meta["ephemeral_key_id"] = q.ephemeralKey.Id
+		if q.ephemeralKey.EndUser != "" {
+			meta["end_user"] = q.ephemeralKey.EndUser
+		}
+	}
+
 	firstResponseTime := q.GetFirstResponseTime()
 	if firstResponseTime > 0 {
 		meta["first_response"] = firstResponseTime
@@ -246,7 +367,7 @@ func (q *Quota) getRequestTime() int {
 // 通过 token 数获取消费配额
 func (q *Quota) GetTotalQuota(promptTokens, completionTokens int, extraBilling map[string]types.ExtraBilling) (quota int) {
 	if q.price.Type == model.TimesPriceType {
-		quota = int(1000 * q.inputRatio)
+		quota = q.getTimesQuota(q.inputRatio)
 	} else {
 		quota = int(math.Ceil((float64(promptTokens) * q.inputRatio) + (float64(completionTokens) * q.outputRatio)))
 	}
@@ -280,6 +401,32 @@ func (q *Quota) GetTotalQuota(promptTokens, completionTokens int, extraBilling m
 	return quota
 }
 
+// 通过 usage 获取渠道成本配额，成本不受用户分组倍率影响
+func (q *Quota) GetCostQuotaByUsage(usage *types.Usage) (costQuota int) {
+	promptTokens, completionTokens := q.getComputeTokensByUsage(usage)
+	if promptTokens+completionTokens == 0 {
+		return 0
+	}
+
+	if q.price.Type == model.TimesPriceType {
+		costQuota = q.getTimesQuota(q.costInputRatio)
+	} else {
+		costQuota = int(math.Ceil((float64(promptTokens) * q.costInputRatio) + (float64(completionTokens) * q.costOutputRatio)))
+	}
+
+	if q.extraBillingData != nil {
+		extraBillingQuota := 0
+		for _, value := range q.extraBillingData {
+			extraBillingQuota += int(math.Ceil(
+				float64(value.Price)*float64(config.QuotaPerUnit),
+			)) * value.CallCount
+		}
+		costQuota += int(math.Ceil(float64(extraBillingQuota) * q.costRatio))
+	}
+
+	return costQuota
+}
+
 // 获取计算的 token 数
 func (q *Quota) getComputeTokensByUsage(usage *types.Usage) (promptTokens, completionTokens int) {
 	promptTokens = usage.PromptTokens
@@ -0,0 +1,319 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"one-api/common"
+	"one-api/common/config"
+	"one-api/common/jsonschema"
+	"one-api/common/logger"
+	"one-api/common/utils"
+	"one-api/model"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+	"regexp"
+	"strings"
+)
+
+const (
+	structuredOutputFunction = "json_output"
+
+	structuredOutputPrompt = "You must reply with a single JSON value that conforms to the following JSON schema. " +
+		"Do not wrap it in markdown and do not add any other text.\n\nJSON schema:\n%s"
+	structuredOutputRepairPrompt = "Your previous reply is not valid against the JSON schema: %s\n" +
+		"Reply again with only the corrected JSON."
+)
+
+var functionNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// errorBiller 返回错误时上游已产生需要计费的用量，如结构化输出重试后仍然失败
+type errorBiller interface {
+	billOnError() bool
+}
+
+func (r *relayChat) billOnError() bool {
+	return r.billError
+}
+
+// structuredOutput 为不支持 json_schema 的渠道模拟结构化输出，并校验返回的 JSON
+type structuredOutput struct {
+	relay  *relayChat
+	mode   string
+	schema any
+	// 转换为函数调用时使用的函数名
+	function string
+}
+
+// newStructuredOutput 渠道需要模拟，或请求要求严格遵守 schema 时返回处理器，否则由上游原生处理
+func newStructuredOutput(r *relayChat) *structuredOutput {
+	format := r.chatRequest.ResponseFormat
+	if format == nil || format.Type != "json_schema" || format.JsonSchema == nil || format.JsonSchema.Schema == nil {
+		return nil
+	}
+
+	mode := r.provider.GetChannel().StructuredOutput
+	if mode != model.StructuredOutputTools && mode != model.StructuredOutputPrompt {
+		mode = ""
+	}
+	strict, _ := format.JsonSchema.Strict.(bool)
+	if mode == "" && !strict {
+		return nil
+	}
+
+	// 请求自带工具时不能强制调用输出函数，改为提示词方式
+	if mode == model.StructuredOutputTools && (len(r.chatRequest.Tools) > 0 || len(r.chatRequest.Functions) > 0) {
+		mode = model.StructuredOutputPrompt
+	}
+
+	function := functionNameRegex.ReplaceAllString(format.JsonSchema.Name, "_")
+	if function == "" {
+		function = structuredOutputFunction
+	}
+	if len(function) > 64 {
+		function = function[:64]
+	}
+
+	return &structuredOutput{
+		relay:    r,
+		mode:     mode,
+		schema:   format.JsonSchema.Schema,
+		function: function,
+	}
+}
+
+// buildRequest 生成发送给上游的请求，不修改原始请求，重试其他渠道时可以重新生成
+func (s *structuredOutput) buildRequest() *types.ChatCompletionRequest {
+	request := s.relay.chatRequest
+	request.Messages = append([]types.ChatCompletionMessage(nil), request.Messages...)
+	// 需要完整的结果才能校验，流式请求在校验后再以流的形式返回
+	request.Stream = false
+	request.StreamOptions = nil
+
+	format := request.ResponseFormat.JsonSchema
+	switch s.mode {
+	case model.StructuredOutputTools:
+		request.ResponseFormat = nil
+		request.Tools = []*types.ChatCompletionTool{{
+			Type: types.ToolChoiceTypeFunction,
+			Function: types.ChatCompletionFunction{
+				Name:        s.function,
+				Description: format.Description,
+				Parameters:  s.schema,
+			},
+		}}
+		request.ToolChoice = map[string]any{
+			"type":     types.ToolChoiceTypeFunction,
+			"function": map[string]any{"name": s.function},
+		}
+	case model.StructuredOutputPrompt:
+		request.ResponseFormat = nil
+		schema, _ := json.Marshal(s.schema)
+		instruction := fmt.Sprintf(structuredOutputPrompt, schema)
+		if format.Description != "" {
+			instruction = format.Description + "\n\n" + instruction
+		}
+		// 部分渠道只支持一条系统消息，优先合并到已有的系统消息中
+		if len(request.Messages) > 0 && request.Messages[0].IsSystemRole() {
+			if content, ok := request.Messages[0].Content.(string); ok {
+				request.Messages[0].Content = content + "\n\n" + instruction
+				break
+			}
+		}
+		request.Messages = append([]types.ChatCompletionMessage{{
+			Role:    types.ChatMessageRoleSystem,
+			Content: instruction,
+		}}, request.Messages...)
+	}
+
+	return &request
+}
+
+// extract 从响应中提取 JSON 文本，转换为函数调用时优先读取函数参数
+func (s *structuredOutput) extract(message *types.ChatCompletionMessage) string {
+	if s.mode == model.StructuredOutputTools {
+		for _, toolCall := range message.ToolCalls {
+			if toolCall.Function != nil && toolCall.Function.Name == s.function {
+				return strings.TrimSpace(toolCall.Function.Arguments)
+			}
+		}
+	}
+	return extractJSON(message.StringContent())
+}
+
+// extractJSON 去除模型常见的 markdown 代码块和前后说明文字
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(text, closing)
+	if end <= start {
+		return text
+	}
+	return text[start : end+1]
+}
+
+// validate 校验所有选项，返回第一个不符合 schema 的选项及原因
+func (s *structuredOutput) validate(response *types.ChatCompletionResponse) (int, error) {
+	if len(response.Choices) == 0 {
+		return -1, fmt.Errorf("upstream returned no choices")
+	}
+	for i := range response.Choices {
+		output := s.extract(&response.Choices[i].Message)
+		if err := jsonschema.ValidateJSON(s.schema, output); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// rewrite 将校验通过的 JSON 作为消息内容返回，与原生结构化输出的响应一致
+func (s *structuredOutput) rewrite(response *types.ChatCompletionResponse) {
+	for i := range response.Choices {
+		choice := &response.Choices[i]
+		choice.Message.Content = s.extract(&choice.Message)
+		choice.Message.ToolCalls = nil
+		choice.Message.FunctionCall = nil
+		if choice.FinishReason == types.FinishReasonToolCalls || choice.FinishReason == types.FinishReasonFunctionCall {
+			choice.FinishReason = types.FinishReasonStop
+		}
+	}
+}
+
+func (s *structuredOutput) send(chatProvider providersBase.ChatInterface) (err *types.OpenAIErrorWithStatusCode, done bool) {
+	r := s.relay
+	c := r.c
+	channel := r.provider.GetChannel()
+	usage := r.provider.GetUsage()
+	request := s.buildRequest()
+
+	// 多次请求的用量累计计费
+	promptTokens, completionTokens := 0, 0
+	retries := utils.Max(config.StructuredOutputRetries, 0)
+
+	var response *types.ChatCompletionResponse
+	var validateErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		usage.PromptTokens = common.CountTokenMessages(request.Messages, r.modelName, channel.PreCost)
+		usage.CompletionTokens = 0
+		response, err = chatProvider.CreateChatCompletion(request)
+		if err != nil {
+			// 首次请求失败按普通错误处理，允许切换渠道重试；重试失败时之前的请求已产生用量，需要计费
+			if attempt > 0 {
+				done = true
+				s.billUsage(promptTokens, completionTokens)
+			}
+			return
+		}
+		promptTokens += usage.PromptTokens
+		completionTokens += usage.CompletionTokens
+
+		var index int
+		index, validateErr = s.validate(response)
+		if validateErr == nil {
+			break
+		}
+		logger.LogWarn(c.Request.Context(), fmt.Sprintf("structured output invalid (attempt %d, channel #%d): %s", attempt+1, channel.Id, validateErr.Error()))
+		if index < 0 {
+			continue
+		}
+
+		// 将错误的输出和原因交给模型修正
+		previous := response.Choices[index].Message
+		request.Messages = append(request.Messages,
+			types.ChatCompletionMessage{Role: types.ChatMessageRoleAssistant, Content: s.extract(&previous)},
+			types.ChatCompletionMessage{Role: types.ChatMessageRoleUser, Content: fmt.Sprintf(structuredOutputRepairPrompt, validateErr.Error())},
+		)
+	}
+
+	usage.PromptTokens = promptTokens
+	usage.CompletionTokens = completionTokens
+	usage.TotalTokens = promptTokens + completionTokens
+
+	if r.heartbeat != nil {
+		r.heartbeat.Stop()
+	}
+
+	if validateErr != nil {
+		s.billUsage(promptTokens, completionTokens)
+		err = &types.OpenAIErrorWithStatusCode{
+			OpenAIError: types.OpenAIError{
+				Message: "model output does not match the JSON schema in response_format: " + validateErr.Error(),
+				Type:    "invalid_response_error",
+				Param:   "response_format",
+				Code:    "json_schema_validation_failed",
+			},
+			StatusCode: http.StatusUnprocessableEntity,
+			LocalError: true,
+		}
+		done = true
+		return
+	}
+
+	s.rewrite(response)
+	response.Usage = usage
+	if !r.chatRequest.Stream {
+		err = responseJsonClient(c, response)
+		return
+	}
+
+	responseCache(c, s.toStream(response), true)
+	return
+}
+
+// billUsage 返回错误时仍按已完成请求的累计用量计费
+func (s *structuredOutput) billUsage(promptTokens, completionTokens int) {
+	usage := s.relay.provider.GetUsage()
+	usage.PromptTokens = promptTokens
+	usage.CompletionTokens = completionTokens
+	usage.TotalTokens = promptTokens + completionTokens
+	s.relay.billError = true
+}
+
+// toStream 将完整的响应转换为流式响应
+func (s *structuredOutput) toStream(response *types.ChatCompletionResponse) string {
+	var builder strings.Builder
+	write := func(data any) {
+		body, _ := json.Marshal(data)
+		builder.WriteString("data: " + string(body) + "\n\n")
+	}
+
+	for _, choice := range response.Choices {
+		write(types.ChatCompletionStreamResponse{
+			ID:      response.ID,
+			Object:  "chat.completion.chunk",
+			Created: response.Created,
+			Model:   response.Model,
+			Choices: []types.ChatCompletionStreamChoice{{
+				Index: choice.Index,
+				Delta: types.ChatCompletionStreamChoiceDelta{
+					Role:    types.ChatMessageRoleAssistant,
+					Content: choice.Message.StringContent(),
+				},
+				FinishReason: choice.FinishReason,
+			}},
+		})
+	}
+
+	if usage := s.relay.getUsageResponse(); usage != "" {
+		builder.WriteString("data: " + usage + "\n\n")
+	}
+	builder.WriteString("data: [DONE]\n\n")
+	return builder.String()
+}
@@ -3,6 +3,7 @@ package base
 import (
 	"context"
 	"errors"
+	"one-api/common/webhook"
 	"one-api/model"
 	"one-api/providers/base"
 	"one-api/relay"
@@ -21,6 +22,10 @@ type TaskBase struct {
 	OriginTaskID  string
 	BaseProvider  base.ProviderInterface
 	Response      any
+	NotifyHook    string
+	// 计费模型和计量单位数，为空时使用请求模型按次计费
+	BillingModel string
+	BillingUnits int
 }
 
 type TaskInterface interface {
@@ -29,6 +34,7 @@ type TaskInterface interface {
 	HandleError(err *TaskError)
 	ShouldRetry(c *gin.Context, err *TaskError) bool
 	GetModelName() string
+	GetBilling() (modelName string, units int)
 	GetTask() *model.Task
 	SetProvider() *TaskError
 	GetProvider() base.ProviderInterface
@@ -47,6 +53,14 @@ func (t *TaskBase) InitTask() {
 		SubmitTime: time.Now().Unix(),
 		Status:     model.TaskStatusNotStart,
 		Progress:   0,
+		NotifyHook: t.NotifyHook,
+	}
+}
+
+// SetNotifyHook 记录用户的回调地址，任务状态变化时由网关投递，不透传给上游
+func (t *TaskBase) SetNotifyHook(hook string) {
+	if webhook.ValidHookURL(hook) {
+		t.NotifyHook = hook
 	}
 }
 
@@ -58,6 +72,13 @@ func (t *TaskBase) GetModelName() string {
 	return t.ModelName
 }
 
+func (t *TaskBase) GetBilling() (string, int) {
+	if t.BillingModel != "" {
+		return t.BillingModel, t.BillingUnits
+	}
+	return t.GetModelName(), t.BillingUnits
+}
+
 func (t *TaskBase) GetTask() *model.Task {
 	return t.Task
 }
@@ -92,6 +113,7 @@ func (t *TaskBase) HandleOriginTaskID() error {
 	}
 
 	t.C.Set("specific_channel_id", task.ChannelId)
+	t.C.Set("specific_channel_key_hash", task.ChannelKeyHash)
 
 	return nil
 }
@@ -100,6 +122,19 @@ func (t *TaskBase) GinResponse() {
 	t.C.JSON(200, t.Response)
 }
 
+// GroupTaskIdsByKey 按提交任务时使用的渠道密钥分组，上游任务只能用提交时的密钥查询
+func GroupTaskIdsByKey(taskIds []string, taskM map[string]*model.Task) map[string][]string {
+	groups := make(map[string][]string)
+	for _, taskId := range taskIds {
+		hash := ""
+		if task, ok := taskM[taskId]; ok {
+			hash = task.ChannelKeyHash
+		}
+		groups[hash] = append(groups[hash], taskId)
+	}
+	return groups
+}
+
 type TaskError struct {
 	Code       string `json:"code"`
 	Message    string `json:"message"`
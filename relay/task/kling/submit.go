@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/common/logger"
+	"one-api/common/webhook"
 	"one-api/model"
 	"one-api/providers"
 	KlingProvider "one-api/providers/kling"
@@ -37,6 +38,10 @@ func (t *KlingTask) Init() *base.TaskError {
 	if err := common.UnmarshalBodyReusable(t.C, &t.Request); err != nil {
 		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
 	}
+	if hook, ok := t.Request.CallbackURL.(string); ok {
+		t.SetNotifyHook(hook)
+	}
+	t.Request.CallbackURL = nil
 
 	err := t.actionValidate()
 	if err != nil {
@@ -158,6 +163,28 @@ func updateKlingTaskAll(ctx context.Context, channelId int, taskIds []string, ta
 		return fmt.Errorf("channel not found")
 	}
 
+	for hash, ids := range base.GroupTaskIdsByKey(taskIds, taskM) {
+		keyed, err := model.PickChannelKeyByHash(channel, hash)
+		if err != nil {
+			err := model.TaskBulkUpdate(ids, map[string]any{
+				"fail_reason": fmt.Sprintf("获取渠道密钥失败，请联系管理员，渠道ID：%d", channelId),
+				"status":      "FAILURE",
+				"progress":    100,
+			})
+			if err != nil {
+				logger.SysError(fmt.Sprintf("UpdateTask error: %v", err))
+			}
+			continue
+		}
+		if err := updateKlingTasks(ctx, keyed, ids, taskM); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新异步任务失败: %s", channelId, err.Error()))
+		}
+	}
+	return nil
+}
+
+// updateKlingTasks 使用提交任务时的密钥查询任务状态
+func updateKlingTasks(ctx context.Context, channel *model.Channel, taskIds []string, taskM map[string]*model.Task) error {
 	providers := providers.GetProvider(channel, nil)
 	KlingProvider, ok := providers.(*KlingProvider.KlingProvider)
 	if !ok {
@@ -195,6 +222,7 @@ func updateKlingTaskAll(ctx context.Context, channelId int, taskIds []string, ta
 			continue
 		}
 
+		oldStatus := task.Status
 		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
 		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
 		task.SubmitTime = lo.If(responseItem.SubmitTime != 0, responseItem.SubmitTime).Else(task.SubmitTime)
@@ -223,7 +251,9 @@ func updateKlingTaskAll(ctx context.Context, channelId int, taskIds []string, ta
 		err := task.Update()
 		if err != nil {
 			logger.SysError("UpdateTask task error: " + err.Error())
+			continue
 		}
+		webhook.NotifyTask(task, oldStatus)
 	}
 
 	return nil
@@ -36,7 +36,9 @@ func RelayTaskSubmit(c *gin.Context) {
 		return
 	}
 
-	quotaInstance := relay_util.NewQuota(c, taskAdaptor.GetModelName(), 1000)
+	billingModel, units := taskAdaptor.GetBilling()
+	quotaInstance := relay_util.NewQuota(c, billingModel, 1000)
+	quotaInstance.SetUnits(units)
 	if errWithOA := quotaInstance.PreQuotaConsumption(); errWithOA != nil {
 		taskAdaptor.HandleError(base.OpenAIErrToTaskErr(errWithOA))
 		return
@@ -73,7 +75,8 @@ func RelayTaskSubmit(c *gin.Context) {
 
 		taskErr = taskAdaptor.Relay()
 		if taskErr == nil {
-			go CompletedTask(quotaInstance, taskAdaptor, c)
+			CompletedTask(quotaInstance, taskAdaptor, c)
+			taskAdaptor.GinResponse()
 			return
 		}
 
@@ -94,7 +97,8 @@ func CompletedTask(quotaInstance *relay_util.Quota, taskAdaptor base.TaskInterfa
 	quotaInstance.Consume(c, &types.Usage{CompletionTokens: 0, PromptTokens: 1, TotalTokens: 1}, false)
 
 	task := taskAdaptor.GetTask()
-	task.Quota = int(quotaInstance.GetInputRatio() * 1000)
+	task.Quota = quotaInstance.GetTimesQuota()
+	task.ChannelKeyHash = c.GetString("channel_key_hash")
 
 	err := task.Insert()
 	if err != nil {
@@ -112,6 +116,8 @@ func GetRelayMode(c *gin.Context) int {
 		relayMode = config.RelayModeSuno
 	} else if strings.HasPrefix(path, "/kling") {
 		relayMode = config.RelayModeKling
+	} else if strings.HasPrefix(path, "/v1/videos") {
+		relayMode = config.RelayModeVideos
 	}
 
 	return relayMode
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"one-api/common"
 	"one-api/common/logger"
+	"one-api/common/webhook"
 	"one-api/metrics"
 	"one-api/model"
 	"one-api/providers"
@@ -37,6 +38,8 @@ func (t *SunoTask) Init() *base.TaskError {
 	if err := common.UnmarshalBodyReusable(t.C, &t.Request); err != nil {
 		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
 	}
+	t.SetNotifyHook(t.Request.NotifyHook)
+	t.Request.NotifyHook = ""
 
 	err := t.actionValidate()
 	if err != nil {
@@ -183,6 +186,28 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		return fmt.Errorf("channel not found")
 	}
 
+	for hash, ids := range base.GroupTaskIdsByKey(taskIds, taskM) {
+		keyed, err := model.PickChannelKeyByHash(channel, hash)
+		if err != nil {
+			err := model.TaskBulkUpdate(ids, map[string]any{
+				"fail_reason": fmt.Sprintf("获取渠道密钥失败，请联系管理员，渠道ID：%d", channelId),
+				"status":      "FAILURE",
+				"progress":    100,
+			})
+			if err != nil {
+				logger.SysError(fmt.Sprintf("UpdateTask error: %v", err))
+			}
+			continue
+		}
+		if err := updateSunoTasks(ctx, keyed, ids, taskM); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新异步任务失败: %s", channelId, err.Error()))
+		}
+	}
+	return nil
+}
+
+// updateSunoTasks 使用提交任务时的密钥查询任务状态
+func updateSunoTasks(ctx context.Context, channel *model.Channel, taskIds []string, taskM map[string]*model.Task) error {
 	providers := providers.GetProvider(channel, nil)
 	sunoProvider, ok := providers.(*sunoProvider.SunoProvider)
 	if !ok {
@@ -203,7 +228,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 	}
 
 	if !resp.IsSuccess() {
-		return fmt.Errorf("渠道 #%d 未完成的任务有: %d, 报错: %s", channelId, len(taskIds), resp.Message)
+		return fmt.Errorf("渠道 #%d 未完成的任务有: %d, 报错: %s", channel.Id, len(taskIds), resp.Message)
 	}
 
 	for _, responseItem := range *resp.Data {
@@ -212,6 +237,7 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 			continue
 		}
 
+		oldStatus := task.Status
 		task.Status = lo.If(model.TaskStatus(responseItem.Status) != "", model.TaskStatus(responseItem.Status)).Else(task.Status)
 		task.FailReason = lo.If(responseItem.FailReason != "", responseItem.FailReason).Else(task.FailReason)
 		task.SubmitTime = lo.If(responseItem.SubmitTime != 0, responseItem.SubmitTime).Else(task.SubmitTime)
@@ -240,7 +266,9 @@ func updateSunoTaskAll(ctx context.Context, channelId int, taskIds []string, tas
 		err := task.Update()
 		if err != nil {
 			logger.SysError("UpdateTask task error: " + err.Error())
+			continue
 		}
+		webhook.NotifyTask(task, oldStatus)
 	}
 	return nil
 }
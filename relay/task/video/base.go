@@ -0,0 +1,79 @@
+package video
+
+import (
+	"encoding/json"
+	"one-api/model"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 任务属性，记录上游的任务ID
+type videoProperties struct {
+	UpstreamId string `json:"upstream_id"`
+}
+
+func StringError(c *gin.Context, httpCode int, code, message string) {
+	c.JSON(httpCode, types.OpenAIErrorResponse{
+		Error: types.OpenAIError{
+			Code:    code,
+			Message: message,
+			Type:    "video_error",
+		},
+	})
+}
+
+func getUpstreamId(task *model.Task) string {
+	properties := &videoProperties{}
+	_ = json.Unmarshal(task.Properties, properties)
+	return properties.UpstreamId
+}
+
+func toTaskStatus(status string) model.TaskStatus {
+	switch status {
+	case types.VideoStatusQueued:
+		return model.TaskStatusQueued
+	case types.VideoStatusInProgress:
+		return model.TaskStatusInProgress
+	case types.VideoStatusCompleted:
+		return model.TaskStatusSuccess
+	case types.VideoStatusFailed:
+		return model.TaskStatusFailure
+	default:
+		return model.TaskStatusUnknown
+	}
+}
+
+func toVideoStatus(status model.TaskStatus) string {
+	switch status {
+	case model.TaskStatusSuccess:
+		return types.VideoStatusCompleted
+	case model.TaskStatusFailure:
+		return types.VideoStatusFailed
+	case model.TaskStatusInProgress:
+		return types.VideoStatusInProgress
+	default:
+		return types.VideoStatusQueued
+	}
+}
+
+// TaskModel2Video 将任务转换为 OpenAI 视频对象
+func TaskModel2Video(task *model.Task) *types.VideoTask {
+	video := &types.VideoTask{}
+	_ = json.Unmarshal(task.Data, video)
+
+	video.Id = task.TaskID
+	video.Object = "video"
+	video.Status = toVideoStatus(task.Status)
+	video.Progress = task.Progress
+	video.CreatedAt = task.SubmitTime
+	video.CompletedAt = task.FinishTime
+	if task.Status == model.TaskStatusFailure && video.Error == nil {
+		video.Error = &types.VideoTaskError{
+			Code:    "video_generation_failed",
+			Message: task.FailReason,
+		}
+	}
+
+	return video
+}
@@ -0,0 +1,84 @@
+package video
+
+import (
+	"net/http"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetFetchByID(c *gin.Context) {
+	task, ok := getUserTask(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, TaskModel2Video(task))
+}
+
+// GetContent 下载视频，已转存的重定向到存储地址，否则从上游获取
+func GetContent(c *gin.Context) {
+	task, ok := getUserTask(c)
+	if !ok {
+		return
+	}
+
+	if task.Status != model.TaskStatusSuccess {
+		StringError(c, http.StatusNotFound, "video_not_ready", "video is not ready")
+		return
+	}
+
+	video := TaskModel2Video(task)
+	if video.VideoURL != "" {
+		c.Redirect(http.StatusFound, video.VideoURL)
+		return
+	}
+
+	channel := model.ChannelGroup.GetChannel(task.ChannelId)
+	if channel == nil {
+		StringError(c, http.StatusServiceUnavailable, "channel_not_found", "channel not found")
+		return
+	}
+
+	channel, err := model.PickChannelKeyByHash(channel, task.ChannelKeyHash)
+	if err != nil {
+		StringError(c, http.StatusServiceUnavailable, "channel_key_not_found", err.Error())
+		return
+	}
+
+	videoProvider, ok := providers.GetProvider(channel, c).(providersBase.VideoInterface)
+	if !ok {
+		StringError(c, http.StatusServiceUnavailable, "provider_not_found", "provider not found")
+		return
+	}
+
+	resp, errWithCode := videoProvider.GetVideoContent(getUpstreamId(task))
+	if errWithCode != nil {
+		StringError(c, errWithCode.StatusCode, "get_video_content_failed", errWithCode.Message)
+		return
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "video/mp4"
+	}
+	c.DataFromReader(http.StatusOK, resp.ContentLength, contentType, resp.Body, nil)
+}
+
+func getUserTask(c *gin.Context) (*model.Task, bool) {
+	task, err := model.GetTaskByTaskId(model.TaskPlatformVideo, c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		StringError(c, http.StatusInternalServerError, "get_task_failed", err.Error())
+		return nil, false
+	}
+
+	if task == nil {
+		StringError(c, http.StatusNotFound, "task_not_exist", "video not found")
+		return nil, false
+	}
+
+	return task, true
+}
@@ -0,0 +1,330 @@
+package video
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"one-api/common"
+	"one-api/common/logger"
+	"one-api/common/requester"
+	"one-api/common/storage"
+	"one-api/common/utils"
+	"one-api/common/webhook"
+	"one-api/metrics"
+	"one-api/model"
+	"one-api/providers"
+	providersBase "one-api/providers/base"
+	"one-api/relay/task/base"
+	"one-api/types"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultVideoModel = "sora-2"
+
+type VideoTask struct {
+	base.TaskBase
+	Request  *types.VideoRequest
+	Provider providersBase.VideoInterface
+}
+
+func (t *VideoTask) HandleError(err *base.TaskError) {
+	StringError(t.C, err.StatusCode, err.Code, err.Message)
+}
+
+func (t *VideoTask) Init() *base.TaskError {
+	t.Request = &types.VideoRequest{}
+	if err := common.UnmarshalBodyReusable(t.C, t.Request); err != nil {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", err.Error(), true)
+	}
+	t.SetNotifyHook(t.Request.NotifyHook)
+	t.Request.NotifyHook = ""
+
+	if t.Request.Prompt == "" {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "prompt is required", true)
+	}
+	if t.Request.Seconds != "" && t.Request.Seconds.Int() <= 0 {
+		return base.StringTaskError(http.StatusBadRequest, "invalid_request", "seconds is invalid", true)
+	}
+	if t.Request.Model == "" {
+		t.Request.Model = defaultVideoModel
+	}
+	t.OriginalModel = t.Request.Model
+
+	return nil
+}
+
+func (t *VideoTask) SetProvider() *base.TaskError {
+	// 开始通过模型查询渠道
+	provider, err := t.GetProviderByModel()
+	if err != nil {
+		return base.StringTaskError(http.StatusServiceUnavailable, "provider_not_found", err.Error(), true)
+	}
+
+	videoProvider, ok := provider.(providersBase.VideoInterface)
+	if !ok {
+		return base.StringTaskError(http.StatusServiceUnavailable, "provider_not_found", "provider not found", true)
+	}
+
+	t.Provider = videoProvider
+	t.BaseProvider = provider
+	t.Request.Model = t.ModelName
+	t.setBilling()
+
+	return nil
+}
+
+// setBilling 配置了 {模型}-{秒数}s 价格的按条计费，否则按秒计费
+func (t *VideoTask) setBilling() {
+	seconds := t.Request.Seconds.Int()
+	if seconds <= 0 {
+		seconds = t.Provider.GetVideoDefaultSeconds(t.ModelName)
+	}
+
+	modelName := t.GetModelName()
+	clipModel := fmt.Sprintf("%s-%ds", modelName, seconds)
+	if model.PricingInstance.HasPrice(clipModel) {
+		t.BillingModel = clipModel
+		t.BillingUnits = 0
+		return
+	}
+
+	t.BillingModel = modelName
+	t.BillingUnits = 0
+	if model.PricingInstance.GetPrice(modelName).Type == model.TimesPriceType {
+		t.BillingUnits = seconds
+	}
+}
+
+func (t *VideoTask) Relay() *base.TaskError {
+	video, errWithCode := t.Provider.CreateVideo(t.Request)
+	if errWithCode != nil {
+		return base.OpenAIErrToTaskErr(errWithCode)
+	}
+
+	t.InitTask()
+	t.Task.TaskID = "video_" + utils.GetUUID()
+	t.Task.ChannelId = t.BaseProvider.GetChannel().Id
+	t.Task.Action = "generate"
+	t.Task.Status = toTaskStatus(video.Status)
+	t.Task.Properties, _ = json.Marshal(&videoProperties{UpstreamId: video.Id})
+
+	video.Id = t.Task.TaskID
+	video.Object = "video"
+	video.Model = t.OriginalModel
+	video.CreatedAt = t.Task.SubmitTime
+	t.Task.Data, _ = json.Marshal(video)
+	t.Response = video
+
+	return nil
+}
+
+func (t *VideoTask) ShouldRetry(c *gin.Context, err *base.TaskError) bool {
+	if err == nil {
+		return false
+	}
+
+	metrics.RecordProvider(c, err.StatusCode)
+
+	if err.LocalError {
+		return false
+	}
+
+	if _, ok := t.C.Get("specific_channel_id"); ok {
+		return false
+	}
+
+	// 参数错误不重试
+	if err.StatusCode == http.StatusBadRequest {
+		return false
+	}
+
+	// 超时不重试，避免重复生成
+	if err.StatusCode == 504 || err.StatusCode == 524 {
+		return false
+	}
+
+	return true
+}
+
+func (t *VideoTask) UpdateTaskStatus(ctx context.Context, taskChannelM map[int][]string, taskM map[string]*model.Task) error {
+	for channelId, taskIds := range taskChannelM {
+		err := updateVideoTaskAll(ctx, channelId, taskIds, taskM)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新视频任务失败: %s", channelId, err.Error()))
+		}
+	}
+	return nil
+}
+
+func updateVideoTaskAll(ctx context.Context, channelId int, taskIds []string, taskM map[string]*model.Task) error {
+	logger.LogWarn(ctx, fmt.Sprintf("渠道 #%d 未完成的视频任务有: %d", channelId, len(taskIds)))
+	if len(taskIds) == 0 {
+		return nil
+	}
+
+	channel := model.ChannelGroup.GetChannel(channelId)
+	if channel == nil {
+		err := model.TaskBulkUpdate(taskIds, map[string]any{
+			"fail_reason": fmt.Sprintf("获取渠道信息失败，请联系管理员，渠道ID：%d", channelId),
+			"status":      "FAILURE",
+			"progress":    100,
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("UpdateTask error: %v", err))
+		}
+		return fmt.Errorf("channel not found")
+	}
+
+	for hash, ids := range base.GroupTaskIdsByKey(taskIds, taskM) {
+		keyed, err := model.PickChannelKeyByHash(channel, hash)
+		if err != nil {
+			err := model.TaskBulkUpdate(ids, map[string]any{
+				"fail_reason": fmt.Sprintf("获取渠道密钥失败，请联系管理员，渠道ID：%d", channelId),
+				"status":      "FAILURE",
+				"progress":    100,
+			})
+			if err != nil {
+				logger.SysError(fmt.Sprintf("UpdateTask error: %v", err))
+			}
+			continue
+		}
+		if err := updateVideoTasks(ctx, keyed, ids, taskM); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("渠道 #%d 更新视频任务失败: %s", channelId, err.Error()))
+		}
+	}
+	return nil
+}
+
+// updateVideoTasks 使用提交任务时的密钥查询任务状态
+func updateVideoTasks(ctx context.Context, channel *model.Channel, taskIds []string, taskM map[string]*model.Task) error {
+	videoProvider, ok := providers.GetProvider(channel, nil).(providersBase.VideoInterface)
+	if !ok {
+		err := model.TaskBulkUpdate(taskIds, map[string]any{
+			"fail_reason": "获取供应商失败，请联系管理员",
+			"status":      "FAILURE",
+			"progress":    100,
+		})
+		if err != nil {
+			logger.SysError(fmt.Sprintf("UpdateTask error: %v", err))
+		}
+		return fmt.Errorf("provider not found")
+	}
+
+	for _, taskId := range taskIds {
+		task := taskM[taskId]
+		upstreamId := getUpstreamId(task)
+		video, errWithCode := videoProvider.GetVideo(upstreamId)
+		if errWithCode != nil {
+			logger.SysError(fmt.Sprintf("Get Task %s Do req error: %v", taskId, errWithCode))
+			continue
+		}
+
+		status := toTaskStatus(video.Status)
+		if status == task.Status && video.Progress == task.Progress {
+			continue
+		}
+
+		oldStatus := task.Status
+		nowTime := time.Now().Unix()
+		task.Status = status
+		task.Progress = min(video.Progress, 99)
+		if task.StartTime == 0 && status != model.TaskStatusQueued {
+			task.StartTime = nowTime
+		}
+
+		result := TaskModel2Video(task)
+		switch status {
+		case model.TaskStatusSuccess:
+			task.Progress = 100
+			task.FinishTime = nowTime
+			result.VideoURL = video.VideoURL
+			if url := mirrorVideo(ctx, videoProvider, upstreamId, video); url != "" {
+				result.VideoURL = url
+			}
+		case model.TaskStatusFailure:
+			task.Progress = 100
+			task.FinishTime = nowTime
+			task.FailReason = "video generation failed"
+			if video.Error != nil && video.Error.Message != "" {
+				task.FailReason = video.Error.Message
+			}
+			result.Error = video.Error
+			logger.LogError(ctx, task.TaskID+" 生成失败，"+task.FailReason)
+			refundTask(ctx, task)
+		}
+
+		result.Status = toVideoStatus(task.Status)
+		result.Progress = task.Progress
+		result.CompletedAt = task.FinishTime
+		task.Data, _ = json.Marshal(result)
+
+		err := task.Update()
+		if err != nil {
+			logger.SysError("UpdateTask task error: " + err.Error())
+			continue
+		}
+		webhook.NotifyTask(task, oldStatus)
+	}
+
+	return nil
+}
+
+func refundTask(ctx context.Context, task *model.Task) {
+	quota := task.Quota
+	if quota <= 0 {
+		return
+	}
+
+	err := model.IncreaseUserQuota(task.UserId, quota)
+	if err != nil {
+		logger.LogError(ctx, "fail to increase user quota: "+err.Error())
+	}
+	logContent := fmt.Sprintf("异步任务执行失败 %s，补偿 %s", task.TaskID, common.LogQuota(quota))
+	model.RecordLog(task.UserId, model.LogTypeSystem, logContent)
+}
+
+// mirrorVideo 将生成的视频转存到存储，未配置存储或转存失败时返回空
+func mirrorVideo(ctx context.Context, provider providersBase.VideoInterface, upstreamId string, video *types.VideoTask) string {
+	if !storage.Enabled() {
+		return ""
+	}
+
+	data := video.VideoData
+	if len(data) == 0 {
+		var resp *http.Response
+		if video.VideoURL != "" {
+			var err error
+			resp, err = requester.HTTPClient.Get(video.VideoURL)
+			if err != nil {
+				logger.LogError(ctx, fmt.Sprintf("download video %s failed: %s", upstreamId, err.Error()))
+				return ""
+			}
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				logger.LogError(ctx, fmt.Sprintf("download video %s failed: status code %d", upstreamId, resp.StatusCode))
+				return ""
+			}
+		} else {
+			var errWithCode *types.OpenAIErrorWithStatusCode
+			resp, errWithCode = provider.GetVideoContent(upstreamId)
+			if errWithCode != nil {
+				logger.LogError(ctx, fmt.Sprintf("get video %s content failed: %s", upstreamId, errWithCode.Message))
+				return ""
+			}
+		}
+		defer resp.Body.Close()
+
+		var err error
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("read video %s failed: %s", upstreamId, err.Error()))
+			return ""
+		}
+	}
+
+	return storage.Upload(data, utils.GetUUID()+".mp4")
+}
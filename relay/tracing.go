@@ -0,0 +1,63 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"one-api/common/tracing"
+	providersBase "one-api/providers/base"
+	"one-api/types"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attemptSpan 每次请求上游（含重试）对应一个 span，选渠道、上游请求和扣费都挂在其下
+type attemptSpan struct {
+	c      *gin.Context
+	parent context.Context
+	span   trace.Span
+}
+
+func startAttemptSpan(c *gin.Context, attempt int, retryErr *types.OpenAIErrorWithStatusCode) *attemptSpan {
+	parent := c.Request.Context()
+	ctx, span := tracing.Start(parent, "relay.attempt",
+		attribute.Int("attempt", attempt),
+		attribute.String("model", c.GetString("original_model")),
+	)
+	if retryErr != nil {
+		span.SetAttributes(attribute.String("retry.reason", fmt.Sprintf("status %d: %v", retryErr.StatusCode, retryErr.Code)))
+	}
+	c.Request = c.Request.WithContext(ctx)
+
+	return &attemptSpan{c: c, parent: parent, span: span}
+}
+
+// End 结束 span 并恢复请求的 context，provider 为空时说明未选到渠道
+func (a *attemptSpan) End(provider providersBase.ProviderInterface, apiErr *types.OpenAIErrorWithStatusCode) {
+	defer func() {
+		a.c.Request = a.c.Request.WithContext(a.parent)
+	}()
+	defer a.span.End()
+
+	if provider != nil {
+		channel := provider.GetChannel()
+		a.span.SetAttributes(
+			attribute.String("model.upstream", a.c.GetString("new_model")),
+			attribute.Int("channel.id", channel.Id),
+			attribute.Int("channel.type", channel.Type),
+		)
+		if usage := provider.GetUsage(); usage != nil {
+			a.span.SetAttributes(
+				attribute.Int("tokens.prompt", usage.PromptTokens),
+				attribute.Int("tokens.completion", usage.CompletionTokens),
+			)
+		}
+	}
+
+	if apiErr != nil {
+		a.span.SetAttributes(attribute.Int("error.status_code", apiErr.StatusCode))
+		a.span.SetStatus(codes.Error, apiErr.Message)
+	}
+}
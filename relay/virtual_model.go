@@ -0,0 +1,92 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"one-api/model"
+	"one-api/types"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	contextLengthCodes    = []string{"context_length_exceeded", "string_above_max_length"}
+	contextLengthKeywords = []string{
+		"context length",
+		"context_length",
+		"context window",
+		"maximum context",
+		"prompt is too long",
+		"input is too long",
+		"too many tokens",
+		"exceeds the maximum number of tokens",
+	}
+	contentFilterCodes    = []string{"content_filter", "content_policy_violation", "responsibleaipolicyviolation", "data_inspection_failed"}
+	contentFilterKeywords = []string{
+		"content management policy",
+		"content policy",
+		"content filter",
+		"safety system",
+		"prohibited content",
+	}
+)
+
+// resolveVirtualModel 解析虚拟模型，普通模型返回自身
+func resolveVirtualModel(c *gin.Context, modelName string) (*model.VirtualModel, []string) {
+	virtualModel := model.GlobalVirtualModels.Get(modelName)
+	if virtualModel == nil {
+		return nil, []string{modelName}
+	}
+
+	c.Set("virtual_model", virtualModel.Name)
+	return virtualModel, virtualModel.Models
+}
+
+// shouldFallbackModel 当前模型重试失败后，是否切换到虚拟模型的下一个模型
+func shouldFallbackModel(c *gin.Context, virtualModel *model.VirtualModel, apiErr *types.OpenAIErrorWithStatusCode) bool {
+	if virtualModel == nil || apiErr == nil || c.IsAborted() {
+		return false
+	}
+
+	condition := fallbackCondition(apiErr)
+	return condition != "" && virtualModel.ShouldFallback(condition)
+}
+
+// fallbackCondition 根据错误判断对应的切换条件，不满足任何条件时返回空
+func fallbackCondition(apiErr *types.OpenAIErrorWithStatusCode) string {
+	// 本地错误（如额度不足、参数错误）换模型也无法解决，只处理无可用渠道和重试超时
+	if apiErr.LocalError {
+		if apiErr.StatusCode == http.StatusServiceUnavailable || apiErr.StatusCode == http.StatusTooManyRequests {
+			return model.VirtualModelFallbackError
+		}
+		return ""
+	}
+
+	code := strings.ToLower(fmt.Sprint(apiErr.Code))
+	message := strings.ToLower(apiErr.Message)
+	if containsAny(code, contextLengthCodes) || containsAny(message, contextLengthKeywords) {
+		return model.VirtualModelFallbackContextLength
+	}
+	if containsAny(code, contentFilterCodes) || containsAny(message, contentFilterKeywords) {
+		return model.VirtualModelFallbackContentFilter
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusRequestTimeout, 524:
+		return model.VirtualModelFallbackError
+	}
+	if apiErr.StatusCode/100 == 5 {
+		return model.VirtualModelFallbackError
+	}
+	return ""
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
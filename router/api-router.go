@@ -1,6 +1,7 @@
 package router
 
 import (
+	"one-api/common/config"
 	"one-api/controller"
 	"one-api/middleware"
 	"one-api/relay"
@@ -67,6 +68,8 @@ func SetApiRouter(router *gin.Engine) {
 		{
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 			userRoute.POST("/login", middleware.CriticalRateLimit(), controller.Login)
+			userRoute.POST("/ldap/login", middleware.CriticalRateLimit(), controller.LDAPLogin)
+			userRoute.POST("/login/2fa", middleware.CriticalRateLimit(), controller.TwoFactorLogin)
 			userRoute.GET("/logout", controller.Logout)
 
 			selfRoute := userRoute.Group("/")
@@ -78,11 +81,24 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/dashboard/uptimekuma/status-page/heartbeat", controller.UptimeKumaStatusPageHeartbeat)
 				selfRoute.GET("/invoice", controller.GetUserInvoice)
 				selfRoute.GET("/invoice/detail", controller.GetUserInvoiceDetail)
+				selfRoute.GET("/invoice/export", controller.ExportUserInvoice)
+				selfRoute.GET("/invoice/profile", controller.GetInvoiceProfile)
+				selfRoute.PUT("/invoice/profile", controller.UpdateInvoiceProfile)
 				selfRoute.GET("/self", controller.GetSelf)
+				selfRoute.GET("/permissions", controller.GetSelfPermissions)
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.POST("/unbind", controller.Unbind)
 				// selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateAccessToken)
+				selfRoute.DELETE("/token", controller.RevokeSelfAccessToken)
+				selfRoute.GET("/2fa", controller.GetTwoFactorStatus)
+				selfRoute.POST("/2fa/setup", controller.SetupTwoFactor)
+				selfRoute.POST("/2fa/enable", middleware.CriticalRateLimit(), controller.EnableTwoFactor)
+				selfRoute.POST("/2fa/disable", middleware.CriticalRateLimit(), controller.DisableTwoFactor)
+				selfRoute.POST("/2fa/recovery_codes", middleware.CriticalRateLimit(), controller.RegenerateRecoveryCodes)
+				selfRoute.GET("/sessions", controller.GetSelfSessions)
+				selfRoute.DELETE("/sessions", controller.DeleteOtherSelfSessions)
+				selfRoute.DELETE("/sessions/:id", controller.DeleteSelfSession)
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.POST("/topup", controller.TopUp)
 				selfRoute.GET("/payment", controller.GetUserPaymentList)
@@ -91,15 +107,18 @@ func SetApiRouter(router *gin.Engine) {
 			}
 
 			adminRoute := userRoute.Group("/")
-			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetUsersList)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.POST("/quota/:id", controller.ChangeUserQuota)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
+				adminRoute.GET("/", middleware.PermissionAuth(config.PermissionUserRead), controller.GetUsersList)
+				adminRoute.GET("/:id", middleware.PermissionAuth(config.PermissionUserRead), controller.GetUser)
+				adminRoute.POST("/", middleware.PermissionAuth(config.PermissionUserWrite), controller.CreateUser)
+				adminRoute.POST("/manage", middleware.PermissionAuth(config.PermissionUserWrite), controller.ManageUser)
+				adminRoute.POST("/quota/:id", middleware.PermissionAuth(config.PermissionUserQuota), controller.ChangeUserQuota)
+				adminRoute.PUT("/", middleware.PermissionAuth(config.PermissionUserWrite), controller.UpdateUser)
+				adminRoute.DELETE("/:id", middleware.PermissionAuth(config.PermissionUserWrite), controller.DeleteUser)
+				adminRoute.GET("/:id/sessions", middleware.PermissionAuth(config.PermissionUserRead), controller.GetUserSessions)
+				adminRoute.DELETE("/:id/sessions", middleware.PermissionAuth(config.PermissionUserWrite), controller.DeleteUserSessions)
+				adminRoute.DELETE("/:id/token", middleware.PermissionAuth(config.PermissionUserWrite), controller.RevokeUserAccessToken)
+				adminRoute.DELETE("/:id/2fa", middleware.PermissionAuth(config.PermissionUserWrite), controller.ResetUserTwoFactor)
 			}
 		}
 		optionRoute := apiRouter.Group("/option")
@@ -114,14 +133,37 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/telegram/:id", controller.GetTelegramMenu)
 			optionRoute.DELETE("/telegram/:id", controller.DeleteTelegramMenu)
 			optionRoute.GET("/safe_tools", controller.GetSafeTools)
-			optionRoute.POST("/invoice/gen/:time", controller.GenInvoice)
-			optionRoute.POST("/invoice/update/:time", controller.UpdateInvoice)
 			optionRoute.POST("/system_info/log", controller.SystemLog)
 		}
+		invoiceRoute := apiRouter.Group("/option/invoice")
+		invoiceRoute.Use(middleware.RootPermissionAuth(config.PermissionInvoiceManage))
+		{
+			invoiceRoute.POST("/gen/:time", controller.GenInvoice)
+			invoiceRoute.POST("/update/:time", controller.UpdateInvoice)
+			invoiceRoute.GET("/export", controller.ExportInvoice)
+			invoiceRoute.GET("/export/:time", controller.BulkExportInvoice)
+			invoiceRoute.GET("/organization", controller.GetInvoiceOrganizations)
+			invoiceRoute.POST("/organization", controller.AddInvoiceOrganization)
+			invoiceRoute.PUT("/organization", controller.UpdateInvoiceOrganization)
+			invoiceRoute.DELETE("/organization/:id", controller.DeleteInvoiceOrganization)
+			invoiceRoute.PUT("/organization/member", controller.SetInvoiceOrganizationMember)
+		}
+
+		roleRoute := apiRouter.Group("/role")
+		roleRoute.Use(middleware.RootAuth())
+		{
+			roleRoute.GET("/permissions", controller.GetPermissions)
+			roleRoute.GET("/", controller.GetCustomRolesList)
+			roleRoute.GET("/:id", controller.GetCustomRole)
+			roleRoute.POST("/", controller.AddCustomRole)
+			roleRoute.PUT("/", controller.UpdateCustomRole)
+			roleRoute.DELETE("/:id", controller.DeleteCustomRole)
+			roleRoute.PUT("/user", controller.SetUserCustomRole)
+		}
 
 		modelOwnedByRoute := apiRouter.Group("/model_ownedby")
 		modelOwnedByRoute.GET("/", controller.GetAllModelOwnedBy)
-		modelOwnedByRoute.Use(middleware.AdminAuth())
+		modelOwnedByRoute.Use(middleware.PermissionAuth(config.PermissionModelWrite))
 		{
 			modelOwnedByRoute.GET("/:id", controller.GetModelOwnedBy)
 			modelOwnedByRoute.POST("/", controller.CreateModelOwnedBy)
@@ -131,7 +173,7 @@ func SetApiRouter(router *gin.Engine) {
 
 		modelInfoRoute := apiRouter.Group("/model_info")
 		modelInfoRoute.GET("/", controller.GetAllModelInfo)
-		modelInfoRoute.Use(middleware.AdminAuth())
+		modelInfoRoute.Use(middleware.PermissionAuth(config.PermissionModelWrite))
 		{
 			modelInfoRoute.GET("/:id", controller.GetModelInfo)
 			modelInfoRoute.POST("/", controller.CreateModelInfo)
@@ -139,8 +181,18 @@ func SetApiRouter(router *gin.Engine) {
 			modelInfoRoute.DELETE("/:id", controller.DeleteModelInfo)
 		}
 
+		virtualModelRoute := apiRouter.Group("/virtual_model")
+		virtualModelRoute.Use(middleware.PermissionAuth(config.PermissionModelWrite))
+		{
+			virtualModelRoute.GET("/", controller.GetVirtualModelsList)
+			virtualModelRoute.GET("/:id", controller.GetVirtualModel)
+			virtualModelRoute.POST("/", controller.AddVirtualModel)
+			virtualModelRoute.PUT("/", controller.UpdateVirtualModel)
+			virtualModelRoute.DELETE("/:id", controller.DeleteVirtualModel)
+		}
+
 		userGroup := apiRouter.Group("/user_group")
-		userGroup.Use(middleware.AdminAuth())
+		userGroup.Use(middleware.PermissionAuth(config.PermissionUserGroupWrite))
 		{
 			userGroup.GET("/", controller.GetUserGroups)
 			userGroup.GET("/:id", controller.GetUserGroupById)
@@ -150,37 +202,69 @@ func SetApiRouter(router *gin.Engine) {
 			userGroup.DELETE("/:id", controller.DeleteUserGroup)
 
 		}
+		channelRead := middleware.PermissionAuth(config.PermissionChannelRead, config.PermissionChannelWrite, config.PermissionChannelStatus)
+		channelWrite := middleware.PermissionAuth(config.PermissionChannelWrite)
+		channelStatus := middleware.PermissionAuth(config.PermissionChannelWrite, config.PermissionChannelStatus)
 		channelRoute := apiRouter.Group("/channel")
-		channelRoute.Use(middleware.AdminAuth())
 		{
-			channelRoute.GET("/", controller.GetChannelsList)
-			channelRoute.GET("/models", relay.ListModelsForAdmin)
-			channelRoute.POST("/provider_models_list", controller.GetModelList)
-			channelRoute.GET("/:id", controller.GetChannel)
-			channelRoute.GET("/test", controller.TestAllChannels)
-			channelRoute.GET("/test/:id", controller.TestChannel)
-			channelRoute.GET("/update_balance", controller.UpdateAllChannelsBalance)
-			channelRoute.GET("/update_balance/:id", controller.UpdateChannelBalance)
-			channelRoute.POST("/", controller.AddChannel)
-			channelRoute.PUT("/", controller.UpdateChannel)
-			channelRoute.PUT("/batch/azure_api", controller.BatchUpdateChannelsAzureApi)
-			channelRoute.PUT("/batch/del_model", controller.BatchDelModelChannels)
-			channelRoute.DELETE("/disabled", controller.DeleteDisabledChannel)
-			channelRoute.DELETE("/:id/tag", controller.DeleteChannelTag)
-			channelRoute.DELETE("/:id", controller.DeleteChannel)
-			channelRoute.DELETE("/batch", controller.BatchDeleteChannel)
+			channelRoute.GET("/", channelRead, controller.GetChannelsList)
+			channelRoute.GET("/models", channelRead, relay.ListModelsForAdmin)
+			channelRoute.POST("/provider_models_list", channelWrite, controller.GetModelList)
+			channelRoute.GET("/:id", channelRead, controller.GetChannel)
+			channelRoute.GET("/:id/keys", channelRead, controller.GetChannelKeys)
+			channelRoute.PUT("/:id/keys", channelStatus, controller.UpdateChannelKeyStatus)
+			channelRoute.PUT("/:id/status/:status", channelStatus, controller.UpdateChannelStatus)
+			channelRoute.GET("/:id/key", middleware.RootAuth(), controller.RevealChannelKey)
+			channelRoute.POST("/:id/copy", channelWrite, controller.CopyChannel)
+			channelRoute.GET("/test", channelStatus, controller.TestAllChannels)
+			channelRoute.GET("/test/:id", channelStatus, controller.TestChannel)
+			channelRoute.GET("/probe", channelRead, controller.GetChannelProbesList)
+			channelRoute.GET("/probe/uptime", channelRead, controller.GetChannelProbeUptime)
+			channelRoute.GET("/probe/disabled", channelRead, controller.GetChannelModelDisables)
+			channelRoute.DELETE("/probe/disabled", channelStatus, controller.EnableChannelModel)
+			channelRoute.GET("/update_balance", channelStatus, controller.UpdateAllChannelsBalance)
+			channelRoute.GET("/update_balance/:id", channelStatus, controller.UpdateChannelBalance)
+			channelRoute.POST("/", channelWrite, controller.AddChannel)
+			channelRoute.PUT("/", channelWrite, controller.UpdateChannel)
+			channelRoute.PUT("/batch/azure_api", channelWrite, controller.BatchUpdateChannelsAzureApi)
+			channelRoute.PUT("/batch/del_model", channelWrite, controller.BatchDelModelChannels)
+			channelRoute.DELETE("/disabled", channelWrite, controller.DeleteDisabledChannel)
+			channelRoute.DELETE("/:id/tag", channelWrite, controller.DeleteChannelTag)
+			channelRoute.DELETE("/:id", channelWrite, controller.DeleteChannel)
+			channelRoute.DELETE("/batch", channelWrite, controller.BatchDeleteChannel)
+		}
+		schedulerRoute := apiRouter.Group("/scheduler")
+		schedulerRoute.Use(middleware.PermissionAuth(config.PermissionSchedulerManage))
+		{
+			schedulerRoute.GET("/jobs", controller.GetSchedulerJobs)
+			schedulerRoute.POST("/jobs/:name/run", controller.RunSchedulerJob)
+			schedulerRoute.POST("/jobs/:name/pause", controller.PauseSchedulerJob)
+			schedulerRoute.POST("/jobs/:name/resume", controller.ResumeSchedulerJob)
+			schedulerRoute.GET("/runs", controller.GetSchedulerRunsList)
+		}
+		auditRoute := apiRouter.Group("/audit")
+		auditRoute.Use(middleware.PermissionAuth(config.PermissionAuditRead))
+		{
+			auditRoute.GET("/", controller.GetAuditLogsList)
+			auditRoute.GET("/export", controller.ExportAuditLogs)
+			auditRoute.DELETE("/", middleware.RootAuth(), controller.DeleteAuditLogs)
+		}
+		configRoute := apiRouter.Group("/config")
+		configRoute.Use(middleware.RootAuth())
+		{
+			configRoute.GET("/export", controller.ExportConfig)
+			configRoute.POST("/apply", controller.ApplyConfig)
 		}
 		channelTagRoute := apiRouter.Group("/channel_tag")
-		channelTagRoute.Use(middleware.AdminAuth())
 		{
-			channelTagRoute.GET("/_all", controller.GetChannelsTagAllList)
-			channelTagRoute.GET("/:tag/list", controller.GetChannelsTagList)
-			channelTagRoute.GET("/:tag", controller.GetChannelsTag)
-			channelTagRoute.PUT("/:tag", controller.UpdateChannelsTag)
-			channelTagRoute.DELETE("/:tag", controller.DeleteChannelsTag)
-			channelTagRoute.DELETE("/:tag/disabled", controller.DeleteDisabledChannelsTag)
-			channelTagRoute.PUT("/:tag/priority", controller.UpdateChannelsTagPriority)
-			channelTagRoute.PUT("/:tag/status/:status", controller.ChangeChannelsTagStatus)
+			channelTagRoute.GET("/_all", channelRead, controller.GetChannelsTagAllList)
+			channelTagRoute.GET("/:tag/list", channelRead, controller.GetChannelsTagList)
+			channelTagRoute.GET("/:tag", channelRead, controller.GetChannelsTag)
+			channelTagRoute.PUT("/:tag", channelWrite, controller.UpdateChannelsTag)
+			channelTagRoute.DELETE("/:tag", channelWrite, controller.DeleteChannelsTag)
+			channelTagRoute.DELETE("/:tag/disabled", channelWrite, controller.DeleteDisabledChannelsTag)
+			channelTagRoute.PUT("/:tag/priority", channelWrite, controller.UpdateChannelsTagPriority)
+			channelTagRoute.PUT("/:tag/status/:status", channelStatus, controller.ChangeChannelsTagStatus)
 
 		}
 
@@ -195,44 +279,53 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.DELETE("/:id", controller.DeleteToken)
 		}
 		tokenAdminRoute := apiRouter.Group("/token")
-		tokenAdminRoute.Use(middleware.AdminAuth())
+		tokenAdminRoute.Use(middleware.PermissionAuth(config.PermissionTokenManage))
 		{
 			tokenAdminRoute.GET("/admin/search", controller.GetTokensListByAdmin)
 			tokenAdminRoute.PUT("/admin", controller.UpdateTokenByAdmin)
 		}
 		redemptionRoute := apiRouter.Group("/redemption")
-		redemptionRoute.Use(middleware.AdminAuth())
+		redemptionRoute.Use(middleware.PermissionAuth(config.PermissionRedemption))
 		{
 			redemptionRoute.GET("/", controller.GetRedemptionsList)
+			redemptionRoute.GET("/campaign", controller.GetRedemptionCampaignStatistics)
 			redemptionRoute.GET("/:id", controller.GetRedemption)
 			redemptionRoute.POST("/", controller.AddRedemption)
 			redemptionRoute.PUT("/", controller.UpdateRedemption)
 			redemptionRoute.DELETE("/:id", controller.DeleteRedemption)
 		}
 		logRoute := apiRouter.Group("/log")
-		logRoute.GET("/", middleware.AdminAuth(), controller.GetLogsList)
-		logRoute.DELETE("/", middleware.AdminAuth(), controller.DeleteHistoryLogs)
-		logRoute.GET("/stat", middleware.AdminAuth(), controller.GetLogsStat)
+		logRead := middleware.PermissionAuth(config.PermissionLogRead)
+		logManage := middleware.PermissionAuth(config.PermissionLogManage)
+		logRoute.GET("/", logRead, controller.GetLogsList)
+		logRoute.DELETE("/", logManage, controller.DeleteHistoryLogs)
+		logRoute.GET("/stat", logRead, controller.GetLogsStat)
+		logRoute.GET("/payload/:id", logRead, controller.GetLogPayload)
+		logRoute.GET("/archive", logRead, controller.GetLogArchivesList)
+		logRoute.POST("/archive/run", logManage, controller.RunLogArchive)
+		logRoute.GET("/archive/:id", logRead, controller.QueryLogArchive)
+		logRoute.POST("/archive/:id/restore", logManage, controller.RestoreLogArchive)
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		// logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogsList)
 		// logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
 		groupRoute := apiRouter.Group("/group")
-		groupRoute.Use(middleware.AdminAuth())
+		groupRoute.Use(middleware.PermissionAuth(config.PermissionChannelRead, config.PermissionChannelWrite, config.PermissionUserRead, config.PermissionTokenManage))
 		{
 			groupRoute.GET("/", controller.GetGroups)
 		}
 
 		analyticsRoute := apiRouter.Group("/analytics")
-		analyticsRoute.Use(middleware.AdminAuth())
+		analyticsRoute.Use(middleware.PermissionAuth(config.PermissionAnalyticsRead))
 		{
 			analyticsRoute.GET("/statistics", controller.GetStatisticsDetail)
 			analyticsRoute.GET("/period", controller.GetStatisticsByPeriod)
+			analyticsRoute.GET("/margin", controller.GetMarginStatistics)
 			analyticsRoute.GET("/multi_user_stats", controller.GetMultiUserStatistics)
 			analyticsRoute.GET("/multi_user_stats/export", controller.ExportMultiUserStatisticsCSV)
 		}
 		pricesRoute := apiRouter.Group("/prices")
-		pricesRoute.Use(middleware.AdminAuth())
+		pricesRoute.Use(middleware.PermissionAuth(config.PermissionPriceWrite))
 		{
 			pricesRoute.GET("/model_list", controller.GetAllModelList)
 			pricesRoute.POST("/single", controller.AddPrice)
@@ -246,11 +339,12 @@ func SetApiRouter(router *gin.Engine) {
 		}
 
 		paymentRoute := apiRouter.Group("/payment")
-		paymentRoute.Use(middleware.AdminAuth())
+		paymentRoute.Use(middleware.PermissionAuth(config.PermissionPaymentManage))
 		{
 			paymentRoute.GET("/order", controller.GetOrderList)
 			paymentRoute.GET("/", controller.GetPaymentList)
 			paymentRoute.GET("/:id", controller.GetPayment)
+			paymentRoute.GET("/:id/config", middleware.RootAuth(), controller.RevealPaymentConfig)
 			paymentRoute.POST("/", controller.AddPayment)
 			paymentRoute.PUT("/", controller.UpdatePayment)
 			paymentRoute.DELETE("/:id", controller.DeletePayment)
@@ -258,17 +352,26 @@ func SetApiRouter(router *gin.Engine) {
 
 		mjRoute := apiRouter.Group("/mj")
 		mjRoute.GET("/self", middleware.UserAuth(), controller.GetUserMidjourney)
-		mjRoute.GET("/", middleware.AdminAuth(), controller.GetAllMidjourney)
+		mjRoute.GET("/", logRead, controller.GetAllMidjourney)
 
 		taskRoute := apiRouter.Group("/task")
 		taskRoute.GET("/self", middleware.UserAuth(), controller.GetUserAllTask)
-		taskRoute.GET("/", middleware.AdminAuth(), controller.GetAllTask)
+		taskRoute.GET("/", logRead, controller.GetAllTask)
+
+		webhookRoute := apiRouter.Group("/webhook")
+		webhookRoute.Use(middleware.UserAuth())
+		{
+			webhookRoute.GET("/secret", controller.GetWebhookSecret)
+			webhookRoute.GET("/delivery", controller.GetUserWebhookDeliveries)
+			webhookRoute.GET("/delivery/:id", controller.GetUserWebhookDelivery)
+			webhookRoute.POST("/delivery/:id/redeliver", controller.RedeliverWebhook)
+		}
 	}
 
 	sseRouter := router.Group("/api/sse")
 	sseRouter.Use(middleware.GlobalAPIRateLimit())
 	{
-		sseRouter.POST("/channel/check", middleware.AdminAuth(), controller.CheckChannel)
+		sseRouter.POST("/channel/check", middleware.PermissionAuth(config.PermissionChannelStatus, config.PermissionChannelWrite), controller.CheckChannel)
 	}
 
 }
@@ -1,12 +1,14 @@
 package router
 
 import (
+	"one-api/controller"
 	"one-api/middleware"
 	"one-api/relay"
 	"one-api/relay/midjourney"
 	"one-api/relay/task"
 	"one-api/relay/task/kling"
 	"one-api/relay/task/suno"
+	"one-api/relay/task/video"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,8 +32,11 @@ func setOpenAIRouter(router *gin.Engine) {
 		modelsRouter.GET("", relay.ListModelsByToken)
 		modelsRouter.GET("/:model", relay.RetrieveModel)
 	}
+	// 临时密钥只能由普通令牌派生
+	router.POST("/v1/ephemeral_keys", middleware.OpenaiAuth(), controller.CreateEphemeralKey)
+
 	relayV1Router := router.Group("/v1")
-	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
+	relayV1Router.Use(middleware.RelayPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.RequestPolicy(), middleware.DynamicRedisRateLimiter())
 	{
 		relayV1Router.POST("/completions", relay.Relay)
 		relayV1Router.POST("/chat/completions", relay.Relay)
@@ -48,6 +53,9 @@ func setOpenAIRouter(router *gin.Engine) {
 		relayV1Router.POST("/moderations", relay.Relay)
 		relayV1Router.POST("/rerank", relay.RelayRerank)
 		relayV1Router.GET("/realtime", relay.ChatRealtime)
+		relayV1Router.POST("/videos", task.RelayTaskSubmit)
+		relayV1Router.GET("/videos/:id", video.GetFetchByID)
+		relayV1Router.GET("/videos/:id/content", video.GetContent)
 
 		relayV1Router.Use(middleware.SpecifiedChannel())
 		{
@@ -78,7 +86,7 @@ func setMJRouter(router *gin.Engine) {
 // Path: router/relay-router.go
 func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
 	relayMjRouter.GET("/image/:id", midjourney.RelayMidjourneyImage)
-	relayMjRouter.Use(middleware.RelayMJPanicRecover(), middleware.MjAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
+	relayMjRouter.Use(middleware.RelayMJPanicRecover(), middleware.MjAuth(), middleware.Distribute(), middleware.RequestPolicy(), middleware.DynamicRedisRateLimiter())
 	{
 		relayMjRouter.POST("/submit/action", midjourney.RelayMidjourney)
 		relayMjRouter.POST("/submit/shorten", midjourney.RelayMidjourney)
@@ -99,7 +107,7 @@ func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
 
 func setSunoRouter(router *gin.Engine) {
 	relaySunoRouter := router.Group("/suno")
-	relaySunoRouter.Use(middleware.RelaySunoPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
+	relaySunoRouter.Use(middleware.RelaySunoPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.RequestPolicy(), middleware.DynamicRedisRateLimiter())
 	{
 		relaySunoRouter.POST("/submit/:action", task.RelayTaskSubmit)
 		relaySunoRouter.POST("/fetch", suno.GetFetch)
@@ -110,7 +118,7 @@ func setSunoRouter(router *gin.Engine) {
 func setClaudeRouter(router *gin.Engine) {
 	relayClaudeRouter := router.Group("/claude")
 	relayV1Router := relayClaudeRouter.Group("/v1")
-	relayV1Router.Use(middleware.APIEnabled("claude"), middleware.RelayCluadePanicRecover(), middleware.ClaudeAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
+	relayV1Router.Use(middleware.APIEnabled("claude"), middleware.RelayCluadePanicRecover(), middleware.ClaudeAuth(), middleware.Distribute(), middleware.RequestPolicy(), middleware.DynamicRedisRateLimiter())
 	{
 		relayV1Router.POST("/messages", relay.Relay)
 		relayV1Router.GET("/models", relay.ListClaudeModelsByToken)
@@ -119,7 +127,7 @@ func setClaudeRouter(router *gin.Engine) {
 
 func setGeminiRouter(router *gin.Engine) {
 	relayGeminiRouter := router.Group("/gemini")
-	relayGeminiRouter.Use(middleware.APIEnabled("gemini"), middleware.RelayGeminiPanicRecover(), middleware.GeminiAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
+	relayGeminiRouter.Use(middleware.APIEnabled("gemini"), middleware.RelayGeminiPanicRecover(), middleware.GeminiAuth(), middleware.Distribute(), middleware.RequestPolicy(), middleware.DynamicRedisRateLimiter())
 	{
 		relayGeminiRouter.POST("/:version/models/:model", relay.Relay)
 		relayGeminiRouter.GET("/:version/models", relay.ListGeminiModelsByToken)
@@ -128,7 +136,7 @@ func setGeminiRouter(router *gin.Engine) {
 
 func setRecraftRouter(router *gin.Engine) {
 	relayRecraftRouter := router.Group("/recraftAI/v1")
-	relayRecraftRouter.Use(middleware.RelayPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.DynamicRedisRateLimiter())
+	relayRecraftRouter.Use(middleware.RelayPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.RequestPolicy(), middleware.DynamicRedisRateLimiter())
 	{
 		relayRecraftRouter.POST("/images/generations", relay.Relay)
 		relayRecraftRouter.POST("/images/vectorize", relay.RelayRecraftAI)
@@ -141,7 +149,7 @@ func setRecraftRouter(router *gin.Engine) {
 
 func setKlingRouter(router *gin.Engine) {
 	relayKlingRouter := router.Group("/kling")
-	relayKlingRouter.Use(middleware.RelayKlingPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute())
+	relayKlingRouter.Use(middleware.RelayKlingPanicRecover(), middleware.OpenaiAuth(), middleware.Distribute(), middleware.RequestPolicy())
 	relayKlingRouter.GET("/v1/videos/text2video/:id", kling.GetFetchByID)
 	relayKlingRouter.GET("/v1/videos/image2video/:id", kling.GetFetchByID)
 
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"one-api/controller"
+	"one-api/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetScimRouter(router *gin.Engine) {
+	scimRouter := router.Group("/scim/v2")
+	scimRouter.Use(middleware.GlobalAPIRateLimit(), middleware.SCIMAuth())
+	{
+		scimRouter.GET("/ServiceProviderConfig", controller.GetSCIMServiceProviderConfig)
+
+		scimRouter.GET("/Users", controller.SCIMListUsers)
+		scimRouter.POST("/Users", controller.SCIMCreateUser)
+		scimRouter.GET("/Users/:id", controller.SCIMGetUser)
+		scimRouter.PUT("/Users/:id", controller.SCIMReplaceUser)
+		scimRouter.PATCH("/Users/:id", controller.SCIMPatchUser)
+		scimRouter.DELETE("/Users/:id", controller.SCIMDeleteUser)
+
+		scimRouter.GET("/Groups", controller.SCIMListGroups)
+		scimRouter.POST("/Groups", controller.SCIMCreateGroup)
+		scimRouter.GET("/Groups/:id", controller.SCIMGetGroup)
+		scimRouter.PUT("/Groups/:id", controller.SCIMReplaceGroup)
+		scimRouter.PATCH("/Groups/:id", controller.SCIMPatchGroup)
+		scimRouter.DELETE("/Groups/:id", controller.SCIMDeleteGroup)
+	}
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+const (
+	VideoStatusQueued     = "queued"
+	VideoStatusInProgress = "in_progress"
+	VideoStatusCompleted  = "completed"
+	VideoStatusFailed     = "failed"
+)
+
+// VideoSeconds 兼容字符串和数字两种写法，如 "8" 和 8
+type VideoSeconds string
+
+func (s *VideoSeconds) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*s = ""
+		return nil
+	}
+	*s = VideoSeconds(strings.Trim(string(data), `"`))
+	return nil
+}
+
+func (s VideoSeconds) Int() int {
+	seconds, _ := strconv.Atoi(string(s))
+	return seconds
+}
+
+// VideoRequest OpenAI 视频生成请求 /v1/videos
+type VideoRequest struct {
+	Model          string                `json:"model" form:"model"`
+	Prompt         string                `json:"prompt" form:"prompt"`
+	Seconds        VideoSeconds          `json:"seconds,omitempty" form:"seconds"`
+	Size           string                `json:"size,omitempty" form:"size"`
+	InputReference *multipart.FileHeader `json:"-" form:"input_reference"`
+	NotifyHook     string                `json:"notify_hook,omitempty" form:"notify_hook"`
+}
+
+type VideoTaskError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// VideoTask OpenAI 视频对象，各平台的任务状态都转换为该格式
+type VideoTask struct {
+	Id          string          `json:"id"`
+	Object      string          `json:"object"`
+	Model       string          `json:"model"`
+	Status      string          `json:"status"`
+	Progress    int             `json:"progress"`
+	CreatedAt   int64           `json:"created_at"`
+	CompletedAt int64           `json:"completed_at,omitempty"`
+	Seconds     string          `json:"seconds,omitempty"`
+	Size        string          `json:"size,omitempty"`
+	VideoURL    string          `json:"video_url,omitempty"`
+	Error       *VideoTaskError `json:"error,omitempty"`
+
+	// 上游直接返回的视频内容，如 Veo 的 base64 结果，不输出给用户
+	VideoData     []byte `json:"-"`
+	VideoMimeType string `json:"-"`
+}
+
+func (v *VideoTask) IsFinished() bool {
+	return v.Status == VideoStatusCompleted || v.Status == VideoStatusFailed
+}
@@ -58,19 +58,10 @@ export default function ChannelTable({ tag }) {
     let res;
     try {
       switch (action) {
-        case 'copy': {
-          let oldRes = await API.get(`/api/channel/${id}`);
-          const { success, message, data } = oldRes.data;
-          if (!success) {
-            showError(message);
-            return;
-          }
-          // 删除 data.id
-          delete data.id;
-          data.name = data.name + '_copy';
-          res = await API.post(`/api/channel/`, { ...data });
+        case 'copy':
+          // 接口返回的密钥已隐藏，由后端复制渠道
+          res = await API.post(url + `${id}/copy`);
           break;
-        }
         case 'delete':
           res = await API.delete(url + id);
           break;
@@ -39,7 +39,7 @@ import CheckBoxOutlineBlankIcon from '@mui/icons-material/CheckBoxOutlineBlank';
 import CheckBoxIcon from '@mui/icons-material/CheckBox';
 import { useTranslation } from 'react-i18next';
 import useCustomizeT from 'hooks/useCustomizeT';
-import { PreCostType } from '../type/other';
+import { PreCostType, KeyRotationType } from '../type/other';
 import MapInput from './MapInput';
 import ListInput from './ListInput';
 import ModelSelectorModal from './ModelSelectorModal';
@@ -772,7 +772,7 @@ const EditModal = ({ open, channelId, onCancel, onOk, groupOptions, isTag, model
                   </ButtonGroup>
                 </Container>
                 <FormControl fullWidth error={Boolean(touched.key && errors.key)} sx={{ ...theme.typography.otherInput }}>
-                  {!batchAdd ? (
+                  {!batchAdd && !values.key_rotation ? (
                     <>
                       <InputLabel htmlFor="channel-key-label">{customizeT(inputLabel.key)}</InputLabel>
                       <OutlinedInput
@@ -826,6 +826,28 @@ const EditModal = ({ open, channelId, onCancel, onOk, groupOptions, isTag, model
                   )}
                 </FormControl>
 
+                <FormControl fullWidth sx={{ ...theme.typography.otherInput }}>
+                  <InputLabel htmlFor="channel-key_rotation-label">{customizeT(inputLabel.key_rotation)}</InputLabel>
+                  <Select
+                    id="channel-key_rotation-label"
+                    label={customizeT(inputLabel.key_rotation)}
+                    value={values.key_rotation || ''}
+                    name="key_rotation"
+                    onBlur={handleBlur}
+                    onChange={handleChange}
+                    displayEmpty
+                  >
+                    {KeyRotationType.map((option) => {
+                      return (
+                        <MenuItem key={option.value} value={option.value}>
+                          {option.label}
+                        </MenuItem>
+                      );
+                    })}
+                  </Select>
+                  <FormHelperText id="helper-tex-channel-key_rotation-label"> {customizeT(inputPrompt.key_rotation)} </FormHelperText>
+                </FormControl>
+
                 {inputPrompt.model_mapping && (
                   <FormControl
                     fullWidth
@@ -18,7 +18,8 @@ const defaultConfig = {
     pre_cost: 1,
     disabled_stream: [],
     compatible_response: false,
-    allow_extra_body: false
+    allow_extra_body: false,
+    key_rotation: ''
   },
   inputLabel: {
     name: '渠道名称',
@@ -39,7 +40,8 @@ const defaultConfig = {
     pre_cost: '预计费选项',
     disabled_stream: '禁用流式的模型',
     compatible_response: '兼容Response API',
-    allow_extra_body: '允许额外字段透传'
+    allow_extra_body: '允许额外字段透传',
+    key_rotation: '密钥模式'
   },
   prompt: {
     type: '请选择渠道类型',
@@ -64,7 +66,9 @@ const defaultConfig = {
       '这里选择预计费选项，用于预估费用，如果你觉得计算图片占用太多资源，可以选择关闭图片计费。但是请注意：有些渠道在stream下是不会返回tokens的，这会导致输入tokens计算错误。',
     disabled_stream: '这里填写禁用流式的模型，注意：如果填写了禁用流式的模型，那么这些模型在流式请求时会跳过该渠道',
     compatible_response: '兼容Response API',
-    allow_extra_body: '开启后，将会透传用户请求中的额外字段（如OpenAI SDK的extra_body参数），适用于需要传递自定义参数到上游API的场景'
+    allow_extra_body: '开启后，将会透传用户请求中的额外字段（如OpenAI SDK的extra_body参数），适用于需要传递自定义参数到上游API的场景',
+    key_rotation:
+      '多密钥模式下密钥一行一个，保存在同一个渠道中按轮询或最少使用轮换，单个密钥出现鉴权或额度错误时只禁用该密钥，频率限制时只冻结该密钥。不适用于密钥本身包含换行的渠道（如 Vertex AI 的 JSON 凭证）'
   },
   modelGroup: 'OpenAI'
 };
@@ -564,6 +568,15 @@ const typeConfig = {
     inputLabel: {
       provider_models_list: '从OR获取模型列表'
     }
+  },
+  57: {
+    inputLabel: {
+      base_url: '接口地址'
+    },
+    prompt: {
+      base_url: '请填写上游异步视频接口地址，例如：https://api.dev.runwayml.com',
+      key: '请输入上游密钥，将以 Bearer 方式传递，其他请求头请在模型请求头中配置'
+    }
   }
 };
 
@@ -3,3 +3,9 @@ export const PreCostType = [
   { value: 2, label: '不计算图片' },
   { value: 3, label: '全部不计算' }
 ];
+
+export const KeyRotationType = [
+  { value: '', label: '单密钥' },
+  { value: 'round_robin', label: '多密钥轮询' },
+  { value: 'least_used', label: '多密钥最少使用' }
+];
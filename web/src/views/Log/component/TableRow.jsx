@@ -1,10 +1,10 @@
 import PropTypes from 'prop-types';
 import { useMemo, useState } from 'react';
-import { ArrowForward } from '@mui/icons-material';
+import { ArrowForward, DataObject } from '@mui/icons-material';
 
 import Badge from '@mui/material/Badge';
 
-import { TableRow, TableCell, Stack, Collapse, Tooltip, Typography } from '@mui/material';
+import { TableRow, TableCell, Stack, Collapse, Tooltip, Typography, IconButton } from '@mui/material';
 
 import { timestamp2string, renderQuota } from 'utils/common';
 import Label from 'ui-component/Label';
@@ -145,7 +145,20 @@ export default function LogTableRow({ item, userIsAdmin, userGroup, columnVisibi
           </TableCell>
         )}
         {columnVisibility.type && <TableCell sx={{ p: '10px 8px' }}>{renderType(item.type, LogType, t)}</TableCell>}
-        {columnVisibility.model_name && <TableCell sx={{ p: '10px 8px' }}>{viewModelName(item.model_name, item.is_stream)}</TableCell>}
+        {columnVisibility.model_name && (
+          <TableCell sx={{ p: '10px 8px' }}>
+            <Stack direction="row" spacing={0.5} alignItems="center">
+              {viewModelName(item.model_name, item.is_stream)}
+              {userIsAdmin && item.metadata?.payload_id && (
+                <Tooltip title="Payload">
+                  <IconButton size="small" href={`/api/log/payload/${item.metadata.payload_id}`} target="_blank" rel="noopener noreferrer">
+                    <DataObject fontSize="inherit" />
+                  </IconButton>
+                </Tooltip>
+              )}
+            </Stack>
+          </TableCell>
+        )}
 
         {columnVisibility.duration && (
           <TableCell sx={{ p: '10px 8px' }}>